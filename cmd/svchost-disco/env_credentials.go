@@ -0,0 +1,99 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package main
+
+import (
+	"context"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	svchost "github.com/opentofu/svchost"
+	"github.com/opentofu/svchost/svcauth"
+)
+
+// envCredentialsPrefix is the prefix of the names of the environment
+// variables that envCredentialsSource consults.
+const envCredentialsPrefix = "TF_TOKEN_"
+
+// envPortSuffix matches the port number at the end of an environment
+// variable name produced by [envCredentialsVariable]. The separator must
+// follow something other than an underscore so that a hyphen at the end of
+// a label isn't mistaken for part of it.
+var envPortSuffix = regexp.MustCompile(`^(.*[^_])___([0-9]+)$`)
+
+// envCredentialsSource is a read-only [svcauth.CredentialsSource] that finds
+// bearer tokens in environment variables named in the same way as for
+// OpenTofu CLI, as described for [envCredentialsVariable].
+//
+// Variable names that aren't in the normalized form, such as ones using
+// uppercase letters, are also accepted. Variables with an empty value are
+// ignored.
+type envCredentialsSource struct{}
+
+// ForHost implements [svcauth.CredentialsSource].
+func (envCredentialsSource) ForHost(_ context.Context, host svchost.Hostname) (svcauth.HostCredentials, error) {
+	if name := envCredentialsVariable(host); name != "" {
+		if token := os.Getenv(name); token != "" {
+			return svcauth.HostCredentialsToken(token), nil
+		}
+	}
+	for _, env := range os.Environ() {
+		name, token, ok := strings.Cut(env, "=")
+		if !ok || token == "" {
+			continue
+		}
+		if got, ok := envCredentialsHost(name); ok && got == host {
+			return svcauth.HostCredentialsToken(token), nil
+		}
+	}
+	return nil, nil
+}
+
+// envCredentialsVariable returns the normalized name of the environment
+// variable that [envCredentialsSource] consults for the given hostname, or
+// the empty string if the hostname can't be represented in a variable name,
+// as for an IPv6 address.
+//
+// The name is "TF_TOKEN_" followed by the hostname with each period replaced
+// by an underscore and each hyphen by two underscores, as for OpenTofu CLI,
+// such as "TF_TOKEN_registry_example__corp_com" for
+// "registry.example-corp.com". Non-ASCII characters are given as Unicode
+// rather than Punycode, as OpenTofu CLI expects. Because a colon isn't valid
+// in a variable name either, a port number follows three underscores
+// instead, such as "TF_TOKEN_example_com___8443" for "example.com:8443".
+func envCredentialsVariable(host svchost.Hostname) string {
+	if addr, ok := host.IPAddress(); ok && addr.Is6() {
+		return ""
+	}
+	r := strings.NewReplacer(".", "_", "-", "__")
+	ret := envCredentialsPrefix + r.Replace(host.WithoutPort().ForDisplay())
+	if host.WithoutPort() != host {
+		ret += "___" + strconv.Itoa(host.Port())
+	}
+	return ret
+}
+
+// envCredentialsHost returns the hostname that the environment variable
+// with the given name provides credentials for, if any.
+func envCredentialsHost(name string) (svchost.Hostname, bool) {
+	rest, ok := strings.CutPrefix(name, envCredentialsPrefix)
+	if !ok {
+		return "", false
+	}
+	var portPortion string
+	if m := envPortSuffix.FindStringSubmatch(rest); m != nil {
+		rest, portPortion = m[1], ":"+m[2]
+	}
+	// Hyphens can't appear at the start or end of a label, so a pair of
+	// underscores can't be the separator between two labels.
+	rest = strings.ReplaceAll(rest, "__", "-")
+	rest = strings.ReplaceAll(rest, "_", ".")
+	host, err := svchost.ForComparison(rest + portPortion)
+	if err != nil {
+		return "", false
+	}
+	return host, true
+}
@@ -0,0 +1,96 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package main
+
+import (
+	"testing"
+
+	svchost "github.com/opentofu/svchost"
+	"github.com/opentofu/svchost/svcauth"
+)
+
+func TestEnvCredentialsVariable(t *testing.T) {
+	tests := map[svchost.Hostname]string{
+		"example.com":               "TF_TOKEN_example_com",
+		"registry.example-corp.com": "TF_TOKEN_registry_example__corp_com",
+		"example.com:8443":          "TF_TOKEN_example_com___8443",
+		"a--b.example.com:8443":     "TF_TOKEN_a____b_example_com___8443",
+		"xn--xample-9ua.com":        "TF_TOKEN_éxample_com",
+		"192.0.2.1:8080":            "TF_TOKEN_192_0_2_1___8080",
+		"[2001:db8::1]:8443":        "",
+	}
+	for host, want := range tests {
+		t.Run(host.String(), func(t *testing.T) {
+			got := envCredentialsVariable(host)
+			if got != want {
+				t.Fatalf("wrong name %q; want %q", got, want)
+			}
+			if got == "" {
+				return
+			}
+			// The name must decode back to the same hostname.
+			if again, ok := envCredentialsHost(got); !ok || again != host {
+				t.Errorf("name %q decodes to %q, %t; want %q", got, again, ok, host)
+			}
+		})
+	}
+}
+
+func TestEnvCredentialsHost(t *testing.T) {
+	tests := map[string]struct {
+		want svchost.Hostname
+		ok   bool
+	}{
+		"TF_TOKEN_example_com":              {"example.com", true},
+		"TF_TOKEN_EXAMPLE_COM":              {"example.com", true},
+		"TF_TOKEN_example__corp_com":        {"example-corp.com", true},
+		"TF_TOKEN_a____b_example_com":       {"a--b.example.com", true},
+		"TF_TOKEN_example_com___8443":       {"example.com:8443", true},
+		"TF_TOKEN_example__corp_com___8443": {"example-corp.com:8443", true},
+		"TF_TOKEN_192_0_2_1___8080":         {"192.0.2.1:8080", true},
+		"TF_TOKEN_example_com___443":        {"example.com", true},
+		"TF_TOKEN_example_com___":           {"", false},
+		"TF_TOKEN_example_com____8443":      {"example.com--8443", true},
+		"TF_TOKEN_example_com___99999":      {"", false},
+		"TF_TOKEN_example___com":            {"", false},
+		"TF_TOKEN_":                         {"", false},
+		"TF_TOKENS_example_com":             {"", false},
+		"OTHER_example_com":                 {"", false},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, ok := envCredentialsHost(name)
+			if got != test.want || ok != test.ok {
+				t.Errorf("wrong result %q, %t; want %q, %t", got, ok, test.want, test.ok)
+			}
+		})
+	}
+}
+
+func TestEnvCredentialsSource(t *testing.T) {
+	t.Setenv("TF_TOKEN_example_com", "abc123")
+	t.Setenv("TF_TOKEN_example_com___8443", "def456")
+	t.Setenv("TF_TOKEN_REGISTRY_EXAMPLE__CORP_COM", "ghi789")
+	t.Setenv("TF_TOKEN_empty_example_com", "")
+	src := envCredentialsSource{}
+
+	tests := map[svchost.Hostname]svcauth.HostCredentials{
+		"example.com":               svcauth.HostCredentialsToken("abc123"),
+		"example.com:8443":          svcauth.HostCredentialsToken("def456"),
+		"registry.example-corp.com": svcauth.HostCredentialsToken("ghi789"),
+		"empty.example.com":         nil,
+		"other.example.com":         nil,
+	}
+	for host, want := range tests {
+		t.Run(host.String(), func(t *testing.T) {
+			got, err := src.ForHost(t.Context(), host)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got != want {
+				t.Errorf("wrong credentials %#v; want %#v", got, want)
+			}
+		})
+	}
+}
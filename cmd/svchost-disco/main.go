@@ -0,0 +1,178 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+// Command svchost-disco performs remote service discovery for a hostname
+// and prints what it finds, for troubleshooting discovery problems.
+//
+// Usage:
+//
+//	svchost-disco [-timeout duration] [-credentials-file path] hostname
+//
+// The output includes each service declared by the host, with its URL or
+// OAuth client configuration as this module would interpret it, and the
+// credentials source that would be used for requests to the host.
+//
+// Credentials are read from a TF_TOKEN_ environment variable named after
+// the hostname, such as TF_TOKEN_example_com, or otherwise from the
+// credentials file that "tofu login" writes, in the same way as OpenTofu
+// CLI. A port number follows three underscores, such as
+// TF_TOKEN_example_com___8443 for example.com:8443. The credentials
+// themselves are never printed.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	svchost "github.com/opentofu/svchost"
+	"github.com/opentofu/svchost/disco"
+	"github.com/opentofu/svchost/svcauth"
+)
+
+func main() {
+	os.Exit(run(context.Background(), os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("svchost-disco", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	timeout := flags.Duration("timeout", 10*time.Second, "maximum time to wait for discovery")
+	credsFile := flags.String("credentials-file", defaultCredentialsFile(), "file to read saved credentials from")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: svchost-disco [-timeout duration] [-credentials-file path] hostname")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	hostname, err := svchost.ForComparison(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(stderr, "Invalid hostname: %s\n", err)
+		return 1
+	}
+
+	creds, credsDesc := findCredentials(ctx, hostname, *credsFile, stderr)
+	d, err := disco.NewWithErrors(disco.WithCredentials(svcauth.StaticCredentialsSource(map[svchost.Hostname]svcauth.HostCredentials{
+		hostname: creds,
+	})))
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to configure discovery: %s\n", err)
+		return 1
+	}
+
+	host, err := d.DiscoverWithTimeout(ctx, hostname, *timeout)
+	if err != nil {
+		fmt.Fprintf(stderr, "Discovery failed: %s\n", err)
+		return 1
+	}
+
+	fmt.Fprintf(stdout, "Host:        %s\n", hostname.ForDisplay())
+	fmt.Fprintf(stdout, "Credentials: %s\n", credsDesc)
+	printHost(stdout, host)
+	return 0
+}
+
+// printHost writes a description of the given discovery result to w.
+func printHost(w io.Writer, host *disco.Host) {
+	fmt.Fprintf(w, "Protocol:    version %d\n", host.ProtocolVersion())
+
+	ids := host.ServiceIDs()
+	if len(ids) == 0 {
+		fmt.Fprintln(w, "\nThe host does not declare any services.")
+		return
+	}
+	fmt.Fprintln(w, "\nServices:")
+	for _, id := range ids {
+		if u, err := host.ServiceURL(id); err == nil {
+			fmt.Fprintf(w, "  %s: %s\n", id, u)
+			continue
+		}
+		client, err := host.ServiceOAuthClient(id)
+		if err != nil {
+			// Neither a URL nor an OAuth client, so we'll report why it
+			// isn't valid as a URL, which is by far the most common case.
+			_, err := host.ServiceURL(id)
+			fmt.Fprintf(w, "  %s: invalid: %s\n", id, err)
+			continue
+		}
+		fmt.Fprintf(w, "  %s: OAuth client %q\n", id, client.ID)
+		if client.AuthorizationURL != nil {
+			fmt.Fprintf(w, "    authorization URL: %s\n", client.AuthorizationURL)
+		}
+		if client.TokenURL != nil {
+			fmt.Fprintf(w, "    token URL:         %s\n", client.TokenURL)
+		}
+		fmt.Fprintf(w, "    redirect ports:    %d-%d\n", client.MinPort, client.MaxPort)
+		if len(client.Scopes) != 0 {
+			fmt.Fprintf(w, "    scopes:            %s\n", strings.Join(client.Scopes, " "))
+		}
+	}
+}
+
+// findCredentials returns the credentials for the given hostname from the
+// first of the sources OpenTofu CLI would use that has some, along with a
+// description of where they came from. The result is nil if no source has
+// credentials, in which case requests are anonymous.
+//
+// A source that fails is reported as a warning to stderr and skipped.
+func findCredentials(ctx context.Context, hostname svchost.Hostname, credsFile string, stderr io.Writer) (svcauth.HostCredentials, string) {
+	type source struct {
+		desc string
+		src  svcauth.CredentialsSource
+	}
+	sources := []source{
+		{"environment variable " + envCredentialsVariable(hostname), envCredentialsSource{}},
+	}
+	if credsFile != "" {
+		sources = append(sources, source{"credentials file " + credsFile, svcauth.FileCredentialsStore(credsFile)})
+	}
+	for _, source := range sources {
+		creds, err := source.src.ForHost(ctx, hostname)
+		if err != nil {
+			fmt.Fprintf(stderr, "Warning: failed to read credentials from %s: %s\n", source.desc, err)
+			continue
+		}
+		if creds != nil {
+			return creds, fmt.Sprintf("%s from %s", describeCredentials(creds), source.desc)
+		}
+	}
+	return nil, "none (requests are anonymous)"
+}
+
+// describeCredentials returns a short description of the kind of the given
+// credentials, without revealing any secrets.
+func describeCredentials(creds svcauth.HostCredentials) string {
+	if _, ok := creds.(svcauth.HostCredentialsToken); ok {
+		return "bearer token"
+	}
+	return "credentials"
+}
+
+// defaultCredentialsFile returns the path of the credentials file that
+// "tofu login" writes by default, or the empty string if it can't be
+// determined.
+func defaultCredentialsFile() string {
+	if runtime.GOOS == "windows" {
+		if dir := os.Getenv("APPDATA"); dir != "" {
+			return filepath.Join(dir, "terraform.d", "credentials.tfrc.json")
+		}
+		return ""
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".terraform.d", "credentials.tfrc.json")
+}
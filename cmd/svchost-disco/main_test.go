@@ -0,0 +1,108 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/opentofu/svchost"
+	"github.com/opentofu/svchost/disco"
+	"github.com/opentofu/svchost/svcauth"
+)
+
+func TestPrintHost(t *testing.T) {
+	d := disco.New()
+	d.ForceHostServices("example.com", map[string]any{
+		"modules.v1": "/modules/",
+		"login.v1": map[string]any{
+			"client": "tofu-cli",
+			"authz":  "/oauth/authorization",
+			"token":  "/oauth/token",
+			"ports":  []any{10000.0, 10010.0},
+		},
+		"broken.v1": "ftp://example.com/",
+	})
+	host, err := d.Discover(t.Context(), "example.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var buf bytes.Buffer
+	printHost(&buf, host)
+	got := buf.String()
+	for _, want := range []string{
+		"Protocol:    version 1\n",
+		"  broken.v1: invalid: failed to parse service URL: unsupported scheme ftp\n",
+		"  login.v1: OAuth client \"tofu-cli\"\n",
+		"    authorization URL: https://example.com/oauth/authorization\n",
+		"    redirect ports:    10000-10010\n",
+		"  modules.v1: https://example.com/modules/\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output does not contain %q\ngot:\n%s", want, got)
+		}
+	}
+}
+
+func TestFindCredentials(t *testing.T) {
+	credsFile := filepath.Join(t.TempDir(), "credentials.tfrc.json")
+	src := `{"credentials":{"example.com:8443":{"token":"abc123"},"example.net":{"token":"def456"}}}`
+	if err := os.WriteFile(credsFile, []byte(src), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TF_TOKEN_example_net", "ghi789")
+
+	tests := []struct {
+		hostname  svchost.Hostname
+		wantCreds svcauth.HostCredentials
+		wantDesc  string
+	}{
+		{"example.com:8443", svcauth.HostCredentialsToken("abc123"), "bearer token from credentials file " + credsFile},
+		{"example.net", svcauth.HostCredentialsToken("ghi789"), "bearer token from environment variable TF_TOKEN_example_net"},
+		{"example.org", nil, "none (requests are anonymous)"},
+	}
+	for _, test := range tests {
+		t.Run(test.hostname.String(), func(t *testing.T) {
+			var stderr bytes.Buffer
+			creds, desc := findCredentials(t.Context(), test.hostname, credsFile, &stderr)
+			if creds != test.wantCreds {
+				t.Errorf("wrong credentials %#v; want %#v", creds, test.wantCreds)
+			}
+			if desc != test.wantDesc {
+				t.Errorf("wrong description %q; want %q", desc, test.wantDesc)
+			}
+			if stderr.Len() != 0 {
+				t.Errorf("unexpected warnings:\n%s", stderr.String())
+			}
+		})
+	}
+
+	t.Run("invalid file", func(t *testing.T) {
+		if err := os.WriteFile(credsFile, []byte("not JSON"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		var stderr bytes.Buffer
+		creds, _ := findCredentials(t.Context(), "example.com:8443", credsFile, &stderr)
+		if creds != nil {
+			t.Errorf("unexpected credentials %#v", creds)
+		}
+		if !strings.Contains(stderr.String(), "Warning: failed to read credentials") {
+			t.Errorf("missing warning\ngot:\n%s", stderr.String())
+		}
+	})
+}
+
+func TestRunUsage(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if got := run(t.Context(), nil, &stdout, &stderr); got != 2 {
+		t.Errorf("wrong exit status %d; want 2", got)
+	}
+	if !strings.Contains(stderr.String(), "Usage:") {
+		t.Errorf("missing usage message\ngot:\n%s", stderr.String())
+	}
+}
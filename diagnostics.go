@@ -0,0 +1,95 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package svchost
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Severity describes how serious a [Diagnostic] is.
+type Severity string
+
+const (
+	// SeverityError is the severity of a diagnostic describing a problem
+	// that prevented an operation from succeeding.
+	SeverityError Severity = "error"
+
+	// SeverityWarning is the severity of a diagnostic describing a potential
+	// problem that did not prevent an operation from succeeding, but which
+	// the user might wish to address.
+	SeverityWarning Severity = "warning"
+)
+
+// Diagnostic is a machine-readable description of a problem, or potential
+// problem, detected by an operation in this module.
+//
+// Some APIs return diagnostics alongside their main result instead of (or
+// in addition to) an error, so that callers can report warnings and
+// present problems with additional context.
+type Diagnostic struct {
+	Severity Severity `json:"severity"`
+
+	// Summary is a short description of the problem, suitable for use as
+	// a heading in a user interface.
+	Summary string `json:"summary"`
+
+	// Detail is an optional longer description of the problem, possibly
+	// including suggestions on how to address it.
+	Detail string `json:"detail,omitempty"`
+
+	// Host is the hostname that the problem relates to, if any.
+	Host Hostname `json:"host,omitempty"`
+
+	// Service is the service identifier that the problem relates to, such
+	// as "modules.v1", if any.
+	Service string `json:"service,omitempty"`
+}
+
+// Error returns a single-line description of the diagnostic, so that
+// a diagnostic of severity [SeverityError] can be used as an error value.
+func (d Diagnostic) Error() string {
+	var buf strings.Builder
+	if d.Host != "" {
+		buf.WriteString(ForDisplay(string(d.Host)))
+		buf.WriteString(": ")
+	}
+	if d.Service != "" {
+		fmt.Fprintf(&buf, "service %s: ", d.Service)
+	}
+	buf.WriteString(d.Summary)
+	if d.Detail != "" {
+		buf.WriteString(": ")
+		buf.WriteString(d.Detail)
+	}
+	return buf.String()
+}
+
+// Diagnostics is a list of diagnostics, typically all produced by the same
+// operation.
+type Diagnostics []Diagnostic
+
+// HasErrors returns true if any of the diagnostics have severity
+// [SeverityError].
+func (diags Diagnostics) HasErrors() bool {
+	for _, diag := range diags {
+		if diag.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// Err returns an error representing all of the diagnostics of severity
+// [SeverityError], or nil if there are none. Warnings are not included.
+func (diags Diagnostics) Err() error {
+	var errs []error
+	for _, diag := range diags {
+		if diag.Severity == SeverityError {
+			errs = append(errs, diag)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,48 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package svchost
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDiagnostics(t *testing.T) {
+	var diags Diagnostics
+	if diags.HasErrors() || diags.Err() != nil {
+		t.Error("empty diagnostics reported errors")
+	}
+
+	diags = append(diags, Diagnostic{
+		Severity: SeverityWarning,
+		Summary:  "Unencrypted service URL",
+		Host:     "example.com",
+		Service:  "modules.v1",
+	})
+	if diags.HasErrors() || diags.Err() != nil {
+		t.Error("warnings-only diagnostics reported errors")
+	}
+
+	diags = append(diags, Diagnostic{
+		Severity: SeverityError,
+		Summary:  "Invalid service URL",
+		Detail:   "unsupported scheme ftp",
+		Host:     "example.com",
+		Service:  "providers.v1",
+	})
+	if !diags.HasErrors() {
+		t.Error("HasErrors returned false; want true")
+	}
+	if got, want := diags.Err().Error(), "example.com: service providers.v1: Invalid service URL: unsupported scheme ftp"; got != want {
+		t.Errorf("wrong error\ngot:  %s\nwant: %s", got, want)
+	}
+
+	got, err := json.Marshal(diags[0])
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if want := `{"severity":"warning","summary":"Unencrypted service URL","host":"example.com","service":"modules.v1"}`; string(got) != want {
+		t.Errorf("wrong JSON\ngot:  %s\nwant: %s", got, want)
+	}
+}
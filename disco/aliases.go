@@ -0,0 +1,81 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package disco
+
+import (
+	"maps"
+	"strings"
+
+	"github.com/opentofu/svchost"
+)
+
+// ErrAliasCycle is returned when a hostname can't be resolved because the
+// aliases registered with [Disco.Alias] form a cycle.
+type ErrAliasCycle struct {
+	// Chain is the sequence of hostnames visited while resolving, starting
+	// with the hostname being resolved and ending with the first hostname
+	// that was visited twice.
+	Chain []svchost.Hostname
+}
+
+func (e *ErrAliasCycle) Error() string {
+	names := make([]string, len(e.Chain))
+	for i, hostname := range e.Chain {
+		names[i] = hostname.ForDisplay()
+	}
+	return "hostname aliases form a cycle: " + strings.Join(names, " -> ")
+}
+
+// Aliases returns a snapshot of the aliases registered with [Disco.Alias],
+// mapping each alias to the target it was registered with.
+//
+// The targets are as given to Alias, and so may themselves be aliases. Use
+// [Disco.ResolveAlias] to find the hostname that will actually be used.
+func (d *Disco) Aliases() map[svchost.Hostname]svchost.Hostname {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return maps.Clone(d.aliases)
+}
+
+// ResolveAlias returns the hostname that will be consulted for discovery and
+// credentials in place of the given hostname, following aliases whose
+// targets are themselves aliases until reaching a hostname that is not.
+//
+// The boolean result is false if the given hostname is not an alias, in
+// which case the hostname is returned unchanged. It's also false if the
+// aliases form a cycle, in which case discovery for the hostname fails with
+// [ErrAliasCycle].
+func (d *Disco) ResolveAlias(hostname svchost.Hostname) (svchost.Hostname, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	target, err := d.resolveAliasLocked(hostname)
+	if err != nil {
+		return hostname, false
+	}
+	return target, target != hostname
+}
+
+// resolveAliasLocked is the main implementation of ResolveAlias that assumes
+// the caller has already locked d.mu. It returns the given hostname unchanged
+// if it is not an alias.
+func (d *Disco) resolveAliasLocked(hostname svchost.Hostname) (svchost.Hostname, error) {
+	target, ok := d.aliases[hostname]
+	if !ok {
+		return hostname, nil
+	}
+	chain := []svchost.Hostname{hostname}
+	for {
+		for _, visited := range chain {
+			if visited == target {
+				return hostname, &ErrAliasCycle{Chain: append(chain, target)}
+			}
+		}
+		next, ok := d.aliases[target]
+		if !ok {
+			return target, nil
+		}
+		chain = append(chain, target)
+		target = next
+	}
+}
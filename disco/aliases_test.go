@@ -0,0 +1,122 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package disco
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/opentofu/svchost"
+	"github.com/opentofu/svchost/svcauth"
+)
+
+func TestDiscoResolveAlias(t *testing.T) {
+	d := New()
+	d.Alias("a.example.com", "b.example.com")
+	d.Alias("b.example.com", "c.example.com")
+	d.Alias("loop1.example.com", "loop2.example.com")
+	d.Alias("loop2.example.com", "loop1.example.com")
+	d.Alias("self.example.com", "self.example.com")
+
+	tests := []struct {
+		hostname svchost.Hostname
+		want     svchost.Hostname
+		wantOK   bool
+	}{
+		{"a.example.com", "c.example.com", true},
+		{"b.example.com", "c.example.com", true},
+		{"c.example.com", "c.example.com", false},
+		{"loop1.example.com", "loop1.example.com", false},
+		{"self.example.com", "self.example.com", false},
+	}
+	for _, test := range tests {
+		t.Run(test.hostname.String(), func(t *testing.T) {
+			got, ok := d.ResolveAlias(test.hostname)
+			if got != test.want || ok != test.wantOK {
+				t.Errorf("wrong result (%s, %t); want (%s, %t)", got, ok, test.want, test.wantOK)
+			}
+		})
+	}
+
+	want := map[svchost.Hostname]svchost.Hostname{
+		"a.example.com":     "b.example.com",
+		"b.example.com":     "c.example.com",
+		"loop1.example.com": "loop2.example.com",
+		"loop2.example.com": "loop1.example.com",
+		"self.example.com":  "self.example.com",
+	}
+	aliases := d.Aliases()
+	if diff := cmp.Diff(want, aliases); diff != "" {
+		t.Errorf("wrong aliases\n%s", diff)
+	}
+	// The result is a snapshot that the caller is free to modify.
+	delete(aliases, "a.example.com")
+	if _, ok := d.ResolveAlias("a.example.com"); !ok {
+		t.Error("modifying the result of Aliases removed an alias")
+	}
+
+	d.ForgetAlias("b.example.com")
+	if got, _ := d.ResolveAlias("a.example.com"); got != "b.example.com" {
+		t.Errorf("wrong result after ForgetAlias %s; want b.example.com", got)
+	}
+}
+
+func TestDiscoAliasTransitive(t *testing.T) {
+	portStr, cleanup := testServer(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+		w.Write([]byte(`{"thingy.v1":"http://example.com/foo"}`))
+	})
+	defer cleanup()
+
+	target := svchost.Hostname("localhost" + portStr)
+	d := New(WithHTTPClient(testClient))
+	d.SetCredentialsSource(svcauth.StaticCredentialsSource(map[svchost.Hostname]svcauth.HostCredentials{
+		target: svcauth.HostCredentialsToken("hunter2"),
+	}))
+	d.Alias("first.example.com", "second.example.com")
+	d.Alias("second.example.com", target)
+
+	discovered, err := d.Discover(t.Context(), "first.example.com")
+	if err != nil {
+		t.Fatalf("unexpected discovery error: %s", err)
+	}
+	if _, err := discovered.ServiceURL("thingy.v1"); err != nil {
+		t.Errorf("unexpected service URL error: %s", err)
+	}
+	creds, err := d.CredentialsForHost(t.Context(), "first.example.com")
+	if err != nil {
+		t.Fatalf("unexpected credentials error: %s", err)
+	}
+	if got, want := creds, svcauth.HostCredentialsToken("hunter2"); got != want {
+		t.Errorf("wrong credentials %#v; want %#v", got, want)
+	}
+}
+
+func TestDiscoAliasCycle(t *testing.T) {
+	d := New()
+	d.SetCredentialsSource(svcauth.StaticCredentialsSource(nil))
+	d.Alias("loop1.example.com", "loop2.example.com")
+	d.Alias("loop2.example.com", "loop1.example.com")
+
+	_, err := d.Discover(t.Context(), "loop1.example.com")
+	var cycleErr *ErrAliasCycle
+	if !errors.As(err, &cycleErr) {
+		t.Fatalf("wrong error %v; want ErrAliasCycle", err)
+	}
+	wantChain := []svchost.Hostname{"loop1.example.com", "loop2.example.com", "loop1.example.com"}
+	if diff := cmp.Diff(wantChain, cycleErr.Chain); diff != "" {
+		t.Errorf("wrong chain\n%s", diff)
+	}
+	if got, want := cycleErr.Error(), "hostname aliases form a cycle: loop1.example.com -> loop2.example.com -> loop1.example.com"; got != want {
+		t.Errorf("wrong message %q; want %q", got, want)
+	}
+
+	_, err = d.CredentialsForHost(t.Context(), "loop2.example.com")
+	if !errors.As(err, &cycleErr) {
+		t.Errorf("wrong credentials error %v; want ErrAliasCycle", err)
+	}
+}
@@ -0,0 +1,287 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package disco
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	svchost "github.com/opentofu/svchost"
+)
+
+// DiscoCacheStore is implemented by persistent storage for discovery results,
+// used with [WithCacheStore] so that discovery results can be reused across
+// multiple processes, such as separate runs of a command line program.
+//
+// Implementations must be safe for concurrent use.
+type DiscoCacheStore interface {
+	// Load returns the stored entry for the given hostname, or nil if there
+	// is no entry. Load may return expired entries; the caller is
+	// responsible for checking [CacheEntry.Expires].
+	Load(ctx context.Context, hostname svchost.Hostname) (*CacheEntry, error)
+
+	// Store saves the given entry for the given hostname, replacing any
+	// existing entry.
+	Store(ctx context.Context, hostname svchost.Hostname, entry *CacheEntry) error
+
+	// Forget deletes any stored entry for the given hostname. It does
+	// nothing and returns successfully if there is no entry.
+	Forget(ctx context.Context, hostname svchost.Hostname) error
+}
+
+// CacheEntry is the representation of a discovery result that is saved in a
+// [DiscoCacheStore].
+type CacheEntry struct {
+	// DiscoveryURL is the URL the discovery document was fetched from, after
+	// following any redirects. Relative service URLs are resolved against it.
+	DiscoveryURL string `json:"discovery_url"`
+
+	ProtocolVersion int `json:"protocol_version"`
+
+	// Document is the discovery document exactly as it was received, or
+	// empty if the host has no discovery document. Header holds the
+	// response headers named in [WithResponseVerifier], if any. The
+	// document is checked again each time the entry is loaded, in the same
+	// way as a newly-fetched document.
+	Document json.RawMessage `json:"document,omitempty"`
+	Header   http.Header     `json:"header,omitempty"`
+
+	// FetchedAt is when the discovery document was last fetched or
+	// revalidated, and Expires is the time after which the entry must not
+	// be used without first revalidating it.
+	FetchedAt time.Time `json:"fetched_at"`
+	Expires   time.Time `json:"expires"`
+
+	// ETag and LastModified are the validators from the response headers of
+	// the same names, if any, which allow revalidating an expired entry
+	// using a conditional request instead of downloading the document again.
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// newCacheEntry returns a cache entry representing the given host that
+// expires once the given duration has passed since the given time.
+func newCacheEntry(host *Host, ttl time.Duration, now time.Time) *CacheEntry {
+	return &CacheEntry{
+		DiscoveryURL:    host.discoURL.String(),
+		ProtocolVersion: host.protocolVersion,
+		Document:        host.document,
+		Header:          host.documentHeader,
+		FetchedAt:       host.fetchedAt,
+		Expires:         now.Add(ttl),
+		ETag:            host.etag,
+		LastModified:    host.lastModified,
+	}
+}
+
+// host returns the [Host] represented by the receiver, or an error if the
+// entry is invalid or if its document fails the checks made by
+// [Disco.checkDocument].
+func (e *CacheEntry) host(d *Disco, hostname svchost.Hostname) (*Host, error) {
+	discoURL, err := url.Parse(e.DiscoveryURL)
+	if err != nil || !discoURL.IsAbs() {
+		return nil, fmt.Errorf("invalid discovery URL %q in cache entry", e.DiscoveryURL)
+	}
+	if e.FetchedAt.IsZero() {
+		return nil, errors.New("cache entry has no fetch time")
+	}
+	var services map[string]any
+	if len(e.Document) != 0 {
+		services, err = d.checkDocument(hostname, e.Document, e.Header)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &Host{
+		discoURL:        discoURL,
+		hostname:        hostname.ForDisplay(),
+		services:        services,
+		protocolVersion: e.ProtocolVersion,
+		fetchedAt:       e.FetchedAt,
+		document:        e.Document,
+		documentHeader:  e.Header,
+		etag:            e.ETag,
+		lastModified:    e.LastModified,
+	}, nil
+}
+
+// cacheLifetime returns how long a discovery response with the given headers
+// may be stored, given the default lifetime from [WithCacheStore]. The
+// result is zero if the response must not be stored.
+//
+// The "no-store", "no-cache", and "max-age" Cache-Control directives are
+// honored, and others are ignored.
+func cacheLifetime(header http.Header, defaultTTL time.Duration) time.Duration {
+	ttl := defaultTTL
+	for _, value := range header.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			name, arg, _ := strings.Cut(strings.TrimSpace(directive), "=")
+			switch strings.ToLower(name) {
+			case "no-store", "no-cache":
+				return 0
+			case "max-age":
+				seconds, err := strconv.Atoi(strings.Trim(arg, `"`))
+				if err != nil || seconds < 0 {
+					return 0 // invalid directive, so we'll be conservative
+				}
+				ttl = time.Duration(seconds) * time.Second
+			}
+		}
+	}
+	return ttl
+}
+
+// loadFromStore attempts to load a discovery result for the given hostname
+// from the persistent cache store, returning nil if there is no usable entry.
+//
+// If there is an expired entry that can be revalidated then it is returned
+// as stale instead, for use with a conditional discovery request.
+//
+// Errors from the store are ignored, since the persistent cache is only an
+// optimization and discovery can proceed without it. An entry whose document
+// is rejected by the verifier or validators is ignored too, so that a
+// document is never used without being checked.
+func (d *Disco) loadFromStore(ctx context.Context, hostname svchost.Hostname) (host, stale *Host) {
+	if d.cacheStore == nil {
+		return nil, nil
+	}
+	if d.hostPolicy != nil {
+		// A result saved before the policy changed must not bypass it, so
+		// in that case we let discovery report the policy error.
+		d.mu.Lock()
+		target, err := d.resolveAliasLocked(hostname)
+		d.mu.Unlock()
+		if err != nil || d.hostPolicy(target) != nil {
+			return nil, nil
+		}
+	}
+	entry, err := d.cacheStore.Load(ctx, hostname)
+	if err != nil || entry == nil {
+		return nil, nil
+	}
+	host, err = entry.host(d, hostname)
+	if err != nil {
+		return nil, nil
+	}
+	host.unknownOAuthFields = d.unknownOAuthFieldsFor(hostname)
+	host.serviceURLPolicy = d.serviceURLPolicyFor(hostname)
+	if !d.clock.Now().Before(entry.Expires) {
+		if host.etag == "" && host.lastModified == "" {
+			return nil, nil
+		}
+		return nil, host
+	}
+	return host, nil
+}
+
+// saveToStore saves the given discovery result in the persistent cache
+// store, if there is one and the result may be stored.
+func (d *Disco) saveToStore(ctx context.Context, hostname svchost.Hostname, host *Host) {
+	if d.cacheStore == nil || host.storeTTL <= 0 {
+		return
+	}
+	//nolint:errcheck // the persistent cache is only an optimization
+	d.cacheStore.Store(ctx, hostname, newCacheEntry(host, host.storeTTL, d.clock.Now()))
+}
+
+// forgetFromStore discards any entry for the given hostname from the
+// persistent cache store, if there is one.
+func (d *Disco) forgetFromStore(hostname svchost.Hostname) {
+	if d.cacheStore == nil {
+		return
+	}
+	//nolint:errcheck // Forget has no way to report errors
+	d.cacheStore.Forget(context.Background(), hostname)
+}
+
+// NewFileCacheStore returns a [DiscoCacheStore] that saves each entry as a
+// separate JSON file in the given directory, creating the directory if
+// necessary.
+//
+// The directory can safely be shared between multiple processes, because
+// each entry is replaced atomically.
+func NewFileCacheStore(dir string) (DiscoCacheStore, error) {
+	if dir == "" {
+		return nil, errors.New("cache directory must not be empty")
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	return fileCacheStore(dir), nil
+}
+
+type fileCacheStore string
+
+// Load implements [DiscoCacheStore].
+func (s fileCacheStore) Load(_ context.Context, hostname svchost.Hostname) (*CacheEntry, error) {
+	raw, err := os.ReadFile(s.filename(hostname))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entry CacheEntry
+	if err := json.Unmarshal(raw, &entry); err != nil {
+		return nil, fmt.Errorf("invalid cache entry for %s: %w", hostname.ForDisplay(), err)
+	}
+	return &entry, nil
+}
+
+// Store implements [DiscoCacheStore].
+func (s fileCacheStore) Store(_ context.Context, hostname svchost.Hostname, entry *CacheEntry) error {
+	raw, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to serialize cache entry: %w", err)
+	}
+
+	// We write to a temporary file and then rename it into place so that
+	// concurrent readers never see a partially-written entry.
+	f, err := os.CreateTemp(string(s), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(raw)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), s.filename(hostname))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
+
+// Forget implements [DiscoCacheStore].
+func (s fileCacheStore) Forget(_ context.Context, hostname svchost.Hostname) error {
+	err := os.Remove(s.filename(hostname))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// filename returns the path of the file holding the entry for the given
+// hostname. Hostnames may contain characters that are not valid in
+// filenames on all platforms, such as the colon before a port number, so
+// we use a hash of the hostname instead.
+func (s fileCacheStore) filename(hostname svchost.Hostname) string {
+	sum := sha256.Sum256([]byte(hostname))
+	return filepath.Join(string(s), hex.EncodeToString(sum[:])+".json")
+}
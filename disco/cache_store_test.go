@@ -0,0 +1,332 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package disco
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	svchost "github.com/opentofu/svchost"
+)
+
+func TestCacheLifetime(t *testing.T) {
+	tests := map[string]time.Duration{
+		"":                        time.Hour,
+		"public":                  time.Hour,
+		"max-age=60":              time.Minute,
+		"public, max-age=\"120\"": 2 * time.Minute,
+		"no-store":                0,
+		"max-age=60, no-cache":    0,
+		"max-age=bad":             0,
+	}
+	for value, want := range tests {
+		t.Run(value, func(t *testing.T) {
+			header := http.Header{}
+			if value != "" {
+				header.Set("Cache-Control", value)
+			}
+			if got := cacheLifetime(header, time.Hour); got != want {
+				t.Errorf("wrong lifetime %s; want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestFileCacheStore(t *testing.T) {
+	store, err := NewFileCacheStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	const hostname = svchost.Hostname("example.com:8443")
+
+	got, err := store.Load(t.Context(), hostname)
+	if err != nil || got != nil {
+		t.Fatalf("unexpected result for missing entry: %#v, %v", got, err)
+	}
+
+	want := &CacheEntry{
+		DiscoveryURL:    "https://example.com:8443/.well-known/terraform.json",
+		ProtocolVersion: 1,
+		Document:        json.RawMessage(`{"modules.v1":"/modules/"}`),
+		Header:          http.Header{"X-Signature": {"abc123"}},
+		FetchedAt:       time.Date(2029, 12, 31, 0, 0, 0, 0, time.UTC),
+		Expires:         time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	if err := store.Store(t.Context(), hostname, want); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	got, err = store.Load(t.Context(), hostname)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("wrong entry\n%s", diff)
+	}
+
+	if err := store.Forget(t.Context(), hostname); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := store.Forget(t.Context(), hostname); err != nil {
+		t.Fatalf("unexpected error forgetting missing entry: %s", err)
+	}
+	got, err = store.Load(t.Context(), hostname)
+	if err != nil || got != nil {
+		t.Fatalf("unexpected result for forgotten entry: %#v, %v", got, err)
+	}
+}
+
+func TestWithCacheStore(t *testing.T) {
+	requests := 0
+	cacheControl := ""
+	portStr, cleanup := testServer(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Add("Content-Type", "application/json")
+		if cacheControl != "" {
+			w.Header().Add("Cache-Control", cacheControl)
+		}
+		w.Write([]byte(`{"thingy.v1": "/thingy/"}`))
+	})
+	defer cleanup()
+
+	host, err := svchost.ForComparison("localhost" + portStr)
+	if err != nil {
+		t.Fatalf("test server hostname is invalid: %s", err)
+	}
+
+	store, err := NewFileCacheStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	discover := func() *Host {
+		t.Helper()
+		// Each call uses a separate Disco object, as if in a new process.
+		d, err := NewWithErrors(WithHTTPClient(testClient), WithCacheStore(store, time.Hour))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		got, err := d.Discover(t.Context(), host)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		return got
+	}
+
+	discover()
+	cached := discover()
+	if requests != 1 {
+		t.Errorf("made %d requests; want 1", requests)
+	}
+	gotURL, err := cached.ServiceURL("thingy.v1")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got, want := gotURL.String(), "https://localhost"+portStr+"/thingy/"; got != want {
+		t.Errorf("wrong URL from cached entry %q; want %q", got, want)
+	}
+
+	store.Forget(t.Context(), host)
+	cacheControl = "no-store"
+	discover()
+	discover()
+	if requests != 3 {
+		t.Errorf("made %d requests; want 3 after no-store", requests)
+	}
+
+	if _, err := NewWithErrors(WithCacheStore(store, 0)); err == nil {
+		t.Error("unexpected success with zero TTL; want error")
+	}
+}
+
+func TestWithCacheStoreRevalidation(t *testing.T) {
+	requests := 0
+	notModified := 0
+	portStr, cleanup := testServer(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Add("Content-Type", "application/json")
+		w.Write([]byte(`{"thingy.v1": "/thingy/"}`))
+	})
+	defer cleanup()
+
+	host, err := svchost.ForComparison("localhost" + portStr)
+	if err != nil {
+		t.Fatalf("test server hostname is invalid: %s", err)
+	}
+
+	store, err := NewFileCacheStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	d := New(WithHTTPClient(testClient), WithCacheStore(store, time.Hour))
+	first, err := d.Discover(t.Context(), host)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got, want := first.ETag(), `"v1"`; got != want {
+		t.Errorf("wrong ETag %q; want %q", got, want)
+	}
+
+	// Expire the stored entry so that the next discovery must revalidate it.
+	entry, err := store.Load(t.Context(), host)
+	if err != nil || entry == nil {
+		t.Fatalf("no stored entry: %v", err)
+	}
+	entry.Expires = time.Now().Add(-time.Minute)
+	if err := store.Store(t.Context(), host, entry); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	d = New(WithHTTPClient(testClient), WithCacheStore(store, time.Hour))
+	revalidated, err := d.Discover(t.Context(), host)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if requests != 2 || notModified != 1 {
+		t.Errorf("made %d requests with %d not modified; want 2 with 1 not modified", requests, notModified)
+	}
+	gotURL, err := revalidated.ServiceURL("thingy.v1")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got, want := gotURL.String(), "https://localhost"+portStr+"/thingy/"; got != want {
+		t.Errorf("wrong URL from revalidated entry %q; want %q", got, want)
+	}
+	entry, err = store.Load(t.Context(), host)
+	if err != nil || entry == nil {
+		t.Fatalf("no stored entry after revalidation: %v", err)
+	}
+	if !time.Now().Before(entry.Expires) {
+		t.Errorf("stored entry still expired after revalidation")
+	}
+
+	// Refresh also revalidates the in-memory result.
+	if _, err := d.Refresh(t.Context(), host); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if requests != 3 || notModified != 2 {
+		t.Errorf("made %d requests with %d not modified; want 3 with 2 not modified", requests, notModified)
+	}
+}
+
+func TestWithCacheStore_hostPolicy(t *testing.T) {
+	store, err := NewFileCacheStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	const hostname = svchost.Hostname("example.com")
+	err = store.Store(t.Context(), hostname, &CacheEntry{
+		DiscoveryURL:    "https://example.com/.well-known/terraform.json",
+		ProtocolVersion: 1,
+		Document:        json.RawMessage(`{"modules.v1":"/modules/"}`),
+		FetchedAt:       time.Now(),
+		Expires:         time.Now().Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// A result saved earlier must not bypass a policy that now denies the
+	// host.
+	errDenied := errors.New("host not allowed")
+	d, err := NewWithErrors(WithCacheStore(store, time.Hour), WithHostPolicy(func(svchost.Hostname) error {
+		return errDenied
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := d.Discover(t.Context(), hostname); !errors.Is(err, errDenied) {
+		t.Errorf("wrong error %v; want host policy error", err)
+	}
+}
+
+func TestWithCacheStore_checked(t *testing.T) {
+	store, err := NewFileCacheStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	const hostname = svchost.Hostname("example.com")
+	fetchedAt := time.Now().Add(-30 * time.Minute).Truncate(time.Second)
+	err = store.Store(t.Context(), hostname, &CacheEntry{
+		DiscoveryURL:    "https://example.com/.well-known/terraform.json",
+		ProtocolVersion: 1,
+		Document:        json.RawMessage(`{"modules.v1":"/modules/"}`),
+		Header:          http.Header{"X-Signature": {"good"}},
+		FetchedAt:       fetchedAt,
+		Expires:         time.Now().Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	// The client refuses all requests, so a result can come only from the
+	// store.
+	client := &http.Client{
+		Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			return nil, errors.New("no network access")
+		}),
+	}
+
+	t.Run("accepted", func(t *testing.T) {
+		var gotSignature string
+		d, err := NewWithErrors(
+			WithHTTPClient(client),
+			WithCacheStore(store, time.Hour),
+			WithResponseVerifier(func(_ svchost.Hostname, _ []byte, header http.Header) error {
+				gotSignature = header.Get("X-Signature")
+				return nil
+			}, "X-Signature"),
+		)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		host, err := d.Discover(t.Context(), hostname)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if got, want := gotSignature, "good"; got != want {
+			t.Errorf("verifier got signature %q; want %q", got, want)
+		}
+		if !host.fetchedAt.Equal(fetchedAt) {
+			t.Errorf("wrong fetch time %s; want %s from the stored entry", host.fetchedAt, fetchedAt)
+		}
+	})
+	t.Run("verifier", func(t *testing.T) {
+		d, err := NewWithErrors(
+			WithHTTPClient(client),
+			WithCacheStore(store, time.Hour),
+			WithResponseVerifier(func(svchost.Hostname, []byte, http.Header) error {
+				return errors.New("bad signature")
+			}),
+		)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if _, err := d.Discover(t.Context(), hostname); err == nil {
+			t.Error("unexpected success; want the stored document to be rejected")
+		}
+	})
+	t.Run("validator", func(t *testing.T) {
+		d, err := NewWithErrors(
+			WithHTTPClient(client),
+			WithCacheStore(store, time.Hour),
+			WithDocumentValidator(func(svchost.Hostname, map[string]any) error {
+				return errors.New("not allowed")
+			}),
+		)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if _, err := d.Discover(t.Context(), hostname); err == nil {
+			t.Error("unexpected success; want the stored document to be rejected")
+		}
+	})
+}
@@ -0,0 +1,116 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package disco
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	svchost "github.com/opentofu/svchost"
+	"github.com/opentofu/svchost/svcauth"
+)
+
+// ServiceCheck describes the result of [Disco.CheckService].
+type ServiceCheck struct {
+	// URL is the base URL of the service, as found by discovery.
+	URL *url.URL
+
+	// Method is the HTTP method of the request that produced the result,
+	// which is "HEAD" unless the server doesn't support that method for
+	// the service's base URL, in which case it's "GET".
+	Method string
+
+	// StatusCode and Status are the status of the server's response.
+	StatusCode int
+	Status     string
+
+	// Latency is the time taken for the server to respond, not including
+	// discovery or the credentials lookup.
+	Latency time.Duration
+
+	// CredentialsKind describes the kind of credentials that were sent with
+	// the request, as returned by [svcauth.CredentialsKind], or is empty if
+	// the request was sent without credentials.
+	CredentialsKind string
+}
+
+// Authenticated returns true if the request was sent with credentials.
+func (c *ServiceCheck) Authenticated() bool {
+	return c.CredentialsKind != ""
+}
+
+// AuthRejected returns true if the server rejected the request because it
+// required credentials or didn't accept the credentials that were sent.
+func (c *ServiceCheck) AuthRejected() bool {
+	return c.StatusCode == http.StatusUnauthorized || c.StatusCode == http.StatusForbidden
+}
+
+// OK returns true if the server responded with neither a client nor a server
+// error status, suggesting that the service is reachable and that any
+// credentials were accepted.
+func (c *ServiceCheck) OK() bool {
+	return c.StatusCode < 400
+}
+
+// CheckService verifies that the service with the given identifier on the
+// given hostname is reachable, and that the server accepts the credentials
+// for the host, if any, by performing discovery and then sending a request
+// to the service's base URL.
+//
+// The request uses the HEAD method, falling back to GET if the server
+// doesn't support HEAD, and carries the same credentials that discovery
+// would use. Only the response status is checked, because services
+// typically don't define any response for their base URL, and so many
+// servers respond with 404 Not Found even when the service is working.
+//
+// The error is non-nil only if discovery fails, the host doesn't provide the
+// given service, or the request couldn't be sent at all. An error status
+// from the server is reported in the result instead.
+func (d *Disco) CheckService(ctx context.Context, hostname svchost.Hostname, serviceID string) (*ServiceCheck, error) {
+	serviceURL, err := d.DiscoverServiceURL(ctx, hostname, serviceID)
+	if err != nil {
+		return nil, err
+	}
+	creds, err := d.CredentialsForHost(ctx, hostname)
+	if err != nil {
+		return nil, err
+	}
+	client := d.redirectClient(d.httpClient, hostname, creds, nil)
+
+	ret := &ServiceCheck{
+		URL:             serviceURL,
+		CredentialsKind: svcauth.CredentialsKind(creds),
+	}
+	for _, method := range []string{"HEAD", "GET"} {
+		req, err := http.NewRequestWithContext(ctx, method, serviceURL.String(), nil)
+		if err != nil {
+			return nil, svchost.WrapHostError(hostname, OpCheckService, fmt.Errorf("invalid service URL: %w", err))
+		}
+		req.Header.Set("User-Agent", d.userAgent())
+		svcauth.PrepareRequest(ctx, creds, req, serviceID)
+
+		start := time.Now()
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, svchost.WrapHostError(hostname, OpCheckService, err)
+		}
+		ret.Latency = time.Since(start)
+		ret.Method = method
+		ret.StatusCode = resp.StatusCode
+		ret.Status = resp.Status
+		// We don't need the body, but reading a little of it allows the
+		// connection to be reused if the body is short.
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 4096)) //nolint:errcheck // the body is irrelevant
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusMethodNotAllowed && resp.StatusCode != http.StatusNotImplemented {
+			break
+		}
+	}
+	return ret, nil
+}
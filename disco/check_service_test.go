@@ -0,0 +1,91 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package disco
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	svchost "github.com/opentofu/svchost"
+	"github.com/opentofu/svchost/svcauth"
+)
+
+func TestCheckService(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/terraform.json":
+			w.Header().Add("Content-Type", "application/json")
+			w.Write([]byte(`{"modules.v1": "/modules/", "providers.v1": "/providers/"}`))
+		case "/modules/":
+			if r.Header.Get("Authorization") != "Bearer abc123" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		case "/providers/":
+			// This service supports only GET.
+			if r.Method != "GET" {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	host, err := svchost.ForComparison(strings.TrimPrefix(server.URL, "https://"))
+	if err != nil {
+		t.Fatalf("test server hostname is invalid: %s", err)
+	}
+
+	t.Run("authenticated", func(t *testing.T) {
+		d := New(WithHTTPClient(testClient))
+		d.SetCredentialsSource(svcauth.StaticCredentialsSource(map[svchost.Hostname]svcauth.HostCredentials{
+			host: svcauth.HostCredentialsToken("abc123"),
+		}))
+		got, err := d.CheckService(t.Context(), host, "modules.v1")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !got.OK() || !got.Authenticated() || got.AuthRejected() {
+			t.Errorf("wrong result %#v", got)
+		}
+		if got.Method != "HEAD" || got.StatusCode != http.StatusNoContent || got.CredentialsKind != "token" {
+			t.Errorf("wrong result %#v", got)
+		}
+		if got, want := got.URL.String(), server.URL+"/modules/"; got != want {
+			t.Errorf("wrong URL %q; want %q", got, want)
+		}
+	})
+	t.Run("unauthenticated", func(t *testing.T) {
+		d := New(WithHTTPClient(testClient))
+		got, err := d.CheckService(t.Context(), host, "modules.v1")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if got.OK() || got.Authenticated() || !got.AuthRejected() {
+			t.Errorf("wrong result %#v", got)
+		}
+	})
+	t.Run("falls back to GET", func(t *testing.T) {
+		d := New(WithHTTPClient(testClient))
+		got, err := d.CheckService(t.Context(), host, "providers.v1")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if got.Method != "GET" || got.StatusCode != http.StatusOK {
+			t.Errorf("wrong result %#v", got)
+		}
+	})
+	t.Run("unsupported service", func(t *testing.T) {
+		d := New(WithHTTPClient(testClient))
+		if _, err := d.CheckService(t.Context(), host, "thingy.v1"); err == nil {
+			t.Error("unexpected success; want error")
+		}
+	})
+}
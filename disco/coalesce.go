@@ -0,0 +1,61 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package disco
+
+import (
+	"context"
+	"errors"
+
+	"github.com/opentofu/svchost"
+)
+
+// inflightDiscovery represents a discovery request that is shared between
+// concurrent callers when [WithRequestCoalescing] is enabled.
+//
+// host and err must not be accessed until done is closed.
+type inflightDiscovery struct {
+	done chan struct{}
+	host *Host
+	err  error
+}
+
+// coalesce calls fn for the given hostname unless there is already a call in
+// progress for the same hostname, in which case it waits for that call to
+// complete and returns its result instead.
+func (d *Disco) coalesce(ctx context.Context, hostname svchost.Hostname, fn func(context.Context, svchost.Hostname) (*Host, error)) (*Host, error) {
+	for {
+		d.mu.Lock()
+		call, running := d.inflight[hostname]
+		if !running {
+			call = &inflightDiscovery{done: make(chan struct{})}
+			d.inflight[hostname] = call
+			d.mu.Unlock()
+			return d.runInflight(ctx, hostname, call, fn)
+		}
+		d.mu.Unlock()
+
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return nil, svchost.WrapHostError(hostname, OpDiscover, ctx.Err())
+		}
+		if ctx.Err() == nil && (errors.Is(call.err, context.Canceled) || errors.Is(call.err, context.DeadlineExceeded)) {
+			// The request failed only because the caller that started it
+			// gave up, so we'll try again on behalf of this caller.
+			continue
+		}
+		return call.host, call.err
+	}
+}
+
+func (d *Disco) runInflight(ctx context.Context, hostname svchost.Hostname, call *inflightDiscovery, fn func(context.Context, svchost.Hostname) (*Host, error)) (*Host, error) {
+	defer func() {
+		d.mu.Lock()
+		delete(d.inflight, hostname)
+		d.mu.Unlock()
+		close(call.done)
+	}()
+	call.host, call.err = fn(ctx, hostname)
+	return call.host, call.err
+}
@@ -0,0 +1,129 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package disco
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/opentofu/svchost"
+)
+
+func TestWithRequestCoalescing(t *testing.T) {
+	var requests atomic.Int32
+	release := make(chan struct{})
+	portStr, cleanup := testServer(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		<-release
+		w.Header().Add("Content-Type", "application/json")
+		w.Write([]byte(`{"thingy.v1":"http://example.com/foo"}`))
+	})
+	defer cleanup()
+
+	host, err := svchost.ForComparison("localhost" + portStr)
+	if err != nil {
+		t.Fatalf("test server hostname is invalid: %s", err)
+	}
+	d, err := NewWithErrors(WithHTTPClient(testClient), WithRequestCoalescing(true))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	const workers = 10
+	var wg sync.WaitGroup
+	results := make([]*Host, workers)
+	errs := make([]error, workers)
+	for i := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = d.Discover(t.Context(), host)
+		}()
+	}
+	// Give all of the workers a chance to start waiting before the server
+	// responds, so that they'll find the request already in progress.
+	for {
+		d.mu.Lock()
+		started := d.inflight[host] != nil
+		d.mu.Unlock()
+		if started {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	for i := range workers {
+		if errs[i] != nil {
+			t.Fatalf("worker %d failed: %s", i, errs[i])
+		}
+		if results[i] != results[0] {
+			t.Errorf("worker %d got a different result than worker 0", i)
+		}
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("wrong number of discovery requests %d; want 1", got)
+	}
+}
+
+func TestWithRequestCoalescingCanceledLeader(t *testing.T) {
+	d := New(WithRequestCoalescing(true))
+	host := svchost.Hostname("example.com")
+
+	leaderStarted := make(chan struct{})
+	leaderCtx, cancelLeader := context.WithCancel(t.Context())
+	want := &Host{hostname: "example.com"}
+	calls := 0
+	var mu sync.Mutex
+	fn := func(ctx context.Context, _ svchost.Hostname) (*Host, error) {
+		mu.Lock()
+		calls++
+		first := calls == 1
+		mu.Unlock()
+		if first {
+			close(leaderStarted)
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return want, nil
+	}
+
+	var leaderErr error
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, leaderErr = d.coalesce(leaderCtx, host, fn)
+	}()
+	<-leaderStarted
+
+	followerDone := make(chan struct{})
+	var got *Host
+	var followerErr error
+	go func() {
+		defer close(followerDone)
+		got, followerErr = d.coalesce(t.Context(), host, fn)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancelLeader()
+	wg.Wait()
+	<-followerDone
+
+	if !errors.Is(leaderErr, context.Canceled) {
+		t.Errorf("wrong leader error %v; want context.Canceled", leaderErr)
+	}
+	if followerErr != nil {
+		t.Fatalf("unexpected follower error: %s", followerErr)
+	}
+	if got != want {
+		t.Errorf("wrong follower result %#v; want %#v", got, want)
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package disco
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// setAcceptEncoding asks the server to compress the discovery document.
+//
+// Setting this header ourselves prevents [http.Transport] from transparently
+// decompressing the response, so that [decodedBody] can enforce the document
+// size limit on the decompressed bytes rather than on what was transferred.
+func setAcceptEncoding(req *http.Request) {
+	req.Header.Set("Accept-Encoding", "gzip")
+}
+
+// decodedBody returns a reader for the body of the given response with any
+// content encoding removed, along with a reader that counts the bytes read
+// from the underlying body, or nil if the body isn't encoded.
+func decodedBody(resp *http.Response) (io.Reader, *countingReader, error) {
+	encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	switch encoding {
+	case "", "identity":
+		return resp.Body, nil, nil
+	case "gzip", "x-gzip":
+		raw := &countingReader{r: resp.Body}
+		zr, err := gzip.NewReader(raw)
+		if err != nil {
+			return nil, nil, &ErrDiscoveryInvalidDocument{
+				Reason: "discovery document has invalid gzip encoding",
+				Err:    err,
+			}
+		}
+		return zr, raw, nil
+	default:
+		return nil, nil, &ErrDiscoveryInvalidDocument{
+			Reason: fmt.Sprintf("discovery URL returned an unsupported Content-Encoding %q", encoding),
+		}
+	}
+}
+
+// countingReader is an [io.Reader] that counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,114 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package disco
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/opentofu/svchost"
+)
+
+func gzipBytes(t *testing.T, src []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(src); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDiscoverGzip(t *testing.T) {
+	doc := []byte(`{"thingy.v1":"http://example.com/foo"}`)
+	compressed := gzipBytes(t, doc)
+	portStr, cleanup := testServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept-Encoding") != "gzip" {
+			t.Errorf("wrong Accept-Encoding %q; want gzip", r.Header.Get("Accept-Encoding"))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(compressed)
+	})
+	defer cleanup()
+
+	var gotCompressed, gotUncompressed int64
+	ctx := ContextWithDiscoTrace(t.Context(), &DiscoTrace{
+		DocumentDecompressed: func(_ context.Context, _ svchost.Hostname, compressedSize, uncompressedSize int64) {
+			gotCompressed, gotUncompressed = compressedSize, uncompressedSize
+		},
+	})
+	d := New(WithHTTPClient(testClient))
+	host, err := d.Discover(ctx, svchost.Hostname("localhost"+portStr))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := host.ServiceURL("thingy.v1"); err != nil {
+		t.Errorf("unexpected service URL error: %s", err)
+	}
+	if got, want := gotCompressed, int64(len(compressed)); got != want {
+		t.Errorf("wrong compressed size %d; want %d", got, want)
+	}
+	if got, want := gotUncompressed, int64(len(doc)); got != want {
+		t.Errorf("wrong uncompressed size %d; want %d", got, want)
+	}
+}
+
+func TestDiscoverGzipTooLarge(t *testing.T) {
+	// The document is highly compressible, so its compressed size is well
+	// within the limit even though its decompressed size is not.
+	doc := []byte(`{"thingy.v1":"http://example.com/` + strings.Repeat("a", 4096) + `"}`)
+	compressed := gzipBytes(t, doc)
+	portStr, cleanup := testServer(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(compressed)
+	})
+	defer cleanup()
+
+	d := New(WithHTTPClient(testClient), WithMaxDocSize(1024))
+	_, err := d.Discover(t.Context(), svchost.Hostname("localhost"+portStr))
+	var tooLarge *ErrDiscoveryDocTooLarge
+	if !errors.As(err, &tooLarge) {
+		t.Fatalf("wrong error %v; want ErrDiscoveryDocTooLarge", err)
+	}
+	if len(compressed) >= 1024 {
+		t.Fatalf("test document compressed to %d bytes, which doesn't test the decompressed limit", len(compressed))
+	}
+}
+
+func TestDiscoverContentEncodingErrors(t *testing.T) {
+	tests := map[string]struct {
+		encoding string
+		body     []byte
+	}{
+		"unsupported encoding": {"br", []byte(`{}`)},
+		"invalid gzip":         {"gzip", []byte(`{}`)},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			portStr, cleanup := testServer(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("Content-Encoding", test.encoding)
+				w.Write(test.body)
+			})
+			defer cleanup()
+
+			d := New(WithHTTPClient(testClient))
+			_, err := d.Discover(t.Context(), svchost.Hostname("localhost"+portStr))
+			var invalidErr *ErrDiscoveryInvalidDocument
+			if !errors.As(err, &invalidErr) {
+				t.Errorf("wrong error %v; want ErrDiscoveryInvalidDocument", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package disco
+
+import (
+	"errors"
+	"sync"
+)
+
+var (
+	// defaultMu protects all of the other default* variables below.
+	defaultMu         sync.Mutex
+	defaultDisco      *Disco
+	defaultOptions    []DiscoOption
+	defaultConfigured bool
+)
+
+// Default returns a process-wide shared [Disco] object, creating it on the
+// first call using any options previously registered with
+// [SetDefaultOptions].
+//
+// This is intended for small programs and utilities that would otherwise
+// construct a new Disco for each operation and so lose the benefit of its
+// cache of previous discovery results. Larger programs should typically
+// construct their own Disco using [New] and pass it explicitly to the
+// components that need it.
+//
+// It is safe to call Default concurrently from multiple goroutines.
+func Default() *Disco {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+
+	if defaultDisco == nil {
+		defaultDisco = New(defaultOptions...)
+	}
+	return defaultDisco
+}
+
+// SetDefaultOptions registers the options to use when [Default] initializes
+// the shared Disco object.
+//
+// The default options may be set only once, and only before the first call
+// to [Default]. SetDefaultOptions returns an error if either of those
+// requirements is not met, in which case the given options are ignored.
+func SetDefaultOptions(options ...DiscoOption) error {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+
+	if defaultDisco != nil {
+		return errors.New("default Disco object has already been initialized")
+	}
+	if defaultConfigured {
+		return errors.New("default Disco options have already been set")
+	}
+	defaultOptions = options
+	defaultConfigured = true
+	return nil
+}
+
+// resetDefault discards the shared Disco object and any registered default
+// options, so that tests can exercise the initialization behavior more
+// than once in the same process.
+func resetDefault() {
+	defaultMu.Lock()
+	defaultDisco = nil
+	defaultOptions = nil
+	defaultConfigured = false
+	defaultMu.Unlock()
+}
@@ -0,0 +1,63 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package disco
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+)
+
+func TestDefault(t *testing.T) {
+	t.Cleanup(resetDefault)
+
+	t.Run("concurrent initialization", func(t *testing.T) {
+		resetDefault()
+
+		const n = 16
+		results := make([]*Disco, n)
+		var wg sync.WaitGroup
+		for i := range n {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				results[i] = Default()
+			}()
+		}
+		wg.Wait()
+
+		for i, got := range results {
+			if got == nil {
+				t.Fatalf("result %d is nil", i)
+			}
+			if got != results[0] {
+				t.Errorf("result %d is a different object than result 0", i)
+			}
+		}
+	})
+	t.Run("with options", func(t *testing.T) {
+		resetDefault()
+
+		client := &http.Client{}
+		if err := SetDefaultOptions(WithHTTPClient(client)); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if err := SetDefaultOptions(); err == nil {
+			t.Error("second SetDefaultOptions call succeeded; want error")
+		}
+
+		d := Default()
+		if d.httpClient != client {
+			t.Error("default Disco does not use the configured HTTP client")
+		}
+	})
+	t.Run("options after initialization", func(t *testing.T) {
+		resetDefault()
+
+		Default()
+		if err := SetDefaultOptions(); err == nil {
+			t.Error("SetDefaultOptions succeeded after initialization; want error")
+		}
+	})
+}
@@ -0,0 +1,112 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package disco
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// ServiceChangeKind describes how a service differs between two [Host]
+// objects, as reported in [ServiceChange].
+type ServiceChangeKind int
+
+const (
+	// ServiceAdded means that the service is present only in the newer host.
+	ServiceAdded ServiceChangeKind = iota + 1
+
+	// ServiceRemoved means that the service is present only in the older host.
+	ServiceRemoved
+
+	// ServiceUpdated means that the service is present in both hosts but its
+	// definition differs.
+	ServiceUpdated
+)
+
+func (k ServiceChangeKind) String() string {
+	switch k {
+	case ServiceAdded:
+		return "added"
+	case ServiceRemoved:
+		return "removed"
+	case ServiceUpdated:
+		return "updated"
+	default:
+		return "unknown"
+	}
+}
+
+// ServiceChange describes a single service whose definition differs between
+// two [Host] objects, as returned by [Host.Diff].
+type ServiceChange struct {
+	// ID is the service identifier, such as "modules.v1".
+	ID string
+
+	Kind ServiceChangeKind
+
+	// Old and New are the service definitions exactly as they appeared in
+	// each discovery document, which is usually a URL string but may be
+	// some other JSON value for services that need more than just a URL.
+	//
+	// Old is nil for ServiceAdded and New is nil for ServiceRemoved.
+	Old, New any
+}
+
+// Diff compares the services of the receiver with those of another host,
+// which is typically a more recent discovery result for the same hostname,
+// and returns a description of each service that differs, in order of
+// service ID.
+//
+// Relative service URLs are resolved against each host's discovery URL
+// before comparing them, so a change of discovery URL, such as due to a
+// redirect, can cause otherwise-identical services to be reported as
+// updated. Either host may be nil, which is treated as having no services.
+func (h *Host) Diff(other *Host) []ServiceChange {
+	var oldServices, newServices map[string]any
+	if h != nil {
+		oldServices = h.services
+	}
+	if other != nil {
+		newServices = other.services
+	}
+
+	var ret []ServiceChange
+	for id, oldDef := range oldServices {
+		newDef, exists := newServices[id]
+		switch {
+		case !exists:
+			ret = append(ret, ServiceChange{ID: id, Kind: ServiceRemoved, Old: oldDef})
+		case !reflect.DeepEqual(h.resolvedService(oldDef), other.resolvedService(newDef)):
+			ret = append(ret, ServiceChange{ID: id, Kind: ServiceUpdated, Old: oldDef, New: newDef})
+		}
+	}
+	for id, newDef := range newServices {
+		if _, exists := oldServices[id]; !exists {
+			ret = append(ret, ServiceChange{ID: id, Kind: ServiceAdded, New: newDef})
+		}
+	}
+	// The document metadata is not a service.
+	ret = slices.DeleteFunc(ret, func(c ServiceChange) bool {
+		return c.ID == DocumentMetaKey
+	})
+	slices.SortFunc(ret, func(a, b ServiceChange) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+	return ret
+}
+
+// resolvedService returns the given service definition with any URL string
+// resolved to an absolute URL, for comparison purposes only.
+func (h *Host) resolvedService(def any) any {
+	urlStr, ok := def.(string)
+	if !ok {
+		return def
+	}
+	u, err := h.parseURL(urlStr)
+	if err != nil {
+		return def
+	}
+	return u.String()
+}
@@ -0,0 +1,51 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package disco
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestHostDiff(t *testing.T) {
+	oldURL, _ := url.Parse("https://example.com/.well-known/terraform.json")
+	newURL, _ := url.Parse("https://example.net/.well-known/terraform.json")
+	oldHost := &Host{
+		discoURL: oldURL,
+		services: map[string]any{
+			"modules.v1":   "https://example.com/modules/",
+			"providers.v1": "/providers/",
+			"relative.v1":  "https://example.net/relative/",
+			"removed.v1":   "https://example.com/removed/",
+		},
+	}
+	newHost := &Host{
+		discoURL: newURL,
+		services: map[string]any{
+			"modules.v1":   "https://example.com/modules/",
+			"providers.v1": "https://example.com/providers/v2/",
+			"relative.v1":  "/relative/",
+			"added.v1":     "https://example.com/added/",
+		},
+	}
+
+	got := oldHost.Diff(newHost)
+	want := []ServiceChange{
+		{ID: "added.v1", Kind: ServiceAdded, New: "https://example.com/added/"},
+		{ID: "providers.v1", Kind: ServiceUpdated, Old: "/providers/", New: "https://example.com/providers/v2/"},
+		{ID: "removed.v1", Kind: ServiceRemoved, Old: "https://example.com/removed/"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("wrong result\n%s", diff)
+	}
+
+	if got := oldHost.Diff(oldHost); len(got) != 0 {
+		t.Errorf("unexpected changes comparing host with itself: %#v", got)
+	}
+	if got := (*Host)(nil).Diff(newHost); len(got) != len(newHost.services) {
+		t.Errorf("wrong number of changes from nil host %d; want %d", len(got), len(newHost.services))
+	}
+}
@@ -17,17 +17,25 @@ package disco
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"mime"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
+	"os"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
 	svchost "github.com/opentofu/svchost"
+	"github.com/opentofu/svchost/internal/clock"
+	"github.com/opentofu/svchost/internal/events"
+	"github.com/opentofu/svchost/internal/fips"
+	"github.com/opentofu/svchost/internal/transport"
 	"github.com/opentofu/svchost/svcauth"
 )
 
@@ -42,27 +50,145 @@ const (
 	maxRedirects = 3
 
 	// Arbitrary-but-small time limit to prevent UI "hangs" during discovery.
-	// This is used only when the caller doesn't provide their own HTTP client.
+	// This is used only when the caller doesn't provide their own HTTP client,
+	// unless overridden using WithDefaultTimeout.
 	discoTimeout = 11 * time.Second
 
 	// 1MB - to prevent abusive services from using loads of our memory.
+	// This can be overridden using WithMaxDocSize.
 	maxDiscoDocBytes = 1 * 1024 * 1024
 )
 
+// The operation descriptions used in the Op field of [svchost.HostError]
+// values returned by this package, which other implementations of
+// [Discoverer] can also use so that their errors read the same way.
+const (
+	OpDiscover     = "discover services for"
+	OpServiceURL   = "find service URL for"
+	OpCredentials  = "get credentials for"
+	OpCheckService = "check service on"
+	OpPrimeHost    = "prime discovery cache for"
+)
+
 // Disco is the main type in this package, which allows discovery on given
 // hostnames and caches the results by hostname to avoid repeated requests
 // for the same information.
 type Disco struct {
 	// must lock "mu" while interacting with these maps
-	aliases   map[svchost.Hostname]svchost.Hostname
-	hostCache map[svchost.Hostname]*Host
-	mu        sync.Mutex
+	aliases       map[svchost.Hostname]svchost.Hostname
+	hostCache     map[svchost.Hostname]*Host
+	negativeCache map[svchost.Hostname]negativeCacheEntry
+	// revalidating records the hostnames whose cache entries are being
+	// refreshed in the background because of WithStaleWhileRevalidate.
+	revalidating map[svchost.Hostname]*revalidation
+	discoPaths   map[svchost.Hostname]string
+	// defaultPaths is set by WithDiscoveryPaths, and is nil if discovery
+	// uses only the standard path.
+	defaultPaths []string
+	hostHeaders  map[svchost.Hostname]http.Header
+	mu           sync.Mutex
 
 	credsSrc svcauth.CredentialsSource
 
-	httpClient *http.Client
+	// httpClient is the client used for discovery requests, and
+	// ownHTTPClient records whether the caller provided it using
+	// WithHTTPClient.
+	httpClient    *http.Client
+	ownHTTPClient bool
+
+	// transport describes customizations for the default HTTP client, used
+	// only when the caller doesn't provide a client using WithHTTPClient.
+	// transportOptions records the names of the options that populated it,
+	// so that we can report a conflict with WithHTTPClient.
+	// transportWrappers are set by WithTransportWrapper.
+	transport         transport.Config
+	transportOptions  []string
+	transportWrappers []func(http.RoundTripper) http.RoundTripper
+
+	// proxyOption is the name of the option that set transport.Proxy, so
+	// that we can report a conflict with another option that sets it.
+	proxyOption string
+
+	protocolVersions []int
+
+	// timeout is the time limit for the default HTTP client, and
+	// maxDocBytes is the size limit for discovery documents, where zero
+	// means no limit. These are set by WithDefaultTimeout and
+	// WithMaxDocSize respectively.
+	timeout     time.Duration
+	maxDocBytes int64
+
+	// extraMediaTypes is set by WithAcceptedMediaTypes, and lists the
+	// media types accepted for discovery documents in addition to
+	// defaultMediaType.
+	extraMediaTypes []string
+
+	// userAgentProduct and userAgentComment customize the User-Agent header
+	// sent with discovery requests, as described by [Disco.userAgent].
+	userAgentProduct string
+	userAgentComment string
+
+	hostPolicy func(svchost.Hostname) error
+
+	// redirectPolicy is set by WithRedirectPolicy, and hasRedirectPolicy
+	// records whether it was, since the zero value is also a valid policy.
+	redirectPolicy    RedirectPolicy
+	hasRedirectPolicy bool
+
+	// serviceURLPolicy is set by WithServiceURLPolicy.
+	serviceURLPolicy *ServiceURLPolicy
+
+	// dnsResolver is set by WithDNSHints.
+	dnsResolver DNSResolver
+
+	// insecureHosts is non-nil if WithInsecureHosts was used, even if
+	// no hosts were given.
+	insecureHosts map[svchost.Hostname]struct{}
+
+	verifier        ResponseVerifier
+	verifierHeaders []string
+	validators      []DocumentValidator
+	requestMutators []RequestMutator
+
+	unknownOAuthFields UnknownOAuthFieldsFunc
+
+	events events.Bus[HostEvent]
+
+	cacheStore    DiscoCacheStore
+	cacheStoreTTL time.Duration
+
+	// negativeCacheTTL is set by WithNegativeCacheTTL, and is zero if
+	// failed discovery results are not cached.
+	negativeCacheTTL time.Duration
+
+	// staleAfter is set by WithStaleWhileRevalidate, and is zero if cached
+	// results are never refreshed in the background.
+	staleAfter time.Duration
+
+	// clock is used for cache expiry and retry delays, and is replaced by
+	// WithClock in tests.
+	clock Clock
+
+	retryPolicy *RetryPolicy
+
+	// inflight tracks in-progress discovery requests when coalesceRequests
+	// is set, and must be accessed only with "mu" locked.
+	coalesceRequests bool
+	inflight         map[svchost.Hostname]*inflightDiscovery
+}
+
+// Discoverer is the subset of the [Disco] API that most components need in
+// order to find and authenticate to services, so that those components can
+// accept this interface instead of the concrete type and be given a fake
+// implementation in tests.
+type Discoverer interface {
+	Discover(ctx context.Context, hostname svchost.Hostname) (*Host, error)
+	DiscoverServiceURL(ctx context.Context, hostname svchost.Hostname, serviceID string) (*url.URL, error)
+	CredentialsForHost(ctx context.Context, hostname svchost.Hostname) (svcauth.HostCredentials, error)
 }
 
+var _ Discoverer = (*Disco)(nil)
+
 // ErrServiceDiscoveryNetworkRequest represents the error that occurs when
 // the service discovery fails for an unknown network problem.
 type ErrServiceDiscoveryNetworkRequest struct {
@@ -82,6 +208,17 @@ func (e ErrServiceDiscoveryNetworkRequest) Unwrap() error {
 	return e.err
 }
 
+// ErrPrivateAddress is wrapped by the errors returned when discovery is
+// refused because a host resolved to a private network address while
+// [WithPrivateAddressProtection] is in effect.
+var ErrPrivateAddress = transport.ErrPrivateAddress
+
+// Clock tells the current time and waits for time to pass, for use with
+// [WithClock]. Its Now method returns the current time, and its After method
+// returns a channel that receives the current time once at least the given
+// duration has passed, in the same way as [time.After].
+type Clock = clock.Clock
+
 // New returns a new initialized discovery object initialized with the
 // given options.
 //
@@ -92,28 +229,133 @@ func (e ErrServiceDiscoveryNetworkRequest) Unwrap() error {
 // Use [WithCredentials] to specify an [svcauth.CredentialsSource] that can
 // provide credentials to use when performing service discovery. If none is
 // provided then all requests are made anonymously.
+//
+// New tolerates invalid or conflicting options, applying them on a best-effort
+// basis. Use [NewWithErrors] instead to detect misconfiguration up front.
 func New(options ...DiscoOption) *Disco {
+	ret, _ := NewWithErrors(options...)
+	return ret
+}
+
+// NewWithErrors is like [New] except that it validates the given options,
+// both individually and in combination, and returns an error describing
+// any problems.
+//
+// If the returned error is non-nil then the returned Disco object is still
+// usable, but its behavior is unlikely to match the caller's intent.
+func NewWithErrors(options ...DiscoOption) (*Disco, error) {
 	ret := &Disco{
-		aliases:   make(map[svchost.Hostname]svchost.Hostname),
-		hostCache: make(map[svchost.Hostname]*Host),
+		aliases:          make(map[svchost.Hostname]svchost.Hostname),
+		hostCache:        make(map[svchost.Hostname]*Host),
+		negativeCache:    make(map[svchost.Hostname]negativeCacheEntry),
+		revalidating:     make(map[svchost.Hostname]*revalidation),
+		discoPaths:       make(map[svchost.Hostname]string),
+		hostHeaders:      make(map[svchost.Hostname]http.Header),
+		inflight:         make(map[svchost.Hostname]*inflightDiscovery),
+		protocolVersions: defaultProtocolVersions,
+		timeout:          discoTimeout,
+		maxDocBytes:      maxDiscoDocBytes,
+		clock:            clock.Real,
 	}
+	var errs []error
 	for _, opt := range options {
-		opt.applyOption(ret)
+		if err := opt.applyOption(ret); err != nil {
+			errs = append(errs, err)
+		}
 	}
 
-	if ret.httpClient == nil {
-		ret.httpClient = &http.Client{
-			Timeout: discoTimeout,
-			CheckRedirect: func(req *http.Request, via []*http.Request) error {
-				if len(via) > maxRedirects {
-					return errors.New("too many redirects") // this error will never actually be seen
+	if ret.httpClient != nil {
+		ret.ownHTTPClient = true
+		for _, name := range ret.transportOptions {
+			errs = append(errs, fmt.Errorf("%s cannot be used with WithHTTPClient, because it customizes the default HTTP client", name))
+		}
+		// The caller's client can't enforce some options, and New ignores
+		// the errors above, so in that case we must refuse to make
+		// requests at all rather than make them without the protection.
+		var refuseErr error
+		if ret.transport.DenyPrivateAddresses {
+			refuseErr = errors.New("WithPrivateAddressProtection cannot be enforced with the client given in WithHTTPClient")
+		}
+		if fips.Enabled(ret.transport.FIPS) {
+			if err := fips.CheckHTTPClient(ret.httpClient); err != nil {
+				err = fmt.Errorf("HTTP client is not compatible with FIPS mode: %w", err)
+				errs = append(errs, err)
+				refuseErr = errors.Join(refuseErr, err)
+			}
+		}
+		if ret.hasRedirectPolicy {
+			// We apply the policy to a copy of the client so that we
+			// don't modify the caller's object.
+			client := *ret.httpClient
+			client.CheckRedirect = ret.redirectPolicy.checkRedirect(client.CheckRedirect)
+			ret.httpClient = &client
+		}
+		if refuseErr != nil {
+			ret.httpClient = &http.Client{Transport: refusingRoundTripper{err: refuseErr}}
+		}
+	} else {
+		if fips.Enabled(ret.transport.FIPS) {
+			for hostname, cfg := range ret.transport.HostTLSConfigs {
+				if err := fips.CheckTLSConfig(fips.RestrictTLSConfig(cfg)); err != nil {
+					errs = append(errs, fmt.Errorf("TLS configuration for %s is not compatible with FIPS mode: %w", hostname.ForDisplay(), err))
 				}
-				return nil
-			},
+			}
 		}
+		ret.httpClient = ret.defaultHTTPClient()
 	}
 
-	return ret
+	return ret, errors.Join(errs...)
+}
+
+// refusingRoundTripper is an [http.RoundTripper] that fails every request
+// with the given error, used in place of a client given in [WithHTTPClient]
+// that can't honor the other options.
+type refusingRoundTripper struct {
+	err error
+}
+
+func (rt refusingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	return nil, rt.err
+}
+
+// defaultHTTPClient returns the HTTP client to use when the caller doesn't
+// provide one using [WithHTTPClient].
+func (d *Disco) defaultHTTPClient() *http.Client {
+	rt := transport.NewRoundTripper(&d.transport)
+	for _, wrap := range d.transportWrappers {
+		rt = wrap(rt)
+	}
+	return &http.Client{
+		Transport:     rt,
+		Timeout:       d.timeout,
+		CheckRedirect: d.redirectPolicy.checkRedirect(nil),
+	}
+}
+
+// DefaultHTTPClient returns a new HTTP client configured in the same way as
+// the one that [New] creates for discovery requests when given the same
+// options, for callers that need to make their own requests to a host, or
+// want to customize the client further and pass it to [WithHTTPClient],
+// without losing the default timeout, redirect limit, and other hardening.
+//
+// All of the options that customize the default HTTP client are supported,
+// such as [WithProxyFunc], [WithHostTLSConfig] for trusting a private
+// certificate authority, [WithRedirectPolicy], and [WithTransportWrapper].
+// Options that don't affect the HTTP client are accepted but have no effect
+// on the result. It returns an error if any of the options are invalid, or if
+// they include [WithHTTPClient].
+func DefaultHTTPClient(options ...DiscoOption) (*http.Client, error) {
+	d, err := NewWithErrors(options...)
+	if err != nil {
+		return nil, err
+	}
+	if d.ownHTTPClient {
+		return nil, errors.New("DefaultHTTPClient cannot be used with WithHTTPClient")
+	}
+	return d.httpClient, nil
 }
 
 // SetCredentialsSource changes the credentials source that will be used to
@@ -142,10 +384,32 @@ func (d *Disco) CredentialsForHost(ctx context.Context, hostname svchost.Hostnam
 	}
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	if aliasedHost, aliasExists := d.aliases[hostname]; aliasExists {
-		hostname = aliasedHost
+	target, err := d.resolveAliasLocked(hostname)
+	if err != nil {
+		return nil, svchost.WrapHostError(hostname, OpCredentials, err)
+	}
+	creds, err := d.credsSrc.ForHost(ctx, target)
+	if err != nil {
+		return nil, svchost.WrapHostError(hostname, OpCredentials, err)
+	}
+	return creds, nil
+}
+
+// credentialsForDiscovery is like [Disco.CredentialsForHost] but also reports
+// the lookup to the [DiscoTrace] in the given context, if any.
+func (d *Disco) credentialsForDiscovery(ctx context.Context, hostname svchost.Hostname) (svcauth.HostCredentials, error) {
+	if d.credsSrc == nil {
+		return nil, nil
+	}
+	trace := discoTraceFromContext(ctx)
+	ctx = trace.credentialsLookupStart(ctx, hostname)
+	creds, err := d.CredentialsForHost(ctx, hostname)
+	if err != nil {
+		trace.credentialsLookupFailure(ctx, hostname, err)
+		return nil, err
 	}
-	return d.credsSrc.ForHost(ctx, hostname)
+	trace.credentialsLookupSuccess(ctx, hostname, creds != nil)
+	return creds, nil
 }
 
 // ForceHostServices provides a pre-defined set of services for a given
@@ -157,37 +421,199 @@ func (d *Disco) CredentialsForHost(ctx context.Context, hostname svchost.Hostnam
 // the initial discovery URL that would have been used for network-based
 // discovery, yielding the same results as if the given map were published
 // at the host's default discovery URL, though using absolute URLs is strongly
-// recommended to make the configured behavior more explicit.
+// recommended to make the configured behavior more explicit. Use
+// [Disco.ForceHostServicesAt] to resolve them against a different URL.
 func (d *Disco) ForceHostServices(hostname svchost.Hostname, services map[string]any) {
+	d.forceHostServices(hostname, d.discoveryURL(hostname), services)
+}
+
+// ForceHostServicesAt is like [Disco.ForceHostServices] except that relative
+// URLs in the given services are resolved against the given base URL rather
+// than the host's usual discovery URL, as if the services were published in
+// a discovery document at that URL. This is useful for hosts whose services
+// are served from a different port or path than discovery would use, such as
+// behind some internal gateways.
+//
+// The base URL must be absolute. It returns an error if it isn't, in which
+// case the receiver is not modified.
+func (d *Disco) ForceHostServicesAt(hostname svchost.Hostname, baseURL *url.URL, services map[string]any) error {
+	if baseURL == nil || !baseURL.IsAbs() || baseURL.Host == "" {
+		return fmt.Errorf("base URL for forced services of %s must be an absolute URL", hostname.ForDisplay())
+	}
+	u := *baseURL
+	d.forceHostServices(hostname, &u, services)
+	return nil
+}
+
+// forceHostServices is the common implementation of
+// [Disco.ForceHostServices] and [Disco.ForceHostServicesAt].
+func (d *Disco) forceHostServices(hostname svchost.Hostname, discoURL *url.URL, services map[string]any) {
 	if services == nil {
 		services = map[string]any{}
 	}
 
+	host := &Host{
+		discoURL:        discoURL,
+		hostname:        hostname.ForDisplay(),
+		services:        services,
+		protocolVersion: ProtocolVersion1,
+	}
+	host.unknownOAuthFields = d.unknownOAuthFieldsFor(hostname)
 	d.mu.Lock()
-	d.hostCache[hostname] = &Host{
-		discoURL: &url.URL{
-			Scheme: "https",
-			Host:   string(hostname),
-			Path:   discoPath,
-		},
-		hostname: hostname.ForDisplay(),
-		services: services,
+	d.hostCache[hostname] = host
+	delete(d.negativeCache, hostname)
+	d.mu.Unlock()
+	d.publishCached(hostname, host)
+}
+
+// ForceHostServicesFromFile is like [Disco.ForceHostServices] except that it
+// reads the services from the given file, which must contain a JSON object
+// in the same format as a discovery document, so that services can be
+// configured entirely from local files in environments without network
+// access to the host.
+//
+// Relative URLs in the file are resolved against the host's usual discovery
+// URL, not against the location of the file.
+func (d *Disco) ForceHostServicesFromFile(hostname svchost.Hostname, filename string) error {
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		return err
 	}
+	services, err := ParseServicesDoc(src)
+	if err != nil {
+		return fmt.Errorf("invalid services document in %s: %w", filename, err)
+	}
+	if _, err := parseDocumentVersion(services); err != nil {
+		return fmt.Errorf("invalid services document in %s: %w", filename, err)
+	}
+	d.ForceHostServices(hostname, services)
+	return nil
+}
+
+// CacheHost adds the given discovery result for the given hostname to the
+// in-memory cache, replacing any existing result, so that later calls to
+// [Disco.Discover] return it without making a discovery request.
+//
+// This is for restoring results saved from an earlier discovery, such as
+// by using [Host.MarshalJSON] or [NewHost]. Use [Disco.ForceHostServices]
+// instead to configure services that are not based on a discovery document,
+// or [Disco.PrimeHost] to install a raw discovery document.
+func (d *Disco) CacheHost(hostname svchost.Hostname, host *Host) {
+	d.mu.Lock()
+	d.hostCache[hostname] = host
+	delete(d.negativeCache, hostname)
 	d.mu.Unlock()
+	d.publishCached(hostname, host)
 }
 
 // Alias accepts an alias and target Hostname. When service discovery is performed
 // or credentials are requested for the alias hostname, the target will be consulted instead.
+//
+// The target may itself be an alias, in which case its own target is
+// consulted, and so on. Use [Disco.ResolveAlias] to find the hostname that
+// will be consulted for an alias.
 func (d *Disco) Alias(alias, target svchost.Hostname) {
 	d.mu.Lock()
 	d.aliases[alias] = target
 	d.mu.Unlock()
 }
 
+// SetDiscoveryPath overrides the path of the discovery document for the
+// given hostname, for hosts that serve it somewhere other than the standard
+// location, such as behind a gateway that reserves the standard path.
+//
+// The path must be absolute and may include a query string. Any cached
+// result for the hostname is discarded so that the next discovery uses the
+// new path. When the hostname is the target of an alias, the path applies
+// to discovery for the alias too.
+//
+// The path replaces the whole list of paths given in [WithDiscoveryPaths],
+// so discovery for the hostname doesn't fall back to any other path.
+func (d *Disco) SetDiscoveryPath(hostname svchost.Hostname, path string) error {
+	if err := validateDiscoveryPath(path); err != nil {
+		return err
+	}
+	d.mu.Lock()
+	d.discoPaths[hostname] = path
+	forgotten := d.forgetInternal(hostname)
+	d.mu.Unlock()
+	if forgotten {
+		d.publishForgotten(hostname)
+	}
+	return nil
+}
+
+// validateDiscoveryPath returns an error if the given path is not suitable
+// for use as the path of a discovery document.
+func validateDiscoveryPath(path string) error {
+	u, err := url.Parse(path)
+	if err != nil || u.Scheme != "" || u.Host != "" || !strings.HasPrefix(u.Path, "/") {
+		return fmt.Errorf("invalid discovery path %q: must be an absolute path", path)
+	}
+	return nil
+}
+
+// discoveryURL returns the preferred URL of the discovery document for the
+// given hostname, which is the first of the URLs returned by
+// [Disco.discoveryURLs].
+func (d *Disco) discoveryURL(hostname svchost.Hostname) *url.URL {
+	return d.discoveryURLs(hostname)[0]
+}
+
+// discoveryURLs returns the URLs to try, in order, when looking for the
+// discovery document for the given hostname.
+func (d *Disco) discoveryURLs(hostname svchost.Hostname) []*url.URL {
+	d.mu.Lock()
+	path, ok := d.discoPaths[hostname]
+	d.mu.Unlock()
+	paths := []string{path}
+	if !ok {
+		paths = d.defaultPaths
+		if len(paths) == 0 {
+			paths = []string{discoPath}
+		}
+	}
+
+	ret := make([]*url.URL, len(paths))
+	for i, path := range paths {
+		// The paths were already validated by SetDiscoveryPath or
+		// WithDiscoveryPaths, and the standard path is valid, so this
+		// can't fail.
+		u, _ := url.Parse(path)
+		u.Scheme = "https"
+		if d.insecureHost(hostname) {
+			u.Scheme = "http"
+		}
+		u.Host = hostname.String()
+		ret[i] = u
+	}
+	return ret
+}
+
+// insecureHost returns true if discovery for the given hostname may use
+// plain HTTP, as configured by [WithInsecureHosts].
+func (d *Disco) insecureHost(hostname svchost.Hostname) bool {
+	if d.insecureHosts == nil {
+		return false
+	}
+	if _, ok := d.insecureHosts[hostname]; ok {
+		return true
+	}
+	if addr, ok := hostname.IPAddress(); ok {
+		return addr.IsLoopback()
+	}
+	return hostname.WithoutPort() == "localhost"
+}
+
 // Discover runs the discovery protocol against the given hostname (which must
 // already have been validated and prepared with svchost.ForComparison) and
 // returns an object describing the services available at that host.
 //
+// The hostname may be an IP address literal, including an IPv6 address in
+// brackets with an optional port number such as "[::1]:8443", in which case
+// the discovery request is sent to that address. [svchost.ForAddrPort]
+// returns such a hostname for a given network address.
+//
 // If a given hostname supports no OpenTofu services at all, a non-nil but
 // empty Host object is returned. When giving feedback to the end user about
 // such situations, we say "host <name> does not provide a <service> service",
@@ -204,23 +630,113 @@ func (d *Disco) Discover(ctx context.Context, hostname svchost.Hostname) (*Host,
 	// matter because we're already assuming (by caching the results at all)
 	// that a host will generally not vary its results in meaningful ways
 	// between requests made in close time proximity.
+	//
+	// WithRequestCoalescing opts in to sharing a single request between
+	// concurrent callers instead, for callers that expect many concurrent
+	// requests for the same hostname.
 	d.mu.Lock()
 	if host, cached := d.hostCache[hostname]; cached {
+		rv := d.startRevalidationLocked(hostname, host)
 		d.mu.Unlock()
 		trace := discoTraceFromContext(ctx)
 		trace.discoveryHostCached(ctx, hostname)
+		if rv != nil {
+			go d.revalidateInBackground(ctx, hostname, rv)
+		}
 		return host, nil
 	}
 	d.mu.Unlock()
+	if err := d.loadNegative(hostname); err != nil {
+		trace := discoTraceFromContext(ctx)
+		trace.discoveryNegativeCached(ctx, hostname, err)
+		return nil, err
+	}
 
-	host, err := d.discover(ctx, hostname)
+	if d.coalesceRequests {
+		return d.coalesce(ctx, hostname, d.discoverAndCache)
+	}
+	return d.discoverAndCache(ctx, hostname)
+}
+
+// discoverAndCache is the part of [Disco.Discover] that runs when there is
+// no in-memory cached result for the given hostname.
+func (d *Disco) discoverAndCache(ctx context.Context, hostname svchost.Hostname) (*Host, error) {
+	host, stale := d.loadFromStore(ctx, hostname)
+	if host != nil {
+		d.mu.Lock()
+		d.hostCache[hostname] = host
+		d.mu.Unlock()
+		d.publishCached(hostname, host)
+		trace := discoTraceFromContext(ctx)
+		trace.discoveryHostCached(ctx, hostname)
+		return host, nil
+	}
+
+	host, err := d.discover(ctx, hostname, stale)
 	if err != nil {
+		err = svchost.WrapHostError(hostname, OpDiscover, err)
+		d.saveNegative(ctx, hostname, err)
 		return nil, err
 	}
 	d.mu.Lock()
 	d.hostCache[hostname] = host
+	delete(d.negativeCache, hostname)
+	d.mu.Unlock()
+	d.publishCached(hostname, host)
+	d.saveToStore(ctx, hostname, host)
+
+	return host, nil
+}
+
+// Refresh is like [Disco.Discover] except that it always makes a new
+// discovery request, even if there is already a cached result for the given
+// hostname, and replaces any cached result with the new one.
+//
+// If the new result's services differ from those of the cached result then
+// Refresh reports the differences to the ServicesChanged callback of any
+// [DiscoTrace] in the given context. Long-running callers can call this
+// periodically to notice when a host has moved its services elsewhere.
+//
+// If the cached result has an ETag or Last-Modified validator then the new
+// request is conditional, and the cached services are kept if the server
+// reports that they haven't changed.
+//
+// If discovery fails then the previously-cached result, if any, is retained.
+// Refresh ignores any failed result remembered because of
+// [WithNegativeCacheTTL], and replaces it if the new request succeeds.
+func (d *Disco) Refresh(ctx context.Context, hostname svchost.Hostname) (*Host, error) {
+	return d.refresh(ctx, hostname, nil)
+}
+
+// refresh implements [Disco.Refresh]. If rv is not nil then the result is
+// discarded instead of being cached if the hostname is forgotten while the
+// discovery request is in progress.
+func (d *Disco) refresh(ctx context.Context, hostname svchost.Hostname, rv *revalidation) (*Host, error) {
+	d.mu.Lock()
+	cached := d.hostCache[hostname]
+	d.mu.Unlock()
+	host, err := d.discover(ctx, hostname, cached)
+	if err != nil {
+		return nil, svchost.WrapHostError(hostname, OpDiscover, err)
+	}
+	d.mu.Lock()
+	if rv != nil && rv.forgotten {
+		d.mu.Unlock()
+		return host, nil
+	}
+	prev, hadPrev := d.hostCache[hostname]
+	d.hostCache[hostname] = host
+	delete(d.negativeCache, hostname)
 	d.mu.Unlock()
+	d.publishCached(hostname, host)
+	d.saveToStore(ctx, hostname, host)
 
+	if hadPrev {
+		if changes := prev.Diff(host); len(changes) != 0 {
+			trace := discoTraceFromContext(ctx)
+			trace.servicesChanged(ctx, hostname, changes)
+		}
+	}
 	return host, nil
 }
 
@@ -231,7 +747,23 @@ func (d *Disco) DiscoverServiceURL(ctx context.Context, hostname svchost.Hostnam
 	if err != nil {
 		return nil, err
 	}
-	return host.ServiceURL(serviceID)
+	u, err := host.ServiceURL(serviceID)
+	if err != nil {
+		return nil, svchost.WrapHostError(hostname, OpServiceURL, err)
+	}
+	return u, nil
+}
+
+// DiscoverWithTimeout is like [Disco.Discover] except that the discovery
+// process is abandoned with an error if it doesn't complete within the given
+// duration.
+//
+// A result from the cache of previous discovery results is returned
+// immediately, regardless of the timeout.
+func (d *Disco) DiscoverWithTimeout(ctx context.Context, hostname svchost.Hostname, timeout time.Duration) (*Host, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return d.Discover(ctx, hostname)
 }
 
 // discover implements the actual discovery process, with its result cached
@@ -240,58 +772,123 @@ func (d *Disco) DiscoverServiceURL(ctx context.Context, hostname svchost.Hostnam
 // This must be called _without_ d.mu locked. d.mu is there only to protect
 // the integrity of our internal maps, and not to prevent multiple concurrent
 // service discovery lookups even for the same hostname.
-func (d *Disco) discover(ctx context.Context, hostname svchost.Hostname) (host *Host, err error) {
+func (d *Disco) discover(ctx context.Context, hostname svchost.Hostname, stale *Host) (host *Host, err error) {
 	d.mu.Lock()
-	if aliasedHost, aliasExists := d.aliases[hostname]; aliasExists {
-		hostname = aliasedHost
-	}
+	hostname, err = d.resolveAliasLocked(hostname)
 	d.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
 
 	trace := discoTraceFromContext(ctx)
 	ctx = trace.discoveryStart(ctx, hostname)
+	var stats DiscoveryStats
+	start := time.Now()
 	defer func(ctx context.Context) {
 		if err == nil {
 			trace.discoverySuccess(ctx, hostname)
 		} else {
 			trace.discoveryFailure(ctx, hostname, err)
 		}
+		stats.Duration = time.Since(start)
+		trace.discoveryStats(ctx, hostname, stats)
 	}(ctx)
 
-	discoURL := &url.URL{
-		Scheme: "https",
-		Host:   hostname.String(),
-		Path:   discoPath,
-	}
-
-	client := d.httpClient
-	req, err := http.NewRequestWithContext(ctx, "GET", discoURL.String(), nil)
-	if err != nil {
-		// Should not get in here because everything about the request args is under our control.
-		return nil, fmt.Errorf("invalid discovery request: %w", err)
+	if d.hostPolicy != nil {
+		if err := d.hostPolicy(hostname); err != nil {
+			return nil, fmt.Errorf("discovery is not permitted by host policy: %w", err)
+		}
 	}
-	req.Header.Set("Accept", "application/json")
 
-	creds, err := d.CredentialsForHost(ctx, hostname)
+	creds, err := d.credentialsForDiscovery(ctx, hostname)
 	if err != nil {
 		// If we fail to obtain credentials then we just treat it as anonymous
 		creds = nil
 	}
-	if creds != nil {
-		// Update the request to include credentials.
-		creds.PrepareRequest(req)
-	}
+	hostHeader := d.hostHeader(hostname)
 
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, ErrServiceDiscoveryNetworkRequest{err}
+	// We try each of the discovery URLs in turn until one of them returns
+	// something other than 404 Not Found, or we run out of URLs.
+	discoURLs := d.discoveryURLs(hostname)
+	var resp *http.Response
+	var timer *discoveryTimer
+	var revalidating bool
+	for i, discoURL := range discoURLs {
+		discoURL = d.applyDNSHints(ctx, hostname, discoURL)
+		reqCreds := hintedCredentials(hostname, discoURL, creds)
+		client := d.redirectClient(d.httpClient, hostname, reqCreds, hostHeader)
+
+		timer = &discoveryTimer{}
+		reqCtx := httptrace.WithClientTrace(ctx, timer.clientTrace())
+		req, err := http.NewRequestWithContext(reqCtx, "GET", discoURL.String(), nil)
+		if err != nil {
+			// Should not get in here because everything about the request args is under our control.
+			return nil, fmt.Errorf("invalid discovery request: %w", err)
+		}
+		req.Header.Set("Accept", d.acceptHeader())
+		req.Header.Set("User-Agent", d.userAgent())
+		req.Header.Set(AcceptProtocolVersionHeader, formatProtocolVersions(d.protocolVersions))
+		setAcceptEncoding(req)
+		setHostHeader(req, hostHeader)
+		revalidating = setConditionalHeaders(req, stale)
+
+		if reqCreds != nil {
+			// Update the request to include credentials.
+			reqCreds.PrepareRequest(req)
+		}
+
+		if err := d.mutateRequest(req); err != nil {
+			return nil, err
+		}
+
+		resp, err = d.doWithRetry(client, req)
+		if err != nil {
+			return nil, ErrServiceDiscoveryNetworkRequest{err}
+		}
+		if resp.StatusCode != http.StatusNotFound || i == len(discoURLs)-1 {
+			break
+		}
+		resp.Body.Close()
 	}
 	defer resp.Body.Close()
+	stats.StatusCode = resp.StatusCode
+	// Each request made to follow a redirect refers to the response that
+	// caused it, so we can find the redirects by following that chain
+	// backwards from the final request.
+	var redirects []*http.Request
+	for r := resp.Request; r.Response != nil; r = r.Response.Request {
+		redirects = append(redirects, r)
+	}
+	stats.Redirects = len(redirects)
+	for _, r := range slices.Backward(redirects) {
+		trace.redirectFollowed(ctx, hostname, r.Response.Request.URL, r.URL)
+	}
 
 	host = &Host{
 		// Use the discovery URL from resp.Request in
 		// case the client followed any redirects.
-		discoURL: resp.Request.URL,
-		hostname: hostname.ForDisplay(),
+		discoURL:        resp.Request.URL,
+		hostname:        hostname.ForDisplay(),
+		protocolVersion: ProtocolVersion1,
+		responseHeader:  captureHeaders(resp.Header),
+		responseTime:    d.clock.Now(),
+		fetchedAt:       d.clock.Now(),
+		etag:            resp.Header.Get("ETag"),
+		lastModified:    resp.Header.Get("Last-Modified"),
+		discoveryInfo:   timer.result(resp),
+	}
+	host.unknownOAuthFields = d.unknownOAuthFieldsFor(hostname)
+	host.serviceURLPolicy = d.serviceURLPolicyFor(hostname)
+	if t, err := http.ParseTime(resp.Header.Get("Date")); err == nil {
+		host.responseTime = t
+	}
+	if d.cacheStore != nil {
+		host.storeTTL = cacheLifetime(resp.Header, d.cacheStoreTTL)
+	}
+
+	// The server confirmed that the stale result is still current.
+	if resp.StatusCode == http.StatusNotModified && revalidating {
+		return revalidated(stale, host), nil
 	}
 
 	// Return the host without any services.
@@ -300,67 +897,169 @@ func (d *Disco) discover(ctx context.Context, hostname svchost.Hostname) (host *
 	}
 
 	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("failed to request discovery document: %s", resp.Status)
+		return nil, &ErrDiscoveryHTTPStatus{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+		}
+	}
+
+	if raw := resp.Header.Get(ProtocolVersionHeader); raw != "" {
+		version, err := parseProtocolVersion(raw)
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Contains(d.protocolVersions, version) {
+			return nil, &ErrUnsupportedProtocolVersion{
+				Version:   version,
+				Supported: d.protocolVersions,
+			}
+		}
+		host.protocolVersion = version
 	}
 
 	contentType := resp.Header.Get("Content-Type")
 	mediaType, _, err := mime.ParseMediaType(contentType)
 	if err != nil {
-		return nil, fmt.Errorf("discovery URL has a malformed Content-Type %q", contentType)
+		return nil, &ErrDiscoveryInvalidDocument{
+			Reason: fmt.Sprintf("discovery URL has a malformed Content-Type %q", contentType),
+		}
 	}
-	if mediaType != "application/json" {
-		return nil, fmt.Errorf("discovery URL returned an unsupported Content-Type %q", mediaType)
+	if !d.acceptedMediaType(mediaType) {
+		return nil, &ErrDiscoveryInvalidDocument{
+			Reason: fmt.Sprintf("discovery URL returned an unsupported Content-Type %q", mediaType),
+		}
 	}
 
-	// This doesn't catch chunked encoding, because ContentLength is -1 in that case.
-	if resp.ContentLength > maxDiscoDocBytes {
+	// A limit of zero means that WithMaxDocSize disabled the limit.
+	limit := d.maxDocBytes
+
+	// This doesn't catch chunked encoding, because ContentLength is -1 in that
+	// case. For a compressed response this is the compressed size, which is
+	// checked again after decompression below.
+	if limit > 0 && resp.ContentLength > limit {
 		// Size limit here is not a contractual requirement and so we may
 		// adjust it over time if we find a different limit is warranted.
-		return nil, fmt.Errorf(
-			"discovery doc response is too large (got %d bytes; limit %d)",
-			resp.ContentLength, maxDiscoDocBytes,
-		)
+		return nil, &ErrDiscoveryDocTooLarge{
+			Size:  resp.ContentLength,
+			Limit: limit,
+		}
 	}
 
-	// If the response is using chunked encoding then we can't predict its
-	// size, but we'll at least prevent reading the entire thing into memory.
-	lr := io.LimitReader(resp.Body, maxDiscoDocBytes)
+	body, compressed, err := decodedBody(resp)
+	if err != nil {
+		return nil, err
+	}
 
-	servicesBytes, err := io.ReadAll(lr)
+	// If the response is using chunked encoding or is compressed then we
+	// can't predict its size, but we'll at least prevent reading the entire
+	// thing into memory, which also rejects "compression bombs" that expand
+	// to a huge size. We read one byte more than the limit so we can tell
+	// whether the document was truncated.
+	if limit > 0 {
+		body = io.LimitReader(body, limit+1)
+	}
+
+	servicesBytes, err := io.ReadAll(body)
+	stats.ResponseSize = len(servicesBytes)
 	if err != nil {
 		return nil, fmt.Errorf("error reading discovery document body: %v", err)
 	}
+	if limit > 0 && int64(len(servicesBytes)) > limit {
+		return nil, &ErrDiscoveryDocTooLarge{
+			Size:  -1,
+			Limit: limit,
+		}
+	}
+	if compressed != nil {
+		trace.documentDecompressed(ctx, hostname, compressed.n, int64(len(servicesBytes)))
+	}
 
-	var services map[string]any
-	err = json.Unmarshal(servicesBytes, &services)
+	services, err := d.checkDocument(hostname, servicesBytes, resp.Header)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode discovery document as a JSON object: %v", err)
+		return nil, err
 	}
 	host.services = services
+	if d.cacheStore != nil {
+		host.document = servicesBytes
+		host.documentHeader = verifierHeader(resp.Header, d.verifierHeaders)
+	}
 
 	return host, nil
 }
 
+// checkDocument verifies, decodes, and validates the given raw discovery
+// document for the given hostname, using any verifier and validators
+// registered with the receiver. header is the header of the response that
+// contained the document, if any.
+func (d *Disco) checkDocument(hostname svchost.Hostname, doc []byte, header http.Header) (map[string]any, error) {
+	if d.verifier != nil {
+		if err := d.verifier(hostname, doc, verifierHeader(header, d.verifierHeaders)); err != nil {
+			return nil, fmt.Errorf("discovery document failed verification: %w", err)
+		}
+	}
+
+	services, err := ParseServicesDoc(doc)
+	if err != nil {
+		return nil, &ErrDiscoveryInvalidDocument{
+			Reason: "failed to decode discovery document as a JSON object",
+			Err:    err,
+		}
+	}
+	if _, err := parseDocumentVersion(services); err != nil {
+		var unsupported *ErrUnsupportedDocumentVersion
+		if errors.As(err, &unsupported) {
+			return nil, err
+		}
+		return nil, &ErrDiscoveryInvalidDocument{
+			Reason: "invalid discovery document metadata",
+			Err:    err,
+		}
+	}
+	for _, validate := range d.validators {
+		if err := validate(hostname, services); err != nil {
+			return nil, fmt.Errorf("discovery document rejected by validator: %w", err)
+		}
+	}
+	return services, nil
+}
+
 // Forget invalidates any cached record of the given hostname. If the host
 // has no cache entry then this is a no-op.
 func (d *Disco) Forget(hostname svchost.Hostname) {
 	d.mu.Lock()
-	d.forgetInternal(hostname)
+	forgotten := d.forgetInternal(hostname)
 	d.mu.Unlock()
+	d.forgetFromStore(hostname)
+	if forgotten {
+		d.publishForgotten(hostname)
+	}
 }
 
 // forgetInternal is the main implementation of Forget that assumes the
 // caller has already locked d.mu, so this can also be used in other
-// places like ForgetAlias.
-func (d *Disco) forgetInternal(hostname svchost.Hostname) {
+// places like ForgetAlias. It returns true if there was a cache entry
+// to forget.
+func (d *Disco) forgetInternal(hostname svchost.Hostname) bool {
+	_, exists := d.hostCache[hostname]
 	delete(d.hostCache, hostname)
+	delete(d.negativeCache, hostname)
+	if rv, ok := d.revalidating[hostname]; ok {
+		rv.forgotten = true
+	}
+	return exists
 }
 
 // ForgetAll is like Forget, but for all of the hostnames that have cache entries.
 func (d *Disco) ForgetAll() {
 	d.mu.Lock()
+	forgotten := slices.Sorted(maps.Keys(d.hostCache))
 	d.hostCache = make(map[svchost.Hostname]*Host)
+	d.negativeCache = make(map[svchost.Hostname]negativeCacheEntry)
+	for _, rv := range d.revalidating {
+		rv.forgotten = true
+	}
 	d.mu.Unlock()
+	d.publishForgotten(forgotten...)
 }
 
 // ForgetAlias removes a previously aliased hostname as well as its cached entry, if any exist.
@@ -368,6 +1067,10 @@ func (d *Disco) ForgetAll() {
 func (d *Disco) ForgetAlias(alias svchost.Hostname) {
 	d.mu.Lock()
 	delete(d.aliases, alias)
-	d.forgetInternal(alias)
+	forgotten := d.forgetInternal(alias)
 	d.mu.Unlock()
+	d.forgetFromStore(alias)
+	if forgotten {
+		d.publishForgotten(alias)
+	}
 }
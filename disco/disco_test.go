@@ -5,11 +5,20 @@
 package disco
 
 import (
+	"context"
 	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/netip"
 	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -176,6 +185,74 @@ func TestDiscover(t *testing.T) {
 			}
 		}
 	})
+	t.Run("forced services at base URL", func(t *testing.T) {
+		forced := map[string]any{
+			"thingy.v1": "thingy/",
+			"wotsit.v2": "/wotsit/",
+		}
+		base, _ := url.Parse("https://gateway.example.com:8443/registry/discovery.json")
+
+		d := New(WithHTTPClient(testClient))
+		if err := d.ForceHostServicesAt("example.com", base, forced); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		discovered, err := d.Discover(t.Context(), "example.com")
+		if err != nil {
+			t.Fatalf("unexpected discovery error: %s", err)
+		}
+		for id, want := range map[string]string{
+			"thingy.v1": "https://gateway.example.com:8443/registry/thingy/",
+			"wotsit.v2": "https://gateway.example.com:8443/wotsit/",
+		} {
+			gotURL, err := discovered.ServiceURL(id)
+			if err != nil {
+				t.Fatalf("unexpected service URL error for %s: %s", id, err)
+			}
+			if got := gotURL.String(); got != want {
+				t.Errorf("wrong result for %s %q; want %q", id, got, want)
+			}
+		}
+
+		relative, _ := url.Parse("/registry/discovery.json")
+		if err := d.ForceHostServicesAt("example.net", relative, forced); err == nil {
+			t.Error("unexpected success with relative base URL")
+		}
+		if err := d.ForceHostServicesAt("example.net", nil, forced); err == nil {
+			t.Error("unexpected success with nil base URL")
+		}
+	})
+	t.Run("forced services from file", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "services.json")
+		if err := os.WriteFile(filename, []byte(`{"thingy.v1": "/foo"}`), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		d := New(WithHTTPClient(testClient))
+		if err := d.ForceHostServicesFromFile("example.com", filename); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		discovered, err := d.Discover(t.Context(), "example.com")
+		if err != nil {
+			t.Fatalf("unexpected discovery error: %s", err)
+		}
+		gotURL, err := discovered.ServiceURL("thingy.v1")
+		if err != nil {
+			t.Fatalf("unexpected service URL error: %s", err)
+		}
+		if got, want := gotURL.String(), "https://example.com/foo"; got != want {
+			t.Fatalf("wrong result %q; want %q", got, want)
+		}
+
+		if err := os.WriteFile(filename, []byte(`["thingy.v1"]`), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := d.ForceHostServicesFromFile("example.net", filename); err == nil {
+			t.Error("unexpected success with invalid document")
+		}
+		if err := d.ForceHostServicesFromFile("example.net", filename+".missing"); err == nil {
+			t.Error("unexpected success with missing file")
+		}
+	})
 	t.Run("not JSON", func(t *testing.T) {
 		portStr, cleanup := testServer(func(w http.ResponseWriter, r *http.Request) {
 			resp := []byte(`{"thingy.v1": "http://example.com/foo"}`)
@@ -302,11 +379,20 @@ func TestDiscover(t *testing.T) {
 		discovered, err := d.Discover(t.Context(), host)
 
 		// Verify the error is an ErrServiceDiscoveryNetworkRequest
-		_, isDiscoError := err.(ErrServiceDiscoveryNetworkRequest)
-		if !isDiscoError {
+		var discoErr ErrServiceDiscoveryNetworkRequest
+		if !errors.As(err, &discoErr) {
 			t.Fatalf("was not an ErrServiceDiscoveryNetworkRequest, got %T %v", err, err)
 		}
 
+		// ...wrapped in a HostError identifying the host
+		var hostErr *svchost.HostError
+		if !errors.As(err, &hostErr) {
+			t.Fatalf("was not a HostError, got %T %v", err, err)
+		}
+		if hostErr.Host != host {
+			t.Errorf("wrong host in error %q; want %q", hostErr.Host, host)
+		}
+
 		// Returned discovered should be nil (empty).
 		if discovered != nil {
 			t.Errorf("discovered not nil (empty); should be")
@@ -459,3 +545,1074 @@ func testServer(h func(w http.ResponseWriter, r *http.Request)) (portStr string,
 
 	return portStr, cleanup
 }
+
+func TestNewWithErrors(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		d, err := NewWithErrors(WithHTTPClient(testClient))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if d.httpClient != testClient {
+			t.Error("Disco does not use the given HTTP client")
+		}
+	})
+	t.Run("nil client", func(t *testing.T) {
+		d, err := NewWithErrors(WithHTTPClient(nil))
+		if err == nil {
+			t.Fatal("unexpected success; want error")
+		}
+		if d.httpClient == nil {
+			t.Error("Disco has no HTTP client; should have fallen back to the default")
+		}
+	})
+	t.Run("conflicting clients", func(t *testing.T) {
+		_, err := NewWithErrors(WithHTTPClient(testClient), WithHTTPClient(&http.Client{}))
+		if err == nil {
+			t.Fatal("unexpected success; want error")
+		}
+	})
+	t.Run("conflicting credentials", func(t *testing.T) {
+		_, err := NewWithErrors(WithCredentials(svcauth.NoCredentials), WithCredentials(svcauth.NoCredentials))
+		if err == nil {
+			t.Fatal("unexpected success; want error")
+		}
+	})
+}
+
+func TestDiscoverWithTimeout(t *testing.T) {
+	donec := make(chan struct{})
+	portStr, cleanup := testServer(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-donec:
+		case <-r.Context().Done():
+		}
+	})
+	defer cleanup()
+	defer close(donec)
+
+	host, err := svchost.ForComparison("localhost" + portStr)
+	if err != nil {
+		t.Fatalf("test server hostname is invalid: %s", err)
+	}
+
+	d := New(WithHTTPClient(testClient))
+	start := time.Now()
+	_, err = d.DiscoverWithTimeout(t.Context(), host, 50*time.Millisecond)
+	if err == nil {
+		t.Fatal("unexpected success; want error")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("discovery took %s; timeout was not respected", elapsed)
+	}
+}
+
+func TestWithDefaultTimeout(t *testing.T) {
+	d, err := NewWithErrors(WithDefaultTimeout(time.Minute))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got, want := d.httpClient.Timeout, time.Minute; got != want {
+		t.Errorf("wrong timeout %s; want %s", got, want)
+	}
+
+	if _, err := NewWithErrors(WithDefaultTimeout(-time.Second)); err == nil {
+		t.Error("unexpected success with negative timeout")
+	}
+	if _, err := NewWithErrors(WithHTTPClient(testClient), WithDefaultTimeout(time.Minute)); err == nil {
+		t.Error("unexpected success combining WithDefaultTimeout with WithHTTPClient")
+	}
+}
+
+func TestDefaultHTTPClient(t *testing.T) {
+	client, err := DefaultHTTPClient()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got, want := client.Timeout, discoTimeout; got != want {
+		t.Errorf("wrong default timeout %s; want %s", got, want)
+	}
+	if client.CheckRedirect == nil {
+		t.Error("default client has no redirect limit")
+	}
+
+	client, err = DefaultHTTPClient(WithDefaultTimeout(time.Minute))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got, want := client.Timeout, time.Minute; got != want {
+		t.Errorf("wrong timeout %s; want %s", got, want)
+	}
+
+	if _, err := DefaultHTTPClient(WithHTTPClient(testClient)); err == nil {
+		t.Error("unexpected success with WithHTTPClient")
+	}
+	if _, err := DefaultHTTPClient(WithDefaultTimeout(-time.Second)); err == nil {
+		t.Error("unexpected success with invalid option")
+	}
+}
+
+func TestWithTransportWrapper(t *testing.T) {
+	portStr, cleanup := testServer(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+		w.Write([]byte(`{"thingy.v1": "/foo"}`))
+	})
+	defer cleanup()
+
+	var got []string
+	wrapper := func(name string) func(http.RoundTripper) http.RoundTripper {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				got = append(got, name)
+				return next.RoundTrip(req)
+			})
+		}
+	}
+	// The test server uses a self-signed certificate, which we trust by
+	// wrapping the test client's transport instead of the default one.
+	trustTestServer := func(http.RoundTripper) http.RoundTripper {
+		return testClient.Transport
+	}
+	d, err := NewWithErrors(
+		WithTransportWrapper(trustTestServer),
+		WithTransportWrapper(wrapper("inner")),
+		WithTransportWrapper(wrapper("outer")),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := d.Discover(t.Context(), svchost.Hostname("localhost"+portStr)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if want := []string{"outer", "inner"}; !slices.Equal(got, want) {
+		t.Errorf("wrong wrapper calls %q; want %q", got, want)
+	}
+
+	if _, err := NewWithErrors(WithTransportWrapper(nil)); err == nil {
+		t.Error("unexpected success with nil wrapper")
+	}
+	if _, err := NewWithErrors(WithHTTPClient(testClient), WithTransportWrapper(wrapper("x"))); err == nil {
+		t.Error("unexpected success combining WithTransportWrapper with WithHTTPClient")
+	}
+}
+
+// roundTripperFunc is an [http.RoundTripper] implemented by a function.
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestWithMaxDocSize(t *testing.T) {
+	// The document is larger than the default limit, but is still valid.
+	doc := `{"thingy.v1": "/foo", "padding": "` + strings.Repeat("x", maxDiscoDocBytes) + `"}`
+	portStr, cleanup := testServer(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+		w.Write([]byte(doc))
+	})
+	defer cleanup()
+
+	host, err := svchost.ForComparison("localhost" + portStr)
+	if err != nil {
+		t.Fatalf("test server hostname is invalid: %s", err)
+	}
+
+	t.Run("default", func(t *testing.T) {
+		d := New(WithHTTPClient(testClient))
+		_, err := d.Discover(t.Context(), host)
+		var sizeErr *ErrDiscoveryDocTooLarge
+		if !errors.As(err, &sizeErr) || sizeErr.Limit != maxDiscoDocBytes {
+			t.Errorf("wrong error %v; want ErrDiscoveryDocTooLarge with default limit", err)
+		}
+	})
+	t.Run("smaller", func(t *testing.T) {
+		d := New(WithHTTPClient(testClient), WithMaxDocSize(1024))
+		_, err := d.Discover(t.Context(), host)
+		var sizeErr *ErrDiscoveryDocTooLarge
+		if !errors.As(err, &sizeErr) || sizeErr.Limit != 1024 {
+			t.Errorf("wrong error %v; want ErrDiscoveryDocTooLarge with limit 1024", err)
+		}
+	})
+	t.Run("unlimited", func(t *testing.T) {
+		d := New(WithHTTPClient(testClient), WithMaxDocSize(0))
+		discovered, err := d.Discover(t.Context(), host)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if _, err := discovered.ServiceURL("thingy.v1"); err != nil {
+			t.Errorf("unexpected error: %s", err)
+		}
+	})
+}
+
+func TestWithSOCKS5Proxy(t *testing.T) {
+	d, err := NewWithErrors(WithSOCKS5Proxy("localhost:1080", nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	req, _ := http.NewRequest("GET", "https://example.com/", nil)
+	proxyURL, err := d.httpClient.Transport.(*http.Transport).Proxy(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got, want := proxyURL.String(), "socks5://localhost:1080"; got != want {
+		t.Errorf("wrong proxy URL %q; want %q", got, want)
+	}
+
+	_, err = NewWithErrors(WithHTTPClient(testClient), WithSOCKS5Proxy("localhost:1080", nil))
+	if err == nil {
+		t.Error("unexpected success with both WithHTTPClient and WithSOCKS5Proxy; want error")
+	}
+}
+
+func TestWithHostProxy(t *testing.T) {
+	proxyURL, _ := url.Parse("http://proxy.example.com:3128")
+	d, err := NewWithErrors(
+		WithProxyFunc(func(*http.Request) (*url.URL, error) { return nil, nil }),
+		WithHostProxy("registry.example.com", proxyURL),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	proxy := d.httpClient.Transport.(*http.Transport).Proxy
+	req, _ := http.NewRequest("GET", "https://registry.example.com/", nil)
+	got, err := proxy(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got == nil || got.String() != proxyURL.String() {
+		t.Errorf("wrong proxy URL %v; want %s", got, proxyURL)
+	}
+	req, _ = http.NewRequest("GET", "https://example.com/", nil)
+	got, err = proxy(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got != nil {
+		t.Errorf("unexpected proxy URL %s for host without a specific proxy", got)
+	}
+
+	_, err = NewWithErrors(WithHTTPClient(testClient), WithHostProxy("registry.example.com", proxyURL))
+	if err == nil {
+		t.Error("unexpected success with both WithHTTPClient and WithHostProxy; want error")
+	}
+	_, err = NewWithErrors(WithProxyFunc(nil))
+	if err == nil {
+		t.Error("unexpected success with nil proxy function; want error")
+	}
+}
+
+func TestWithInsecureHosts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+		w.Write([]byte(`{"thingy.v1": "/foo"}`))
+	}))
+	defer server.Close()
+	host, err := svchost.ForComparison(strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatalf("test server hostname is invalid: %s", err)
+	}
+
+	t.Run("loopback", func(t *testing.T) {
+		d := New(WithInsecureHosts())
+		discovered, err := d.Discover(t.Context(), host)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		u, err := discovered.ServiceURL("thingy.v1")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if got, want := u.String(), server.URL+"/foo"; got != want {
+			t.Errorf("wrong service URL %q; want %q", got, want)
+		}
+	})
+	t.Run("not enabled", func(t *testing.T) {
+		d := New()
+		if _, err := d.Discover(t.Context(), host); err == nil {
+			t.Error("unexpected success discovering over plain HTTP")
+		}
+	})
+	t.Run("listed", func(t *testing.T) {
+		d := New(WithInsecureHosts("dev.example.com"))
+		if got, want := d.discoveryURL("dev.example.com").String(), "http://dev.example.com/.well-known/terraform.json"; got != want {
+			t.Errorf("wrong discovery URL %q; want %q", got, want)
+		}
+		if got, want := d.discoveryURL("example.com").String(), "https://example.com/.well-known/terraform.json"; got != want {
+			t.Errorf("wrong discovery URL %q; want %q", got, want)
+		}
+	})
+}
+
+func TestWithUserAgent(t *testing.T) {
+	var gotUserAgent string
+	portStr, cleanup := testServer(func(w http.ResponseWriter, r *http.Request) {
+		gotUserAgent = r.Header.Get("User-Agent")
+		w.Header().Add("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	})
+	defer cleanup()
+	host, err := svchost.ForComparison("localhost" + portStr)
+	if err != nil {
+		t.Fatalf("test server hostname is invalid: %s", err)
+	}
+
+	tests := map[string]struct {
+		options []DiscoOption
+		want    string
+	}{
+		"default": {
+			nil,
+			"svchost/devel",
+		},
+		"product": {
+			[]DiscoOption{WithUserAgent("OpenTofu/1.10.0")},
+			"OpenTofu/1.10.0 svchost/devel",
+		},
+		"product and comment": {
+			[]DiscoOption{WithUserAgent("OpenTofu/1.10.0"), WithUserAgentComment("+https://opentofu.org")},
+			"OpenTofu/1.10.0 svchost/devel (+https://opentofu.org)",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			d, err := NewWithErrors(append(test.options, WithHTTPClient(testClient))...)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if _, err := d.Discover(t.Context(), host); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if gotUserAgent != test.want {
+				t.Errorf("wrong User-Agent %q; want %q", gotUserAgent, test.want)
+			}
+		})
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		if _, err := NewWithErrors(WithUserAgent("OpenTofu\r\n")); err == nil {
+			t.Error("unexpected success with control characters in product")
+		}
+		if _, err := NewWithErrors(WithUserAgentComment("(nested)")); err == nil {
+			t.Error("unexpected success with parentheses in comment")
+		}
+	})
+}
+
+func TestWithHostTLSConfig(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+		w.Write([]byte(`{"thingy.v1": "/foo"}`))
+	}))
+	defer server.Close()
+	host, err := svchost.ForComparison(strings.TrimPrefix(server.URL, "https://"))
+	if err != nil {
+		t.Fatalf("test server hostname is invalid: %s", err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+
+	t.Run("trusted", func(t *testing.T) {
+		d, err := NewWithErrors(WithHostTLSConfig(host, &tls.Config{RootCAs: roots}))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if _, err := d.Discover(t.Context(), host); err != nil {
+			t.Errorf("unexpected error: %s", err)
+		}
+	})
+	t.Run("other host", func(t *testing.T) {
+		d, err := NewWithErrors(WithHostTLSConfig("example.com", &tls.Config{RootCAs: roots}))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if _, err := d.Discover(t.Context(), host); err == nil {
+			t.Error("unexpected success with untrusted certificate")
+		}
+	})
+	t.Run("FIPS", func(t *testing.T) {
+		_, err := NewWithErrors(WithFIPSMode(), WithHostTLSConfig(host, &tls.Config{InsecureSkipVerify: true}))
+		if err == nil {
+			t.Error("unexpected success disabling verification in FIPS mode")
+		}
+	})
+	t.Run("with HTTP client", func(t *testing.T) {
+		_, err := NewWithErrors(WithHTTPClient(testClient), WithHostTLSConfig(host, &tls.Config{RootCAs: roots}))
+		if err == nil {
+			t.Error("unexpected success combining with WithHTTPClient")
+		}
+	})
+}
+
+func TestWithFIPSMode(t *testing.T) {
+	d, err := NewWithErrors(WithFIPSMode())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	tlsConfig := d.httpClient.Transport.(*http.Transport).TLSClientConfig
+	if tlsConfig == nil || tlsConfig.MinVersion < tls.VersionTLS12 || len(tlsConfig.CipherSuites) == 0 {
+		t.Errorf("default client does not have a restricted TLS configuration")
+	}
+
+	// testClient disables certificate verification, so it's not acceptable
+	// in FIPS mode.
+	_, err = NewWithErrors(WithFIPSMode(), WithHTTPClient(testClient))
+	if err == nil {
+		t.Error("unexpected success with non-compliant HTTP client; want error")
+	}
+
+	// New ignores the error above, so the client must not be used.
+	requested := false
+	portStr, cleanup := testServer(func(w http.ResponseWriter, r *http.Request) {
+		requested = true
+		w.WriteHeader(http.StatusNotFound)
+	})
+	defer cleanup()
+	host, err := svchost.ForComparison("localhost" + portStr)
+	if err != nil {
+		t.Fatalf("test server hostname is invalid: %s", err)
+	}
+	d = New(WithFIPSMode(), WithHTTPClient(testClient))
+	if _, err := d.Discover(t.Context(), host); err == nil {
+		t.Error("unexpected success with non-compliant HTTP client; want error")
+	}
+	if requested {
+		t.Error("server received a request from a non-compliant client")
+	}
+}
+
+func TestWithSystemProxy(t *testing.T) {
+	d, err := NewWithErrors(WithSystemProxy())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if d.httpClient.Transport.(*http.Transport).Proxy == nil {
+		t.Error("default client has no proxy function")
+	}
+
+	_, err = NewWithErrors(WithHTTPClient(testClient), WithSystemProxy())
+	if err == nil {
+		t.Error("unexpected success with both WithHTTPClient and WithSystemProxy; want error")
+	}
+}
+
+func TestProxyOptionsConflict(t *testing.T) {
+	proxyFunc := func(*http.Request) (*url.URL, error) { return nil, nil }
+	_, err := NewWithErrors(WithSystemProxy(), WithSOCKS5Proxy("localhost:1080", nil))
+	if got, want := fmt.Sprint(err), "conflicting proxies given in WithSystemProxy and WithSOCKS5Proxy options"; got != want {
+		t.Errorf("wrong error %q; want %q", got, want)
+	}
+	_, err = NewWithErrors(WithProxyFunc(proxyFunc), WithProxyFunc(proxyFunc))
+	if err == nil {
+		t.Error("unexpected success with multiple WithProxyFunc options; want error")
+	}
+
+	// New ignores the conflict and keeps the first proxy.
+	d := New(WithSOCKS5Proxy("localhost:1080", nil), WithProxyFunc(proxyFunc))
+	req, _ := http.NewRequest("GET", "https://example.com/", nil)
+	proxyURL, err := d.httpClient.Transport.(*http.Transport).Proxy(req)
+	if err != nil || proxyURL == nil || proxyURL.String() != "socks5://localhost:1080" {
+		t.Errorf("wrong proxy URL %v with error %v; want the SOCKS5 proxy", proxyURL, err)
+	}
+}
+
+func TestWithHostPolicy(t *testing.T) {
+	requested := false
+	portStr, cleanup := testServer(func(w http.ResponseWriter, r *http.Request) {
+		requested = true
+		w.WriteHeader(http.StatusNotFound)
+	})
+	defer cleanup()
+
+	host, err := svchost.ForComparison("localhost" + portStr)
+	if err != nil {
+		t.Fatalf("test server hostname is invalid: %s", err)
+	}
+
+	errDenied := errors.New("host not allowed")
+	d, err := NewWithErrors(WithHTTPClient(testClient), WithHostPolicy(func(hostname svchost.Hostname) error {
+		if hostname == host {
+			return errDenied
+		}
+		return nil
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	_, err = d.Discover(t.Context(), host)
+	if !errors.Is(err, errDenied) {
+		t.Errorf("wrong error %v; want host policy error", err)
+	}
+	if requested {
+		t.Error("discovery request was sent despite the host policy")
+	}
+
+	_, err = NewWithErrors(WithHostPolicy(nil))
+	if err == nil {
+		t.Error("unexpected success with nil policy; want error")
+	}
+}
+
+func TestWithPrivateAddressProtection(t *testing.T) {
+	requested := false
+	portStr, cleanup := testServer(func(w http.ResponseWriter, r *http.Request) {
+		requested = true
+		w.WriteHeader(http.StatusNotFound)
+	})
+	defer cleanup()
+
+	host, err := svchost.ForComparison("localhost" + portStr)
+	if err != nil {
+		t.Fatalf("test server hostname is invalid: %s", err)
+	}
+
+	d, err := NewWithErrors(WithPrivateAddressProtection())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	_, err = d.Discover(t.Context(), host)
+	if !errors.Is(err, ErrPrivateAddress) {
+		t.Errorf("wrong error %v; want ErrPrivateAddress", err)
+	}
+
+	_, err = NewWithErrors(WithPrivateAddressProtection(netip.Prefix{}))
+	if err == nil {
+		t.Error("unexpected success with invalid prefix; want error")
+	}
+	_, err = NewWithErrors(WithHTTPClient(testClient), WithPrivateAddressProtection())
+	if err == nil {
+		t.Error("unexpected success with both WithHTTPClient and WithPrivateAddressProtection; want error")
+	}
+
+	// New ignores the errors above, so the protection must fail closed.
+	loopback := []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8"), netip.MustParsePrefix("::1/128")}
+	d = New(WithPrivateAddressProtection(append(loopback, netip.Prefix{})...))
+	if _, err := d.Discover(t.Context(), host); !errors.Is(err, ErrPrivateAddress) {
+		t.Errorf("wrong error with invalid prefix %v; want ErrPrivateAddress", err)
+	}
+	d = New(WithHTTPClient(testClient), WithPrivateAddressProtection())
+	if _, err := d.Discover(t.Context(), host); err == nil {
+		t.Error("unexpected success with both WithHTTPClient and WithPrivateAddressProtection; want error")
+	}
+	if requested {
+		t.Error("server received a request despite the protection")
+	}
+}
+
+func TestWithResponseVerifier(t *testing.T) {
+	const doc = `{"thingy.v1": "http://example.com/foo"}`
+	portStr, cleanup := testServer(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+		w.Header().Add("X-Signature", "good")
+		w.Header().Add("X-Other", "ignored")
+		w.Write([]byte(doc))
+	})
+	defer cleanup()
+
+	host, err := svchost.ForComparison("localhost" + portStr)
+	if err != nil {
+		t.Fatalf("test server hostname is invalid: %s", err)
+	}
+
+	errBadSignature := errors.New("bad signature")
+	verify := func(hostname svchost.Hostname, gotDoc []byte, header http.Header) error {
+		if hostname != host {
+			t.Errorf("wrong hostname %q; want %q", hostname, host)
+		}
+		if string(gotDoc) != doc {
+			t.Errorf("wrong document %q; want %q", gotDoc, doc)
+		}
+		if got := header.Get("X-Other"); got != "" {
+			t.Errorf("verifier received unselected header X-Other: %q", got)
+		}
+		if header.Get("X-Signature") != "good" {
+			return errBadSignature
+		}
+		return nil
+	}
+
+	t.Run("accepted", func(t *testing.T) {
+		d, err := NewWithErrors(WithHTTPClient(testClient), WithResponseVerifier(verify, "x-signature"))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if _, err := d.Discover(t.Context(), host); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	})
+	t.Run("rejected", func(t *testing.T) {
+		d, err := NewWithErrors(WithHTTPClient(testClient), WithResponseVerifier(verify))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		_, err = d.Discover(t.Context(), host)
+		if !errors.Is(err, errBadSignature) {
+			t.Errorf("wrong error %v; want verifier error", err)
+		}
+		if _, cached := d.hostCache[host]; cached {
+			t.Error("rejected document was cached")
+		}
+	})
+	t.Run("invalid", func(t *testing.T) {
+		if _, err := NewWithErrors(WithResponseVerifier(nil)); err == nil {
+			t.Error("unexpected success with nil verifier; want error")
+		}
+		if _, err := NewWithErrors(WithResponseVerifier(verify), WithResponseVerifier(verify)); err == nil {
+			t.Error("unexpected success with multiple verifiers; want error")
+		}
+	})
+	t.Run("rejected option keeps first verifier", func(t *testing.T) {
+		// New ignores option errors, so a rejected second verifier must not
+		// replace or disable the first one.
+		for name, second := range map[string]ResponseVerifier{
+			"nil":         nil,
+			"conflicting": func(svchost.Hostname, []byte, http.Header) error { return nil },
+		} {
+			t.Run(name, func(t *testing.T) {
+				d := New(WithHTTPClient(testClient), WithResponseVerifier(verify), WithResponseVerifier(second, "x-signature"))
+				_, err := d.Discover(t.Context(), host)
+				if !errors.Is(err, errBadSignature) {
+					t.Errorf("wrong error %v; want error from the first verifier", err)
+				}
+			})
+		}
+	})
+}
+
+func TestWithRequestMutator(t *testing.T) {
+	var gotHeaders []http.Header
+	var portStr string
+	portStr, cleanup := testServer(func(w http.ResponseWriter, r *http.Request) {
+		gotHeaders = append(gotHeaders, r.Header.Clone())
+		if r.URL.RawQuery == "" {
+			http.Redirect(w, r, "https://localhost"+portStr+"/.well-known/terraform.json?moved", http.StatusFound)
+			return
+		}
+		w.Header().Add("Content-Type", "application/json")
+		w.Write([]byte(`{"thingy.v1": "/foo"}`))
+	})
+	defer cleanup()
+
+	host, err := svchost.ForComparison("localhost" + portStr)
+	if err != nil {
+		t.Fatalf("test server hostname is invalid: %s", err)
+	}
+
+	t.Run("applied", func(t *testing.T) {
+		gotHeaders = nil
+		var requests int
+		d, err := NewWithErrors(
+			WithHTTPClient(testClient),
+			WithCredentials(svcauth.StaticCredentialsSource(map[svchost.Hostname]svcauth.HostCredentials{
+				host: svcauth.HostCredentialsToken("abc123"),
+			})),
+			WithRequestMutator(func(req *http.Request) error {
+				requests++
+				// Mutators run after credentials are applied, so they
+				// can sign them.
+				req.Header.Set("X-Signature", "signed:"+req.Header.Get("Authorization"))
+				return nil
+			}),
+			WithRequestMutator(func(req *http.Request) error {
+				req.Header.Set("X-Request-Id", strconv.Itoa(requests))
+				return nil
+			}),
+		)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if _, err := d.Discover(t.Context(), host); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if len(gotHeaders) != 2 {
+			t.Fatalf("server received %d requests; want 2", len(gotHeaders))
+		}
+		for i, header := range gotHeaders {
+			if got, want := header.Get("X-Signature"), "signed:Bearer abc123"; got != want {
+				t.Errorf("wrong X-Signature in request %d %q; want %q", i, got, want)
+			}
+			if got, want := header.Get("X-Request-Id"), strconv.Itoa(i+1); got != want {
+				t.Errorf("wrong X-Request-Id in request %d %q; want %q", i, got, want)
+			}
+		}
+	})
+	t.Run("error", func(t *testing.T) {
+		gotHeaders = nil
+		errNoSigningKey := errors.New("no signing key")
+		d := New(WithHTTPClient(testClient), WithRequestMutator(func(req *http.Request) error {
+			return errNoSigningKey
+		}))
+		if _, err := d.Discover(t.Context(), host); !errors.Is(err, errNoSigningKey) {
+			t.Errorf("wrong error %v; want mutator error", err)
+		}
+		if len(gotHeaders) != 0 {
+			t.Errorf("server received %d requests; want 0", len(gotHeaders))
+		}
+	})
+	t.Run("nil", func(t *testing.T) {
+		if _, err := NewWithErrors(WithRequestMutator(nil)); err == nil {
+			t.Error("unexpected success with nil mutator")
+		}
+	})
+}
+
+func TestWithDocumentValidator(t *testing.T) {
+	portStr, cleanup := testServer(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+		w.Write([]byte(`{"thingy.v1": "http://example.com/foo", "wotsit.v2": "https://example.net/bar"}`))
+	})
+	defer cleanup()
+
+	host, err := svchost.ForComparison("localhost" + portStr)
+	if err != nil {
+		t.Fatalf("test server hostname is invalid: %s", err)
+	}
+
+	errInsecure := errors.New("service uses plain http")
+	requireHTTPS := func(hostname svchost.Hostname, doc map[string]any) error {
+		for _, v := range doc {
+			if s, ok := v.(string); ok && strings.HasPrefix(s, "http:") {
+				return errInsecure
+			}
+		}
+		return nil
+	}
+	var validated []string
+	recordHost := func(hostname svchost.Hostname, doc map[string]any) error {
+		validated = append(validated, hostname.String())
+		return nil
+	}
+
+	t.Run("rejected", func(t *testing.T) {
+		validated = nil
+		d, err := NewWithErrors(WithHTTPClient(testClient), WithDocumentValidator(recordHost), WithDocumentValidator(requireHTTPS))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		_, err = d.Discover(t.Context(), host)
+		if !errors.Is(err, errInsecure) {
+			t.Errorf("wrong error %v; want validator error", err)
+		}
+		if _, cached := d.hostCache[host]; cached {
+			t.Error("rejected document was cached")
+		}
+		if !slices.Equal(validated, []string{host.String()}) {
+			t.Errorf("wrong validated hosts %q; want %q", validated, host.String())
+		}
+	})
+	t.Run("accepted", func(t *testing.T) {
+		validated = nil
+		d, err := NewWithErrors(WithHTTPClient(testClient), WithDocumentValidator(recordHost))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if _, err := d.Discover(t.Context(), host); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if len(validated) != 1 {
+			t.Errorf("validator called %d times; want 1", len(validated))
+		}
+	})
+	t.Run("invalid", func(t *testing.T) {
+		if _, err := NewWithErrors(WithDocumentValidator(nil)); err == nil {
+			t.Error("unexpected success with nil validator; want error")
+		}
+	})
+}
+
+func TestRefresh(t *testing.T) {
+	doc := `{"thingy.v1": "http://example.com/foo"}`
+	portStr, cleanup := testServer(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+		w.Write([]byte(doc))
+	})
+	defer cleanup()
+
+	host, err := svchost.ForComparison("localhost" + portStr)
+	if err != nil {
+		t.Fatalf("test server hostname is invalid: %s", err)
+	}
+
+	var gotChanges []ServiceChange
+	ctx := ContextWithDiscoTrace(t.Context(), &DiscoTrace{
+		ServicesChanged: func(ctx context.Context, hostname svchost.Hostname, changes []ServiceChange) {
+			gotChanges = append(gotChanges, changes...)
+		},
+	})
+
+	d := New(WithHTTPClient(testClient))
+	if _, err := d.Discover(ctx, host); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := d.Refresh(ctx, host); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(gotChanges) != 0 {
+		t.Errorf("unexpected changes for unchanged document: %#v", gotChanges)
+	}
+
+	doc = `{"thingy.v1": "http://example.com/bar"}`
+	refreshed, err := d.Refresh(ctx, host)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(gotChanges) != 1 || gotChanges[0].ID != "thingy.v1" || gotChanges[0].Kind != ServiceUpdated {
+		t.Errorf("wrong changes %#v; want thingy.v1 updated", gotChanges)
+	}
+	cached, err := d.Discover(ctx, host)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if cached != refreshed {
+		t.Error("refreshed result did not replace the cached result")
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	var gotEvents []string
+	d := New()
+	unsubscribe := d.Subscribe(func(e HostEvent) {
+		gotEvents = append(gotEvents, e.Kind.String()+" "+e.Hostname.String())
+		if (e.Host != nil) != (e.Kind == HostCacheUpdated) {
+			t.Errorf("wrong Host for %s event: %#v", e.Kind, e.Host)
+		}
+	})
+
+	d.ForceHostServices("example.com", nil)
+	d.ForceHostServices("example.net", nil)
+	d.Forget("example.com")
+	d.Forget("example.com") // no cache entry, so no event
+	d.ForgetAll()
+	unsubscribe()
+	d.ForceHostServices("example.org", nil)
+
+	want := []string{
+		"updated example.com",
+		"updated example.net",
+		"forgotten example.com",
+		"forgotten example.net",
+	}
+	if !slices.Equal(gotEvents, want) {
+		t.Errorf("wrong events\ngot:  %q\nwant: %q", gotEvents, want)
+	}
+}
+
+func TestSetDiscoveryPath(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/custom/disco.json" || r.URL.Query().Get("v") != "1" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Add("Content-Type", "application/json")
+		w.Write([]byte(`{"thingy.v1": "thingy/"}`))
+	}))
+	defer server.Close()
+
+	host, err := svchost.ForComparison(strings.TrimPrefix(server.URL, "https://"))
+	if err != nil {
+		t.Fatalf("test server hostname is invalid: %s", err)
+	}
+
+	d := New(WithHTTPClient(testClient))
+	if err := d.SetDiscoveryPath(host, "/custom/disco.json?v=1"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	discovered, err := d.Discover(t.Context(), host)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	gotURL, err := discovered.ServiceURL("thingy.v1")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got, want := gotURL.String(), server.URL+"/custom/thingy/"; got != want {
+		t.Errorf("wrong URL %q; want %q", got, want)
+	}
+
+	for _, path := range []string{"custom/disco.json", "https://example.com/disco.json"} {
+		if err := d.SetDiscoveryPath(host, path); err == nil {
+			t.Errorf("unexpected success for %q; want error", path)
+		}
+	}
+}
+
+func TestWithDiscoveryPaths(t *testing.T) {
+	var requested []string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.Path)
+		switch r.URL.Path {
+		case "/.well-known/terraform.json":
+			w.Header().Add("Content-Type", "application/json")
+			w.Write([]byte(`{"thingy.v1": "/terraform/"}`))
+		case "/.well-known/broken.json":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	host, err := svchost.ForComparison(strings.TrimPrefix(server.URL, "https://"))
+	if err != nil {
+		t.Fatalf("test server hostname is invalid: %s", err)
+	}
+
+	tests := map[string]struct {
+		paths         []string
+		wantRequested []string
+		wantURL       string
+		wantErr       bool
+	}{
+		"falls through 404": {
+			paths:         []string{"/.well-known/opentofu.json", "/.well-known/terraform.json"},
+			wantRequested: []string{"/.well-known/opentofu.json", "/.well-known/terraform.json"},
+			wantURL:       server.URL + "/terraform/",
+		},
+		"first success wins": {
+			paths:         []string{"/.well-known/terraform.json", "/.well-known/opentofu.json"},
+			wantRequested: []string{"/.well-known/terraform.json"},
+			wantURL:       server.URL + "/terraform/",
+		},
+		"other errors don't fall through": {
+			paths:         []string{"/.well-known/broken.json", "/.well-known/terraform.json"},
+			wantRequested: []string{"/.well-known/broken.json"},
+			wantErr:       true,
+		},
+		"all not found": {
+			paths:         []string{"/.well-known/opentofu.json", "/.well-known/other.json"},
+			wantRequested: []string{"/.well-known/opentofu.json", "/.well-known/other.json"},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			requested = nil
+			d, err := NewWithErrors(WithHTTPClient(testClient), WithDiscoveryPaths(test.paths...))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			discovered, err := d.Discover(t.Context(), host)
+			if !slices.Equal(requested, test.wantRequested) {
+				t.Errorf("wrong requests %q; want %q", requested, test.wantRequested)
+			}
+			if test.wantErr {
+				if err == nil {
+					t.Fatal("unexpected success; want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			gotURL, err := discovered.ServiceURL("thingy.v1")
+			if test.wantURL == "" {
+				if err == nil {
+					t.Errorf("unexpected service URL %s; want none", gotURL)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got := gotURL.String(); got != test.wantURL {
+				t.Errorf("wrong URL %q; want %q", got, test.wantURL)
+			}
+		})
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		if _, err := NewWithErrors(WithDiscoveryPaths()); err == nil {
+			t.Error("unexpected success with no paths")
+		}
+		if _, err := NewWithErrors(WithDiscoveryPaths("/.well-known/opentofu.json", "relative.json")); err == nil {
+			t.Error("unexpected success with relative path")
+		}
+	})
+}
+
+func TestDiscoverErrorTypes(t *testing.T) {
+	tests := map[string]struct {
+		handler func(w http.ResponseWriter, r *http.Request)
+		check   func(t *testing.T, err error)
+	}{
+		"server error": {
+			func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			},
+			func(t *testing.T, err error) {
+				var statusErr *ErrDiscoveryHTTPStatus
+				if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusServiceUnavailable {
+					t.Errorf("wrong error %#v; want ErrDiscoveryHTTPStatus with code 503", err)
+				}
+			},
+		},
+		"wrong content type": {
+			func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Add("Content-Type", "text/html")
+				w.Write([]byte(`<html></html>`))
+			},
+			func(t *testing.T, err error) {
+				var docErr *ErrDiscoveryInvalidDocument
+				if !errors.As(err, &docErr) {
+					t.Errorf("wrong error %#v; want ErrDiscoveryInvalidDocument", err)
+				}
+			},
+		},
+		"malformed JSON": {
+			func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Add("Content-Type", "application/json")
+				w.Write([]byte(`{"thingy.v1": "htt`))
+			},
+			func(t *testing.T, err error) {
+				var docErr *ErrDiscoveryInvalidDocument
+				if !errors.As(err, &docErr) || docErr.Err == nil {
+					t.Errorf("wrong error %#v; want ErrDiscoveryInvalidDocument with underlying error", err)
+				}
+			},
+		},
+		"too large": {
+			func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Add("Content-Type", "application/json")
+				w.Header().Add("Content-Length", strconv.Itoa(maxDiscoDocBytes+1))
+			},
+			func(t *testing.T, err error) {
+				var sizeErr *ErrDiscoveryDocTooLarge
+				if !errors.As(err, &sizeErr) || sizeErr.Size != maxDiscoDocBytes+1 {
+					t.Errorf("wrong error %#v; want ErrDiscoveryDocTooLarge with declared size", err)
+				}
+			},
+		},
+		"too large chunked": {
+			func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Add("Content-Type", "application/json")
+				chunk := make([]byte, 64*1024)
+				for written := 0; written <= maxDiscoDocBytes; written += len(chunk) {
+					w.Write(chunk)
+					w.(http.Flusher).Flush()
+				}
+			},
+			func(t *testing.T, err error) {
+				var sizeErr *ErrDiscoveryDocTooLarge
+				if !errors.As(err, &sizeErr) || sizeErr.Size != -1 {
+					t.Errorf("wrong error %#v; want ErrDiscoveryDocTooLarge with unknown size", err)
+				}
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			portStr, cleanup := testServer(test.handler)
+			defer cleanup()
+
+			host, err := svchost.ForComparison("localhost" + portStr)
+			if err != nil {
+				t.Fatalf("test server hostname is invalid: %s", err)
+			}
+			d := New(WithHTTPClient(testClient))
+			_, err = d.Discover(t.Context(), host)
+			if err == nil {
+				t.Fatal("unexpected success; want error")
+			}
+			test.check(t, err)
+		})
+	}
+}
@@ -0,0 +1,206 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+// Package discotest provides a test server that serves a discovery document,
+// for testing programs that use package disco, such as registry clients,
+// without making requests to real hosts.
+//
+// The API of this package is currently experimental and primarily intended for
+// use in OpenTofu CLI itself, rather than external consumption. We may make
+// breaking changes to the API before blessing this module with a stable version
+// number, so third-party callers should be prepared to make adjustments if they
+// choose to use this library before then.
+package discotest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	svchost "github.com/opentofu/svchost"
+	"github.com/opentofu/svchost/disco"
+)
+
+// discoPath is the path at which package disco requests the discovery
+// document by default.
+const discoPath = "/.well-known/terraform.json"
+
+// Server is a TLS server that serves a discovery document at the usual
+// location, along with 404 Not Found responses for all other paths.
+//
+// Use [NewServer] to start a Server, and call [Server.Close] once it is no
+// longer needed.
+type Server struct {
+	server   *httptest.Server
+	doc      []byte
+	opts     options
+	requests atomic.Int64
+}
+
+// Option is an optional setting for [NewServer].
+type Option func(*options)
+
+type options struct {
+	latency    time.Duration
+	token      string
+	redirects  int
+	failures   int
+	failStatus int
+	header     http.Header
+}
+
+// WithLatency causes the server to wait for the given duration before
+// responding to each request for the discovery document.
+func WithLatency(d time.Duration) Option {
+	return func(opts *options) {
+		opts.latency = d
+	}
+}
+
+// WithToken causes the server to require the given bearer token, as sent by
+// [svcauth.HostCredentialsToken], responding with 401 Unauthorized to any
+// request for the discovery document that doesn't include it.
+//
+// [svcauth.HostCredentialsToken]: https://pkg.go.dev/github.com/opentofu/svchost/svcauth#HostCredentialsToken
+func WithToken(token string) Option {
+	return func(opts *options) {
+		opts.token = token
+	}
+}
+
+// WithRedirects causes the server to respond to a request for the discovery
+// document with a chain of the given number of redirects before serving
+// the document. Each redirect changes only the query string of the URL, so
+// relative service URLs resolve in the same way as without redirects.
+func WithRedirects(n int) Option {
+	return func(opts *options) {
+		opts.redirects = n
+	}
+}
+
+// WithFailures causes the server to respond to the first n requests for the
+// discovery document with the given HTTP status code, such as 503 Service
+// Unavailable, before serving the document to later requests.
+func WithFailures(n int, status int) Option {
+	return func(opts *options) {
+		opts.failures = n
+		opts.failStatus = status
+	}
+}
+
+// WithHeader causes the server to include the given header in each successful
+// response containing the discovery document, such as to set caching
+// headers or a protocol version.
+func WithHeader(name, value string) Option {
+	return func(opts *options) {
+		if opts.header == nil {
+			opts.header = make(http.Header)
+		}
+		opts.header.Add(name, value)
+	}
+}
+
+// NewServer starts a server that serves the given services as its discovery
+// document. Relative service URLs are resolved against the server's
+// discovery URL, in the same way as for a real host.
+//
+// NewServer panics if the services can't be serialized as JSON, since that
+// is always a bug in the calling test.
+func NewServer(services map[string]any, opts ...Option) *Server {
+	if services == nil {
+		services = map[string]any{}
+	}
+	doc, err := json.Marshal(services)
+	if err != nil {
+		panic("invalid discovery document: " + err.Error())
+	}
+	s := &Server{doc: doc}
+	for _, opt := range opts {
+		opt(&s.opts)
+	}
+	s.server = httptest.NewTLSServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != discoPath {
+		http.NotFound(w, r)
+		return
+	}
+	n := s.requests.Add(1)
+	if s.opts.latency > 0 {
+		select {
+		case <-time.After(s.opts.latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	if s.opts.token != "" && r.Header.Get("Authorization") != "Bearer "+s.opts.token {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if n <= int64(s.opts.failures) {
+		w.WriteHeader(s.opts.failStatus)
+		return
+	}
+	if hop, _ := strconv.Atoi(r.URL.Query().Get("redirect")); hop < s.opts.redirects {
+		next := url.URL{Path: discoPath, RawQuery: "redirect=" + strconv.Itoa(hop+1)}
+		http.Redirect(w, r, next.String(), http.StatusFound)
+		return
+	}
+	for name, values := range s.opts.header {
+		w.Header()[name] = values
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(s.doc)
+}
+
+// Hostname returns the hostname to use when discovering services from the
+// server, which includes its port number.
+func (s *Server) Hostname() svchost.Hostname {
+	return svchost.Hostname(strings.TrimPrefix(s.server.URL, "https://"))
+}
+
+// URL returns the base URL of the server, such as "https://127.0.0.1:1234".
+func (s *Server) URL() string {
+	return s.server.URL
+}
+
+// Client returns an HTTP client that trusts the server's TLS certificate.
+func (s *Server) Client() *http.Client {
+	return s.server.Client()
+}
+
+// Disco returns a new [disco.Disco] configured with the given options and
+// with an HTTP client that trusts the server's TLS certificate.
+//
+// If any of the options fail, including those that can't be combined with a
+// custom HTTP client, Disco reports the error using t.Fatal rather than
+// returning a Disco that silently ignores them.
+func (s *Server) Disco(t testing.TB, opts ...disco.DiscoOption) *disco.Disco {
+	t.Helper()
+	d, err := disco.NewWithErrors(slices.Concat(opts, []disco.DiscoOption{disco.WithHTTPClient(s.Client())})...)
+	if err != nil {
+		t.Fatalf("invalid disco options: %s", err)
+	}
+	return d
+}
+
+// Requests returns the number of requests for the discovery document that the
+// server has received so far, including ones that failed or were redirected.
+func (s *Server) Requests() int {
+	return int(s.requests.Load())
+}
+
+// Close shuts down the server, blocking until all outstanding requests have
+// completed.
+func (s *Server) Close() {
+	s.server.Close()
+}
@@ -0,0 +1,185 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package discotest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/opentofu/svchost"
+	"github.com/opentofu/svchost/disco"
+	"github.com/opentofu/svchost/svcauth"
+)
+
+func TestNewServer(t *testing.T) {
+	services := map[string]any{
+		"modules.v1":   "/modules/",
+		"providers.v1": "https://example.com/providers/",
+	}
+
+	t.Run("basic", func(t *testing.T) {
+		s := NewServer(services)
+		defer s.Close()
+
+		host, err := s.Disco(t).Discover(t.Context(), s.Hostname())
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		u, err := host.ModulesV1URL()
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if got, want := u.String(), s.URL()+"/modules/"; got != want {
+			t.Errorf("wrong modules URL %q; want %q", got, want)
+		}
+		if got, want := s.Requests(), 1; got != want {
+			t.Errorf("wrong request count %d; want %d", got, want)
+		}
+	})
+	t.Run("token", func(t *testing.T) {
+		s := NewServer(services, WithToken("abc123"))
+		defer s.Close()
+
+		var statusErr *disco.ErrDiscoveryHTTPStatus
+		if _, err := s.Disco(t).Discover(t.Context(), s.Hostname()); !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("wrong error %v; want 401 Unauthorized", err)
+		}
+		d := s.Disco(t, disco.WithCredentials(svcauth.StaticCredentialsSource(map[svchost.Hostname]svcauth.HostCredentials{
+			s.Hostname(): svcauth.HostCredentialsToken("abc123"),
+		})))
+		if _, err := d.Discover(t.Context(), s.Hostname()); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	})
+	t.Run("redirects", func(t *testing.T) {
+		s := NewServer(services, WithRedirects(2))
+		defer s.Close()
+
+		host, err := s.Disco(t).Discover(t.Context(), s.Hostname())
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		u, err := host.ModulesV1URL()
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if got, want := u.String(), s.URL()+"/modules/"; got != want {
+			t.Errorf("wrong modules URL %q; want %q", got, want)
+		}
+		if got, want := s.Requests(), 3; got != want {
+			t.Errorf("wrong request count %d; want %d", got, want)
+		}
+	})
+	t.Run("failures", func(t *testing.T) {
+		s := NewServer(services, WithFailures(1, http.StatusServiceUnavailable))
+		defer s.Close()
+
+		d := s.Disco(t, disco.WithRetryPolicy(disco.RetryPolicy{MaxAttempts: 2}))
+		if _, err := d.Discover(t.Context(), s.Hostname()); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if got, want := s.Requests(), 2; got != want {
+			t.Errorf("wrong request count %d; want %d", got, want)
+		}
+	})
+	t.Run("latency", func(t *testing.T) {
+		s := NewServer(services, WithLatency(time.Minute))
+		defer s.Close()
+
+		ctx, cancel := context.WithTimeout(t.Context(), 10*time.Millisecond)
+		defer cancel()
+		if _, err := s.Disco(t).Discover(ctx, s.Hostname()); err == nil {
+			t.Fatal("unexpected success; want timeout")
+		}
+	})
+	t.Run("header", func(t *testing.T) {
+		s := NewServer(services, WithHeader("ETag", `"v1"`))
+		defer s.Close()
+
+		host, err := s.Disco(t).Discover(t.Context(), s.Hostname())
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if got, want := host.ETag(), `"v1"`; got != want {
+			t.Errorf("wrong ETag %q; want %q", got, want)
+		}
+	})
+}
+
+func TestFake(t *testing.T) {
+	var f Fake
+	hostname := svchost.Hostname("example.com")
+	errBroken := errors.New("broken")
+
+	if _, err := f.Discover(t.Context(), hostname); err == nil {
+		t.Fatal("unexpected success for unconfigured host; want error")
+	}
+
+	f.SetServices(hostname, map[string]any{"modules.v1": "/modules/"})
+	u, err := f.DiscoverServiceURL(t.Context(), hostname, "modules.v1")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got, want := u.String(), "https://example.com/modules/"; got != want {
+		t.Errorf("wrong URL %q; want %q", got, want)
+	}
+	if _, err := f.DiscoverServiceURL(t.Context(), hostname, "providers.v1"); err == nil {
+		t.Error("unexpected success for unsupported service; want error")
+	}
+
+	f.SetError(hostname, errBroken)
+	_, err = f.Discover(t.Context(), hostname)
+	var hostErr *svchost.HostError
+	if !errors.Is(err, errBroken) || !errors.As(err, &hostErr) || hostErr.Host != hostname || hostErr.Op != disco.OpDiscover {
+		t.Errorf("wrong error %v; want host error wrapping %v", err, errBroken)
+	}
+
+	if creds, err := f.CredentialsForHost(t.Context(), hostname); err != nil || creds != nil {
+		t.Errorf("wrong credentials %#v with error %v; want none", creds, err)
+	}
+	f.SetCredentials(hostname, svcauth.HostCredentialsToken("abc123"))
+	creds, err := f.CredentialsForHost(t.Context(), hostname)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got, want := creds, svcauth.HostCredentialsToken("abc123"); got != want {
+		t.Errorf("wrong credentials %#v; want %#v", got, want)
+	}
+}
+
+// fatalRecorder is a [testing.TB] that records a call to Fatalf instead of
+// failing the test.
+type fatalRecorder struct {
+	testing.TB
+	msg string
+}
+
+func (r *fatalRecorder) Helper() {}
+
+func (r *fatalRecorder) Fatalf(format string, args ...any) {
+	r.msg = fmt.Sprintf(format, args...)
+	runtime.Goexit()
+}
+
+func TestServer_Disco_invalidOption(t *testing.T) {
+	s := NewServer(nil)
+	defer s.Close()
+
+	rec := &fatalRecorder{TB: t}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.Disco(rec, disco.WithResponseVerifier(nil))
+		t.Error("Disco returned despite an invalid option")
+	}()
+	<-done
+	if rec.msg == "" {
+		t.Error("invalid option was not reported")
+	}
+}
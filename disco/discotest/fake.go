@@ -0,0 +1,120 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package discotest
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sync"
+
+	svchost "github.com/opentofu/svchost"
+	"github.com/opentofu/svchost/disco"
+	"github.com/opentofu/svchost/svcauth"
+)
+
+// errNotConfigured is returned by [Fake] for hostnames that it has no result
+// for.
+var errNotConfigured = errors.New("no discovery result is configured for this host in discotest.Fake")
+
+// Fake is an in-memory implementation of [disco.Discoverer] that returns
+// results configured by the test, for testing code that accepts a
+// Discoverer without running a server at all.
+//
+// The zero value is ready to use and has no results, so that discovery fails
+// for every hostname until one is configured. A Fake is safe for concurrent
+// use.
+type Fake struct {
+	mu    sync.Mutex
+	hosts map[svchost.Hostname]*disco.Host
+	errs  map[svchost.Hostname]error
+	creds map[svchost.Hostname]svcauth.HostCredentials
+}
+
+var _ disco.Discoverer = (*Fake)(nil)
+
+// SetHost causes discovery for the given hostname to return the given host,
+// such as one created using [disco.NewHost], replacing any earlier result.
+func (f *Fake) SetHost(hostname svchost.Hostname, host *disco.Host) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.hosts == nil {
+		f.hosts = map[svchost.Hostname]*disco.Host{}
+	}
+	f.hosts[hostname] = host
+	delete(f.errs, hostname)
+}
+
+// SetServices causes discovery for the given hostname to return the given
+// services, replacing any earlier result. Relative service URLs are resolved
+// against the hostname's usual discovery URL, in the same way as for a real
+// host.
+func (f *Fake) SetServices(hostname svchost.Hostname, services map[string]any) {
+	discoURL := &url.URL{Scheme: "https", Host: hostname.String(), Path: discoPath}
+	host, err := disco.NewHost(hostname, discoURL, services)
+	if err != nil {
+		// NewHost fails only for a relative discovery URL, which we never
+		// give it.
+		panic(err)
+	}
+	f.SetHost(hostname, host)
+}
+
+// SetError causes discovery for the given hostname to fail with the given
+// error, replacing any earlier result.
+func (f *Fake) SetError(hostname svchost.Hostname, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.errs == nil {
+		f.errs = map[svchost.Hostname]error{}
+	}
+	f.errs[hostname] = err
+	delete(f.hosts, hostname)
+}
+
+// SetCredentials causes [Fake.CredentialsForHost] to return the given
+// credentials for the given hostname.
+func (f *Fake) SetCredentials(hostname svchost.Hostname, creds svcauth.HostCredentials) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.creds == nil {
+		f.creds = map[svchost.Hostname]svcauth.HostCredentials{}
+	}
+	f.creds[hostname] = creds
+}
+
+// Discover implements [disco.Discoverer], returning the result configured
+// for the given hostname, or an error if there is none.
+func (f *Fake) Discover(ctx context.Context, hostname svchost.Hostname) (*disco.Host, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err, ok := f.errs[hostname]; ok {
+		return nil, svchost.WrapHostError(hostname, disco.OpDiscover, err)
+	}
+	if host, ok := f.hosts[hostname]; ok {
+		return host, nil
+	}
+	return nil, svchost.WrapHostError(hostname, disco.OpDiscover, errNotConfigured)
+}
+
+// DiscoverServiceURL implements [disco.Discoverer].
+func (f *Fake) DiscoverServiceURL(ctx context.Context, hostname svchost.Hostname, serviceID string) (*url.URL, error) {
+	host, err := f.Discover(ctx, hostname)
+	if err != nil {
+		return nil, err
+	}
+	u, err := host.ServiceURL(serviceID)
+	if err != nil {
+		return nil, svchost.WrapHostError(hostname, disco.OpServiceURL, err)
+	}
+	return u, nil
+}
+
+// CredentialsForHost implements [disco.Discoverer], returning the credentials
+// configured for the given hostname, or nil if there are none.
+func (f *Fake) CredentialsForHost(ctx context.Context, hostname svchost.Hostname) (svcauth.HostCredentials, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.creds[hostname], nil
+}
@@ -0,0 +1,113 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package disco
+
+import (
+	"context"
+	"sync"
+
+	"github.com/opentofu/svchost"
+)
+
+// DiscoverAll runs [Disco.Discover] for each of the given hostnames, with at
+// most the given number of discovery requests in progress at once, which is
+// useful for warming the cache for many hosts at startup.
+//
+// If concurrency is zero or negative then there is no limit. Duplicate
+// hostnames are discovered only once, as are hostnames that are aliases of
+// the same target established using [Disco.Alias].
+//
+// The first result includes an entry for each hostname whose discovery
+// succeeded, and the second result includes an entry for each hostname whose
+// discovery failed, or is nil if all of them succeeded. A failure for one
+// hostname does not prevent discovery for the others, but once the given
+// context is canceled any hostnames not yet started fail with the context's
+// error.
+func (d *Disco) DiscoverAll(ctx context.Context, hosts []svchost.Hostname, concurrency int) (map[svchost.Hostname]*Host, map[svchost.Hostname]error) {
+	// We group the hostnames by the target they'd actually be discovered
+	// from, preserving the order of the first appearance of each.
+	var targets []svchost.Hostname
+	groups := make(map[svchost.Hostname][]svchost.Hostname)
+	seen := make(map[svchost.Hostname]struct{}, len(hosts))
+	d.mu.Lock()
+	for _, hostname := range hosts {
+		if _, dup := seen[hostname]; dup {
+			continue
+		}
+		seen[hostname] = struct{}{}
+		// If the aliases form a cycle then the hostname is its own group,
+		// and discovery will report the cycle.
+		target, _ := d.resolveAliasLocked(hostname)
+		if _, exists := groups[target]; !exists {
+			targets = append(targets, target)
+		}
+		groups[target] = append(groups[target], hostname)
+	}
+	d.mu.Unlock()
+
+	if concurrency <= 0 || concurrency > len(targets) {
+		concurrency = len(targets)
+	}
+
+	results := make(map[svchost.Hostname]*Host, len(seen))
+	var errs map[svchost.Hostname]error
+	var resultsMu sync.Mutex
+	record := func(group []svchost.Hostname, host *Host, err error) {
+		resultsMu.Lock()
+		defer resultsMu.Unlock()
+		for _, hostname := range group {
+			if err != nil {
+				if errs == nil {
+					errs = make(map[svchost.Hostname]error)
+				}
+				errs[hostname] = err
+			} else {
+				results[hostname] = host
+			}
+		}
+	}
+
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for _, target := range targets {
+		group := groups[target]
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			record(group, nil, ctx.Err())
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			host, err := d.discoverGroup(ctx, group)
+			record(group, host, err)
+		}()
+	}
+	wg.Wait()
+	return results, errs
+}
+
+// discoverGroup discovers the first of the given hostnames, all of which
+// must resolve to the same alias target, and then caches the result for the
+// others so that they need not be discovered separately.
+func (d *Disco) discoverGroup(ctx context.Context, group []svchost.Hostname) (*Host, error) {
+	host, err := d.Discover(ctx, group[0])
+	if err != nil {
+		return nil, err
+	}
+	for _, hostname := range group[1:] {
+		d.mu.Lock()
+		_, cached := d.hostCache[hostname]
+		if !cached {
+			d.hostCache[hostname] = host
+		}
+		d.mu.Unlock()
+		if !cached {
+			d.publishCached(hostname, host)
+		}
+	}
+	return host, nil
+}
@@ -0,0 +1,75 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package disco
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	svchost "github.com/opentofu/svchost"
+)
+
+func TestDiscoverAll(t *testing.T) {
+	var requests atomic.Int32
+	okPort, cleanup := testServer(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Add("Content-Type", "application/json")
+		w.Write([]byte(`{"thingy.v1": "/foo"}`))
+	})
+	defer cleanup()
+	failPort, cleanup := testServer(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	defer cleanup()
+
+	okHost := svchost.Hostname("localhost" + okPort)
+	failHost := svchost.Hostname("localhost" + failPort)
+	aliasHost := svchost.Hostname("alias.example.com")
+
+	d := New(WithHTTPClient(testClient))
+	d.Alias(aliasHost, okHost)
+
+	hosts, errs := d.DiscoverAll(t.Context(), []svchost.Hostname{okHost, aliasHost, failHost, okHost}, 1)
+	if got, want := requests.Load(), int32(1); got != want {
+		t.Errorf("wrong number of requests %d; want %d", got, want)
+	}
+	if len(hosts) != 2 || hosts[okHost] == nil || hosts[aliasHost] != hosts[okHost] {
+		t.Errorf("wrong hosts %#v; want the same host for %s and %s", hosts, okHost, aliasHost)
+	}
+	if len(errs) != 1 {
+		t.Fatalf("wrong errors %#v; want only one for %s", errs, failHost)
+	}
+	var statusErr *ErrDiscoveryHTTPStatus
+	if !errors.As(errs[failHost], &statusErr) {
+		t.Errorf("wrong error for %s: %v", failHost, errs[failHost])
+	}
+
+	// The alias result is now cached too, so discovering it again doesn't
+	// make another request.
+	if _, err := d.Discover(t.Context(), aliasHost); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got, want := requests.Load(), int32(1); got != want {
+		t.Errorf("wrong number of requests %d after cached discovery; want %d", got, want)
+	}
+}
+
+func TestDiscoverAllCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	d := New(WithHTTPClient(testClient))
+	hosts, errs := d.DiscoverAll(ctx, []svchost.Hostname{"example.com", "example.net"}, 1)
+	if len(hosts) != 0 {
+		t.Errorf("unexpected hosts %#v", hosts)
+	}
+	for _, hostname := range []svchost.Hostname{"example.com", "example.net"} {
+		if !errors.Is(errs[hostname], context.Canceled) {
+			t.Errorf("wrong error for %s: %v; want context.Canceled", hostname, errs[hostname])
+		}
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package disco
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// DiscoveryInfo describes the network request that produced a [Host], for
+// diagnosing slow or misbehaving discovery, as returned by
+// [Host.DiscoveryInfo].
+//
+// If discovery followed redirects or retried a failed request then the
+// timings describe only the final request.
+type DiscoveryInfo struct {
+	// URL is the URL that the discovery document was finally obtained from,
+	// after following any redirects.
+	URL *url.URL
+
+	// Proto is the HTTP protocol version of the response, such as
+	// "HTTP/1.1" or "HTTP/2.0".
+	Proto string
+
+	// TLSVersion is the TLS version negotiated for the connection, such as
+	// [tls.VersionTLS13], or zero if the request didn't use TLS. Use
+	// [tls.VersionName] to describe it.
+	TLSVersion uint16
+
+	// ConnectionReused is true if the request used an existing connection,
+	// in which case DNSLookup, Connect, and TLSHandshake are zero.
+	ConnectionReused bool
+
+	// DNSLookup, Connect, and TLSHandshake are the time taken by each of
+	// those steps of establishing a connection, or zero if the step was
+	// not needed.
+	DNSLookup, Connect, TLSHandshake time.Duration
+
+	// TimeToFirstByte is the time from starting the request, including
+	// establishing any connection, to receiving the first byte of the
+	// response.
+	TimeToFirstByte time.Duration
+}
+
+// DiscoveryInfo returns details about the network request that produced the
+// receiver. The second result is false if the receiver was not produced by
+// a network request, such as when it was given to [Disco.ForceHostServices]
+// or restored from a cache store.
+func (h *Host) DiscoveryInfo() (DiscoveryInfo, bool) {
+	if h == nil || h.discoveryInfo == nil {
+		return DiscoveryInfo{}, false
+	}
+	ret := *h.discoveryInfo
+	if ret.URL != nil {
+		u := *ret.URL
+		ret.URL = &u
+	}
+	return ret, true
+}
+
+// discoveryTimer records the timing of the steps of an HTTP request using
+// [httptrace.ClientTrace] hooks, some of which may be called concurrently.
+type discoveryTimer struct {
+	mu   sync.Mutex
+	info DiscoveryInfo
+
+	start, dnsStart, connectStart, tlsStart time.Time
+}
+
+// clientTrace returns the hooks that update the receiver.
+func (t *discoveryTimer) clientTrace() *httptrace.ClientTrace {
+	record := func(f func(now time.Time)) {
+		now := time.Now()
+		t.mu.Lock()
+		f(now)
+		t.mu.Unlock()
+	}
+	return &httptrace.ClientTrace{
+		GetConn: func(string) {
+			record(func(now time.Time) {
+				// Each redirect or retry starts a new request, and we
+				// report only the last one.
+				t.info = DiscoveryInfo{}
+				t.start = now
+			})
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			record(func(time.Time) { t.info.ConnectionReused = info.Reused })
+		},
+		DNSStart: func(httptrace.DNSStartInfo) {
+			record(func(now time.Time) { t.dnsStart = now })
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			record(func(now time.Time) { t.info.DNSLookup = now.Sub(t.dnsStart) })
+		},
+		ConnectStart: func(string, string) {
+			record(func(now time.Time) { t.connectStart = now })
+		},
+		ConnectDone: func(string, string, error) {
+			record(func(now time.Time) { t.info.Connect = now.Sub(t.connectStart) })
+		},
+		TLSHandshakeStart: func() {
+			record(func(now time.Time) { t.tlsStart = now })
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			record(func(now time.Time) { t.info.TLSHandshake = now.Sub(t.tlsStart) })
+		},
+		GotFirstResponseByte: func() {
+			record(func(now time.Time) { t.info.TimeToFirstByte = now.Sub(t.start) })
+		},
+	}
+}
+
+// result returns the recorded information, completed with the details of
+// the given final response.
+func (t *discoveryTimer) result(resp *http.Response) *DiscoveryInfo {
+	t.mu.Lock()
+	ret := t.info
+	t.mu.Unlock()
+	ret.URL = resp.Request.URL
+	ret.Proto = resp.Proto
+	if resp.TLS != nil {
+		ret.TLSVersion = resp.TLS.Version
+	}
+	return &ret
+}
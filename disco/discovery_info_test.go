@@ -0,0 +1,62 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package disco
+
+import (
+	"net/http"
+	"testing"
+
+	svchost "github.com/opentofu/svchost"
+)
+
+func TestHostDiscoveryInfo(t *testing.T) {
+	portStr, cleanup := testServer(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+		w.Write([]byte(`{"thingy.v1": "/foo"}`))
+	})
+	defer cleanup()
+
+	host, err := svchost.ForComparison("localhost" + portStr)
+	if err != nil {
+		t.Fatalf("test server hostname is invalid: %s", err)
+	}
+
+	t.Run("discovered", func(t *testing.T) {
+		d := New(WithHTTPClient(testClient))
+		discovered, err := d.Discover(t.Context(), host)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		info, ok := discovered.DiscoveryInfo()
+		if !ok {
+			t.Fatal("no discovery info for discovered host")
+		}
+		if got, want := info.URL.String(), "https://localhost"+portStr+"/.well-known/terraform.json"; got != want {
+			t.Errorf("wrong URL %q; want %q", got, want)
+		}
+		if info.Proto == "" {
+			t.Error("missing protocol version")
+		}
+		if info.TLSVersion == 0 {
+			t.Error("missing TLS version")
+		}
+		if info.TimeToFirstByte <= 0 {
+			t.Errorf("wrong time to first byte %s; want positive duration", info.TimeToFirstByte)
+		}
+		if !info.ConnectionReused && info.TLSHandshake <= 0 {
+			t.Errorf("wrong TLS handshake time %s for new connection; want positive duration", info.TLSHandshake)
+		}
+	})
+	t.Run("forced", func(t *testing.T) {
+		d := New(WithHTTPClient(testClient))
+		d.ForceHostServices(host, map[string]any{"thingy.v1": "/foo"})
+		discovered, err := d.Discover(t.Context(), host)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if _, ok := discovered.DiscoveryInfo(); ok {
+			t.Error("unexpected discovery info for forced services")
+		}
+	})
+}
@@ -0,0 +1,83 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package disco
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"strings"
+
+	svchost "github.com/opentofu/svchost"
+	"github.com/opentofu/svchost/svcauth"
+)
+
+// DNSHintService is the service name used in the SRV records consulted by
+// [WithDNSHints]. For example, hints for "registry.example.com" are looked
+// up at "_tofu-disco._tcp.registry.example.com".
+const DNSHintService = "tofu-disco"
+
+// DNSResolver is the subset of the [net.Resolver] API used by [WithDNSHints],
+// so that callers can provide an alternative implementation.
+type DNSResolver interface {
+	LookupSRV(ctx context.Context, service, proto, name string) (cname string, addrs []*net.SRV, err error)
+}
+
+var _ DNSResolver = (*net.Resolver)(nil)
+
+// applyDNSHints returns the given discovery URL modified to use the host and
+// port from the highest-priority SRV record for the given hostname, or
+// returns it unchanged if DNS hints are disabled or there is no usable
+// record.
+func (d *Disco) applyDNSHints(ctx context.Context, hostname svchost.Hostname, discoURL *url.URL) *url.URL {
+	if d.dnsResolver == nil || svchost.IsIPAddress(hostname) || hostname.WithoutPort() != hostname {
+		// A hostname with an explicit port already says where to connect.
+		return discoURL
+	}
+	_, addrs, err := d.dnsResolver.LookupSRV(ctx, DNSHintService, "tcp", hostname.String())
+	if err != nil || len(addrs) == 0 {
+		return discoURL
+	}
+	// LookupSRV returns the records sorted by priority and randomized by
+	// weight, so the first one is the one to use.
+	target := strings.TrimSuffix(addrs[0].Target, ".")
+	if target == "" || addrs[0].Port == 0 {
+		// A target of "." means that the service is explicitly unavailable
+		// at this domain, so we'll just try the usual location.
+		return discoURL
+	}
+	hinted, err := svchost.ForComparison(target)
+	if err != nil {
+		return discoURL
+	}
+	if d.hostPolicy != nil && d.hostPolicy(hinted) != nil {
+		// The host policy must allow the hinted host as well as the
+		// original one, since that is the host we'd actually contact.
+		return discoURL
+	}
+	ret := *discoURL
+	ret.Host = hinted.WithDefaultPort(int(addrs[0].Port)).String()
+	return &ret
+}
+
+// hintedCredentials returns the given credentials for the given hostname if
+// they may be sent to the host of the given discovery URL, or nil otherwise.
+//
+// DNS responses are not authenticated, so a DNS hint could direct discovery
+// to an attacker's host. The credentials for the hostname are therefore only
+// sent to a hinted host in the same registrable domain, and the discovery
+// request to any other host is anonymous.
+func hintedCredentials(hostname svchost.Hostname, discoURL *url.URL, creds svcauth.HostCredentials) svcauth.HostCredentials {
+	if creds == nil {
+		return nil
+	}
+	target, err := svchost.ForComparisonAllowIP(discoURL.Host)
+	if err != nil {
+		return nil
+	}
+	if target.WithoutPort() == hostname.WithoutPort() || sameSite(hostname, target) {
+		return creds
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package disco
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+
+	svchost "github.com/opentofu/svchost"
+	"github.com/opentofu/svchost/svcauth"
+)
+
+type fakeDNSResolver map[string][]*net.SRV
+
+func (r fakeDNSResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	key := "_" + service + "._" + proto + "." + name
+	addrs, ok := r[key]
+	if !ok {
+		return "", nil, &net.DNSError{Err: "no such host", Name: key, IsNotFound: true}
+	}
+	return key, addrs, nil
+}
+
+func TestWithDNSHints(t *testing.T) {
+	portStr, cleanup := testServer(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+		w.Write([]byte(`{"thingy.v1": "/foo"}`))
+	})
+	defer cleanup()
+	port, _ := strconv.Atoi(portStr[1:])
+
+	resolver := fakeDNSResolver{
+		"_tofu-disco._tcp.registry.example.com": {
+			{Target: "localhost.", Port: uint16(port), Priority: 10},
+		},
+		"_tofu-disco._tcp.unavailable.example.com": {
+			{Target: ".", Port: 0},
+		},
+	}
+	d := New(WithHTTPClient(testClient), WithDNSHints(resolver))
+
+	discovered, err := d.Discover(t.Context(), "registry.example.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	u, err := discovered.ServiceURL("thingy.v1")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got, want := u.String(), "https://localhost"+portStr+"/foo"; got != want {
+		t.Errorf("wrong service URL %q; want %q", got, want)
+	}
+
+	// Hostnames without usable hints use the usual discovery URL.
+	for _, hostname := range []svchost.Hostname{"unavailable.example.com", "other.example.com", "registry.example.com:8443"} {
+		defaultURL := d.discoveryURL(hostname)
+		if got := d.applyDNSHints(t.Context(), hostname, defaultURL); got.String() != defaultURL.String() {
+			t.Errorf("wrong discovery URL for %s: %s; want %s", hostname, got, defaultURL)
+		}
+	}
+
+	// The host policy must allow the hinted host.
+	d = New(WithHTTPClient(testClient), WithDNSHints(resolver), WithHostPolicy(func(h svchost.Hostname) error {
+		if h.WithoutPort() == "localhost" {
+			return errors.New("not allowed")
+		}
+		return nil
+	}))
+	defaultURL := d.discoveryURL("registry.example.com")
+	if got := d.applyDNSHints(t.Context(), "registry.example.com", defaultURL); got.String() != defaultURL.String() {
+		t.Errorf("hint to disallowed host was used: %s", got)
+	}
+}
+
+func TestWithDNSHints_credentials(t *testing.T) {
+	var gotAuth []string
+	portStr, cleanup := testServer(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Values("Authorization")
+		w.Header().Add("Content-Type", "application/json")
+		w.Write([]byte(`{"thingy.v1": "/foo"}`))
+	})
+	defer cleanup()
+	port, _ := strconv.Atoi(portStr[1:])
+
+	// The hint points at a host in a different domain, which might have
+	// been chosen by whoever can forge DNS responses, so it must not
+	// receive the credentials for registry.example.com.
+	resolver := fakeDNSResolver{
+		"_tofu-disco._tcp.registry.example.com": {
+			{Target: "localhost.", Port: uint16(port), Priority: 10},
+		},
+	}
+	d := New(WithHTTPClient(testClient), WithDNSHints(resolver))
+	d.SetCredentialsSource(svcauth.StaticCredentialsSource(map[svchost.Hostname]svcauth.HostCredentials{
+		"registry.example.com": svcauth.HostCredentialsToken("abc123"),
+	}))
+	if _, err := d.Discover(t.Context(), "registry.example.com"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(gotAuth) != 0 {
+		t.Errorf("hinted host in another domain received Authorization header %q", gotAuth)
+	}
+
+	// A hinted host in the same registrable domain is trusted with the
+	// credentials.
+	creds := svcauth.HostCredentialsToken("abc123")
+	for target, want := range map[string]bool{
+		"https://registry.example.com:8443/.well-known/terraform.json": true,
+		"https://disco.example.com/.well-known/terraform.json":         true,
+		"https://localhost/.well-known/terraform.json":                 false,
+		"https://registry.example.net/.well-known/terraform.json":      false,
+	} {
+		u, _ := url.Parse(target)
+		if got := hintedCredentials("registry.example.com", u, creds) != nil; got != want {
+			t.Errorf("wrong result for %s: credentials sent = %t; want %t", target, got, want)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package disco
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DocumentMetaKey is the top-level property of a discovery document that
+// holds metadata about the document itself, rather than a service. Its
+// value is a JSON object whose "version" property declares the version of
+// the document format as a string of the form "MAJOR.MINOR", such as:
+//
+//	{"meta": {"version": "1.0"}, "modules.v1": "/modules/"}
+//
+// Documents without metadata are treated as version 1.0.
+//
+// A client accepts documents of any minor version of the major version it
+// supports, ignoring any properties that it doesn't understand, so minor
+// versions may only add features that older clients can safely ignore.
+// Documents with a different major version are rejected with
+// [ErrUnsupportedDocumentVersion].
+const DocumentMetaKey = "meta"
+
+// SupportedDocumentMajorVersion is the major version of the discovery
+// document format that this package supports.
+const SupportedDocumentMajorVersion = 1
+
+// DocumentVersion is the version of the format of a discovery document, as
+// declared by its [DocumentMetaKey] property.
+type DocumentVersion struct {
+	Major, Minor int
+}
+
+// String returns the version in the "MAJOR.MINOR" form used in discovery
+// documents.
+func (v DocumentVersion) String() string {
+	return fmt.Sprintf("%d.%d", v.Major, v.Minor)
+}
+
+// ErrUnsupportedDocumentVersion is returned when a discovery document
+// declares a major version of the document format that this package does
+// not support.
+type ErrUnsupportedDocumentVersion struct {
+	// Version is the version that the document declared.
+	Version DocumentVersion
+
+	// Supported is the major version that this package supports.
+	Supported int
+}
+
+func (e *ErrUnsupportedDocumentVersion) Error() string {
+	return fmt.Sprintf("discovery document uses unsupported format version %s; only version %d.x is supported", e.Version, e.Supported)
+}
+
+// DocumentVersion returns the version of the discovery document format that
+// the host's document declared, which is 1.0 if it declared none.
+func (h *Host) DocumentVersion() DocumentVersion {
+	if h == nil {
+		return DocumentVersion{Major: 1}
+	}
+	// The version was already checked when the host was discovered, so we
+	// can ignore errors here.
+	v, _ := parseDocumentVersion(h.services)
+	return v
+}
+
+// parseDocumentVersion returns the document version declared in the
+// [DocumentMetaKey] property of the given services map, or an error if the
+// metadata is invalid or declares an unsupported major version.
+func parseDocumentVersion(services map[string]any) (DocumentVersion, error) {
+	ret := DocumentVersion{Major: 1}
+	raw, ok := services[DocumentMetaKey]
+	if !ok {
+		return ret, nil
+	}
+	meta, ok := raw.(map[string]any)
+	if !ok {
+		return ret, fmt.Errorf("the %q property must be an object", DocumentMetaKey)
+	}
+	rawVersion, ok := meta["version"]
+	if !ok {
+		return ret, nil
+	}
+	str, ok := rawVersion.(string)
+	if !ok {
+		return ret, fmt.Errorf("the document version must be a string")
+	}
+	majorStr, minorStr, hasMinor := strings.Cut(str, ".")
+	major, err := strconv.Atoi(majorStr)
+	if err != nil || major < 1 {
+		return ret, fmt.Errorf("invalid document version %q", str)
+	}
+	minor := 0
+	if hasMinor {
+		minor, err = strconv.Atoi(minorStr)
+		if err != nil || minor < 0 {
+			return ret, fmt.Errorf("invalid document version %q", str)
+		}
+	}
+	v := DocumentVersion{Major: major, Minor: minor}
+	if major != SupportedDocumentMajorVersion {
+		return ret, &ErrUnsupportedDocumentVersion{
+			Version:   v,
+			Supported: SupportedDocumentMajorVersion,
+		}
+	}
+	return v, nil
+}
@@ -0,0 +1,88 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package disco
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"testing"
+
+	svchost "github.com/opentofu/svchost"
+)
+
+func TestDiscoverDocumentVersion(t *testing.T) {
+	var doc string
+	portStr, cleanup := testServer(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(doc))
+	})
+	defer cleanup()
+
+	host, err := svchost.ForComparison("localhost" + portStr)
+	if err != nil {
+		t.Fatalf("test server hostname is invalid: %s", err)
+	}
+
+	tests := map[string]struct {
+		doc         string
+		want        DocumentVersion
+		wantErr     bool
+		unsupported bool
+	}{
+		"undeclared": {
+			doc:  `{"thingy.v1": "/foo"}`,
+			want: DocumentVersion{Major: 1},
+		},
+		"major only": {
+			doc:  `{"meta": {"version": "1"}, "thingy.v1": "/foo"}`,
+			want: DocumentVersion{Major: 1},
+		},
+		"newer minor": {
+			doc:  `{"meta": {"version": "1.3", "future": true}, "thingy.v1": "/foo"}`,
+			want: DocumentVersion{Major: 1, Minor: 3},
+		},
+		"newer major": {
+			doc:         `{"meta": {"version": "2.0"}, "thingy.v1": "/foo"}`,
+			wantErr:     true,
+			unsupported: true,
+		},
+		"not an object": {
+			doc:     `{"meta": "1.0", "thingy.v1": "/foo"}`,
+			wantErr: true,
+		},
+		"not a number": {
+			doc:     `{"meta": {"version": "one"}, "thingy.v1": "/foo"}`,
+			wantErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			doc = test.doc
+			d := New(WithHTTPClient(testClient))
+			discovered, err := d.Discover(t.Context(), host)
+			if test.wantErr {
+				var versionErr *ErrUnsupportedDocumentVersion
+				if got := errors.As(err, &versionErr); got != test.unsupported {
+					t.Fatalf("wrong error %v; want ErrUnsupportedDocumentVersion %t", err, test.unsupported)
+				}
+				var invalidErr *ErrDiscoveryInvalidDocument
+				if got := errors.As(err, &invalidErr); got == test.unsupported {
+					t.Fatalf("wrong error %v; want ErrDiscoveryInvalidDocument %t", err, !test.unsupported)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got := discovered.DocumentVersion(); got != test.want {
+				t.Errorf("wrong document version %s; want %s", got, test.want)
+			}
+			if got, want := discovered.ServiceIDs(), []string{"thingy.v1"}; !slices.Equal(got, want) {
+				t.Errorf("wrong service IDs %q; want %q", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package disco
+
+import (
+	"fmt"
+)
+
+// ErrDiscoveryHTTPStatus is returned when the server responds to a discovery
+// request with a status code other than 200 OK or 404 Not Found.
+//
+// Status codes in the 5xx range and 429 Too Many Requests typically indicate
+// a temporary problem, which might be resolved by trying again later.
+type ErrDiscoveryHTTPStatus struct {
+	// StatusCode is the response status code, such as 503.
+	StatusCode int
+
+	// Status is the full status line, such as "503 Service Unavailable".
+	Status string
+}
+
+func (e *ErrDiscoveryHTTPStatus) Error() string {
+	return fmt.Sprintf("failed to request discovery document: %s", e.Status)
+}
+
+// ErrDiscoveryInvalidDocument is returned when the server responds to a
+// discovery request with something that is not a valid discovery document,
+// such as content that is not JSON.
+type ErrDiscoveryInvalidDocument struct {
+	// Reason describes what is wrong with the document.
+	Reason string
+
+	// Err is the underlying error, if any.
+	Err error
+}
+
+func (e *ErrDiscoveryInvalidDocument) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s", e.Reason, e.Err)
+	}
+	return e.Reason
+}
+
+// Unwrap returns the underlying error, if any, for use with the standard
+// library errors package and its "Is", "As", and "Unwrap" functions.
+func (e *ErrDiscoveryInvalidDocument) Unwrap() error {
+	return e.Err
+}
+
+// ErrDiscoveryDocTooLarge is returned when the discovery document is larger
+// than this package is willing to accept.
+type ErrDiscoveryDocTooLarge struct {
+	// Size is the size of the document declared by the server, or -1 if
+	// the server didn't declare a size and we only discovered that the
+	// document was too large while reading it.
+	Size int64
+
+	// Limit is the maximum size this package accepts.
+	Limit int64
+}
+
+func (e *ErrDiscoveryDocTooLarge) Error() string {
+	if e.Size < 0 {
+		return fmt.Sprintf("discovery doc response is too large (limit %d bytes)", e.Limit)
+	}
+	return fmt.Sprintf("discovery doc response is too large (got %d bytes; limit %d)", e.Size, e.Limit)
+}
@@ -0,0 +1,72 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package disco
+
+import (
+	svchost "github.com/opentofu/svchost"
+)
+
+// HostEventKind describes the kind of change reported by a [HostEvent].
+type HostEventKind int
+
+const (
+	// HostCacheUpdated means that a new result was stored in the cache for
+	// the hostname, either from discovery or [Disco.ForceHostServices].
+	HostCacheUpdated HostEventKind = iota + 1
+
+	// HostCacheForgotten means that the cached result for the hostname was
+	// discarded.
+	HostCacheForgotten
+)
+
+func (k HostEventKind) String() string {
+	switch k {
+	case HostCacheUpdated:
+		return "updated"
+	case HostCacheForgotten:
+		return "forgotten"
+	default:
+		return "unknown"
+	}
+}
+
+// HostEvent describes a change to the cache of discovery results in a
+// [Disco] object, delivered to the functions registered using
+// [Disco.Subscribe].
+type HostEvent struct {
+	Hostname svchost.Hostname
+	Kind     HostEventKind
+
+	// Host is the newly-cached result for HostCacheUpdated, or nil for
+	// HostCacheForgotten.
+	Host *Host
+}
+
+// Subscribe registers a function to be called whenever the cache of
+// discovery results changes, so that callers can update any state derived
+// from those results without polling. It returns a function that cancels
+// the subscription.
+//
+// The function is called synchronously from whichever goroutine made the
+// change, after the change is complete, and so it should return promptly.
+func (d *Disco) Subscribe(fn func(HostEvent)) (unsubscribe func()) {
+	return d.events.Subscribe(fn)
+}
+
+func (d *Disco) publishCached(hostname svchost.Hostname, host *Host) {
+	d.events.Publish(HostEvent{
+		Hostname: hostname,
+		Kind:     HostCacheUpdated,
+		Host:     host,
+	})
+}
+
+func (d *Disco) publishForgotten(hostnames ...svchost.Hostname) {
+	for _, hostname := range hostnames {
+		d.events.Publish(HostEvent{
+			Hostname: hostname,
+			Kind:     HostCacheForgotten,
+		})
+	}
+}
@@ -7,9 +7,15 @@ package disco
 import (
 	"errors"
 	"fmt"
+	"maps"
+	"net/http"
 	"net/url"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
+
+	"github.com/opentofu/svchost/uritemplates"
 )
 
 // Host represents a service discovered host.
@@ -17,6 +23,47 @@ type Host struct {
 	discoURL *url.URL
 	hostname string
 	services map[string]any
+
+	protocolVersion int
+
+	// responseHeader is the subset of the discovery response headers
+	// retained by captureHeaders, and responseTime is when the response
+	// was generated according to its Date header, or when it was received.
+	responseHeader http.Header
+	responseTime   time.Time
+
+	// fetchedAt is when the result was last obtained or revalidated by a
+	// discovery request according to the Disco's clock, for use by
+	// WithStaleWhileRevalidate, or zero if it was provided by the caller.
+	fetchedAt time.Time
+
+	// etag and lastModified are the validators from the discovery response,
+	// used to revalidate the result with a conditional request.
+	etag         string
+	lastModified string
+
+	// discoveryInfo describes the network request that produced the host,
+	// or is nil if it was not produced by a network request.
+	discoveryInfo *DiscoveryInfo
+
+	// storeTTL is how long the host may be kept in a persistent cache store,
+	// based on the response headers, or zero if it must not be stored.
+	storeTTL time.Duration
+
+	// document and documentHeader are the raw discovery document and the
+	// response headers given to the verifier, retained only when there is
+	// a persistent cache store so that the document can be checked again
+	// when it is loaded from there.
+	document       []byte
+	documentHeader http.Header
+
+	// unknownOAuthFields is called by ServiceOAuthClient with any properties
+	// it doesn't understand, if set by WithUnknownOAuthFields.
+	unknownOAuthFields func(serviceID string, fields []string)
+
+	// serviceURLPolicy is called by the methods that return service URLs
+	// to check each URL, if set by WithServiceURLPolicy.
+	serviceURLPolicy func(serviceID string, u *url.URL) error
 }
 
 // ErrServiceNotProvided is returned when the service is not provided.
@@ -48,6 +95,150 @@ func (e *ErrVersionNotSupported) Error() string {
 	return fmt.Sprintf("host %s does not support %s version %d", e.hostname, e.service, e.version)
 }
 
+// ErrNoSupportedVersion is returned when a host provides a service, but
+// none of the versions of it that the client is able to use.
+type ErrNoSupportedVersion struct {
+	hostname   string
+	service    string
+	acceptable []uint64
+}
+
+// Error returns a customized error message.
+func (e *ErrNoSupportedVersion) Error() string {
+	versions := make([]string, len(e.acceptable))
+	for i, v := range e.acceptable {
+		versions[i] = fmt.Sprintf("v%d", v)
+	}
+	if e.hostname == "" {
+		return fmt.Sprintf("host does not support any of %s versions %s", e.service, strings.Join(versions, ", "))
+	}
+	return fmt.Sprintf("host %s does not support any of %s versions %s", e.hostname, e.service, strings.Join(versions, ", "))
+}
+
+// ProtocolVersion returns the version of the discovery protocol that the
+// host's discovery document conforms to, as declared by the server using
+// the [ProtocolVersionHeader] response header.
+//
+// Hosts that don't declare a version are assumed to use [ProtocolVersion1].
+func (h *Host) ProtocolVersion() int {
+	if h == nil || h.protocolVersion == 0 {
+		return ProtocolVersion1
+	}
+	return h.protocolVersion
+}
+
+// ETag returns the entity tag from the ETag header of the discovery response
+// the receiver was built from, or an empty string if there was none.
+//
+// Together with [Host.LastModified], this allows a persistent cache to
+// revalidate a discovery result using a conditional request.
+func (h *Host) ETag() string {
+	if h == nil {
+		return ""
+	}
+	return h.etag
+}
+
+// LastModified returns the value of the Last-Modified header of the
+// discovery response the receiver was built from, or an empty string if
+// there was none.
+func (h *Host) LastModified() string {
+	if h == nil {
+		return ""
+	}
+	return h.lastModified
+}
+
+// ServiceIDs returns the identifiers of all of the services declared in the
+// host's discovery document, such as "modules.v1", in lexical order.
+//
+// The result may include services that this package doesn't know how to
+// interpret, and services whose definitions are invalid, but never includes
+// the [DocumentMetaKey] property.
+func (h *Host) ServiceIDs() []string {
+	if h == nil {
+		return nil
+	}
+	ret := slices.Sorted(maps.Keys(h.services))
+	return slices.DeleteFunc(ret, func(id string) bool {
+		return id == DocumentMetaKey
+	})
+}
+
+// SupportedVersions returns the major versions of the given service, such
+// as "providers", that the host declares, in ascending numeric order.
+//
+// The result is empty if the host does not provide the service at all.
+// Service IDs that don't have a valid version suffix are ignored.
+func (h *Host) SupportedVersions(name string) []uint64 {
+	return slices.Sorted(maps.Keys(h.serviceVersions(name)))
+}
+
+// ServiceURLLatest returns the URL of the highest version of the given
+// service, such as "modules", that the host declares and that is also one
+// of the given acceptable versions, along with the selected version.
+//
+// If no acceptable versions are given then the highest version declared by
+// the host is selected. If the host provides the service but none of the
+// acceptable versions then the error is [ErrNoSupportedVersion].
+func (h *Host) ServiceURLLatest(name string, acceptable ...uint64) (*url.URL, uint64, error) {
+	declared := h.serviceVersions(name)
+	if len(declared) == 0 {
+		var hostname string
+		if h != nil {
+			hostname = h.hostname
+		}
+		return nil, 0, &ErrServiceNotProvided{hostname: hostname, service: name}
+	}
+
+	var best uint64
+	var bestID string
+	for version, id := range declared {
+		if len(acceptable) != 0 && !slices.Contains(acceptable, version) {
+			continue
+		}
+		if bestID == "" || version > best {
+			best, bestID = version, id
+		}
+	}
+	if bestID == "" {
+		return nil, 0, &ErrNoSupportedVersion{
+			hostname:   h.hostname,
+			service:    name,
+			acceptable: slices.Sorted(slices.Values(acceptable)),
+		}
+	}
+
+	u, err := h.ServiceURL(bestID)
+	if err != nil {
+		return nil, 0, err
+	}
+	return u, best, nil
+}
+
+// serviceVersions returns a map from each declared major version of the given
+// service to the ID of the service entry that declares it.
+//
+// If there are several entries for the same major version, which is possible
+// only with the legacy minor version quirk of the "tfe" service, then the
+// lexically-lowest ID is used so that the result is deterministic.
+func (h *Host) serviceVersions(name string) map[uint64]string {
+	if h == nil {
+		return nil
+	}
+	ret := make(map[uint64]string)
+	for id := range h.services {
+		svcName, version, err := parseServiceID(id)
+		if err != nil || svcName != name {
+			continue
+		}
+		if existing, ok := ret[version]; !ok || id < existing {
+			ret[version] = id
+		}
+	}
+	return ret
+}
+
 // ServiceURL returns the URL associated with the given service identifier,
 // which should be of the form "servicename.vN".
 //
@@ -64,13 +255,78 @@ func (h *Host) ServiceURL(id string) (*url.URL, error) {
 		return nil, &ErrServiceNotProvided{service: svcName}
 	}
 
+	urlStr, err := h.serviceString(id, svcName, version)
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := h.parseURL(urlStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse service URL: %v", err)
+	}
+	if err := h.checkServiceURL(id, u); err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// ServiceEndpoint is like [Host.ServiceURL] except that the service may be
+// defined as a URI template, as described in [RFC 6570], which is expanded
+// using the given variables before resolving the result against the
+// discovery URL. Variables that the template refers to but that are not
+// present in vars are undefined, and so are omitted from the result.
+//
+// If the service definition is not a URI template then vars is ignored and
+// the result is the same as for [Host.ServiceURL].
+//
+// [RFC 6570]: https://datatracker.ietf.org/doc/html/rfc6570
+func (h *Host) ServiceEndpoint(id string, vars map[string]string) (*url.URL, error) {
+	svcName, version, err := parseServiceID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	// No services supported for an empty Host.
+	if h == nil || h.services == nil {
+		return nil, &ErrServiceNotProvided{service: svcName}
+	}
+
+	urlStr, err := h.serviceString(id, svcName, version)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.Contains(urlStr, "{") {
+		tmpl, err := uritemplates.Parse(urlStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid URI template for service %s: %w", id, err)
+		}
+		urlStr = tmpl.Expand(vars)
+	}
+
+	u, err := h.parseURL(urlStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse service URL: %v", err)
+	}
+	if err := h.checkServiceURL(id, u); err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// serviceString returns the string value of the service with the given
+// identifier, whose name and version have already been parsed by
+// [parseServiceID], or an error if the host doesn't provide it.
+func (h *Host) serviceString(id string, svcName string, version uint64) (string, error) {
 	urlStr, ok := h.services[id].(string)
 	if !ok {
 		// See if we have a matching service as that would indicate
 		// the service is supported, but not the requested version.
 		for serviceID := range h.services {
 			if strings.HasPrefix(serviceID, svcName+".") {
-				return nil, &ErrVersionNotSupported{
+				return "", &ErrVersionNotSupported{
 					hostname: h.hostname,
 					service:  svcName,
 					version:  version,
@@ -79,15 +335,9 @@ func (h *Host) ServiceURL(id string) (*url.URL, error) {
 		}
 
 		// No discovered services match the requested service.
-		return nil, &ErrServiceNotProvided{hostname: h.hostname, service: svcName}
-	}
-
-	u, err := h.parseURL(urlStr)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse service URL: %v", err)
+		return "", &ErrServiceNotProvided{hostname: h.hostname, service: svcName}
 	}
-
-	return u, nil
+	return urlStr, nil
 }
 
 // ServiceOAuthClient returns the OAuth client configuration associated with the
@@ -96,6 +346,9 @@ func (h *Host) ServiceURL(id string) (*url.URL, error) {
 // This is an alternative to ServiceURL for unusual services that require
 // a full OAuth2 client definition rather than just a URL. Use this only
 // for services whose specification calls for this sort of definition.
+//
+// The service must be defined with an object in the form described by
+// [OAuthClientConfig].
 func (h *Host) ServiceOAuthClient(id string) (*OAuthClient, error) {
 	serviceName, version, err := parseServiceID(id)
 	if err != nil {
@@ -135,102 +388,93 @@ func (h *Host) ServiceOAuthClient(id string) (*OAuthClient, error) {
 		return nil, fmt.Errorf("service %s must be declared with an object value in the service discovery document", id)
 	}
 
+	cfg, unknown, err := decodeOAuthClientConfig(id, raw)
+	if err != nil {
+		return nil, err
+	}
+	if len(unknown) != 0 && h.unknownOAuthFields != nil {
+		h.unknownOAuthFields(id, unknown)
+	}
+
 	var grantTypes OAuthGrantTypeSet
-	//nolint:nestif
-	if rawGTs, ok := raw["grant_types"]; ok {
-		if gts, ok := rawGTs.([]any); ok {
-			var kws []string
-			for _, gtI := range gts {
-				gt, ok := gtI.(string)
-				if !ok {
-					// We'll ignore this so that we can potentially introduce
-					// other types into this array later if we need to.
-					continue
-				}
-				kws = append(kws, gt)
-			}
-			grantTypes = NewOAuthGrantTypeSet(kws...)
-		} else {
-			return nil, fmt.Errorf("service %s is defined with invalid grant_types property: must be an array of grant type strings", id)
-		}
+	if _, ok := raw["grant_types"]; ok {
+		grantTypes = NewOAuthGrantTypeSet(cfg.GrantTypes...)
 	} else {
 		grantTypes = NewOAuthGrantTypeSet("authz_code")
 	}
 
 	ret := &OAuthClient{
+		ID:                  cfg.Client,
 		SupportedGrantTypes: grantTypes,
+		Secret:              cfg.ClientSecret,
 	}
-	if clientIDStr, ok := raw["client"].(string); ok {
-		ret.ID = clientIDStr
-	} else {
-		return nil, fmt.Errorf("service %s definition is missing required property \"client\"", id)
+	if len(cfg.Scopes) != 0 {
+		ret.Scopes = cfg.Scopes
 	}
-	if urlStr, ok := raw["authz"].(string); ok {
-		u, err := h.parseURL(urlStr)
+	// An empty string is a valid relative URL, referring to the discovery
+	// document itself, so only an absent property counts as missing.
+	if _, ok := raw["authz"].(string); ok {
+		u, err := h.parseURL(cfg.Authz)
 		if err != nil {
 			return nil, fmt.Errorf("failed to parse authorization URL: %v", err)
 		}
+		if err := h.checkServiceURL(id, u); err != nil {
+			return nil, err
+		}
 		ret.AuthorizationURL = u
 	} else if grantTypes.RequiresAuthorizationEndpoint() {
 		return nil, fmt.Errorf("service %s definition is missing required property \"authz\"", id)
 	}
-	if urlStr, ok := raw["token"].(string); ok {
-		u, err := h.parseURL(urlStr)
+	if _, ok := raw["token"].(string); ok {
+		u, err := h.parseURL(cfg.Token)
 		if err != nil {
 			return nil, fmt.Errorf("failed to parse token URL: %v", err)
 		}
+		if err := h.checkServiceURL(id, u); err != nil {
+			return nil, err
+		}
 		ret.TokenURL = u
 	} else if grantTypes.RequiresTokenEndpoint() {
 		return nil, fmt.Errorf("service %s definition is missing required property \"token\"", id)
 	}
-	//nolint:nestif
-	if portsRaw, ok := raw["ports"].([]any); ok {
-		if len(portsRaw) != 2 {
+	if cfg.DeviceAuthz != "" {
+		u, err := h.parseURL(cfg.DeviceAuthz)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse device authorization URL: %v", err)
+		}
+		if err := h.checkServiceURL(id, u); err != nil {
+			return nil, err
+		}
+		ret.DeviceAuthorizationURL = u
+	}
+	if cfg.Ports != nil {
+		if len(cfg.Ports) != 2 {
 			return nil, fmt.Errorf("invalid \"ports\" definition for service %s: must be a two-element array", id)
 		}
-		invalidPortsErr := fmt.Errorf("invalid \"ports\" definition for service %s: both ports must be whole numbers between 1024 and 65535", id)
-		ports := make([]uint16, 2)
-		for i := range ports {
-			switch v := portsRaw[i].(type) {
-			case float64:
-				// JSON unmarshaling always produces float64. HCL 2 might, if
-				// an invalid fractional number were given.
-				if float64(uint16(v)) != v || v < 1024 {
-					return nil, invalidPortsErr
-				}
-				ports[i] = uint16(v)
-			case int:
-				// Legacy HCL produces int. HCL 2 will too, if the given number
-				// is a whole number.
-				if v < 1024 || v > 65535 {
-					return nil, invalidPortsErr
-				}
-				ports[i] = uint16(v)
-			default:
-				return nil, invalidPortsErr
+		for _, port := range cfg.Ports {
+			if port < 1024 || port > 65535 {
+				return nil, fmt.Errorf("invalid \"ports\" definition for service %s: both ports must be whole numbers between 1024 and 65535", id)
 			}
 		}
-		if ports[1] < ports[0] {
+		if cfg.Ports[1] < cfg.Ports[0] {
 			return nil, fmt.Errorf("invalid \"ports\" definition for service %s: minimum port cannot be greater than maximum port", id)
 		}
-		ret.MinPort = ports[0]
-		ret.MaxPort = ports[1]
+		ret.MinPort = uint16(cfg.Ports[0])
+		ret.MaxPort = uint16(cfg.Ports[1])
 	} else {
 		// Default is to accept any port in the range, for a client that is
 		// able to call back to any localhost port.
 		ret.MinPort = 1024
 		ret.MaxPort = 65535
 	}
-	if scopesRaw, ok := raw["scopes"].([]any); ok {
-		var scopes []string
-		for _, scopeI := range scopesRaw {
-			scope, ok := scopeI.(string)
-			if !ok {
-				return nil, fmt.Errorf("invalid \"scopes\" for service %s: all scopes must be strings", id)
-			}
-			scopes = append(scopes, scope)
-		}
-		ret.Scopes = scopes
+	for _, method := range cfg.TokenEndpointAuthMethods {
+		ret.TokenEndpointAuthMethods = append(ret.TokenEndpointAuthMethods, OAuthTokenEndpointAuthMethod(method))
+	}
+	// If the service declares auth methods then at least one of them must
+	// be usable with the information we have.
+	if len(ret.TokenEndpointAuthMethods) != 0 && ret.Secret == "" &&
+		!slices.ContainsFunc(ret.TokenEndpointAuthMethods, func(m OAuthTokenEndpointAuthMethod) bool { return !m.UsesClientSecret() }) {
+		return nil, fmt.Errorf("service %s definition is missing required property \"client_secret\"", id)
 	}
 
 	return ret, nil
@@ -0,0 +1,86 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package disco
+
+import (
+	"fmt"
+	"net/http"
+
+	"golang.org/x/net/http/httpguts"
+
+	svchost "github.com/opentofu/svchost"
+)
+
+// SetHostHeader arranges for discovery requests for the given hostname to
+// include a header with the given name and value, such as a tenant
+// identifier or an access key required by a CDN in front of that host.
+// An empty value removes a header previously set for the hostname.
+//
+// The header replaces any header of the same name that discovery would
+// otherwise send, except that credentials from the credentials source and
+// changes made by a [RequestMutator] take precedence. The header is not
+// sent when following a redirect to a different host. When the hostname is
+// the target of an alias, the header applies to discovery for the alias too.
+//
+// Any cached result for the hostname is discarded so that the next discovery
+// includes the header.
+func (d *Disco) SetHostHeader(hostname svchost.Hostname, key, value string) error {
+	if !httpguts.ValidHeaderFieldName(key) {
+		return fmt.Errorf("invalid header name %q", key)
+	}
+	if !httpguts.ValidHeaderFieldValue(value) {
+		return fmt.Errorf("invalid value for header %q", key)
+	}
+	d.mu.Lock()
+	if value == "" {
+		d.hostHeaders[hostname].Del(key)
+		if len(d.hostHeaders[hostname]) == 0 {
+			delete(d.hostHeaders, hostname)
+		}
+	} else {
+		if d.hostHeaders[hostname] == nil {
+			d.hostHeaders[hostname] = make(http.Header)
+		}
+		d.hostHeaders[hostname].Set(key, value)
+	}
+	forgotten := d.forgetInternal(hostname)
+	d.mu.Unlock()
+	if forgotten {
+		d.publishForgotten(hostname)
+	}
+	return nil
+}
+
+// hostHeader returns a copy of the extra headers set by [Disco.SetHostHeader]
+// for the given hostname, or nil if there are none.
+func (d *Disco) hostHeader(hostname svchost.Hostname) http.Header {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.hostHeaders[hostname]) == 0 {
+		return nil
+	}
+	return d.hostHeaders[hostname].Clone()
+}
+
+// setHostHeader adds the given extra headers to the given request, replacing
+// any existing headers of the same names.
+func setHostHeader(req *http.Request, header http.Header) {
+	for name, values := range header {
+		req.Header[name] = append([]string(nil), values...)
+	}
+}
+
+// redirectHostHeader removes the given extra headers for the given hostname
+// from the given request, which follows a redirect from the discovery
+// request, if it's for a different host.
+func redirectHostHeader(req *http.Request, via []*http.Request, hostname svchost.Hostname, header http.Header) {
+	target, err := svchost.ForComparisonAllowIP(req.URL.Host)
+	origHost, _ := svchost.ForComparisonAllowIP(via[0].URL.Host)
+	if err == nil && (target == hostname || target == origHost) {
+		return
+	}
+	for name := range header {
+		req.Header.Del(name)
+	}
+}
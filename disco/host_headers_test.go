@@ -0,0 +1,106 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package disco
+
+import (
+	"net/http"
+	"testing"
+
+	svchost "github.com/opentofu/svchost"
+)
+
+func TestSetHostHeader(t *testing.T) {
+	var gotTenant, gotAccept string
+	portStr, cleanup := testServer(func(w http.ResponseWriter, r *http.Request) {
+		gotTenant = r.Header.Get("X-Tenant-ID")
+		gotAccept = r.Header.Get("Accept")
+		w.Header().Add("Content-Type", "application/json")
+		w.Write([]byte(`{"thingy.v1": "/foo"}`))
+	})
+	defer cleanup()
+
+	host := svchost.Hostname("localhost" + portStr)
+	other := svchost.Hostname("127.0.0.1" + portStr)
+	d := New(WithHTTPClient(testClient))
+	if err := d.SetHostHeader(host, "X-Tenant-ID", "tenant1"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if _, err := d.Discover(t.Context(), host); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got, want := gotTenant, "tenant1"; got != want {
+		t.Errorf("wrong X-Tenant-ID %q; want %q", got, want)
+	}
+	if got, want := gotAccept, "application/json"; got != want {
+		t.Errorf("wrong Accept %q; want %q", got, want)
+	}
+
+	// The header is only for the host it was set for.
+	if _, err := d.Discover(t.Context(), other); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if gotTenant != "" {
+		t.Errorf("unexpected X-Tenant-ID %q for other host", gotTenant)
+	}
+
+	// Changing the header discards the cached result, so that the next
+	// discovery uses the new value.
+	if err := d.SetHostHeader(host, "X-Tenant-ID", "tenant2"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := d.Discover(t.Context(), host); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got, want := gotTenant, "tenant2"; got != want {
+		t.Errorf("wrong X-Tenant-ID %q; want %q", got, want)
+	}
+
+	// An empty value removes the header.
+	if err := d.SetHostHeader(host, "X-Tenant-ID", ""); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := d.Discover(t.Context(), host); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if gotTenant != "" {
+		t.Errorf("unexpected X-Tenant-ID %q after removal", gotTenant)
+	}
+}
+
+func TestSetHostHeaderInvalid(t *testing.T) {
+	d := New()
+	if err := d.SetHostHeader("example.com", "X Bad", "value"); err == nil {
+		t.Error("unexpected success for invalid name")
+	}
+	if err := d.SetHostHeader("example.com", "X-Good", "bad\nvalue"); err == nil {
+		t.Error("unexpected success for invalid value")
+	}
+}
+
+func TestSetHostHeaderRedirect(t *testing.T) {
+	var gotTenant string
+	targetPortStr, cleanup := testServer(func(w http.ResponseWriter, r *http.Request) {
+		gotTenant = r.Header.Get("X-Tenant-ID")
+		w.Header().Add("Content-Type", "application/json")
+		w.Write([]byte(`{"thingy.v1": "/foo"}`))
+	})
+	defer cleanup()
+	portStr, cleanup := testServer(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "https://127.0.0.1"+targetPortStr+"/.well-known/terraform.json", http.StatusFound)
+	})
+	defer cleanup()
+
+	host := svchost.Hostname("localhost" + portStr)
+	d := New(WithHTTPClient(testClient))
+	if err := d.SetHostHeader(host, "X-Tenant-ID", "tenant1"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := d.Discover(t.Context(), host); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if gotTenant != "" {
+		t.Errorf("X-Tenant-ID %q was sent to redirect target on a different host", gotTenant)
+	}
+}
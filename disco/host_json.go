@@ -0,0 +1,100 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package disco
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+
+	svchost "github.com/opentofu/svchost"
+)
+
+// NewHost returns a [Host] describing the given services as if they had been
+// discovered from a document at the given URL, against which any relative
+// service URLs are resolved.
+//
+// This is for programs that save discovery results elsewhere and later
+// restore them, such as by using [Disco.CacheHost] to seed the cache of a
+// new [Disco] object. The given URL must be absolute.
+func NewHost(hostname svchost.Hostname, discoURL *url.URL, services map[string]any) (*Host, error) {
+	if discoURL == nil || !discoURL.IsAbs() {
+		return nil, fmt.Errorf("discovery URL for %s must be absolute", hostname.ForDisplay())
+	}
+	if services == nil {
+		services = map[string]any{}
+	}
+	return &Host{
+		discoURL:        discoURL,
+		hostname:        hostname.ForDisplay(),
+		services:        services,
+		protocolVersion: ProtocolVersion1,
+	}, nil
+}
+
+// hostJSON is the JSON representation of a [Host].
+type hostJSON struct {
+	Hostname        string         `json:"hostname"`
+	DiscoveryURL    string         `json:"discovery_url"`
+	ProtocolVersion int            `json:"protocol_version"`
+	Services        map[string]any `json:"services"`
+	ETag            string         `json:"etag,omitempty"`
+	LastModified    string         `json:"last_modified,omitempty"`
+}
+
+// MarshalJSON implements [json.Marshaler], producing a JSON object that
+// describes the hostname, discovery URL, and services of the receiver, which
+// [Host.UnmarshalJSON] can later restore.
+//
+// The response headers are not included, and so a restored Host has no rate
+// limits or deprecations.
+func (h *Host) MarshalJSON() ([]byte, error) {
+	if h == nil {
+		return []byte("null"), nil
+	}
+	raw := hostJSON{
+		Hostname:        h.hostname,
+		ProtocolVersion: h.ProtocolVersion(),
+		Services:        h.services,
+		ETag:            h.etag,
+		LastModified:    h.lastModified,
+	}
+	if raw.Services == nil {
+		raw.Services = map[string]any{}
+	}
+	if h.discoURL != nil {
+		raw.DiscoveryURL = h.discoURL.String()
+	}
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON implements [json.Unmarshaler], restoring a Host from the
+// representation produced by [Host.MarshalJSON].
+func (h *Host) UnmarshalJSON(src []byte) error {
+	var raw hostJSON
+	if err := json.Unmarshal(src, &raw); err != nil {
+		return err
+	}
+	hostname, err := svchost.ForComparisonAllowIP(raw.Hostname)
+	if err != nil {
+		return fmt.Errorf("invalid hostname %q: %w", raw.Hostname, err)
+	}
+	discoURL, err := url.Parse(raw.DiscoveryURL)
+	if err != nil || !discoURL.IsAbs() {
+		return fmt.Errorf("invalid discovery URL %q for %s", raw.DiscoveryURL, hostname.ForDisplay())
+	}
+	services := raw.Services
+	if services == nil {
+		services = map[string]any{}
+	}
+	*h = Host{
+		discoURL:        discoURL,
+		hostname:        hostname.ForDisplay(),
+		services:        services,
+		protocolVersion: raw.ProtocolVersion,
+		etag:            raw.ETag,
+		lastModified:    raw.LastModified,
+	}
+	return nil
+}
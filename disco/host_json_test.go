@@ -0,0 +1,75 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package disco
+
+import (
+	"encoding/json"
+	"net/url"
+	"testing"
+
+	svchost "github.com/opentofu/svchost"
+)
+
+func TestHostJSON(t *testing.T) {
+	discoURL, _ := url.Parse("https://example.com/.well-known/terraform.json")
+	orig, err := NewHost("example.com", discoURL, map[string]any{
+		"modules.v1": "/api/modules/v1/",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	src, err := json.Marshal(orig)
+	if err != nil {
+		t.Fatalf("failed to marshal: %s", err)
+	}
+	const want = `{"hostname":"example.com","discovery_url":"https://example.com/.well-known/terraform.json","protocol_version":1,"services":{"modules.v1":"/api/modules/v1/"}}`
+	if got := string(src); got != want {
+		t.Errorf("wrong JSON\ngot:  %s\nwant: %s", got, want)
+	}
+
+	var restored *Host
+	if err := json.Unmarshal(src, &restored); err != nil {
+		t.Fatalf("failed to unmarshal: %s", err)
+	}
+	gotURL, err := restored.ServiceURL("modules.v1")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got, want := gotURL.String(), "https://example.com/api/modules/v1/"; got != want {
+		t.Errorf("wrong service URL %q; want %q", got, want)
+	}
+
+	// A restored host can seed the cache of a new Disco object, which then
+	// doesn't need to make a discovery request.
+	d := New()
+	d.CacheHost("example.com", restored)
+	got, err := d.Discover(t.Context(), svchost.Hostname("example.com"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got != restored {
+		t.Error("Discover did not return the cached host")
+	}
+}
+
+func TestHostJSONInvalid(t *testing.T) {
+	if _, err := NewHost("example.com", &url.URL{Path: "/relative"}, nil); err == nil {
+		t.Error("unexpected success with relative discovery URL")
+	}
+
+	tests := map[string]string{
+		"not an object":    `"example.com"`,
+		"invalid hostname": `{"hostname":"example..com","discovery_url":"https://example.com/"}`,
+		"relative URL":     `{"hostname":"example.com","discovery_url":"/.well-known/terraform.json"}`,
+	}
+	for name, src := range tests {
+		t.Run(name, func(t *testing.T) {
+			var h Host
+			if err := json.Unmarshal([]byte(src), &h); err == nil {
+				t.Error("unexpected success; want error")
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@
 package disco
 
 import (
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -73,6 +74,153 @@ func TestHostServiceURL(t *testing.T) {
 	}
 }
 
+func TestHostServiceEndpoint(t *testing.T) {
+	baseURL, _ := url.Parse("https://example.com/disco/foo.json")
+	host := Host{
+		discoURL: baseURL,
+		hostname: "test-server",
+		services: map[string]any{
+			"plain.v1":    "/plain/",
+			"absolute.v1": "https://example.net/{namespace}/{name}",
+			"relative.v1": "./modules/{namespace}/{name}/versions",
+			"query.v1":    "/search{?q,limit}",
+			"reserved.v1": "/files/{+path}",
+			"invalid.v1":  "/unclosed/{namespace",
+		},
+	}
+	vars := map[string]string{
+		"namespace": "hashi corp",
+		"name":      "aws",
+		"q":         "network",
+		"path":      "a/b",
+	}
+
+	tests := []struct {
+		ID   string
+		want string
+		err  string
+	}{
+		{"plain.v1", "https://example.com/plain/", ""},
+		{"absolute.v1", "https://example.net/hashi%20corp/aws", ""},
+		{"relative.v1", "https://example.com/disco/modules/hashi%20corp/aws/versions", ""},
+		{"query.v1", "https://example.com/search?q=network", ""},
+		{"reserved.v1", "https://example.com/files/a/b", ""},
+		{"invalid.v1", "<nil>", "invalid URI template for service invalid.v1"},
+		{"absolute.v2", "<nil>", "does not support absolute version 2"},
+		{"missing.v1", "<nil>", "does not provide a missing service"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.ID, func(t *testing.T) {
+			serviceURL, err := host.ServiceEndpoint(test.ID, vars)
+			if (err != nil || test.err != "") &&
+				(err == nil || !strings.Contains(err.Error(), test.err)) {
+				t.Fatalf("unexpected service endpoint error: %s", err)
+			}
+
+			var got string
+			if serviceURL != nil {
+				got = serviceURL.String()
+			} else {
+				got = "<nil>"
+			}
+
+			if got != test.want {
+				t.Errorf("wrong result\ngot:  %s\nwant: %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestHostServiceIDs(t *testing.T) {
+	host := &Host{
+		services: map[string]any{
+			"providers.v1": "/providers/",
+			"modules.v1":   "/modules/",
+			"login.v1":     map[string]any{"client": "tofu"},
+		},
+	}
+	got := host.ServiceIDs()
+	want := []string{"login.v1", "modules.v1", "providers.v1"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("wrong result\n%s", diff)
+	}
+
+	if got := (*Host)(nil).ServiceIDs(); len(got) != 0 {
+		t.Errorf("unexpected services for nil host: %q", got)
+	}
+}
+
+func TestHostServiceVersions(t *testing.T) {
+	baseURL, _ := url.Parse("https://example.com/.well-known/terraform.json")
+	host := &Host{
+		discoURL: baseURL,
+		hostname: "example.com",
+		services: map[string]any{
+			"modules.v1":   "/modules/v1/",
+			"modules.v2":   "/modules/v2/",
+			"modules.v10":  "/modules/v10/",
+			"modules.vX":   "/invalid/",
+			"providers.v1": "/providers/v1/",
+			"tfe.v2":       "/tfe/",
+			"tfe.v2.1":     "/tfe/",
+		},
+	}
+
+	t.Run("SupportedVersions", func(t *testing.T) {
+		if diff := cmp.Diff([]uint64{1, 2, 10}, host.SupportedVersions("modules")); diff != "" {
+			t.Errorf("wrong modules versions\n%s", diff)
+		}
+		if diff := cmp.Diff([]uint64{2}, host.SupportedVersions("tfe")); diff != "" {
+			t.Errorf("wrong tfe versions\n%s", diff)
+		}
+		if got := host.SupportedVersions("login"); len(got) != 0 {
+			t.Errorf("unexpected login versions %v", got)
+		}
+		if got := (*Host)(nil).SupportedVersions("modules"); len(got) != 0 {
+			t.Errorf("unexpected versions for nil host %v", got)
+		}
+	})
+
+	tests := []struct {
+		name        string
+		acceptable  []uint64
+		wantURL     string
+		wantVersion uint64
+		wantErr     string
+	}{
+		{"modules", nil, "https://example.com/modules/v10/", 10, ""},
+		{"modules", []uint64{1, 2}, "https://example.com/modules/v2/", 2, ""},
+		{"modules", []uint64{3, 1}, "https://example.com/modules/v1/", 1, ""},
+		{"modules", []uint64{4, 3}, "", 0, "host example.com does not support any of modules versions v3, v4"},
+		{"providers", nil, "https://example.com/providers/v1/", 1, ""},
+		{"login", nil, "", 0, "host example.com does not provide a login service"},
+	}
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("ServiceURLLatest(%q, %v)", test.name, test.acceptable), func(t *testing.T) {
+			u, version, err := host.ServiceURLLatest(test.name, test.acceptable...)
+			if test.wantErr != "" {
+				if err == nil {
+					t.Fatalf("unexpected success; want error %q", test.wantErr)
+				}
+				if got := err.Error(); got != test.wantErr {
+					t.Errorf("wrong error\ngot:  %s\nwant: %s", got, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got := u.String(); got != test.wantURL {
+				t.Errorf("wrong URL\ngot:  %s\nwant: %s", got, test.wantURL)
+			}
+			if version != test.wantVersion {
+				t.Errorf("wrong version %d; want %d", version, test.wantVersion)
+			}
+		})
+	}
+}
+
 func TestHostServiceOAuthClient(t *testing.T) {
 	baseURL, _ := url.Parse("https://example.com/disco/foo.json")
 	host := Host{
@@ -110,6 +258,18 @@ func TestHostServiceOAuthClient(t *testing.T) {
 				"token":       "./token",
 				"grant_types": []any{"password"},
 			},
+			"devicecode.v1": map[string]any{
+				"client":       "devicecode",
+				"token":        "./token",
+				"device_authz": "./device",
+				"grant_types":  []any{"device_code"},
+			},
+			"invaliddeviceauthz.v1": map[string]any{
+				"client":       "invaliddeviceauthz",
+				"token":        "./token",
+				"device_authz": "***not A URL at all!:/<@@@@>***",
+				"grant_types":  []any{"device_code"},
+			},
 			"absolute.v1": map[string]any{
 				"client": "absolute",
 				"authz":  "http://example.net/foo/authz",
@@ -168,6 +328,25 @@ func TestHostServiceOAuthClient(t *testing.T) {
 				"token":  "/token",
 				"scopes": []any{"app1.full_access", 42},
 			},
+			"clientsecret.v1": map[string]any{
+				"client":                                "clientsecret",
+				"client_secret":                         "s3cret",
+				"authz":                                 "/auth",
+				"token":                                 "/token",
+				"token_endpoint_auth_methods_supported": []any{"client_secret_basic", "private_key_jwt"},
+			},
+			"clientsecretmissing.v1": map[string]any{
+				"client":                                "clientsecretmissing",
+				"authz":                                 "/auth",
+				"token":                                 "/token",
+				"token_endpoint_auth_methods_supported": []any{"client_secret_basic", "client_secret_post"},
+			},
+			"authmethodsbad.v1": map[string]any{
+				"client":                                "authmethodsbad",
+				"authz":                                 "/auth",
+				"token":                                 "/token",
+				"token_endpoint_auth_methods_supported": "none",
+			},
 		},
 	}
 
@@ -235,6 +414,23 @@ func TestHostServiceOAuthClient(t *testing.T) {
 			},
 			"",
 		},
+		{
+			"devicecode.v1",
+			&OAuthClient{
+				ID:                     "devicecode",
+				TokenURL:               mustURL(t, "https://example.com/disco/token"),
+				DeviceAuthorizationURL: mustURL(t, "https://example.com/disco/device"),
+				MinPort:                1024,
+				MaxPort:                65535,
+				SupportedGrantTypes:    NewOAuthGrantTypeSet("device_code"),
+			},
+			"",
+		},
+		{
+			"invaliddeviceauthz.v1",
+			nil,
+			"failed to parse device authorization URL",
+		},
 		{
 			"absolute.v1",
 			&OAuthClient{
@@ -340,6 +536,30 @@ func TestHostServiceOAuthClient(t *testing.T) {
 			nil,
 			`invalid "scopes" for service scopesbad.v1: all scopes must be strings`,
 		},
+		{
+			"clientsecret.v1",
+			&OAuthClient{
+				ID:                       "clientsecret",
+				AuthorizationURL:         mustURL(t, "https://example.com/auth"),
+				TokenURL:                 mustURL(t, "https://example.com/token"),
+				MinPort:                  1024,
+				MaxPort:                  65535,
+				SupportedGrantTypes:      NewOAuthGrantTypeSet("authz_code"),
+				Secret:                   "s3cret",
+				TokenEndpointAuthMethods: []OAuthTokenEndpointAuthMethod{OAuthAuthMethodClientSecretBasic, "private_key_jwt"},
+			},
+			"",
+		},
+		{
+			"clientsecretmissing.v1",
+			nil,
+			`service clientsecretmissing.v1 definition is missing required property "client_secret"`,
+		},
+		{
+			"authmethodsbad.v1",
+			nil,
+			`invalid "token_endpoint_auth_methods_supported" for service authmethodsbad.v1: must be an array of strings`,
+		},
 	}
 
 	for _, test := range tests {
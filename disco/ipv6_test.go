@@ -0,0 +1,98 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package disco
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/opentofu/svchost"
+	"github.com/opentofu/svchost/svcauth"
+)
+
+func TestDiscoverIPv6Literal(t *testing.T) {
+	listener, err := net.Listen("tcp6", "[::1]:0")
+	if err != nil {
+		t.Skipf("IPv6 loopback is not available: %s", err)
+	}
+	var gotAuth, gotHost string
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/old.json":
+			http.Redirect(w, r, "/.well-known/terraform.json", http.StatusFound)
+		case "/.well-known/terraform.json":
+			gotAuth = r.Header.Get("Authorization")
+			gotHost = r.Host
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"modules.v1":"/v1/modules/","providers.v1":"https://[::1]:9000/v1/providers/"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	server.Listener = listener
+	server.StartTLS()
+	defer server.Close()
+
+	hostname := svchost.ForAddrPort(listener.Addr().(*net.TCPAddr).AddrPort())
+
+	// A non-normalized form of the address must refer to the same host.
+	given := fmt.Sprintf("[0:0:0:0:0:0:0:1]:%d", hostname.Port())
+	if got, err := svchost.ForComparisonAllowIP(given); err != nil || got != hostname {
+		t.Fatalf("%q normalized to %q, %v; want %q", given, got, err, hostname)
+	}
+
+	creds := svcauth.StaticCredentialsSource(map[svchost.Hostname]svcauth.HostCredentials{
+		hostname: svcauth.HostCredentialsToken("abc123"),
+	})
+	d, err := NewWithErrors(WithHTTPClient(testClient), WithCredentials(creds))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := d.SetDiscoveryPath(hostname, "/old.json"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	host, err := d.Discover(t.Context(), hostname)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got, want := gotAuth, "Bearer abc123"; got != want {
+		t.Errorf("wrong Authorization header %q; want %q", got, want)
+	}
+	if got, want := gotHost, hostname.String(); got != want {
+		t.Errorf("wrong Host header %q; want %q", got, want)
+	}
+
+	tests := map[string]string{
+		"modules.v1":   server.URL + "/v1/modules/",
+		"providers.v1": "https://[::1]:9000/v1/providers/",
+	}
+	for serviceID, want := range tests {
+		u, err := host.ServiceURL(serviceID)
+		if err != nil {
+			t.Errorf("unexpected error for %s: %s", serviceID, err)
+			continue
+		}
+		if got := u.String(); got != want {
+			t.Errorf("wrong URL for %s %q; want %q", serviceID, got, want)
+		}
+	}
+
+	// The result must round-trip through JSON, which records the hostname
+	// in its display form.
+	raw, err := host.MarshalJSON()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	var restored Host
+	if err := restored.UnmarshalJSON(raw); err != nil {
+		t.Fatalf("failed to restore %s: %s", raw, err)
+	}
+	if diff := host.Diff(&restored); len(diff) != 0 {
+		t.Errorf("restored host differs: %v", diff)
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package disco
+
+import (
+	"fmt"
+	"mime"
+	"slices"
+	"strings"
+)
+
+// defaultMediaType is the media type that discovery documents are expected
+// to have, which is always accepted.
+const defaultMediaType = "application/json"
+
+// acceptedMediaType returns true if a discovery document with the given
+// media type, as returned by [mime.ParseMediaType], can be accepted.
+func (d *Disco) acceptedMediaType(mediaType string) bool {
+	return mediaType == defaultMediaType || slices.Contains(d.extraMediaTypes, mediaType)
+}
+
+// acceptHeader returns the value to use for the Accept header in discovery
+// requests, which lists the default media type first so that servers that
+// support content negotiation prefer it.
+func (d *Disco) acceptHeader() string {
+	return strings.Join(append([]string{defaultMediaType}, d.extraMediaTypes...), ", ")
+}
+
+// normalizeMediaType checks that the given string is a media type without
+// any parameters and returns it in the lowercase form that
+// [mime.ParseMediaType] produces, for comparison with response headers.
+func normalizeMediaType(s string) (string, error) {
+	mediaType, params, err := mime.ParseMediaType(s)
+	if err != nil {
+		return "", fmt.Errorf("invalid media type %q: %w", s, err)
+	}
+	if len(params) != 0 {
+		return "", fmt.Errorf("invalid media type %q: must not have parameters", s)
+	}
+	if !strings.Contains(mediaType, "/") || strings.Contains(mediaType, "*") {
+		return "", fmt.Errorf("invalid media type %q: must be a type and subtype without wildcards", s)
+	}
+	return mediaType, nil
+}
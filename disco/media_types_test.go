@@ -0,0 +1,90 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package disco
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/opentofu/svchost"
+)
+
+func TestWithAcceptedMediaTypes(t *testing.T) {
+	tests := map[string]struct {
+		options     []DiscoOption
+		contentType string
+		wantAccept  string
+		wantErr     bool
+	}{
+		"default": {
+			contentType: "application/json",
+			wantAccept:  "application/json",
+		},
+		"default rejects others": {
+			contentType: "text/json",
+			wantAccept:  "application/json",
+			wantErr:     true,
+		},
+		"extended": {
+			options:     []DiscoOption{WithAcceptedMediaTypes("application/vnd.api+json", "Text/JSON")},
+			contentType: "text/json; charset=utf-8",
+			wantAccept:  "application/json, application/vnd.api+json, text/json",
+		},
+		"extended still accepts default": {
+			options:     []DiscoOption{WithAcceptedMediaTypes("text/json")},
+			contentType: "application/json",
+			wantAccept:  "application/json, text/json",
+		},
+		"extended rejects others": {
+			options:     []DiscoOption{WithAcceptedMediaTypes("text/json")},
+			contentType: "text/plain",
+			wantAccept:  "application/json, text/json",
+			wantErr:     true,
+		},
+		"duplicates": {
+			options:     []DiscoOption{WithAcceptedMediaTypes("application/json", "text/json"), WithAcceptedMediaTypes("text/json")},
+			contentType: "text/json",
+			wantAccept:  "application/json, text/json",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var gotAccept string
+			portStr, cleanup := testServer(func(w http.ResponseWriter, r *http.Request) {
+				gotAccept = r.Header.Get("Accept")
+				w.Header().Set("Content-Type", test.contentType)
+				w.Write([]byte(`{"thingy.v1":"http://example.com/foo"}`))
+			})
+			defer cleanup()
+
+			d, err := NewWithErrors(append([]DiscoOption{WithHTTPClient(testClient)}, test.options...)...)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			_, err = d.Discover(t.Context(), svchost.Hostname("localhost"+portStr))
+			if gotAccept != test.wantAccept {
+				t.Errorf("wrong Accept header %q; want %q", gotAccept, test.wantAccept)
+			}
+			if test.wantErr {
+				var docErr *ErrDiscoveryInvalidDocument
+				if !errors.As(err, &docErr) {
+					t.Errorf("wrong error %v; want ErrDiscoveryInvalidDocument", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+		})
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, mediaType := range []string{"json", "text/json; charset=utf-8", "application/*", ""} {
+			if _, err := NewWithErrors(WithAcceptedMediaTypes(mediaType)); err == nil {
+				t.Errorf("unexpected success for %q; want error", mediaType)
+			}
+		}
+	})
+}
@@ -0,0 +1,52 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package disco
+
+import (
+	"context"
+	"time"
+
+	"github.com/opentofu/svchost"
+)
+
+// negativeCacheEntry is a failed discovery result that is remembered for
+// a short time when [WithNegativeCacheTTL] is in effect.
+type negativeCacheEntry struct {
+	err     error
+	expires time.Time
+}
+
+// loadNegative returns the remembered error for the given hostname, or nil
+// if there is none or it has expired.
+func (d *Disco) loadNegative(hostname svchost.Hostname) error {
+	if d.negativeCacheTTL <= 0 {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	entry, ok := d.negativeCache[hostname]
+	if !ok {
+		return nil
+	}
+	if !d.clock.Now().Before(entry.expires) {
+		delete(d.negativeCache, hostname)
+		return nil
+	}
+	return entry.err
+}
+
+// saveNegative remembers the given discovery error for the given hostname,
+// unless negative caching is disabled or the error was caused by the given
+// context ending, which says nothing about the health of the host.
+func (d *Disco) saveNegative(ctx context.Context, hostname svchost.Hostname, err error) {
+	if d.negativeCacheTTL <= 0 || ctx.Err() != nil {
+		return
+	}
+	d.mu.Lock()
+	d.negativeCache[hostname] = negativeCacheEntry{
+		err:     err,
+		expires: d.clock.Now().Add(d.negativeCacheTTL),
+	}
+	d.mu.Unlock()
+}
@@ -0,0 +1,113 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package disco
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/opentofu/svchost"
+	"github.com/opentofu/svchost/internal/clock"
+)
+
+func TestWithNegativeCacheTTL(t *testing.T) {
+	var requests atomic.Int32
+	var healthy atomic.Bool
+	portStr, cleanup := testServer(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Add("Content-Type", "application/json")
+		w.Write([]byte(`{"thingy.v1":"http://example.com/foo"}`))
+	})
+	defer cleanup()
+	hostname := svchost.Hostname("localhost" + portStr)
+
+	clk := clock.NewFake(time.Now())
+	d, err := NewWithErrors(WithHTTPClient(testClient), WithNegativeCacheTTL(time.Minute), WithClock(clk))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var negativeCached []error
+	ctx := ContextWithDiscoTrace(t.Context(), &DiscoTrace{
+		DiscoveryNegativeCached: func(_ context.Context, host svchost.Hostname, err error) {
+			negativeCached = append(negativeCached, err)
+		},
+	})
+
+	_, firstErr := d.Discover(ctx, hostname)
+	var statusErr *ErrDiscoveryHTTPStatus
+	if !errors.As(firstErr, &statusErr) {
+		t.Fatalf("wrong error %v; want ErrDiscoveryHTTPStatus", firstErr)
+	}
+	_, secondErr := d.Discover(ctx, hostname)
+	if secondErr != firstErr {
+		t.Errorf("wrong second error %v; want the cached error %v", secondErr, firstErr)
+	}
+	if got, want := requests.Load(), int32(1); got != want {
+		t.Errorf("wrong number of requests %d; want %d", got, want)
+	}
+	if len(negativeCached) != 1 || negativeCached[0] != firstErr {
+		t.Errorf("wrong DiscoveryNegativeCached calls %v; want one with the cached error", negativeCached)
+	}
+
+	// Once the entry expires we try again.
+	healthy.Store(true)
+	clk.Advance(time.Minute)
+	if _, err := d.Discover(ctx, hostname); err != nil {
+		t.Fatalf("unexpected error after expiry: %s", err)
+	}
+	if got, want := requests.Load(), int32(2); got != want {
+		t.Errorf("wrong number of requests %d; want %d", got, want)
+	}
+
+	t.Run("forget", func(t *testing.T) {
+		healthy.Store(false)
+		d.Forget(hostname)
+		if _, err := d.Discover(t.Context(), hostname); err == nil {
+			t.Fatal("unexpected success; want error")
+		}
+		healthy.Store(true)
+		d.Forget(hostname)
+		if _, err := d.Discover(t.Context(), hostname); err != nil {
+			t.Fatalf("unexpected error after Forget: %s", err)
+		}
+	})
+	t.Run("refresh", func(t *testing.T) {
+		healthy.Store(false)
+		d.Forget(hostname)
+		if _, err := d.Discover(t.Context(), hostname); err == nil {
+			t.Fatal("unexpected success; want error")
+		}
+		healthy.Store(true)
+		if _, err := d.Refresh(t.Context(), hostname); err != nil {
+			t.Fatalf("unexpected error from Refresh: %s", err)
+		}
+		if _, err := d.Discover(t.Context(), hostname); err != nil {
+			t.Fatalf("unexpected error after Refresh: %s", err)
+		}
+	})
+	t.Run("canceled context", func(t *testing.T) {
+		d.Forget(hostname)
+		ctx, cancel := context.WithCancel(t.Context())
+		cancel()
+		if _, err := d.Discover(ctx, hostname); err == nil {
+			t.Fatal("unexpected success with canceled context; want error")
+		}
+		if _, err := d.Discover(t.Context(), hostname); err != nil {
+			t.Fatalf("cancellation was cached: %s", err)
+		}
+	})
+
+	if _, err := NewWithErrors(WithNegativeCacheTTL(-time.Second)); err == nil {
+		t.Error("unexpected success with negative duration; want error")
+	}
+}
@@ -5,8 +5,11 @@
 package disco
 
 import (
+	"encoding/json"
 	"fmt"
+	"maps"
 	"net/url"
+	"slices"
 	"strings"
 
 	"golang.org/x/oauth2"
@@ -34,6 +37,15 @@ type OAuthClient struct {
 	// if none of the grant types in SupportedGrantTypes require it.
 	TokenURL *url.URL
 
+	// DeviceAuthorizationURL is the URL of the device authorization endpoint
+	// to be used with the device authorization grant, as defined in IETF
+	// RFC 8628 section 3.1.
+	//
+	// This is optional even when SupportedGrantTypes includes
+	// [OAuthDeviceCodeGrant], in which case [OAuthClient.DeviceFlow] will
+	// fail because it has no endpoint to begin the flow with.
+	DeviceAuthorizationURL *url.URL
+
 	// MinPort and MaxPort define a range of TCP ports on localhost that this
 	// client is able to use as redirect_uri in an authorization request.
 	// OpenTofu will select a port from this range for the temporary HTTP
@@ -55,17 +67,73 @@ type OAuthClient struct {
 	// OIDC does. Optional list of scopes to include in auth code and token
 	// requests.
 	Scopes []string
+
+	// Secret is the client secret, to be used as "client_secret" in OAuth
+	// requests, for services whose token endpoint requires one. It's empty
+	// for public clients, which is the common case.
+	Secret string
+
+	// TokenEndpointAuthMethods is the set of methods the client may use to
+	// authenticate to the token endpoint, named as in the OAuth
+	// "token_endpoint_auth_methods_supported" metadata property. If empty,
+	// the client authenticates only by including its ID in the request, as
+	// with [OAuthAuthMethodNone].
+	//
+	// This may include methods defined in a later version of this library
+	// which this version doesn't yet know about.
+	TokenEndpointAuthMethods []OAuthTokenEndpointAuthMethod
+}
+
+// OAuthTokenEndpointAuthMethod is an enumeration of the methods a client can
+// use to authenticate to a token endpoint, as defined in IETF RFC 7591
+// section 2.
+//
+// Values of this type don't necessarily match with a known constant of the
+// type, because they may represent methods defined in a later version of
+// this library which this version doesn't yet know about.
+type OAuthTokenEndpointAuthMethod string
+
+const (
+	// OAuthAuthMethodNone represents a public client that does not
+	// authenticate to the token endpoint, and so has no client secret.
+	OAuthAuthMethodNone = OAuthTokenEndpointAuthMethod("none")
+
+	// OAuthAuthMethodClientSecretBasic represents a client that sends its
+	// client secret using HTTP Basic authentication.
+	OAuthAuthMethodClientSecretBasic = OAuthTokenEndpointAuthMethod("client_secret_basic")
+
+	// OAuthAuthMethodClientSecretPost represents a client that sends its
+	// client secret in the body of its token requests.
+	OAuthAuthMethodClientSecretPost = OAuthTokenEndpointAuthMethod("client_secret_post")
+)
+
+// UsesClientSecret returns true if the receiving method requires the client
+// to have a client secret.
+func (m OAuthTokenEndpointAuthMethod) UsesClientSecret() bool {
+	switch m {
+	case OAuthAuthMethodClientSecretBasic, OAuthAuthMethodClientSecretPost:
+		return true
+	default:
+		// We'll default to false so that we don't impose any requirements
+		// for any methods that might be defined for future versions of
+		// this library.
+		return false
+	}
 }
 
 // Endpoint returns an oauth2.Endpoint value ready to be used with the oauth2
 // library, representing the URLs from the receiver.
 func (c *OAuthClient) Endpoint() oauth2.Endpoint {
 	ep := oauth2.Endpoint{
-		// We don't actually auth because we're not a server-based OAuth client,
-		// so this instead just means that we include client_id as an argument
-		// in our requests.
+		// We don't usually actually auth because we're not a server-based
+		// OAuth client, so this instead just means that we include client_id
+		// as an argument in our requests.
 		AuthStyle: oauth2.AuthStyleInParams,
 	}
+	if c.Secret != "" && slices.Contains(c.TokenEndpointAuthMethods, OAuthAuthMethodClientSecretBasic) &&
+		!slices.Contains(c.TokenEndpointAuthMethods, OAuthAuthMethodClientSecretPost) {
+		ep.AuthStyle = oauth2.AuthStyleInHeader
+	}
 
 	if c.AuthorizationURL != nil {
 		ep.AuthURL = c.AuthorizationURL.String()
@@ -73,6 +141,9 @@ func (c *OAuthClient) Endpoint() oauth2.Endpoint {
 	if c.TokenURL != nil {
 		ep.TokenURL = c.TokenURL.String()
 	}
+	if c.DeviceAuthorizationURL != nil {
+		ep.DeviceAuthURL = c.DeviceAuthorizationURL.String()
+	}
 
 	return ep
 }
@@ -93,6 +164,11 @@ const (
 	// OAuthOwnerPasswordGrant represents a resource owner password
 	// credentials grant, as defined in IETF RFC 6749 section 4.3.
 	OAuthOwnerPasswordGrant = OAuthGrantType("password")
+
+	// OAuthDeviceCodeGrant represents a device authorization grant, as
+	// defined in IETF RFC 8628, which is useful on machines that cannot
+	// receive an authorization response on a localhost callback.
+	OAuthDeviceCodeGrant = OAuthGrantType("device_code")
 )
 
 // UsesAuthorizationEndpoint returns true if the receiving grant type makes
@@ -102,7 +178,7 @@ func (t OAuthGrantType) UsesAuthorizationEndpoint() bool {
 	switch t {
 	case OAuthAuthzCodeGrant:
 		return true
-	case OAuthOwnerPasswordGrant:
+	case OAuthOwnerPasswordGrant, OAuthDeviceCodeGrant:
 		return false
 	default:
 		// We'll default to false so that we don't impose any requirements
@@ -121,6 +197,8 @@ func (t OAuthGrantType) UsesTokenEndpoint() bool {
 		return true
 	case OAuthOwnerPasswordGrant:
 		return true
+	case OAuthDeviceCodeGrant:
+		return true
 	default:
 		// We'll default to false so that we don't impose any requirements
 		// on any grant type keywords that might be defined for future
@@ -148,6 +226,69 @@ func (s OAuthGrantTypeSet) Has(t OAuthGrantType) bool {
 	return ok
 }
 
+// Contains returns true if the grant type with the given keyword is in the
+// receiving set.
+func (s OAuthGrantTypeSet) Contains(keyword string) bool {
+	return s.Has(OAuthGrantType(keyword))
+}
+
+// List returns the keywords of the grant types in the receiving set, in
+// lexical order.
+func (s OAuthGrantTypeSet) List() []string {
+	ret := make([]string, 0, len(s))
+	for _, t := range slices.Sorted(maps.Keys(s)) {
+		ret = append(ret, string(t))
+	}
+	return ret
+}
+
+// Union returns a new set containing the grant types that are in either the
+// receiving set or the given set.
+func (s OAuthGrantTypeSet) Union(other OAuthGrantTypeSet) OAuthGrantTypeSet {
+	ret := make(OAuthGrantTypeSet, len(s)+len(other))
+	maps.Copy(ret, s)
+	maps.Copy(ret, other)
+	return ret
+}
+
+// Intersect returns a new set containing only the grant types that are in
+// both the receiving set and the given set, such as for choosing among the
+// grant types supported by a client those that the user's configuration
+// allows.
+func (s OAuthGrantTypeSet) Intersect(other OAuthGrantTypeSet) OAuthGrantTypeSet {
+	ret := make(OAuthGrantTypeSet)
+	for t := range s {
+		if other.Has(t) {
+			ret[t] = struct{}{}
+		}
+	}
+	return ret
+}
+
+// MarshalJSON implements [json.Marshaler], producing a JSON array of the
+// grant type keywords in the same order as [OAuthGrantTypeSet.List], which
+// is the same form as the "grant_types" property of an OAuth client in a
+// discovery document.
+func (s OAuthGrantTypeSet) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.List())
+}
+
+// UnmarshalJSON implements [json.Unmarshaler], accepting a JSON array of
+// grant type keywords as produced by [OAuthGrantTypeSet.MarshalJSON] and
+// replacing the receiving set with a set of those grant types. A JSON null
+// leaves the receiver unchanged.
+func (s *OAuthGrantTypeSet) UnmarshalJSON(src []byte) error {
+	if string(src) == "null" {
+		return nil
+	}
+	var keywords []string
+	if err := json.Unmarshal(src, &keywords); err != nil {
+		return fmt.Errorf("grant types must be an array of strings: %w", err)
+	}
+	*s = NewOAuthGrantTypeSet(keywords...)
+	return nil
+}
+
 // RequiresAuthorizationEndpoint returns true if any of the grant types in
 // the set are known to require an authorization endpoint.
 func (s OAuthGrantTypeSet) RequiresAuthorizationEndpoint() bool {
@@ -173,14 +314,12 @@ func (s OAuthGrantTypeSet) RequiresTokenEndpoint() bool {
 // GoString implements fmt.GoStringer.
 func (s OAuthGrantTypeSet) GoString() string {
 	var buf strings.Builder
-	i := 0
 	buf.WriteString("disco.NewOAuthGrantTypeSet(")
-	for t := range s {
+	for i, keyword := range s.List() {
 		if i > 0 {
 			buf.WriteString(", ")
 		}
-		fmt.Fprintf(&buf, "%q", string(t))
-		i++
+		fmt.Fprintf(&buf, "%q", keyword)
 	}
 	buf.WriteString(")")
 	return buf.String()
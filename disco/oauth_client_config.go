@@ -0,0 +1,184 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package disco
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+
+	svchost "github.com/opentofu/svchost"
+)
+
+// OAuthClientConfig is the representation of an OAuth client configuration
+// in a discovery document, as interpreted by [Host.ServiceOAuthClient].
+// Servers can marshal a value of this type as JSON to produce the value of
+// such a service in their discovery documents.
+type OAuthClientConfig struct {
+	// Client is the client ID. It is required.
+	Client string `json:"client"`
+
+	// Authz, Token, and DeviceAuthz are the URLs of the authorization, token,
+	// and device authorization endpoints, which may be relative to the
+	// discovery document's URL. Whether each is required depends on
+	// GrantTypes.
+	Authz       string `json:"authz,omitempty"`
+	Token       string `json:"token,omitempty"`
+	DeviceAuthz string `json:"device_authz,omitempty"`
+
+	// GrantTypes are the grant types the client supports, such as
+	// "authz_code". If omitted then only "authz_code" is supported.
+	GrantTypes []string `json:"grant_types,omitempty"`
+
+	// Ports is the minimum and maximum port number for the localhost
+	// redirect URI, both of which must be at least 1024. If omitted then
+	// any port from 1024 to 65535 may be used.
+	Ports []int `json:"ports,omitempty"`
+
+	// Scopes are the scopes to request.
+	Scopes []string `json:"scopes,omitempty"`
+
+	// ClientSecret is the client secret, for clients that have one.
+	ClientSecret string `json:"client_secret,omitempty"`
+
+	// TokenEndpointAuthMethods are the methods the client may use to
+	// authenticate to the token endpoint.
+	TokenEndpointAuthMethods []string `json:"token_endpoint_auth_methods_supported,omitempty"`
+}
+
+// UnknownOAuthFieldsFunc is the signature of a function that is notified
+// about properties of an OAuth client configuration that this package
+// doesn't understand, registered using [WithUnknownOAuthFields].
+//
+// fields are the names of the unrecognized properties in lexical order.
+type UnknownOAuthFieldsFunc func(hostname svchost.Hostname, serviceID string, fields []string)
+
+// oauthClientConfigFields is the set of property names that
+// [OAuthClientConfig] understands.
+var oauthClientConfigFields = func() map[string]struct{} {
+	ret := make(map[string]struct{})
+	for _, field := range reflect.VisibleFields(reflect.TypeFor[OAuthClientConfig]()) {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		ret[name] = struct{}{}
+	}
+	return ret
+}()
+
+// legacyOAuthClientProperties are the properties of an OAuth client
+// configuration that are ignored if they have the wrong type, along with
+// functions that report whether a value has the right type.
+var legacyOAuthClientProperties = map[string]func(v any) bool{
+	"authz":  isString,
+	"token":  isString,
+	"ports":  isArray,
+	"scopes": isArray,
+}
+
+func isString(v any) bool {
+	_, ok := v.(string)
+	return ok
+}
+
+func isArray(v any) bool {
+	_, ok := v.([]any)
+	return ok
+}
+
+// unknownOAuthFieldsFor returns the function for [Host] objects for the given
+// hostname to call with unknown OAuth client configuration properties, or nil
+// if WithUnknownOAuthFields wasn't used.
+func (d *Disco) unknownOAuthFieldsFor(hostname svchost.Hostname) func(serviceID string, fields []string) {
+	if d.unknownOAuthFields == nil {
+		return nil
+	}
+	return func(serviceID string, fields []string) {
+		d.unknownOAuthFields(hostname, serviceID, fields)
+	}
+}
+
+// decodeOAuthClientConfig decodes the given definition of the service with
+// the given ID, returning the configuration along with the names of any
+// properties that it doesn't understand.
+func decodeOAuthClientConfig(id string, raw map[string]any) (*OAuthClientConfig, []string, error) {
+	// A client ID that isn't a string, including null, is no more useful
+	// than none.
+	if _, ok := raw["client"].(string); !ok {
+		return nil, nil, fmt.Errorf("service %s definition is missing required property \"client\"", id)
+	}
+
+	// Older versions of this package ignored these properties, rather than
+	// rejecting the whole definition, when they had the wrong type, so we
+	// remove them before decoding to do the same.
+	for name, valid := range legacyOAuthClientProperties {
+		if v, ok := raw[name]; ok && !valid(v) {
+			raw = maps.Clone(raw)
+			delete(raw, name)
+		}
+	}
+
+	if rawGTs, ok := raw["grant_types"]; ok {
+		gts, ok := rawGTs.([]any)
+		if !ok {
+			return nil, nil, fmt.Errorf("service %s is defined with invalid grant_types property: must be an array of grant type strings", id)
+		}
+		// We ignore any grant types that aren't strings so that we can
+		// potentially introduce other types into this array later if we
+		// need to, so we must remove them before decoding.
+		if slices.ContainsFunc(gts, func(gt any) bool { _, ok := gt.(string); return !ok }) {
+			raw = maps.Clone(raw)
+			raw["grant_types"] = slices.DeleteFunc(slices.Clone(gts), func(gt any) bool {
+				_, ok := gt.(string)
+				return !ok
+			})
+		}
+	}
+
+	src, err := json.Marshal(raw)
+	if err != nil {
+		return nil, nil, fmt.Errorf("service %s has an invalid definition: %w", id, err)
+	}
+	var cfg OAuthClientConfig
+	if err := json.Unmarshal(src, &cfg); err != nil {
+		return nil, nil, oauthClientConfigError(id, err)
+	}
+
+	var unknown []string
+	for name := range raw {
+		if _, ok := oauthClientConfigFields[name]; !ok {
+			unknown = append(unknown, name)
+		}
+	}
+	slices.Sort(unknown)
+	return &cfg, unknown, nil
+}
+
+// oauthClientConfigError returns a description of the given error from
+// decoding the definition of the service with the given ID.
+func oauthClientConfigError(id string, err error) error {
+	var typeErr *json.UnmarshalTypeError
+	if !errors.As(err, &typeErr) {
+		return fmt.Errorf("service %s has an invalid definition: %w", id, err)
+	}
+	// The field is a path like "ports.0" when the problem is with an
+	// element of an array.
+	field, _, isElem := strings.Cut(typeErr.Field, ".")
+	switch {
+	case field == "device_authz":
+		return fmt.Errorf("invalid %q for service %s: must be a URL string", field, id)
+	case field == "ports" && isElem:
+		return fmt.Errorf("invalid \"ports\" definition for service %s: both ports must be whole numbers between 1024 and 65535", id)
+	case field == "scopes" && isElem:
+		return fmt.Errorf("invalid \"scopes\" for service %s: all scopes must be strings", id)
+	case field == "client_secret":
+		return fmt.Errorf("invalid \"client_secret\" for service %s: must be a string", id)
+	case field == "token_endpoint_auth_methods_supported":
+		return fmt.Errorf("invalid \"token_endpoint_auth_methods_supported\" for service %s: must be an array of strings", id)
+	default:
+		return fmt.Errorf("service %s has an invalid definition: %w", id, err)
+	}
+}
@@ -0,0 +1,237 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package disco
+
+import (
+	"encoding/json"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/opentofu/svchost"
+)
+
+func TestOAuthClientConfigRoundTrip(t *testing.T) {
+	// A configuration marshaled by a server must be understood by
+	// ServiceOAuthClient in the same way as an equivalent hand-written one.
+	cfg := OAuthClientConfig{
+		Client:      "tofu-cli",
+		Authz:       "/oauth/authz",
+		Token:       "/oauth/token",
+		DeviceAuthz: "/oauth/device",
+		GrantTypes:  []string{"authz_code", "device_code"},
+		Ports:       []int{10000, 10010},
+		Scopes:      []string{"openid"},
+	}
+	src, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var services map[string]any
+	if err := json.Unmarshal([]byte(`{"login.v1":`+string(src)+`}`), &services); err != nil {
+		t.Fatal(err)
+	}
+
+	d := New()
+	d.ForceHostServices("example.com", services)
+	host, err := d.Discover(t.Context(), "example.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	got, err := host.ServiceOAuthClient("login.v1")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	mustURL := func(s string) *url.URL {
+		t.Helper()
+		u, err := url.Parse(s)
+		if err != nil {
+			t.Fatalf("invalid wanted URL %s in test case: %s", s, err)
+		}
+		return u
+	}
+	want := &OAuthClient{
+		ID:                     "tofu-cli",
+		AuthorizationURL:       mustURL("https://example.com/oauth/authz"),
+		TokenURL:               mustURL("https://example.com/oauth/token"),
+		DeviceAuthorizationURL: mustURL("https://example.com/oauth/device"),
+		MinPort:                10000,
+		MaxPort:                10010,
+		SupportedGrantTypes:    NewOAuthGrantTypeSet("authz_code", "device_code"),
+		Scopes:                 []string{"openid"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("wrong result\n%s", diff)
+	}
+}
+
+func TestWithUnknownOAuthFields(t *testing.T) {
+	type call struct {
+		Hostname  svchost.Hostname
+		ServiceID string
+		Fields    []string
+	}
+	var calls []call
+	d := New(WithUnknownOAuthFields(func(hostname svchost.Hostname, serviceID string, fields []string) {
+		calls = append(calls, call{hostname, serviceID, fields})
+	}))
+	d.ForceHostServices("example.com", map[string]any{
+		"login.v1": map[string]any{
+			"client":      "tofu-cli",
+			"authz":       "/authz",
+			"token":       "/token",
+			"pkce":        true,
+			"logout":      "/logout",
+			"grant_types": []any{"authz_code", 5},
+		},
+		"known.v1": map[string]any{
+			"client": "tofu-cli",
+			"authz":  "/authz",
+			"token":  "/token",
+		},
+	})
+	host, err := d.Discover(t.Context(), "example.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for _, id := range []string{"login.v1", "known.v1"} {
+		if _, err := host.ServiceOAuthClient(id); err != nil {
+			t.Fatalf("unexpected error for %s: %s", id, err)
+		}
+	}
+
+	want := []call{
+		{"example.com", "login.v1", []string{"logout", "pkce"}},
+	}
+	if diff := cmp.Diff(want, calls); diff != "" {
+		t.Errorf("wrong calls\n%s", diff)
+	}
+
+	if _, err := NewWithErrors(WithUnknownOAuthFields(nil)); err == nil {
+		t.Error("unexpected success with nil function; want error")
+	}
+}
+
+func TestHostServiceOAuthClient_legacy(t *testing.T) {
+	// These cases pin the behavior of versions of ServiceOAuthClient from
+	// before it decoded OAuthClientConfig, which servers may depend on.
+	baseURL, _ := url.Parse("https://example.com/disco/foo.json")
+	authzURL, _ := url.Parse("https://example.com/disco/authz")
+	tokenURL, _ := url.Parse("https://example.com/disco/token")
+	tests := map[string]struct {
+		def  map[string]any
+		want *OAuthClient
+		err  string
+	}{
+		"non-string authz ignored": {
+			def: map[string]any{
+				"client":      "legacy",
+				"authz":       5,
+				"token":       "./token",
+				"grant_types": []any{"password"},
+			},
+			want: &OAuthClient{
+				ID:                  "legacy",
+				TokenURL:            tokenURL,
+				MinPort:             1024,
+				MaxPort:             65535,
+				SupportedGrantTypes: NewOAuthGrantTypeSet("password"),
+			},
+		},
+		"non-string authz treated as missing": {
+			def: map[string]any{
+				"client": "legacy",
+				"authz":  true,
+				"token":  "./token",
+			},
+			err: `service legacy.v1 definition is missing required property "authz"`,
+		},
+		"non-string token treated as missing": {
+			def: map[string]any{
+				"client": "legacy",
+				"authz":  "./authz",
+				"token":  []any{"./token"},
+			},
+			err: `service legacy.v1 definition is missing required property "token"`,
+		},
+		"non-array scopes ignored": {
+			def: map[string]any{
+				"client": "legacy",
+				"authz":  "./authz",
+				"token":  "./token",
+				"scopes": "openid",
+			},
+			want: &OAuthClient{
+				ID:                  "legacy",
+				AuthorizationURL:    authzURL,
+				TokenURL:            tokenURL,
+				MinPort:             1024,
+				MaxPort:             65535,
+				SupportedGrantTypes: NewOAuthGrantTypeSet("authz_code"),
+			},
+		},
+		"non-array ports ignored": {
+			def: map[string]any{
+				"client": "legacy",
+				"authz":  "./authz",
+				"token":  "./token",
+				"ports":  "1024-2048",
+			},
+			want: &OAuthClient{
+				ID:                  "legacy",
+				AuthorizationURL:    authzURL,
+				TokenURL:            tokenURL,
+				MinPort:             1024,
+				MaxPort:             65535,
+				SupportedGrantTypes: NewOAuthGrantTypeSet("authz_code"),
+			},
+		},
+		"null client rejected": {
+			def: map[string]any{
+				"client": nil,
+				"authz":  "./authz",
+				"token":  "./token",
+			},
+			err: `service legacy.v1 definition is missing required property "client"`,
+		},
+		"empty URLs are relative": {
+			def: map[string]any{
+				"client": "legacy",
+				"authz":  "",
+				"token":  "",
+			},
+			want: &OAuthClient{
+				ID:                  "legacy",
+				AuthorizationURL:    baseURL,
+				TokenURL:            baseURL,
+				MinPort:             1024,
+				MaxPort:             65535,
+				SupportedGrantTypes: NewOAuthGrantTypeSet("authz_code"),
+			},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			host := Host{
+				discoURL: baseURL,
+				hostname: "example.com",
+				services: map[string]any{"legacy.v1": test.def},
+			}
+			got, err := host.ServiceOAuthClient("legacy.v1")
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Fatalf("wrong error %v; want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("wrong result\n%s", diff)
+			}
+		})
+	}
+}
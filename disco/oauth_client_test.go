@@ -0,0 +1,75 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package disco
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestOAuthGrantTypeSet(t *testing.T) {
+	s := NewOAuthGrantTypeSet("password", "authz_code", "password")
+
+	if got, want := s.List(), []string{"authz_code", "password"}; !cmp.Equal(got, want) {
+		t.Errorf("wrong list %q; want %q", got, want)
+	}
+	if !s.Contains("authz_code") {
+		t.Error("set does not contain authz_code")
+	}
+	if s.Contains("device_code") {
+		t.Error("set contains device_code")
+	}
+	if got, want := fmt.Sprintf("%#v", s), `disco.NewOAuthGrantTypeSet("authz_code", "password")`; got != want {
+		t.Errorf("wrong GoString %s; want %s", got, want)
+	}
+
+	other := NewOAuthGrantTypeSet("device_code", "password")
+	if got, want := s.Union(other).List(), []string{"authz_code", "device_code", "password"}; !cmp.Equal(got, want) {
+		t.Errorf("wrong union %q; want %q", got, want)
+	}
+	if got, want := s.Intersect(other).List(), []string{"password"}; !cmp.Equal(got, want) {
+		t.Errorf("wrong intersection %q; want %q", got, want)
+	}
+	if got := s.Intersect(nil); got == nil || len(got) != 0 {
+		t.Errorf("wrong intersection with nil set %#v; want empty set", got)
+	}
+	if got, want := len(s), 2; got != want {
+		t.Errorf("set operations modified the receiver, which now has %d elements; want %d", got, want)
+	}
+}
+
+func TestOAuthGrantTypeSet_json(t *testing.T) {
+	type config struct {
+		GrantTypes OAuthGrantTypeSet `json:"grant_types"`
+	}
+
+	src, err := json.Marshal(config{GrantTypes: NewOAuthGrantTypeSet("password", "authz_code")})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got, want := string(src), `{"grant_types":["authz_code","password"]}`; got != want {
+		t.Errorf("wrong JSON %s; want %s", got, want)
+	}
+
+	var got config
+	if err := json.Unmarshal(src, &got); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if want := NewOAuthGrantTypeSet("authz_code", "password"); !cmp.Equal(got.GrantTypes, want) {
+		t.Errorf("wrong set after round trip %#v; want %#v", got.GrantTypes, want)
+	}
+
+	if src, err := json.Marshal(OAuthGrantTypeSet(nil)); err != nil || string(src) != "[]" {
+		t.Errorf("wrong JSON for nil set %s with error %v; want []", src, err)
+	}
+	if err := json.Unmarshal([]byte(`{"grant_types":null}`), &got); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := json.Unmarshal([]byte(`{"grant_types":"authz_code"}`), &got); err == nil {
+		t.Error("unexpected success decoding a string; want error")
+	}
+}
@@ -0,0 +1,250 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package disco
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+
+	"golang.org/x/oauth2"
+
+	"github.com/opentofu/svchost/svcauth"
+)
+
+// AuthorizationCodeFlowOptions customizes the behavior of
+// [OAuthClient.AuthorizationCodeFlow].
+type AuthorizationCodeFlowOptions struct {
+	// OpenBrowser is called with the URL of the authorization page that the
+	// user must visit in order to approve the request, typically by opening
+	// it in a web browser. It is required.
+	//
+	// If OpenBrowser returns an error then the flow is abandoned with an
+	// error wrapping it.
+	OpenBrowser func(authURL string) error
+}
+
+// AuthorizationCodeFlow obtains credentials using the authorization code
+// grant, including a PKCE code challenge, and returns the resulting tokens
+// as credentials.
+//
+// This starts a temporary HTTP server on a port of the IPv4 loopback address
+// 127.0.0.1 within the range given by MinPort and MaxPort to receive the
+// authorization response, and then waits until either the response arrives
+// or the given context is cancelled. The redirect URL sent to the server
+// uses the same address, rather than the hostname "localhost", so that the
+// response can't be delivered to some other listener that "localhost"
+// resolves to.
+//
+// The credentials are a [svcauth.HostCredentialsOAuthToken] that includes
+// the refresh token and expiry time, if the server returned them, so that
+// [svcauth.RefreshingCredentialsSource] can refresh the access token and a
+// [svcauth.CredentialsStore] can save all of them.
+//
+// The request to the token endpoint uses [http.DefaultClient] unless the
+// context has a different client associated with the [oauth2.HTTPClient]
+// context key.
+func (c *OAuthClient) AuthorizationCodeFlow(ctx context.Context, opts AuthorizationCodeFlowOptions) (svcauth.HostCredentials, error) {
+	if !c.SupportedGrantTypes.Has(OAuthAuthzCodeGrant) {
+		return nil, errors.New("OAuth client does not support the authorization code grant")
+	}
+	if c.AuthorizationURL == nil || c.TokenURL == nil {
+		return nil, errors.New("OAuth client must have both an authorization URL and a token URL")
+	}
+	if opts.OpenBrowser == nil {
+		return nil, errors.New("AuthorizationCodeFlow requires an OpenBrowser function")
+	}
+
+	listener, err := c.listenCallback()
+	if err != nil {
+		return nil, err
+	}
+	cfg := c.oauth2Config("http://" + listener.Addr().String() + "/login")
+
+	state, err := randomState()
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	type callbackResult struct {
+		code string
+		err  error
+	}
+	resultCh := make(chan callbackResult, 1)
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if req.URL.Path != "/login" {
+				http.NotFound(w, req)
+				return
+			}
+			query := req.URL.Query()
+			var result callbackResult
+			switch {
+			case query.Get("state") != state:
+				// This isn't a response to our request, so we'll ignore
+				// it and keep waiting for the real one.
+				http.Error(w, "Invalid state in authorization response.", http.StatusBadRequest)
+				return
+			case query.Get("error") != "":
+				result.err = fmt.Errorf("authorization server returned an error: %s", query.Get("error"))
+			case query.Get("code") == "":
+				result.err = errors.New("authorization response has no code")
+			default:
+				result.code = query.Get("code")
+			}
+			select {
+			case resultCh <- result:
+			default: // we only care about the first response
+			}
+			if result.err != nil {
+				http.Error(w, "Authorization failed. You can close this page.", http.StatusBadRequest)
+				return
+			}
+			fmt.Fprintln(w, "Authorization complete. You can close this page and return to the application.")
+		}),
+	}
+	go server.Serve(listener) //nolint:errcheck // always returns ErrServerClosed after Close
+	defer server.Close()
+
+	authURL := cfg.AuthCodeURL(state, oauth2.S256ChallengeOption(verifier))
+	if err := opts.OpenBrowser(authURL); err != nil {
+		return nil, fmt.Errorf("failed to open authorization page: %w", err)
+	}
+
+	var result callbackResult
+	select {
+	case result = <-resultCh:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if result.err != nil {
+		return nil, result.err
+	}
+
+	token, err := cfg.Exchange(ctx, result.code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, fmt.Errorf("failed to obtain access token: %w", err)
+	}
+	return oauthCredentials(cfg, token), nil
+}
+
+// PasswordGrant obtains credentials using the resource owner password
+// credentials grant, returning the resulting tokens as credentials in the
+// same way as [OAuthClient.AuthorizationCodeFlow].
+//
+// The request to the token endpoint uses the HTTP client associated with the
+// context in the same way as for [OAuthClient.AuthorizationCodeFlow].
+func (c *OAuthClient) PasswordGrant(ctx context.Context, username, password string) (svcauth.HostCredentials, error) {
+	if !c.SupportedGrantTypes.Has(OAuthOwnerPasswordGrant) {
+		return nil, errors.New("OAuth client does not support the password grant")
+	}
+	if c.TokenURL == nil {
+		return nil, errors.New("OAuth client must have a token URL")
+	}
+
+	cfg := c.oauth2Config("")
+	token, err := cfg.PasswordCredentialsToken(ctx, username, password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to obtain access token: %w", err)
+	}
+	return oauthCredentials(cfg, token), nil
+}
+
+// DeviceFlowOptions customizes the behavior of [OAuthClient.DeviceFlow].
+type DeviceFlowOptions struct {
+	// Prompt is called with the response from the device authorization
+	// endpoint, and must tell the user to visit the verification URI and
+	// enter the user code given in the response. It is required.
+	//
+	// If Prompt returns an error then the flow is abandoned with an
+	// error wrapping it.
+	Prompt func(resp *oauth2.DeviceAuthResponse) error
+}
+
+// DeviceFlow obtains credentials using the device authorization grant,
+// returning the resulting tokens as credentials in the same way as
+// [OAuthClient.AuthorizationCodeFlow].
+//
+// Unlike [OAuthClient.AuthorizationCodeFlow] this doesn't need to receive
+// any requests, so it's suitable for use on headless machines where the user
+// must complete the authorization on some other device. After calling
+// opts.Prompt this polls the token endpoint until the user completes the
+// authorization, the device code expires, or the given context is cancelled.
+//
+// The requests to the device authorization and token endpoints use the HTTP
+// client associated with the context in the same way as for
+// [OAuthClient.AuthorizationCodeFlow].
+func (c *OAuthClient) DeviceFlow(ctx context.Context, opts DeviceFlowOptions) (svcauth.HostCredentials, error) {
+	if !c.SupportedGrantTypes.Has(OAuthDeviceCodeGrant) {
+		return nil, errors.New("OAuth client does not support the device authorization grant")
+	}
+	if c.DeviceAuthorizationURL == nil || c.TokenURL == nil {
+		return nil, errors.New("OAuth client must have both a device authorization URL and a token URL")
+	}
+	if opts.Prompt == nil {
+		return nil, errors.New("DeviceFlow requires a Prompt function")
+	}
+
+	cfg := c.oauth2Config("")
+	resp, err := cfg.DeviceAuth(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin device authorization: %w", err)
+	}
+	if err := opts.Prompt(resp); err != nil {
+		return nil, fmt.Errorf("failed to prompt for device authorization: %w", err)
+	}
+
+	token, err := cfg.DeviceAccessToken(ctx, resp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to obtain access token: %w", err)
+	}
+	return oauthCredentials(cfg, token), nil
+}
+
+// oauthCredentials returns credentials for the given token, which was issued
+// using the given configuration, keeping its refresh token and expiry time
+// so that it can be refreshed later.
+func oauthCredentials(cfg *oauth2.Config, token *oauth2.Token) svcauth.HostCredentials {
+	return svcauth.HostCredentialsOAuthToken{Config: cfg, Token: token}
+}
+
+// oauth2Config returns the configuration for the oauth2 library that
+// represents the receiver, with the given redirect URL.
+func (c *OAuthClient) oauth2Config(redirectURL string) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     c.ID,
+		ClientSecret: c.Secret,
+		Endpoint:     c.Endpoint(),
+		RedirectURL:  redirectURL,
+		Scopes:       c.Scopes,
+	}
+}
+
+// listenCallback starts listening on the first available loopback port in
+// the range given by MinPort and MaxPort.
+func (c *OAuthClient) listenCallback() (net.Listener, error) {
+	for port := int(c.MinPort); port <= int(c.MaxPort); port++ {
+		listener, err := net.Listen("tcp4", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+		if err == nil {
+			return listener, nil
+		}
+	}
+	return nil, fmt.Errorf("no port is available for the authorization callback in the range %d-%d", c.MinPort, c.MaxPort)
+}
+
+// randomState returns a random string to use as the OAuth "state" argument.
+func randomState() (string, error) {
+	var buf [16]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return "", fmt.Errorf("failed to generate state: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf[:]), nil
+}
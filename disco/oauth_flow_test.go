@@ -0,0 +1,277 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package disco
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"golang.org/x/oauth2"
+
+	"github.com/opentofu/svchost/svcauth"
+)
+
+func TestOAuthClientAuthorizationCodeFlow(t *testing.T) {
+	var gotVerifier string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/token" {
+			http.NotFound(w, r)
+			return
+		}
+		r.ParseForm()
+		if r.Form.Get("grant_type") != "authorization_code" || r.Form.Get("code") != "the-code" {
+			http.Error(w, "invalid request", http.StatusBadRequest)
+			return
+		}
+		gotVerifier = r.Form.Get("code_verifier")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"abc123","token_type":"bearer","refresh_token":"def456","expires_in":3600}`))
+	}))
+	defer server.Close()
+
+	authzURL, _ := url.Parse(server.URL + "/authz")
+	tokenURL, _ := url.Parse(server.URL + "/token")
+	client := &OAuthClient{
+		ID:                  "tofu-cli",
+		AuthorizationURL:    authzURL,
+		TokenURL:            tokenURL,
+		MinPort:             10000,
+		MaxPort:             10100,
+		SupportedGrantTypes: NewOAuthGrantTypeSet("authz_code"),
+	}
+
+	// Our "browser" plays the part of the user approving the request, by
+	// sending the authorization response directly to the redirect URL.
+	var gotChallenge, gotRedirect string
+	openBrowser := func(authURL string) error {
+		u, err := url.Parse(authURL)
+		if err != nil {
+			return err
+		}
+		query := u.Query()
+		gotChallenge = query.Get("code_challenge")
+		gotRedirect = query.Get("redirect_uri")
+		resp, err := http.Get(query.Get("redirect_uri") + "?code=the-code&state=" + url.QueryEscape(query.Get("state")))
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		return nil
+	}
+
+	creds, err := client.AuthorizationCodeFlow(t.Context(), AuthorizationCodeFlowOptions{
+		OpenBrowser: openBrowser,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	checkOAuthCredentials(t, creds, "def456", true)
+	if gotChallenge == "" || gotVerifier == "" {
+		t.Errorf("PKCE was not used (challenge %q, verifier %q)", gotChallenge, gotVerifier)
+	}
+	// The redirect URL must refer to the address we actually listen on.
+	if u, err := url.Parse(gotRedirect); err != nil || u.Hostname() != "127.0.0.1" {
+		t.Errorf("wrong redirect URL %q; want one for 127.0.0.1", gotRedirect)
+	}
+
+	t.Run("browser failure", func(t *testing.T) {
+		_, err := client.AuthorizationCodeFlow(t.Context(), AuthorizationCodeFlowOptions{
+			OpenBrowser: func(string) error { return errors.New("no browser") },
+		})
+		if err == nil || !strings.Contains(err.Error(), "no browser") {
+			t.Errorf("wrong error %v; want browser error", err)
+		}
+	})
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(t.Context())
+		_, err := client.AuthorizationCodeFlow(ctx, AuthorizationCodeFlowOptions{
+			OpenBrowser: func(string) error { cancel(); return nil },
+		})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("wrong error %v; want context.Canceled", err)
+		}
+	})
+}
+
+func TestOAuthClientPasswordGrant(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("grant_type") != "password" || r.Form.Get("username") != "alfred" || r.Form.Get("password") != "hunter2" {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"abc123","token_type":"bearer"}`))
+	}))
+	defer server.Close()
+
+	tokenURL, _ := url.Parse(server.URL + "/token")
+	client := &OAuthClient{
+		ID:                  "tofu-cli",
+		TokenURL:            tokenURL,
+		SupportedGrantTypes: NewOAuthGrantTypeSet("password"),
+	}
+
+	creds, err := client.PasswordGrant(t.Context(), "alfred", "hunter2")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	checkOAuthCredentials(t, creds, "", false)
+
+	if _, err := client.PasswordGrant(t.Context(), "alfred", "wrong"); err == nil {
+		t.Error("unexpected success with wrong password; want error")
+	}
+	if _, err := client.AuthorizationCodeFlow(t.Context(), AuthorizationCodeFlowOptions{}); err == nil {
+		t.Error("unexpected success with unsupported grant type; want error")
+	}
+}
+
+func TestOAuthClientPasswordGrantClientSecretBasic(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if id, secret, ok := r.BasicAuth(); !ok || id != "tofu-cli" || secret != "s3cret" {
+			http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+			return
+		}
+		if r.Form.Has("client_secret") {
+			http.Error(w, `{"error":"invalid_request"}`, http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"abc123","token_type":"bearer"}`))
+	}))
+	defer server.Close()
+
+	tokenURL, _ := url.Parse(server.URL + "/token")
+	client := &OAuthClient{
+		ID:                       "tofu-cli",
+		Secret:                   "s3cret",
+		TokenURL:                 tokenURL,
+		SupportedGrantTypes:      NewOAuthGrantTypeSet("password"),
+		TokenEndpointAuthMethods: []OAuthTokenEndpointAuthMethod{OAuthAuthMethodClientSecretBasic},
+	}
+
+	creds, err := client.PasswordGrant(t.Context(), "alfred", "hunter2")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	checkOAuthCredentials(t, creds, "", false)
+}
+
+func TestOAuthClientDeviceFlow(t *testing.T) {
+	polls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("client_id") != "tofu-cli" {
+			http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/device":
+			w.Write([]byte(`{"device_code":"dev123","user_code":"ABCD-EFGH","verification_uri":"https://example.com/activate","interval":1}`))
+		case "/token":
+			if r.Form.Get("grant_type") != "urn:ietf:params:oauth:grant-type:device_code" || r.Form.Get("device_code") != "dev123" {
+				http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+				return
+			}
+			polls++
+			if polls == 1 {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"error":"authorization_pending"}`))
+				return
+			}
+			w.Write([]byte(`{"access_token":"abc123","token_type":"bearer","refresh_token":"def456","expires_in":3600}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	tokenURL, _ := url.Parse(server.URL + "/token")
+	deviceURL, _ := url.Parse(server.URL + "/device")
+	client := &OAuthClient{
+		ID:                     "tofu-cli",
+		TokenURL:               tokenURL,
+		DeviceAuthorizationURL: deviceURL,
+		SupportedGrantTypes:    NewOAuthGrantTypeSet("device_code"),
+	}
+
+	var userCode string
+	creds, err := client.DeviceFlow(t.Context(), DeviceFlowOptions{
+		Prompt: func(resp *oauth2.DeviceAuthResponse) error {
+			userCode = resp.UserCode
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	checkOAuthCredentials(t, creds, "def456", true)
+	if got, want := userCode, "ABCD-EFGH"; got != want {
+		t.Errorf("wrong user code %q; want %q", got, want)
+	}
+	if got, want := polls, 2; got != want {
+		t.Errorf("wrong number of token requests %d; want %d", got, want)
+	}
+
+	t.Run("prompt error", func(t *testing.T) {
+		_, err := client.DeviceFlow(t.Context(), DeviceFlowOptions{
+			Prompt: func(*oauth2.DeviceAuthResponse) error {
+				return errors.New("no terminal")
+			},
+		})
+		if err == nil || !strings.Contains(err.Error(), "no terminal") {
+			t.Errorf("wrong error %v; want prompt error", err)
+		}
+	})
+	t.Run("missing device endpoint", func(t *testing.T) {
+		client := *client
+		client.DeviceAuthorizationURL = nil
+		_, err := client.DeviceFlow(t.Context(), DeviceFlowOptions{
+			Prompt: func(*oauth2.DeviceAuthResponse) error { return nil },
+		})
+		if err == nil {
+			t.Error("unexpected success without a device authorization URL; want error")
+		}
+	})
+	t.Run("unsupported grant type", func(t *testing.T) {
+		client := *client
+		client.SupportedGrantTypes = NewOAuthGrantTypeSet("authz_code")
+		_, err := client.DeviceFlow(t.Context(), DeviceFlowOptions{
+			Prompt: func(*oauth2.DeviceAuthResponse) error { return nil },
+		})
+		if err == nil {
+			t.Error("unexpected success with unsupported grant type; want error")
+		}
+	})
+}
+
+// checkOAuthCredentials fails the test if the given credentials aren't for
+// the access token "abc123" with the given refresh token, and with an expiry
+// time if wantExpiry is set.
+func checkOAuthCredentials(t *testing.T, creds svcauth.HostCredentials, wantRefresh string, wantExpiry bool) {
+	t.Helper()
+	got, ok := creds.(svcauth.HostCredentialsOAuthToken)
+	if !ok {
+		t.Fatalf("wrong credentials type %T; want svcauth.HostCredentialsOAuthToken", creds)
+	}
+	if got.Config == nil || got.Token == nil {
+		t.Fatalf("incomplete credentials %#v", got)
+	}
+	if got, want := got.Token.AccessToken, "abc123"; got != want {
+		t.Errorf("wrong access token %q; want %q", got, want)
+	}
+	if got, want := got.Token.RefreshToken, wantRefresh; got != want {
+		t.Errorf("wrong refresh token %q; want %q", got, want)
+	}
+	if got := got.ExpiresAt(); got.IsZero() == wantExpiry {
+		t.Errorf("wrong expiry time %s", got)
+	}
+}
@@ -5,29 +5,703 @@
 package disco
 
 import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
 	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
 
+	svchost "github.com/opentofu/svchost"
+	"github.com/opentofu/svchost/internal/transport"
 	"github.com/opentofu/svchost/svcauth"
 )
 
 type DiscoOption interface {
-	applyOption(disco *Disco)
+	// applyOption applies the option to the given Disco object, returning
+	// an error if the option is invalid either in isolation or in
+	// combination with options that were already applied.
+	//
+	// Options must apply as much of their effect as possible even when
+	// returning an error, because [New] ignores option errors for
+	// backward compatibility.
+	applyOption(disco *Disco) error
 }
 
-type discoOption func(disco *Disco)
+type discoOption func(disco *Disco) error
 
-func (o discoOption) applyOption(disco *Disco) {
-	o(disco)
+func (o discoOption) applyOption(disco *Disco) error {
+	return o(disco)
 }
 
 func WithHTTPClient(client *http.Client) DiscoOption {
-	return discoOption(func(disco *Disco) {
+	return discoOption(func(disco *Disco) error {
+		var err error
+		if client == nil {
+			err = errors.New("WithHTTPClient requires a non-nil HTTP client")
+		} else if disco.httpClient != nil && disco.httpClient != client {
+			err = errors.New("conflicting HTTP clients given in multiple WithHTTPClient options")
+		}
 		disco.httpClient = client
+		return err
 	})
 }
 
 func WithCredentials(creds svcauth.CredentialsSource) DiscoOption {
-	return discoOption(func(disco *Disco) {
+	return discoOption(func(disco *Disco) error {
+		var err error
+		if disco.credsSrc != nil && creds != nil {
+			err = errors.New("conflicting credentials sources given in multiple WithCredentials options")
+		}
 		disco.credsSrc = creds
+		return err
+	})
+}
+
+// WithProtocolVersions overrides the set of discovery protocol versions that
+// the Disco object will accept from a server, which are announced using the
+// [AcceptProtocolVersionHeader] request header.
+//
+// By default only [ProtocolVersion1] is accepted, and that is currently the
+// only version this library is able to interpret. This option is primarily
+// for testing how servers respond to clients offering future versions.
+func WithProtocolVersions(versions ...int) DiscoOption {
+	return discoOption(func(disco *Disco) error {
+		if len(versions) == 0 {
+			return errors.New("WithProtocolVersions requires at least one version")
+		}
+		for _, v := range versions {
+			if v < 1 {
+				return fmt.Errorf("invalid discovery protocol version %d", v)
+			}
+		}
+		disco.protocolVersions = slices.Clone(versions)
+		return nil
+	})
+}
+
+// WithSOCKS5Proxy causes discovery requests to be made through the SOCKS5
+// proxy at the given address, which must be in "host:port" form. auth may
+// be nil if the proxy doesn't require authentication, or otherwise should
+// be constructed using [url.UserPassword].
+//
+// This option customizes the default HTTP client and so cannot be combined
+// with [WithHTTPClient], or with [WithProxyFunc] or [WithSystemProxy].
+func WithSOCKS5Proxy(address string, auth *url.Userinfo) DiscoOption {
+	return discoOption(func(disco *Disco) error {
+		proxyURL, err := transport.SOCKS5ProxyURL(address, auth)
+		if err != nil {
+			return err
+		}
+		return disco.setProxy("WithSOCKS5Proxy", http.ProxyURL(proxyURL))
+	})
+}
+
+// WithProxyFunc causes discovery requests to be made through the proxy
+// returned by the given function, in the same way as [http.Transport.Proxy].
+// This overrides the default behavior of selecting a proxy based on the
+// conventional environment variables.
+//
+// This option customizes the default HTTP client and so cannot be combined
+// with [WithHTTPClient], or with [WithSOCKS5Proxy] or [WithSystemProxy].
+func WithProxyFunc(proxy func(*http.Request) (*url.URL, error)) DiscoOption {
+	return discoOption(func(disco *Disco) error {
+		if proxy == nil {
+			return errors.New("WithProxyFunc requires a non-nil proxy function")
+		}
+		return disco.setProxy("WithProxyFunc", proxy)
+	})
+}
+
+// setProxy sets the proxy function of the default HTTP client on behalf of
+// the option with the given name, returning an error if another option
+// already set it. Only one of [WithSOCKS5Proxy], [WithProxyFunc], and
+// [WithSystemProxy] may be used, since each replaces the others.
+func (d *Disco) setProxy(option string, proxy func(*http.Request) (*url.URL, error)) error {
+	if d.proxyOption != "" {
+		return fmt.Errorf("conflicting proxies given in %s and %s options", d.proxyOption, option)
+	}
+	d.transport.Proxy = proxy
+	d.proxyOption = option
+	d.transportOptions = append(d.transportOptions, option)
+	return nil
+}
+
+// WithHostProxy causes discovery requests for the given hostname to be made
+// through the proxy at the given URL, taking priority over any other proxy
+// configuration. If proxyURL is nil then requests for the hostname are made
+// directly, without a proxy.
+//
+// This option may be used multiple times to configure proxies for different
+// hosts. It customizes the default HTTP client and so cannot be combined
+// with [WithHTTPClient].
+func WithHostProxy(hostname svchost.Hostname, proxyURL *url.URL) DiscoOption {
+	return discoOption(func(disco *Disco) error {
+		if disco.transport.HostProxies == nil {
+			disco.transport.HostProxies = make(map[svchost.Hostname]*url.URL)
+		}
+		disco.transport.HostProxies[hostname] = proxyURL
+		disco.transportOptions = append(disco.transportOptions, "WithHostProxy")
+		return nil
+	})
+}
+
+// WithHostTLSConfig causes discovery requests for the given hostname to use
+// the given TLS configuration, which is useful for trusting a private
+// certificate authority for a single host by setting [tls.Config.RootCAs],
+// without replacing the HTTP client or disabling certificate verification.
+//
+// The configuration applies to redirects to the given hostname too, but
+// not to redirects from it to other hosts. [WithFIPSMode] restricts the
+// configuration in the same way as the default, and [NewWithErrors] reports
+// an error if it disables certificate verification in FIPS mode.
+//
+// This option may be used multiple times to configure different hosts. It
+// customizes the default HTTP client and so cannot be combined with
+// [WithHTTPClient].
+func WithHostTLSConfig(hostname svchost.Hostname, cfg *tls.Config) DiscoOption {
+	return discoOption(func(disco *Disco) error {
+		if cfg == nil {
+			return errors.New("WithHostTLSConfig requires a non-nil TLS configuration")
+		}
+		if disco.transport.HostTLSConfigs == nil {
+			disco.transport.HostTLSConfigs = make(map[svchost.Hostname]*tls.Config)
+		}
+		disco.transport.HostTLSConfigs[hostname] = cfg
+		disco.transportOptions = append(disco.transportOptions, "WithHostTLSConfig")
+		return nil
+	})
+}
+
+// WithTransportWrapper causes the default HTTP client to send requests
+// through the [http.RoundTripper] returned by the given function, which
+// receives the default transport and typically wraps it to add behavior such
+// as logging or metrics while delegating the requests themselves.
+//
+// This option may be used multiple times, in which case each function wraps
+// the result of the previous one. It customizes the default HTTP client and
+// so cannot be combined with [WithHTTPClient].
+func WithTransportWrapper(wrap func(http.RoundTripper) http.RoundTripper) DiscoOption {
+	return discoOption(func(disco *Disco) error {
+		if wrap == nil {
+			return errors.New("WithTransportWrapper requires a non-nil function")
+		}
+		disco.transportWrappers = append(disco.transportWrappers, wrap)
+		disco.transportOptions = append(disco.transportOptions, "WithTransportWrapper")
+		return nil
+	})
+}
+
+// WithDefaultTimeout overrides the default limit of 11 seconds on the total
+// time taken by each discovery request, including any redirects. A timeout
+// of zero means that there is no limit other than that of the context passed
+// to [Disco.Discover].
+//
+// This option customizes the default HTTP client and so cannot be combined
+// with [WithHTTPClient], whose own Timeout field applies instead.
+func WithDefaultTimeout(timeout time.Duration) DiscoOption {
+	return discoOption(func(disco *Disco) error {
+		if timeout < 0 {
+			return errors.New("discovery timeout must not be negative")
+		}
+		disco.timeout = timeout
+		disco.transportOptions = append(disco.transportOptions, "WithDefaultTimeout")
+		return nil
+	})
+}
+
+// WithMaxDocSize overrides the default limit of 1MiB on the size of a
+// discovery document, which protects against servers that return
+// unreasonably large responses. The limit applies to the size of the
+// document after decompression, if the server compressed it, so that a small
+// compressed response can't expand to a huge document. A limit of zero
+// disables the limit entirely, which should be used only for trusted hosts,
+// such as internal hosts with very large service catalogs.
+//
+// Unlike the options that customize the default HTTP client, this option
+// also applies when combined with [WithHTTPClient].
+func WithMaxDocSize(bytes int64) DiscoOption {
+	return discoOption(func(disco *Disco) error {
+		if bytes < 0 {
+			return errors.New("discovery document size limit must not be negative")
+		}
+		disco.maxDocBytes = bytes
+		return nil
+	})
+}
+
+// WithDiscoveryPaths sets the paths at which discovery looks for the
+// discovery document, in order of preference, instead of only the standard
+// "/.well-known/terraform.json". Each path must be absolute and may include
+// a query string.
+//
+// Discovery requests each path in turn until the server responds with
+// something other than 404 Not Found, and then uses that response. This
+// allows clients to prefer a newer well-known name while still supporting
+// hosts that serve only the standard one, such as:
+//
+//	WithDiscoveryPaths("/.well-known/opentofu.json", "/.well-known/terraform.json")
+//
+// A path set for a specific hostname using [Disco.SetDiscoveryPath] takes
+// precedence over all of the given paths.
+func WithDiscoveryPaths(paths ...string) DiscoOption {
+	return discoOption(func(disco *Disco) error {
+		if len(paths) == 0 {
+			return errors.New("WithDiscoveryPaths requires at least one path")
+		}
+		var errs []error
+		valid := make([]string, 0, len(paths))
+		for _, path := range paths {
+			if err := validateDiscoveryPath(path); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			valid = append(valid, path)
+		}
+		if len(valid) != 0 {
+			disco.defaultPaths = valid
+		}
+		return errors.Join(errs...)
+	})
+}
+
+// WithAcceptedMediaTypes extends the media types that are accepted for
+// discovery documents beyond the standard "application/json", for use with
+// gateways that serve the document as, for example,
+// "application/vnd.api+json" or "text/json". The given types are also
+// listed in the Accept header of discovery requests, after
+// "application/json".
+//
+// Each media type must be a type and subtype without parameters, such as
+// "text/json", and is compared case-insensitively. The document must still
+// be JSON regardless of its media type.
+func WithAcceptedMediaTypes(mediaTypes ...string) DiscoOption {
+	return discoOption(func(disco *Disco) error {
+		var errs []error
+		for _, s := range mediaTypes {
+			mediaType, err := normalizeMediaType(s)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if !disco.acceptedMediaType(mediaType) {
+				disco.extraMediaTypes = append(disco.extraMediaTypes, mediaType)
+			}
+		}
+		return errors.Join(errs...)
+	})
+}
+
+// WithRedirectPolicy restricts the number and targets of the redirects that
+// discovery requests may follow, so that a compromised or misconfigured
+// server can't send discovery requests, and any credentials they include,
+// to an unexpected location. Discovery fails with an error wrapping
+// [ErrRedirectNotAllowed] if the server responds with a redirect that the
+// policy doesn't allow.
+//
+// When combined with [WithHTTPClient], the policy is enforced before calling
+// the client's own CheckRedirect function, if any. The given client is not
+// modified.
+func WithRedirectPolicy(policy RedirectPolicy) DiscoOption {
+	return discoOption(func(disco *Disco) error {
+		disco.redirectPolicy = policy
+		disco.hasRedirectPolicy = true
+		return nil
+	})
+}
+
+// WithServiceURLPolicy restricts the URLs that discovered hosts may declare
+// for their services, so that a compromised or malicious discovery document
+// can't direct clients to send credentials to an unexpected location. The
+// methods of [Host] that return service URLs, including the endpoints of
+// [Host.ServiceOAuthClient], return an [*ErrServiceURLNotAllowed] error for
+// a URL that the policy doesn't allow.
+//
+// The policy compares service URLs with the hostname being discovered, even
+// if the discovery request was redirected or directed elsewhere by
+// [WithDNSHints]. It applies only to hosts discovered by the resulting
+// [Disco], and not to services configured using [Disco.ForceHostServices]
+// or [Disco.CacheHost], which the caller is responsible for.
+func WithServiceURLPolicy(policy ServiceURLPolicy) DiscoOption {
+	return discoOption(func(disco *Disco) error {
+		policy.AllowedHosts = slices.Clone(policy.AllowedHosts)
+		disco.serviceURLPolicy = &policy
+		return nil
+	})
+}
+
+// WithDNSHints causes discovery to consult DNS SRV records, named using
+// [DNSHintService], to find the host and port to request the discovery
+// document from, which allows operators to move discovery for a hostname to
+// another host or port without running a reverse proxy at the original one.
+//
+// If there is no such record, or the lookup fails, discovery uses the
+// hostname as usual. Hints are not consulted for hostnames that include an
+// explicit port number or that are IP addresses. The document path is not
+// affected, and the server at the hinted host must present a TLS certificate
+// that is valid for that host. Relative URLs in the discovery document are
+// resolved against the hinted URL. Any policy from [WithHostPolicy] must
+// allow the hinted host, or the hint is ignored.
+//
+// Because DNS responses are not authenticated, the credentials for the
+// hostname are only sent to a hinted host in the same registrable domain,
+// as decided by the public suffix list. The discovery request to a hinted
+// host in any other domain is sent without credentials.
+//
+// If resolver is nil then [net.DefaultResolver] is used.
+func WithDNSHints(resolver DNSResolver) DiscoOption {
+	return discoOption(func(disco *Disco) error {
+		if resolver == nil {
+			resolver = net.DefaultResolver
+		}
+		disco.dnsResolver = resolver
+		return nil
+	})
+}
+
+// WithFIPSMode restricts the TLS settings used for discovery requests to
+// only FIPS-approved protocol versions and algorithms.
+//
+// FIPS mode is also enabled automatically when the Go runtime is operating
+// in FIPS 140-3 mode. When combined with [WithHTTPClient], the given client
+// is checked for compliance instead, and [NewWithErrors] reports an error
+// if it uses settings that are not allowed. In that case every discovery
+// request fails, even for a Disco created using [New], rather than being
+// sent using the non-compliant client.
+func WithFIPSMode() DiscoOption {
+	return discoOption(func(disco *Disco) error {
+		disco.transport.FIPS = true
+		return nil
+	})
+}
+
+// WithSystemProxy causes discovery requests to use the proxy configured in
+// the operating system's settings on platforms where those are stored
+// outside of the environment, such as the registry on Windows or the
+// SystemConfiguration framework on macOS.
+//
+// The conventional HTTPS_PROXY and related environment variables still take
+// priority when set. Only statically-configured proxies are supported: proxy
+// auto-configuration, whether by a PAC script or by WPAD auto-discovery, is
+// ignored, so requests are sent directly when the system is configured only
+// for auto-configuration.
+//
+// This option customizes the default HTTP client and so cannot be combined
+// with [WithHTTPClient], or with [WithSOCKS5Proxy] or [WithProxyFunc].
+func WithSystemProxy() DiscoOption {
+	return discoOption(func(disco *Disco) error {
+		return disco.setProxy("WithSystemProxy", transport.SystemProxy())
+	})
+}
+
+// WithHostPolicy registers a function that is consulted before making a
+// network discovery request for any hostname. If the function returns an
+// error then discovery fails with an error wrapping it.
+//
+// When a hostname has an alias, the policy is checked against the alias
+// target, since that is the host that would be contacted. The policy is also
+// checked against the target of each redirect and any host chosen by
+// [WithDNSHints], and before using a result from the persistent cache given
+// in [WithCacheStore]. The policy is not consulted for hosts whose services
+// were provided using [Disco.ForceHostServices], or for results already in
+// the in-memory cache.
+func WithHostPolicy(policy func(svchost.Hostname) error) DiscoOption {
+	return discoOption(func(disco *Disco) error {
+		if policy == nil {
+			return errors.New("WithHostPolicy requires a non-nil policy function")
+		}
+		disco.hostPolicy = policy
+		return nil
+	})
+}
+
+// WithPrivateAddressProtection causes discovery requests to refuse to connect
+// to loopback, link-local, private (such as RFC 1918), carrier-grade NAT
+// (RFC 6598), and unspecified network addresses, which is intended for
+// services that perform discovery on hostnames supplied by untrusted users.
+//
+// The check applies to the addresses that each hostname resolves to,
+// immediately before connecting, so it also covers any redirects. Failures
+// wrap [ErrPrivateAddress]. Addresses within any of the given allowed
+// prefixes are permitted regardless.
+//
+// When requests are made through a proxy, the check applies to the address
+// of the proxy rather than to the discovery host, so the proxy must either
+// be allowed explicitly or enforce an equivalent policy itself.
+//
+// This option customizes the default HTTP client and so cannot be combined
+// with [WithHTTPClient]. If it is, then every discovery request fails, even
+// for a Disco created using [New], rather than being sent without the
+// protection.
+func WithPrivateAddressProtection(allowed ...netip.Prefix) DiscoOption {
+	return discoOption(func(disco *Disco) error {
+		disco.transport.DenyPrivateAddresses = true
+		disco.transportOptions = append(disco.transportOptions, "WithPrivateAddressProtection")
+		// On error we still enable the protection, since New ignores option
+		// errors, but without allowing any of the given prefixes.
+		for _, prefix := range allowed {
+			if !prefix.IsValid() {
+				return fmt.Errorf("invalid allowed prefix %s for WithPrivateAddressProtection", prefix)
+			}
+		}
+		disco.transport.AllowedPrefixes = append(disco.transport.AllowedPrefixes, allowed...)
+		return nil
+	})
+}
+
+// WithResponseVerifier registers a function that must approve each discovery
+// document before it is accepted, for use with signing schemes that are
+// not part of the discovery protocol itself.
+//
+// The verifier receives the raw document along with the values of any of the
+// given response headers, such as a header containing a signature. It is not
+// called for responses that indicate the host has no discovery document at
+// all.
+func WithResponseVerifier(verify ResponseVerifier, headerNames ...string) DiscoOption {
+	return discoOption(func(disco *Disco) error {
+		// On error we keep any verifier that is already registered, since
+		// New ignores option errors and verification must not be disabled
+		// by mistake.
+		if verify == nil {
+			return errors.New("WithResponseVerifier requires a non-nil verifier function")
+		}
+		if disco.verifier != nil {
+			return errors.New("conflicting verifiers given in multiple WithResponseVerifier options")
+		}
+		disco.verifier = verify
+		disco.verifierHeaders = slices.Clone(headerNames)
+		return nil
+	})
+}
+
+// WithRequestMutator registers a function that may modify each discovery
+// request after any credentials have been applied and before it is sent, such
+// as to add a signature, a request ID, or tenancy headers, without replacing
+// the HTTP client. Mutators are also called for each request made to follow
+// a redirect, after the credentials for the new location have been applied.
+//
+// This option may be used multiple times, in which case each mutator is
+// called in the order given until one returns an error.
+func WithRequestMutator(mutate RequestMutator) DiscoOption {
+	return discoOption(func(disco *Disco) error {
+		if mutate == nil {
+			return errors.New("WithRequestMutator requires a non-nil mutator function")
+		}
+		disco.requestMutators = append(disco.requestMutators, mutate)
+		return nil
+	})
+}
+
+// WithDocumentValidator registers a function that must approve the content of
+// each discovery document after it has been decoded, such as to enforce an
+// organizational policy that services must not be hosted on other domains or
+// use plain "http" URLs.
+//
+// This option may be used multiple times, in which case each validator is
+// called in the order given until one returns an error. Validators apply
+// only to documents fetched over the network, and not to services provided
+// using [Disco.ForceHostServices].
+func WithDocumentValidator(validate DocumentValidator) DiscoOption {
+	return discoOption(func(disco *Disco) error {
+		if validate == nil {
+			return errors.New("WithDocumentValidator requires a non-nil validator function")
+		}
+		disco.validators = append(disco.validators, validate)
+		return nil
+	})
+}
+
+// WithUnknownOAuthFields registers a function that is called by
+// [Host.ServiceOAuthClient] with the names of any properties of an OAuth
+// client configuration that this package doesn't understand, so that callers
+// can warn about them. This is called each time the configuration is
+// decoded, for hosts discovered or configured by the resulting [Disco].
+//
+// Unknown properties are otherwise ignored, so that later versions of the
+// discovery protocol can add new properties.
+func WithUnknownOAuthFields(notify UnknownOAuthFieldsFunc) DiscoOption {
+	return discoOption(func(disco *Disco) error {
+		if notify == nil {
+			return errors.New("WithUnknownOAuthFields requires a non-nil function")
+		}
+		disco.unknownOAuthFields = notify
+		return nil
+	})
+}
+
+// WithCacheStore adds a persistent cache of discovery results in addition to
+// the in-memory cache, so that separate processes can reuse results. Use
+// [NewFileCacheStore] for a cache saved in files on local disk.
+//
+// Results are kept for the given default duration, unless the discovery
+// response includes a Cache-Control header with either a "max-age"
+// directive, which overrides the default, or a "no-store" or "no-cache"
+// directive, which prevents persisting the result at all.
+//
+// [Disco.Forget] and [Disco.ForgetAlias] also discard any persistent entry
+// for the given hostname, but [Disco.ForgetAll] affects only the in-memory
+// cache.
+func WithCacheStore(store DiscoCacheStore, ttl time.Duration) DiscoOption {
+	return discoOption(func(disco *Disco) error {
+		if store == nil {
+			return errors.New("WithCacheStore requires a non-nil cache store")
+		}
+		if ttl <= 0 {
+			return errors.New("WithCacheStore requires a positive duration")
+		}
+		disco.cacheStore = store
+		disco.cacheStoreTTL = ttl
+		return nil
+	})
+}
+
+// WithNegativeCacheTTL causes failed discovery results to be remembered for
+// the given duration, so that [Disco.Discover] fails immediately with the
+// same error rather than repeatedly sending requests to a host that is
+// known to be broken. A duration of zero, the default, disables this.
+//
+// Failures caused by the caller's context being canceled or reaching its
+// deadline are not remembered. A successful result for the hostname, such
+// as from [Disco.Refresh] or [Disco.CacheHost], replaces any remembered
+// failure, and [Disco.Forget] discards it.
+//
+// Hosts that provide no services at all are not failures, and so their
+// results are cached in the same way as any other successful result.
+func WithNegativeCacheTTL(ttl time.Duration) DiscoOption {
+	return discoOption(func(disco *Disco) error {
+		if ttl < 0 {
+			return errors.New("negative cache duration must not be negative")
+		}
+		disco.negativeCacheTTL = ttl
+		return nil
+	})
+}
+
+// WithStaleWhileRevalidate causes [Disco.Discover] to keep returning a cached
+// discovery result immediately once it is older than the given age, while
+// refreshing it in the background using [Disco.Refresh]. This suits
+// long-running processes that would otherwise keep using the first result
+// for a host forever. A duration of zero, the default, disables this.
+//
+// Only one background refresh runs at a time for each hostname, and
+// failures leave the cached result in place to be retried on a later call.
+// Use the BackgroundRefresh callback of [DiscoTrace] to learn when a
+// background refresh completes.
+//
+// Results given to [Disco.ForceHostServices] or [Disco.CacheHost] are never
+// refreshed, because they were not obtained by a discovery request.
+func WithStaleWhileRevalidate(maxAge time.Duration) DiscoOption {
+	return discoOption(func(disco *Disco) error {
+		if maxAge < 0 {
+			return errors.New("stale-while-revalidate age must not be negative")
+		}
+		disco.staleAfter = maxAge
+		return nil
+	})
+}
+
+// WithClock overrides the clock used to decide when cached discovery results
+// expire, including those in a persistent cache store or remembered by
+// [WithNegativeCacheTTL], and to wait between retries, so that tests can
+// simulate the passage of time without waiting for it.
+//
+// The durations reported by [DiscoTrace.DiscoveryStats] and
+// [Host.DiscoveryInfo] always use the system clock.
+func WithClock(c Clock) DiscoOption {
+	return discoOption(func(disco *Disco) error {
+		if c == nil {
+			return errors.New("WithClock requires a non-nil clock")
+		}
+		disco.clock = c
+		return nil
+	})
+}
+
+// WithRetryPolicy causes discovery requests that fail with a transient
+// network error, such as a timeout, or with one of the policy's retryable
+// status codes to be retried, with an exponential backoff between attempts.
+//
+// By default discovery requests are not retried.
+func WithRetryPolicy(policy RetryPolicy) DiscoOption {
+	return discoOption(func(disco *Disco) error {
+		if policy.MinBackoff < 0 || policy.MaxBackoff < policy.MinBackoff {
+			return errors.New("WithRetryPolicy requires a non-negative MinBackoff no greater than MaxBackoff")
+		}
+		disco.retryPolicy = &policy
+		return nil
+	})
+}
+
+// WithRequestCoalescing, when enabled, causes concurrent calls to
+// [Disco.Discover] for the same hostname to share a single discovery request
+// instead of each making their own, which can significantly reduce load when
+// many workers concurrently resolve the same host.
+//
+// Each caller still waits only as long as its own context allows. If the
+// shared request fails because the context of the caller that started it
+// was canceled, the other callers start a new request of their own.
+//
+// Request coalescing is disabled by default.
+func WithRequestCoalescing(enabled bool) DiscoOption {
+	return discoOption(func(disco *Disco) error {
+		disco.coalesceRequests = enabled
+		return nil
+	})
+}
+
+// WithInsecureHosts allows discovery over plain HTTP, rather than HTTPS, for
+// loopback hosts such as "localhost" and "127.0.0.1" on any port, and for
+// each of the given hostnames, which is useful when developing a service
+// locally without a trusted TLS certificate.
+//
+// Plain HTTP exposes the discovery document and any credentials sent with it
+// to interception, so this should be used only for hosts that are under the
+// user's own control. All other hosts still require HTTPS.
+func WithInsecureHosts(hosts ...svchost.Hostname) DiscoOption {
+	return discoOption(func(disco *Disco) error {
+		if disco.insecureHosts == nil {
+			disco.insecureHosts = make(map[svchost.Hostname]struct{}, len(hosts))
+		}
+		for _, host := range hosts {
+			disco.insecureHosts[host] = struct{}{}
+		}
+		return nil
+	})
+}
+
+// WithUserAgent identifies the product making discovery requests, such as
+// "OpenTofu/1.10.0", in the User-Agent header of each request.
+//
+// The User-Agent header always includes a token identifying this library and
+// its version, so that servers can distinguish discovery traffic from other
+// requests. The given product, if any, is placed before that token, and any
+// comment given using [WithUserAgentComment] follows it.
+func WithUserAgent(product string) DiscoOption {
+	return discoOption(func(disco *Disco) error {
+		if !validUserAgentPart(product) {
+			return errors.New("WithUserAgent requires a product containing only printable ASCII characters")
+		}
+		disco.userAgentProduct = product
+		return nil
+	})
+}
+
+// WithUserAgentComment appends the given comment, enclosed in parentheses,
+// to the end of the User-Agent header sent with discovery requests, which
+// is useful for including additional details such as the operating system
+// or the name of the automation system running the product.
+//
+// The comment must not itself include the enclosing parentheses.
+func WithUserAgentComment(comment string) DiscoOption {
+	return discoOption(func(disco *Disco) error {
+		if !validUserAgentPart(comment) || strings.ContainsAny(comment, "()") {
+			return errors.New("WithUserAgentComment requires a comment containing only printable ASCII characters other than parentheses")
+		}
+		disco.userAgentComment = comment
+		return nil
 	})
 }
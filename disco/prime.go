@@ -0,0 +1,85 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package disco
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+
+	svchost "github.com/opentofu/svchost"
+)
+
+// PrimeHost adds a discovery result for the given hostname to the in-memory
+// cache from a raw discovery document that was obtained some other way than
+// by a discovery request, such as one bundled in an archive for use without
+// network access, or one fetched by another process. Later calls to
+// [Disco.Discover] for the hostname return that result without making a
+// discovery request.
+//
+// Unlike [Disco.ForceHostServices], the document is checked in the same way
+// as one returned by the host itself: it must not exceed the size limit set
+// by [WithMaxDocSize], the hostname must be permitted by [WithHostPolicy],
+// and the document must pass any verifier and validators registered with
+// [WithResponseVerifier] and [WithDocumentValidator], though the verifier
+// sees no response headers. The result is also subject to any
+// [WithServiceURLPolicy].
+//
+// discoURL is the URL the document would have been fetched from, against
+// which any relative URLs in it are resolved. If it is nil then the host's
+// usual discovery URL is used. Otherwise it must be absolute.
+//
+// If the document is rejected then PrimeHost returns an error and the cache
+// is not modified.
+func (d *Disco) PrimeHost(hostname svchost.Hostname, discoURL *url.URL, servicesJSON []byte) error {
+	host, err := d.primedHost(hostname, discoURL, servicesJSON)
+	if err != nil {
+		return svchost.WrapHostError(hostname, OpPrimeHost, err)
+	}
+	d.CacheHost(hostname, host)
+	return nil
+}
+
+// primedHost is the part of [Disco.PrimeHost] that checks the given document
+// and builds a Host from it.
+func (d *Disco) primedHost(hostname svchost.Hostname, discoURL *url.URL, servicesJSON []byte) (*Host, error) {
+	if discoURL == nil {
+		discoURL = d.discoveryURL(hostname)
+	} else if !discoURL.IsAbs() || discoURL.Host == "" {
+		return nil, errors.New("discovery URL must be an absolute URL")
+	} else {
+		u := *discoURL
+		discoURL = &u
+	}
+
+	if d.hostPolicy != nil {
+		if err := d.hostPolicy(hostname); err != nil {
+			return nil, fmt.Errorf("discovery is not permitted by host policy: %w", err)
+		}
+	}
+
+	// A limit of zero means that WithMaxDocSize disabled the limit.
+	if limit := d.maxDocBytes; limit > 0 && int64(len(servicesJSON)) > limit {
+		return nil, &ErrDiscoveryDocTooLarge{
+			Size:  int64(len(servicesJSON)),
+			Limit: limit,
+		}
+	}
+
+	services, err := d.checkDocument(hostname, servicesJSON, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	host := &Host{
+		discoURL:        discoURL,
+		hostname:        hostname.ForDisplay(),
+		services:        services,
+		protocolVersion: ProtocolVersion1,
+		responseTime:    d.clock.Now(),
+	}
+	host.unknownOAuthFields = d.unknownOAuthFieldsFor(hostname)
+	host.serviceURLPolicy = d.serviceURLPolicyFor(hostname)
+	return host, nil
+}
@@ -0,0 +1,166 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package disco
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+
+	svchost "github.com/opentofu/svchost"
+)
+
+func TestPrimeHost(t *testing.T) {
+	hostname := svchost.Hostname("example.com")
+	doc := []byte(`{"thingy.v1":"/thingy/","other.v1":"https://other.example.net/"}`)
+
+	t.Run("default URL", func(t *testing.T) {
+		// The client refuses all requests, so the result must come from the
+		// primed cache.
+		d, err := NewWithErrors(WithHTTPClient(&http.Client{
+			Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				t.Errorf("unexpected request to %s", req.URL)
+				return nil, errors.New("no network access")
+			}),
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := d.PrimeHost(hostname, nil, doc); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		host, err := d.Discover(t.Context(), hostname)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		u, err := host.ServiceURL("thingy.v1")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if got, want := u.String(), "https://example.com/thingy/"; got != want {
+			t.Errorf("wrong URL %q; want %q", got, want)
+		}
+	})
+
+	t.Run("given URL", func(t *testing.T) {
+		d := New()
+		base, _ := url.Parse("https://mirror.example.com/hosts/example.com.json")
+		if err := d.PrimeHost(hostname, base, doc); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		u, err := d.DiscoverServiceURL(t.Context(), hostname, "thingy.v1")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if got, want := u.String(), "https://mirror.example.com/thingy/"; got != want {
+			t.Errorf("wrong URL %q; want %q", got, want)
+		}
+	})
+
+	t.Run("relative URL", func(t *testing.T) {
+		d := New()
+		base, _ := url.Parse("/hosts/example.com.json")
+		if err := d.PrimeHost(hostname, base, doc); err == nil {
+			t.Fatal("unexpected success; want error")
+		}
+	})
+
+	t.Run("service URL policy", func(t *testing.T) {
+		d, err := NewWithErrors(WithServiceURLPolicy(ServiceURLPolicy{SameHostOnly: true}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := d.PrimeHost(hostname, nil, doc); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		_, err = d.DiscoverServiceURL(t.Context(), hostname, "other.v1")
+		var notAllowed *ErrServiceURLNotAllowed
+		if !errors.As(err, &notAllowed) {
+			t.Errorf("wrong error %v; want ErrServiceURLNotAllowed", err)
+		}
+	})
+}
+
+func TestPrimeHost_rejected(t *testing.T) {
+	hostname := svchost.Hostname("example.com")
+	errRejected := errors.New("rejected")
+	tests := map[string]struct {
+		options []DiscoOption
+		doc     string
+		want    error
+	}{
+		"not an object": {
+			doc:  `["thingy.v1"]`,
+			want: &ErrDiscoveryInvalidDocument{},
+		},
+		"invalid version": {
+			doc:  `{"meta":{"version":"one"},"thingy.v1":"/thingy/"}`,
+			want: &ErrDiscoveryInvalidDocument{},
+		},
+		"too large": {
+			options: []DiscoOption{WithMaxDocSize(10)},
+			doc:     `{"thingy.v1":"/thingy/"}`,
+			want:    &ErrDiscoveryDocTooLarge{},
+		},
+		"host policy": {
+			options: []DiscoOption{WithHostPolicy(func(svchost.Hostname) error {
+				return errRejected
+			})},
+			doc:  `{"thingy.v1":"/thingy/"}`,
+			want: errRejected,
+		},
+		"verifier": {
+			options: []DiscoOption{WithResponseVerifier(func(svchost.Hostname, []byte, http.Header) error {
+				return errRejected
+			})},
+			doc:  `{"thingy.v1":"/thingy/"}`,
+			want: errRejected,
+		},
+		"validator": {
+			options: []DiscoOption{WithDocumentValidator(func(svchost.Hostname, map[string]any) error {
+				return errRejected
+			})},
+			doc:  `{"thingy.v1":"/thingy/"}`,
+			want: errRejected,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			d, err := NewWithErrors(test.options...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = d.PrimeHost(hostname, nil, []byte(test.doc))
+			if err == nil {
+				t.Fatal("unexpected success; want error")
+			}
+			var hostErr *svchost.HostError
+			if !errors.As(err, &hostErr) || hostErr.Host != hostname {
+				t.Errorf("error %v does not identify the host", err)
+			}
+			switch want := test.want.(type) {
+			case *ErrDiscoveryInvalidDocument:
+				if !errors.As(err, &want) {
+					t.Errorf("wrong error %v; want %T", err, want)
+				}
+			case *ErrDiscoveryDocTooLarge:
+				if !errors.As(err, &want) {
+					t.Errorf("wrong error %v; want %T", err, want)
+				}
+			default:
+				if !errors.Is(err, want) {
+					t.Errorf("wrong error %v; want %v", err, want)
+				}
+			}
+
+			d.mu.Lock()
+			_, cached := d.hostCache[hostname]
+			d.mu.Unlock()
+			if cached {
+				t.Error("rejected document was cached")
+			}
+		})
+	}
+}
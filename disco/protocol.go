@@ -0,0 +1,112 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package disco
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	// AcceptProtocolVersionHeader is the name of the HTTP request header
+	// that a client uses to list the versions of the discovery protocol
+	// it supports, as a comma-separated list of decimal integers.
+	AcceptProtocolVersionHeader = "Accept-Discovery-Version"
+
+	// ProtocolVersionHeader is the name of the HTTP response header that a
+	// server uses to declare which version of the discovery protocol its
+	// discovery document conforms to.
+	//
+	// Servers that predate protocol version negotiation don't send this
+	// header, and so its absence implies [ProtocolVersion1].
+	ProtocolVersionHeader = "Discovery-Version"
+
+	// ProtocolVersion1 is the original version of the discovery protocol,
+	// in which the discovery document is a JSON object whose property names
+	// are service identifiers.
+	ProtocolVersion1 = 1
+)
+
+// defaultProtocolVersions is the set of protocol versions supported by
+// a [Disco] object when not overridden using [WithProtocolVersions].
+var defaultProtocolVersions = []int{ProtocolVersion1}
+
+// ErrUnsupportedProtocolVersion is returned when a server declares that its
+// discovery document conforms to a protocol version that the client did not
+// offer to accept.
+type ErrUnsupportedProtocolVersion struct {
+	// Version is the version that the server declared.
+	Version int
+
+	// Supported is the set of versions that the client would have accepted.
+	Supported []int
+}
+
+func (e *ErrUnsupportedProtocolVersion) Error() string {
+	return fmt.Sprintf("server uses unsupported discovery protocol version %d", e.Version)
+}
+
+// NegotiateProtocolVersion chooses the highest protocol version that
+// appears both in the given Accept-Discovery-Version header value sent
+// by a client and in the given list of versions supported by a server.
+//
+// An empty header value is treated as accepting only [ProtocolVersion1],
+// since that's what clients that predate version negotiation support.
+// The second return value is false if there is no version in common.
+//
+// This is intended for use in server implementations of the discovery
+// protocol, to decide which version of the discovery document to return.
+func NegotiateProtocolVersion(acceptHeader string, supported ...int) (int, bool) {
+	accepted, err := parseProtocolVersions(acceptHeader)
+	if err != nil || len(accepted) == 0 {
+		accepted = defaultProtocolVersions
+	}
+	best, ok := 0, false
+	for _, v := range accepted {
+		if v > best && slices.Contains(supported, v) {
+			best, ok = v, true
+		}
+	}
+	return best, ok
+}
+
+// formatProtocolVersions returns the given versions in the syntax expected
+// for the Accept-Discovery-Version header.
+func formatProtocolVersions(versions []int) string {
+	strs := make([]string, len(versions))
+	for i, v := range versions {
+		strs[i] = strconv.Itoa(v)
+	}
+	return strings.Join(strs, ", ")
+}
+
+// parseProtocolVersions parses a comma-separated list of protocol versions,
+// as used in the Accept-Discovery-Version header.
+func parseProtocolVersions(s string) ([]int, error) {
+	var ret []int
+	for part := range strings.SplitSeq(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		v, err := parseProtocolVersion(part)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, v)
+	}
+	return ret, nil
+}
+
+// parseProtocolVersion parses a single protocol version number, as used in
+// the Discovery-Version response header.
+func parseProtocolVersion(s string) (int, error) {
+	v, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || v < 1 {
+		return 0, fmt.Errorf("invalid discovery protocol version %q", s)
+	}
+	return v, nil
+}
@@ -0,0 +1,110 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package disco
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	svchost "github.com/opentofu/svchost"
+)
+
+func TestNegotiateProtocolVersion(t *testing.T) {
+	tests := []struct {
+		accept    string
+		supported []int
+		want      int
+		wantOK    bool
+	}{
+		{"", []int{1}, 1, true},
+		{"", []int{2}, 0, false},
+		{"1", []int{1, 2}, 1, true},
+		{"1, 2", []int{1, 2}, 2, true},
+		{"2,1", []int{1}, 1, true},
+		{"3", []int{1, 2}, 0, false},
+		{"not a number", []int{1}, 1, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.accept, func(t *testing.T) {
+			got, ok := NegotiateProtocolVersion(test.accept, test.supported...)
+			if got != test.want || ok != test.wantOK {
+				t.Errorf("wrong result %d, %t; want %d, %t", got, ok, test.want, test.wantOK)
+			}
+		})
+	}
+}
+
+func TestDiscoverProtocolVersion(t *testing.T) {
+	var gotAccept string
+	serverVersion := ""
+	portStr, cleanup := testServer(func(w http.ResponseWriter, r *http.Request) {
+		gotAccept = r.Header.Get(AcceptProtocolVersionHeader)
+		if serverVersion != "" {
+			w.Header().Set(ProtocolVersionHeader, serverVersion)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	})
+	defer cleanup()
+
+	host, err := svchost.ForComparison("localhost" + portStr)
+	if err != nil {
+		t.Fatalf("test server hostname is invalid: %s", err)
+	}
+
+	t.Run("undeclared", func(t *testing.T) {
+		d := New(WithHTTPClient(testClient))
+		discovered, err := d.Discover(t.Context(), host)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if got, want := gotAccept, "1"; got != want {
+			t.Errorf("wrong %s header %q; want %q", AcceptProtocolVersionHeader, got, want)
+		}
+		if got, want := discovered.ProtocolVersion(), 1; got != want {
+			t.Errorf("wrong protocol version %d; want %d", got, want)
+		}
+	})
+	t.Run("negotiated", func(t *testing.T) {
+		serverVersion = "2"
+		d := New(WithHTTPClient(testClient), WithProtocolVersions(1, 2))
+		discovered, err := d.Discover(t.Context(), host)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if got, want := gotAccept, "1, 2"; got != want {
+			t.Errorf("wrong %s header %q; want %q", AcceptProtocolVersionHeader, got, want)
+		}
+		if got, want := discovered.ProtocolVersion(), 2; got != want {
+			t.Errorf("wrong protocol version %d; want %d", got, want)
+		}
+	})
+	t.Run("caller's slice modified", func(t *testing.T) {
+		serverVersion = "2"
+		versions := []int{1, 2}
+		d := New(WithHTTPClient(testClient), WithProtocolVersions(versions...))
+		versions[1] = 3
+		discovered, err := d.Discover(t.Context(), host)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if got, want := discovered.ProtocolVersion(), 2; got != want {
+			t.Errorf("wrong protocol version %d; want %d", got, want)
+		}
+	})
+	t.Run("unsupported", func(t *testing.T) {
+		serverVersion = "2"
+		d := New(WithHTTPClient(testClient))
+		_, err := d.Discover(t.Context(), host)
+		var versionErr *ErrUnsupportedProtocolVersion
+		if !errors.As(err, &versionErr) {
+			t.Fatalf("wrong error %v; want ErrUnsupportedProtocolVersion", err)
+		}
+		if got, want := versionErr.Version, 2; got != want {
+			t.Errorf("wrong version in error %d; want %d", got, want)
+		}
+	})
+}
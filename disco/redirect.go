@@ -0,0 +1,206 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package disco
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"golang.org/x/net/publicsuffix"
+
+	svchost "github.com/opentofu/svchost"
+	"github.com/opentofu/svchost/svcauth"
+)
+
+// ErrRedirectNotAllowed is wrapped by the errors returned when discovery
+// fails because the server redirected to a location that the
+// [RedirectPolicy] given in [WithRedirectPolicy] doesn't allow.
+var ErrRedirectNotAllowed = errors.New("redirect not allowed")
+
+// RedirectPolicy restricts the redirects that discovery requests may follow,
+// for use with [WithRedirectPolicy].
+//
+// The zero value follows up to three redirects to any location, which is
+// the default behavior.
+type RedirectPolicy struct {
+	// MaxRedirects is the maximum number of redirects to follow for each
+	// discovery request. Zero means the default of three, and a negative
+	// value means that no redirects are followed at all.
+	MaxRedirects int
+
+	// SameHostOnly allows only redirects to the same hostname and port as
+	// the original discovery request.
+	SameHostOnly bool
+
+	// SameSiteOnly allows only redirects to hostnames in the same
+	// registrable domain as the original discovery request, such as from
+	// "registry.example.com" to "cdn.example.com", as decided by the public
+	// suffix list.
+	SameSiteOnly bool
+
+	// HTTPSOnly allows only redirects to HTTPS URLs, even when the original
+	// request used plain HTTP because of [WithInsecureHosts].
+	HTTPSOnly bool
+}
+
+// checkRedirect returns a function suitable for [http.Client.CheckRedirect]
+// that enforces the receiver and then, if next is non-nil, calls next.
+func (p RedirectPolicy) checkRedirect(next func(*http.Request, []*http.Request) error) func(*http.Request, []*http.Request) error {
+	limit := p.MaxRedirects
+	if limit == 0 {
+		limit = maxRedirects
+	}
+	return func(req *http.Request, via []*http.Request) error {
+		if len(via) > limit || limit < 0 {
+			return fmt.Errorf("%w: too many redirects", ErrRedirectNotAllowed)
+		}
+		if err := p.checkTarget(via[0], req); err != nil {
+			return fmt.Errorf("%w: %w", ErrRedirectNotAllowed, err)
+		}
+		if next != nil {
+			return next(req, via)
+		}
+		return nil
+	}
+}
+
+// checkTarget returns an error if the policy doesn't allow a redirect from
+// the given original request to the given new request.
+func (p RedirectPolicy) checkTarget(orig, req *http.Request) error {
+	if p.HTTPSOnly && req.URL.Scheme != "https" {
+		return fmt.Errorf("redirect to %s uses %s instead of https", req.URL.Redacted(), req.URL.Scheme)
+	}
+	if !p.SameHostOnly && !p.SameSiteOnly {
+		return nil
+	}
+	origHost, err := svchost.ForComparisonAllowIP(orig.URL.Host)
+	if err != nil {
+		return err
+	}
+	newHost, err := svchost.ForComparisonAllowIP(req.URL.Host)
+	if err != nil {
+		return fmt.Errorf("redirect to invalid hostname: %w", err)
+	}
+	if newHost == origHost {
+		return nil
+	}
+	if p.SameHostOnly {
+		return fmt.Errorf("redirect to different host %s", newHost.ForDisplay())
+	}
+	if !sameSite(origHost, newHost) {
+		return fmt.Errorf("redirect to %s, which is not in the same domain as %s", newHost.ForDisplay(), origHost.ForDisplay())
+	}
+	return nil
+}
+
+// sameSite returns true if the given hostnames belong to the same
+// registrable domain. IP addresses are only the same site as themselves.
+func sameSite(a, b svchost.Hostname) bool {
+	if svchost.IsIPAddress(a) || svchost.IsIPAddress(b) {
+		return a.WithoutPort() == b.WithoutPort()
+	}
+	siteA, err := publicsuffix.EffectiveTLDPlusOne(a.WithoutPort().String())
+	if err != nil {
+		// Hostnames like "localhost" have no registrable domain, so they
+		// are only the same site as themselves.
+		return a.WithoutPort() == b.WithoutPort()
+	}
+	siteB, err := publicsuffix.EffectiveTLDPlusOne(b.WithoutPort().String())
+	return err == nil && siteA == siteB
+}
+
+// redirectClient returns a copy of the given client that checks each redirect
+// against any policy from [WithHostPolicy] and prepares each request made to
+// follow a redirect in the same way as the original discovery request for the
+// given hostname, which used the given credentials and extra headers, if the
+// receiver is configured in a way that requires that.
+func (d *Disco) redirectClient(client *http.Client, hostname svchost.Hostname, creds svcauth.HostCredentials, hostHeader http.Header) *http.Client {
+	if d.credsSrc == nil && len(d.requestMutators) == 0 && len(hostHeader) == 0 && d.hostPolicy == nil {
+		return client
+	}
+	ret := *client
+	next := client.CheckRedirect
+	ret.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if next != nil {
+			if err := next(req, via); err != nil {
+				return err
+			}
+		} else if len(via) >= 10 {
+			// This is the default behavior of http.Client.
+			return errors.New("stopped after 10 redirects")
+		}
+		if err := d.checkRedirectHostPolicy(req); err != nil {
+			return err
+		}
+		if len(hostHeader) != 0 {
+			redirectHostHeader(req, via, hostname, hostHeader)
+		}
+		if d.credsSrc != nil {
+			d.redirectCredentials(req, via, hostname, creds)
+		}
+		return d.mutateRequest(req)
+	}
+	return &ret
+}
+
+// checkRedirectHostPolicy returns an error if the host of the given request,
+// which follows a redirect, is not permitted by any policy from
+// [WithHostPolicy].
+func (d *Disco) checkRedirectHostPolicy(req *http.Request) error {
+	if d.hostPolicy == nil {
+		return nil
+	}
+	target, err := svchost.ForComparisonAllowIP(req.URL.Host)
+	if err != nil {
+		return fmt.Errorf("%w: redirect to invalid hostname: %w", ErrRedirectNotAllowed, err)
+	}
+	if err := d.hostPolicy(target); err != nil {
+		return fmt.Errorf("%w: redirect to %s is not permitted by host policy: %w", ErrRedirectNotAllowed, target.ForDisplay(), err)
+	}
+	return nil
+}
+
+// redirectCredentials makes sure that the given request, which follows a
+// redirect from the discovery request for the given hostname, carries the
+// credentials for its own host, if any, rather than the given credentials
+// for the hostname being discovered.
+//
+// The Go HTTP client already drops the Authorization header when following
+// a redirect to an unrelated domain, but not when redirecting to a
+// subdomain, and it never attaches credentials for the redirect target. It
+// also keeps any other headers that credentials may set, such as the
+// session token of [svcauth.HostCredentialsAWSSigV4], so we remove all of
+// the headers declared by [svcauth.CredentialHeaders].
+func (d *Disco) redirectCredentials(req *http.Request, via []*http.Request, hostname svchost.Hostname, creds svcauth.HostCredentials) {
+	// The credentials for the hostname also apply to the host that
+	// the original request was sent to, which can differ when using
+	// DNS hints.
+	target, err := svchost.ForComparisonAllowIP(req.URL.Host)
+	origHost, _ := svchost.ForComparisonAllowIP(via[0].URL.Host)
+	if err == nil && (target == hostname || target == origHost) {
+		if creds != nil {
+			creds.PrepareRequest(req)
+		}
+		return
+	}
+
+	ctx := req.Context()
+	for _, name := range svcauth.CredentialHeaders(creds) {
+		req.Header.Del(name)
+	}
+	if creds != nil {
+		trace := discoTraceFromContext(ctx)
+		trace.redirectCredentialsRemoved(ctx, hostname, req.URL)
+	}
+	if err != nil {
+		// We can't look up credentials for an invalid hostname, so
+		// we'll just let the request continue anonymously.
+		return
+	}
+	targetCreds, err := d.credentialsForDiscovery(ctx, target)
+	if err == nil && targetCreds != nil {
+		targetCreds.PrepareRequest(req)
+	}
+}
@@ -0,0 +1,287 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package disco
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+
+	svchost "github.com/opentofu/svchost"
+	"github.com/opentofu/svchost/svcauth"
+)
+
+func TestWithRedirectPolicy(t *testing.T) {
+	var portStr string
+	portStr, cleanup := testServer(func(w http.ResponseWriter, r *http.Request) {
+		// The "to" query parameter selects where to redirect to, and
+		// "hops" how many more redirects to send before the real response.
+		hops, _ := strconv.Atoi(r.URL.Query().Get("hops"))
+		switch to := r.URL.Query().Get("to"); {
+		case hops > 0:
+			next := "https://localhost" + portStr + "/.well-known/terraform.json?hops=" + strconv.Itoa(hops-1)
+			http.Redirect(w, r, next, http.StatusFound)
+		case to != "":
+			http.Redirect(w, r, to+portStr+"/.well-known/terraform.json", http.StatusFound)
+		default:
+			w.Header().Add("Content-Type", "application/json")
+			w.Write([]byte(`{"thingy.v1": "/foo"}`))
+		}
+	})
+	defer cleanup()
+
+	tests := map[string]struct {
+		policy  RedirectPolicy
+		query   string
+		wantErr bool
+	}{
+		"default allows other hosts": {
+			policy: RedirectPolicy{},
+			query:  "to=https://127.0.0.1",
+		},
+		"default allows three redirects": {
+			policy: RedirectPolicy{},
+			query:  "hops=3",
+		},
+		"default refuses four redirects": {
+			policy:  RedirectPolicy{},
+			query:   "hops=4",
+			wantErr: true,
+		},
+		"custom limit": {
+			policy:  RedirectPolicy{MaxRedirects: 1},
+			query:   "hops=2",
+			wantErr: true,
+		},
+		"no redirects": {
+			policy:  RedirectPolicy{MaxRedirects: -1},
+			query:   "hops=1",
+			wantErr: true,
+		},
+		"same host allowed": {
+			policy: RedirectPolicy{SameHostOnly: true},
+			query:  "to=https://localhost",
+		},
+		"same host refused": {
+			policy:  RedirectPolicy{SameHostOnly: true},
+			query:   "to=https://127.0.0.1",
+			wantErr: true,
+		},
+		"same site refused": {
+			policy:  RedirectPolicy{SameSiteOnly: true},
+			query:   "to=https://127.0.0.1",
+			wantErr: true,
+		},
+		"https only refused": {
+			policy:  RedirectPolicy{HTTPSOnly: true},
+			query:   "to=http://localhost",
+			wantErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			d := New(WithHTTPClient(testClient), WithRedirectPolicy(test.policy))
+			host := svchost.Hostname("localhost" + portStr)
+			if err := d.SetDiscoveryPath(host, "/.well-known/terraform.json?"+test.query); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			_, err := d.Discover(t.Context(), host)
+			if test.wantErr {
+				if !errors.Is(err, ErrRedirectNotAllowed) {
+					t.Fatalf("wrong error %v; want ErrRedirectNotAllowed", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+		})
+	}
+}
+
+func TestRedirectCredentials(t *testing.T) {
+	var gotAuth string
+	targetPortStr, cleanup := testServer(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		w.Header().Add("Content-Type", "application/json")
+		w.Write([]byte(`{"thingy.v1": "/foo"}`))
+	})
+	defer cleanup()
+	portStr, cleanup := testServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer origin-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		to := r.URL.Query().Get("to")
+		http.Redirect(w, r, to+targetPortStr+"/.well-known/terraform.json", http.StatusFound)
+	})
+	defer cleanup()
+
+	origin := svchost.Hostname("localhost" + portStr)
+	target := svchost.Hostname("127.0.0.1" + targetPortStr)
+
+	tests := map[string]struct {
+		to          string
+		targetCreds svcauth.HostCredentials
+		wantAuth    string
+		wantRemoved bool
+	}{
+		"different host without credentials": {
+			to:          "https://127.0.0.1",
+			wantAuth:    "",
+			wantRemoved: true,
+		},
+		"different host with credentials": {
+			to:          "https://127.0.0.1",
+			targetCreds: svcauth.HostCredentialsToken("target-token"),
+			wantAuth:    "Bearer target-token",
+			wantRemoved: true,
+		},
+		"different port": {
+			to:          "https://localhost",
+			wantAuth:    "Bearer port-token",
+			wantRemoved: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			gotAuth = ""
+			creds := map[svchost.Hostname]svcauth.HostCredentials{
+				origin: svcauth.HostCredentialsToken("origin-token"),
+				svchost.Hostname("localhost" + targetPortStr): svcauth.HostCredentialsToken("port-token"),
+			}
+			if test.targetCreds != nil {
+				creds[target] = test.targetCreds
+			}
+			d := New(
+				WithHTTPClient(testClient),
+				WithCredentials(svcauth.StaticCredentialsSource(creds)),
+			)
+			if err := d.SetDiscoveryPath(origin, "/.well-known/terraform.json?to="+url.QueryEscape(test.to)); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			var gotRemoved bool
+			ctx := ContextWithDiscoTrace(t.Context(), &DiscoTrace{
+				RedirectCredentialsRemoved: func(ctx context.Context, host svchost.Hostname, to *url.URL) {
+					if host != origin {
+						t.Errorf("wrong host %s; want %s", host, origin)
+					}
+					gotRemoved = true
+				},
+			})
+			if _, err := d.Discover(ctx, origin); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if gotAuth != test.wantAuth {
+				t.Errorf("wrong Authorization header %q; want %q", gotAuth, test.wantAuth)
+			}
+			if gotRemoved != test.wantRemoved {
+				t.Errorf("wrong RedirectCredentialsRemoved %t; want %t", gotRemoved, test.wantRemoved)
+			}
+		})
+	}
+}
+
+func TestRedirectHostPolicy(t *testing.T) {
+	var targetRequested bool
+	targetPortStr, cleanup := testServer(func(w http.ResponseWriter, r *http.Request) {
+		targetRequested = true
+		w.Header().Add("Content-Type", "application/json")
+		w.Write([]byte(`{"thingy.v1": "/foo"}`))
+	})
+	defer cleanup()
+	portStr, cleanup := testServer(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "https://127.0.0.1"+targetPortStr+"/.well-known/terraform.json", http.StatusFound)
+	})
+	defer cleanup()
+
+	errDenied := errors.New("host not allowed")
+	d, err := NewWithErrors(WithHTTPClient(testClient), WithHostPolicy(func(h svchost.Hostname) error {
+		if h.WithoutPort() == "127.0.0.1" {
+			return errDenied
+		}
+		return nil
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	_, err = d.Discover(t.Context(), svchost.Hostname("localhost"+portStr))
+	if !errors.Is(err, ErrRedirectNotAllowed) || !errors.Is(err, errDenied) {
+		t.Errorf("wrong error %v; want host policy error for redirect", err)
+	}
+	if targetRequested {
+		t.Error("redirect target was requested despite the host policy")
+	}
+}
+
+func TestRedirectCredentials_extraHeaders(t *testing.T) {
+	var gotHeader http.Header
+	targetPortStr, cleanup := testServer(func(w http.ResponseWriter, r *http.Request) {
+		gotHeader = r.Header.Clone()
+		w.Header().Add("Content-Type", "application/json")
+		w.Write([]byte(`{"thingy.v1": "/foo"}`))
+	})
+	defer cleanup()
+	portStr, cleanup := testServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Amz-Security-Token") != "session-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		http.Redirect(w, r, "https://127.0.0.1"+targetPortStr+"/.well-known/terraform.json", http.StatusFound)
+	})
+	defer cleanup()
+
+	origin := svchost.Hostname("localhost" + portStr)
+	d := New(
+		WithHTTPClient(testClient),
+		WithCredentials(svcauth.StaticCredentialsSource(map[svchost.Hostname]svcauth.HostCredentials{
+			origin: svcauth.HostCredentialsAWSSigV4{
+				Region:  "us-east-1",
+				Service: "execute-api",
+				Credentials: svcauth.AWSCredentials{
+					AccessKeyID:     "AKIDEXAMPLE",
+					SecretAccessKey: "secret",
+					SessionToken:    "session-token",
+				},
+			},
+		})),
+	)
+	if _, err := d.Discover(t.Context(), origin); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for _, name := range []string{"Authorization", "X-Amz-Date", "X-Amz-Security-Token"} {
+		if got := gotHeader.Values(name); len(got) != 0 {
+			t.Errorf("redirect target received %s header %q", name, got)
+		}
+	}
+}
+
+func TestSameSite(t *testing.T) {
+	tests := []struct {
+		a, b svchost.Hostname
+		want bool
+	}{
+		{"registry.example.com", "cdn.example.com", true},
+		{"registry.example.com", "example.com", true},
+		{"registry.example.com:8443", "cdn.example.com", true},
+		{"registry.example.com", "example.net", false},
+		{"a.github.io", "b.github.io", false},
+		{"localhost", "localhost:8080", true},
+		{"localhost", "example.com", false},
+		{"127.0.0.1", "127.0.0.1:8080", true},
+		{"127.0.0.1", "127.0.0.2", false},
+	}
+	for _, test := range tests {
+		if got := sameSite(test.a, test.b); got != test.want {
+			t.Errorf("wrong result %t for %q and %q; want %t", got, test.a, test.b, test.want)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package disco
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// capturedHeaderPrefixes and capturedHeaders select the response headers
+// that [Disco.Discover] retains in the resulting [Host], because they
+// describe the server's policy rather than the discovery document itself.
+var (
+	capturedHeaderPrefixes = []string{"X-Ratelimit-"}
+	capturedHeaders        = []string{"Sunset", "Deprecation", "Warning"}
+)
+
+// captureHeaders returns a copy of the subset of the given response headers
+// that a [Host] retains, or nil if there are none.
+func captureHeaders(header http.Header) http.Header {
+	var ret http.Header
+	for name, values := range header {
+		name = http.CanonicalHeaderKey(name)
+		hasPrefix := func(prefix string) bool { return strings.HasPrefix(name, prefix) }
+		if !slices.Contains(capturedHeaders, name) && !slices.ContainsFunc(capturedHeaderPrefixes, hasPrefix) {
+			continue
+		}
+		if ret == nil {
+			ret = make(http.Header)
+		}
+		ret[name] = append([]string(nil), values...)
+	}
+	return ret
+}
+
+// ResponseHeaders returns the headers describing rate limits and
+// deprecations that the server included in its discovery response, if any.
+//
+// Only the "X-RateLimit-*", "Sunset", "Deprecation", and "Warning" headers
+// are retained. Hosts loaded from a [DiscoCacheStore] have no response
+// headers. The caller may modify the result.
+func (h *Host) ResponseHeaders() http.Header {
+	if h == nil {
+		return nil
+	}
+	return h.responseHeader.Clone()
+}
+
+// RateLimit describes a rate limit that the server announced using the
+// conventional "X-RateLimit-Limit", "X-RateLimit-Remaining", and
+// "X-RateLimit-Reset" response headers.
+type RateLimit struct {
+	// Limit is the number of requests allowed in the current window,
+	// or -1 if the server didn't say.
+	Limit int
+
+	// Remaining is the number of requests remaining in the current window,
+	// or -1 if the server didn't say.
+	Remaining int
+
+	// Reset is the time when the current window ends, or the zero time if
+	// the server didn't say.
+	Reset time.Time
+}
+
+// RateLimit returns the rate limit that the server announced in its
+// discovery response, and false if it didn't announce one.
+//
+// The "X-RateLimit-Reset" header is interpreted as a Unix timestamp if it
+// is large enough to plausibly be one, and otherwise as a number of seconds
+// relative to when the response was generated.
+func (h *Host) RateLimit() (RateLimit, bool) {
+	if h == nil {
+		return RateLimit{}, false
+	}
+	header := h.responseHeader
+	ret := RateLimit{
+		Limit:     headerInt(header, "X-Ratelimit-Limit"),
+		Remaining: headerInt(header, "X-Ratelimit-Remaining"),
+	}
+	if reset := headerInt(header, "X-Ratelimit-Reset"); reset >= 0 {
+		// No rate limit window is a decade long, so values this large
+		// are timestamps.
+		if reset > 10*365*24*60*60 {
+			ret.Reset = time.Unix(int64(reset), 0)
+		} else {
+			ret.Reset = h.responseTime.Add(time.Duration(reset) * time.Second)
+		}
+	}
+	if ret.Limit < 0 && ret.Remaining < 0 && ret.Reset.IsZero() {
+		return RateLimit{}, false
+	}
+	return ret, true
+}
+
+// Deprecation describes a deprecation notice that the server included in
+// its discovery response.
+type Deprecation struct {
+	// Since is when the discovery endpoint was or will be deprecated, as
+	// given in the "Deprecation" header, or the zero time if the server
+	// didn't give a date.
+	Since time.Time
+
+	// Sunset is when the discovery endpoint will stop working, as given in
+	// the "Sunset" header, or the zero time if the server didn't give one.
+	Sunset time.Time
+
+	// Message is the text of a "Warning" header, intended for display to
+	// end users, or empty if the notice came from the "Deprecation" or
+	// "Sunset" headers.
+	Message string
+}
+
+// Deprecations returns the deprecation notices that the server included in
+// its discovery response, so that clients can show them to end users.
+//
+// The "Deprecation" and "Sunset" headers together produce at most one
+// notice, and each warning in the "Warning" headers produces another. The
+// result is empty if the response included none of those headers.
+func (h *Host) Deprecations() []Deprecation {
+	if h == nil {
+		return nil
+	}
+	var ret []Deprecation
+
+	deprecation := strings.TrimSpace(h.responseHeader.Get("Deprecation"))
+	sunset := strings.TrimSpace(h.responseHeader.Get("Sunset"))
+	if (deprecation != "" && deprecation != "false") || sunset != "" {
+		var notice Deprecation
+		if strings.HasPrefix(deprecation, "@") {
+			// RFC 9745 uses a structured field date, which is a Unix timestamp.
+			if ts, err := strconv.ParseInt(deprecation[1:], 10, 64); err == nil {
+				notice.Since = time.Unix(ts, 0)
+			}
+		} else if t, err := http.ParseTime(deprecation); err == nil {
+			// Earlier drafts used an HTTP date, or just "true".
+			notice.Since = t
+		}
+		if t, err := http.ParseTime(sunset); err == nil {
+			notice.Sunset = t
+		}
+		ret = append(ret, notice)
+	}
+
+	for _, value := range h.responseHeader.Values("Warning") {
+		if msg := warningText(value); msg != "" {
+			ret = append(ret, Deprecation{Message: msg})
+		}
+	}
+	return ret
+}
+
+// headerInt returns the value of the given header as a non-negative integer,
+// or -1 if it is absent or invalid.
+func headerInt(header http.Header, name string) int {
+	v, err := strconv.Atoi(strings.TrimSpace(header.Get(name)))
+	if err != nil || v < 0 {
+		return -1
+	}
+	return v
+}
+
+// warningText extracts the warn-text from a Warning header value of the
+// form described in RFC 7234 section 5.5, such as:
+//
+//	299 registry.example.com "This registry is deprecated"
+//
+// If the value doesn't include a quoted warn-text then the whole value is
+// returned as-is.
+func warningText(value string) string {
+	value = strings.TrimSpace(value)
+	start := strings.IndexByte(value, '"')
+	if start < 0 {
+		return value
+	}
+	var b strings.Builder
+	for i := start + 1; i < len(value); i++ {
+		switch c := value[i]; c {
+		case '\\':
+			if i+1 < len(value) {
+				i++
+				b.WriteByte(value[i])
+			}
+		case '"':
+			return b.String()
+		default:
+			b.WriteByte(c)
+		}
+	}
+	// Unterminated quoted string, so we'll just use what we found.
+	return b.String()
+}
@@ -0,0 +1,90 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package disco
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	svchost "github.com/opentofu/svchost"
+)
+
+func TestHostResponseHeaders(t *testing.T) {
+	responseTime := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	portStr, cleanup := testServer(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Date", responseTime.Format(http.TimeFormat))
+		w.Header().Set("X-RateLimit-Limit", "100")
+		w.Header().Set("X-RateLimit-Remaining", "42")
+		w.Header().Set("X-RateLimit-Reset", "30")
+		w.Header().Set("Deprecation", "@1748736000")
+		w.Header().Set("Sunset", "Wed, 31 Dec 2025 23:59:59 GMT")
+		w.Header().Add("Warning", `299 example.com "Please use \"new.example.com\" instead"`)
+		w.Header().Set("X-Unrelated", "ignored")
+		w.Write([]byte(`{}`))
+	})
+	defer cleanup()
+	host, err := svchost.ForComparison("localhost" + portStr)
+	if err != nil {
+		t.Fatalf("test server hostname is invalid: %s", err)
+	}
+
+	d := New(WithHTTPClient(testClient))
+	discovered, err := d.Discover(t.Context(), host)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if got := discovered.ResponseHeaders().Get("X-Unrelated"); got != "" {
+		t.Errorf("unrelated header was retained: %q", got)
+	}
+
+	gotLimit, ok := discovered.RateLimit()
+	if !ok {
+		t.Fatal("no rate limit; want one")
+	}
+	wantLimit := RateLimit{
+		Limit:     100,
+		Remaining: 42,
+		Reset:     responseTime.Add(30 * time.Second),
+	}
+	if diff := cmp.Diff(wantLimit, gotLimit); diff != "" {
+		t.Errorf("wrong rate limit\n%s", diff)
+	}
+
+	gotDeprecations := discovered.Deprecations()
+	wantDeprecations := []Deprecation{
+		{
+			Since:  time.Unix(1748736000, 0),
+			Sunset: time.Date(2025, 12, 31, 23, 59, 59, 0, time.UTC),
+		},
+		{
+			Message: `Please use "new.example.com" instead`,
+		},
+	}
+	if diff := cmp.Diff(wantDeprecations, gotDeprecations); diff != "" {
+		t.Errorf("wrong deprecations\n%s", diff)
+	}
+}
+
+func TestHostResponseHeadersNone(t *testing.T) {
+	var h *Host
+	if _, ok := h.RateLimit(); ok {
+		t.Error("nil host has a rate limit")
+	}
+	if got := h.Deprecations(); len(got) != 0 {
+		t.Errorf("nil host has deprecations: %#v", got)
+	}
+
+	h = &Host{responseHeader: http.Header{"Deprecation": {"true"}}}
+	if _, ok := h.RateLimit(); ok {
+		t.Error("host without rate limit headers has a rate limit")
+	}
+	if got, want := h.Deprecations(), []Deprecation{{}}; !cmp.Equal(got, want) {
+		t.Errorf("wrong deprecations %#v; want %#v", got, want)
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package disco
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy describes how discovery requests are retried after a failure
+// that might be transient, for use with [WithRetryPolicy].
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of requests to make for each
+	// discovery, including the first. Values less than two disable retries.
+	MaxAttempts int
+
+	// MinBackoff is the delay before the first retry, which doubles for
+	// each subsequent retry up to MaxBackoff.
+	//
+	// If a failed response includes a Retry-After header then its delay is
+	// used instead, but still limited to MaxBackoff.
+	MinBackoff, MaxBackoff time.Duration
+
+	// RetryableStatusCodes are the HTTP response status codes that cause a
+	// retry. If nil, [DefaultRetryableStatusCodes] is used.
+	//
+	// Network errors are retried only if they are likely to be transient:
+	// timeouts, and connections that were refused or reset. Other errors,
+	// such as TLS certificate errors, redirects rejected by policy, or
+	// cancellation of the caller's context, are never retried.
+	RetryableStatusCodes []int
+}
+
+// DefaultRetryableStatusCodes are the response status codes that are
+// retried when [RetryPolicy.RetryableStatusCodes] is nil.
+var DefaultRetryableStatusCodes = []int{
+	http.StatusTooManyRequests,
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// retryable returns true if a request that produced the given response or
+// error should be retried under the receiving policy.
+func (p *RetryPolicy) retryable(ctx context.Context, resp *http.Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if err != nil {
+		return transientError(err)
+	}
+	codes := p.RetryableStatusCodes
+	if codes == nil {
+		codes = DefaultRetryableStatusCodes
+	}
+	return slices.Contains(codes, resp.StatusCode)
+}
+
+// transientError returns true if the given error from sending a request is
+// one that might not happen again if the request is retried.
+func transientError(err error) bool {
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+	// This includes the errors returned when the client's own timeout
+	// expires, but errors that are really about the caller's context were
+	// already excluded by our caller.
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// backoff returns how long to wait before the given retry, counting from
+// one, after a failed request that produced the given response, which may
+// be nil, where now is the current time.
+func (p *RetryPolicy) backoff(retry int, resp *http.Response, now time.Time) time.Duration {
+	delay := p.MinBackoff
+	for i := 1; i < retry && delay < p.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if resp != nil {
+		if after, ok := parseRetryAfter(resp.Header.Get("Retry-After"), now); ok {
+			delay = after
+		}
+	}
+	return min(delay, p.MaxBackoff)
+}
+
+// parseRetryAfter interprets the value of a Retry-After header, which may be
+// either a number of seconds or an HTTP date, relative to the given time.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(at.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+// doWithRetry sends the given request using the given client, retrying
+// according to the receiver's retry policy, if any.
+//
+// The request must not have a body, so that it can be sent multiple times.
+func (d *Disco) doWithRetry(client *http.Client, req *http.Request) (*http.Response, error) {
+	policy := d.retryPolicy
+	for attempt := 1; ; attempt++ {
+		resp, err := client.Do(req)
+		if policy == nil || attempt >= policy.MaxAttempts || !policy.retryable(req.Context(), resp, err) {
+			return resp, err
+		}
+
+		delay := policy.backoff(attempt, resp, d.clock.Now())
+		if resp != nil {
+			// We must drain and close the body of a response we're
+			// discarding so that its connection can be reused.
+			io.Copy(io.Discard, io.LimitReader(resp.Body, maxDiscoDocBytes)) //nolint:errcheck
+			resp.Body.Close()
+		}
+
+		select {
+		case <-d.clock.After(delay):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+}
@@ -0,0 +1,211 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package disco
+
+import (
+	"context"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	svchost "github.com/opentofu/svchost"
+)
+
+func TestWithRetryPolicy(t *testing.T) {
+	requests := 0
+	failures := 0
+	portStr, cleanup := testServer(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests <= failures {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Add("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	})
+	defer cleanup()
+
+	host, err := svchost.ForComparison("localhost" + portStr)
+	if err != nil {
+		t.Fatalf("test server hostname is invalid: %s", err)
+	}
+	policy := RetryPolicy{
+		MaxAttempts: 3,
+		MinBackoff:  time.Millisecond,
+		MaxBackoff:  10 * time.Millisecond,
+	}
+
+	t.Run("recovers", func(t *testing.T) {
+		requests, failures = 0, 2
+		d, err := NewWithErrors(WithHTTPClient(testClient), WithRetryPolicy(policy))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if _, err := d.Discover(t.Context(), host); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if requests != 3 {
+			t.Errorf("made %d requests; want 3", requests)
+		}
+	})
+	t.Run("gives up", func(t *testing.T) {
+		requests, failures = 0, 5
+		d, err := NewWithErrors(WithHTTPClient(testClient), WithRetryPolicy(policy))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if _, err := d.Discover(t.Context(), host); err == nil {
+			t.Fatal("unexpected success; want error")
+		}
+		if requests != 3 {
+			t.Errorf("made %d requests; want 3", requests)
+		}
+	})
+	t.Run("non-retryable status", func(t *testing.T) {
+		requests, failures = 0, 5
+		d, err := NewWithErrors(WithHTTPClient(testClient), WithRetryPolicy(RetryPolicy{
+			MaxAttempts:          3,
+			RetryableStatusCodes: []int{http.StatusBadGateway},
+		}))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if _, err := d.Discover(t.Context(), host); err == nil {
+			t.Fatal("unexpected success; want error")
+		}
+		if requests != 1 {
+			t.Errorf("made %d requests; want 1", requests)
+		}
+	})
+	t.Run("invalid", func(t *testing.T) {
+		_, err := NewWithErrors(WithRetryPolicy(RetryPolicy{MinBackoff: time.Second}))
+		if err == nil {
+			t.Error("unexpected success with MaxBackoff less than MinBackoff; want error")
+		}
+	})
+}
+
+func TestWithRetryPolicy_certificateError(t *testing.T) {
+	var conns atomic.Int64
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request to %s", r.URL)
+	}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	host, err := svchost.ForComparison(strings.TrimPrefix(server.URL, "https://"))
+	if err != nil {
+		t.Fatalf("test server hostname is invalid: %s", err)
+	}
+	// This client doesn't trust the server's certificate, which won't
+	// change however many times we retry.
+	d, err := NewWithErrors(WithHTTPClient(&http.Client{}), WithRetryPolicy(RetryPolicy{MaxAttempts: 3}))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := d.Discover(t.Context(), host); err == nil {
+		t.Fatal("unexpected success; want certificate error")
+	}
+	if got := conns.Load(); got != 1 {
+		t.Errorf("made %d connections; want 1", got)
+	}
+}
+
+func TestTransientError(t *testing.T) {
+	tests := map[string]struct {
+		err  error
+		want bool
+	}{
+		"timeout": {
+			&url.Error{Op: "Get", URL: "https://example.com/", Err: &net.OpError{Op: "dial", Err: os.ErrDeadlineExceeded}},
+			true,
+		},
+		"connection refused": {
+			&url.Error{Op: "Get", URL: "https://example.com/", Err: &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}},
+			true,
+		},
+		"connection reset": {
+			&url.Error{Op: "Get", URL: "https://example.com/", Err: &net.OpError{Op: "read", Err: syscall.ECONNRESET}},
+			true,
+		},
+		"certificate": {
+			&url.Error{Op: "Get", URL: "https://example.com/", Err: x509.UnknownAuthorityError{}},
+			false,
+		},
+		"redirect not allowed": {
+			&url.Error{Op: "Get", URL: "https://example.com/", Err: ErrRedirectNotAllowed},
+			false,
+		},
+		"private address": {
+			&url.Error{Op: "Get", URL: "https://example.com/", Err: &net.OpError{Op: "dial", Err: ErrPrivateAddress}},
+			false,
+		},
+		"cancelled": {
+			&url.Error{Op: "Get", URL: "https://example.com/", Err: context.Canceled},
+			false,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := transientError(test.err); got != test.want {
+				t.Errorf("wrong result %t for %v; want %t", got, test.err, test.want)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{
+		MinBackoff: time.Second,
+		MaxBackoff: 5 * time.Second,
+	}
+	for retry, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 5 * time.Second} {
+		if got := policy.backoff(retry, nil, time.Now()); got != want {
+			t.Errorf("wrong backoff for retry %d: %s; want %s", retry, got, want)
+		}
+	}
+
+	resp := &http.Response{Header: http.Header{"Retry-After": {"3"}}}
+	if got, want := policy.backoff(1, resp, time.Now()), 3*time.Second; got != want {
+		t.Errorf("wrong backoff with Retry-After: %s; want %s", got, want)
+	}
+	resp.Header.Set("Retry-After", "3600")
+	if got, want := policy.backoff(1, resp, time.Now()), 5*time.Second; got != want {
+		t.Errorf("wrong backoff with long Retry-After: %s; want %s", got, want)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := map[string]time.Duration{
+		"120":                           2 * time.Minute,
+		"Thu, 01 Jan 2026 00:00:30 GMT": 30 * time.Second,
+		"Wed, 31 Dec 2025 00:00:00 GMT": 0,
+	}
+	for value, want := range tests {
+		got, ok := parseRetryAfter(value, now)
+		if !ok || got != want {
+			t.Errorf("wrong result for %q: %s, %t; want %s", value, got, ok, want)
+		}
+	}
+	for _, value := range []string{"", "-1", "soon"} {
+		if _, ok := parseRetryAfter(value, now); ok {
+			t.Errorf("unexpected success for %q", value)
+		}
+	}
+}
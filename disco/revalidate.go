@@ -0,0 +1,50 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package disco
+
+import (
+	"net/http"
+)
+
+// setConditionalHeaders makes the given discovery request conditional on
+// the document having changed since the given stale result was obtained,
+// returning true if it did so.
+//
+// The request is made conditional only if the stale result has validators
+// and was obtained from the same URL without following redirects, because
+// validators only apply to the URL that returned them.
+func setConditionalHeaders(req *http.Request, stale *Host) bool {
+	if stale == nil || stale.discoURL == nil || stale.discoURL.String() != req.URL.String() {
+		return false
+	}
+	if stale.etag == "" && stale.lastModified == "" {
+		return false
+	}
+	if stale.etag != "" {
+		req.Header.Set("If-None-Match", stale.etag)
+	}
+	if stale.lastModified != "" {
+		req.Header.Set("If-Modified-Since", stale.lastModified)
+	}
+	return true
+}
+
+// revalidated returns a copy of the given stale result updated with the
+// details of the "304 Not Modified" response described by fresh.
+func revalidated(stale, fresh *Host) *Host {
+	ret := *stale
+	ret.responseHeader = fresh.responseHeader
+	ret.responseTime = fresh.responseTime
+	ret.fetchedAt = fresh.fetchedAt
+	ret.storeTTL = fresh.storeTTL
+	ret.discoveryInfo = fresh.discoveryInfo
+	// A 304 response may include updated validators.
+	if fresh.etag != "" {
+		ret.etag = fresh.etag
+	}
+	if fresh.lastModified != "" {
+		ret.lastModified = fresh.lastModified
+	}
+	return &ret
+}
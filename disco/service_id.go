@@ -0,0 +1,145 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package disco
+
+import (
+	"cmp"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ServiceID is a parsed service identifier, such as "modules.v1", which may
+// also include a minor version, as in "modules.v1.2".
+//
+// Minor versions allow a host to advertise incremental revisions of a
+// protocol that remain compatible with earlier revisions of the same major
+// version. Use [Host.ServiceURLCompatible] to select the newest revision
+// that a client is able to use.
+type ServiceID struct {
+	// Name is the name of the service, such as "modules".
+	Name string
+
+	// Major and Minor are the version of the service. Minor is zero for
+	// identifiers that have only a major version.
+	Major, Minor uint64
+}
+
+// ParseServiceID parses a service identifier of either the form
+// "servicename.vN" or "servicename.vN.M".
+//
+// This is more liberal than the parsing used by [Host.ServiceURL], which
+// accepts minor versions only for the legacy "tfe" service.
+func ParseServiceID(id string) (ServiceID, error) {
+	name, version, ok := strings.Cut(id, ".")
+	if !ok {
+		return ServiceID{}, fmt.Errorf("invalid service ID format (i.e. service.vN or service.vN.M): %s", id)
+	}
+
+	const errMsg = "invalid service version: must be \"v\" followed by an integer major version number and optionally a dot and an integer minor version number"
+	version, ok = strings.CutPrefix(version, "v")
+	if !ok {
+		return ServiceID{}, fmt.Errorf("%s: %s", errMsg, id)
+	}
+	majorStr, minorStr, hasMinor := strings.Cut(version, ".")
+	major, err := strconv.ParseUint(majorStr, 10, 64)
+	if err != nil {
+		return ServiceID{}, fmt.Errorf("%s: %s", errMsg, id)
+	}
+	var minor uint64
+	if hasMinor {
+		minor, err = strconv.ParseUint(minorStr, 10, 64)
+		if err != nil {
+			return ServiceID{}, fmt.Errorf("%s: %s", errMsg, id)
+		}
+	}
+
+	return ServiceID{Name: name, Major: major, Minor: minor}, nil
+}
+
+// String returns the identifier in the form used in discovery documents,
+// omitting the minor version if it is zero.
+func (id ServiceID) String() string {
+	if id.Minor == 0 {
+		return fmt.Sprintf("%s.v%d", id.Name, id.Major)
+	}
+	return fmt.Sprintf("%s.v%d.%d", id.Name, id.Major, id.Minor)
+}
+
+// Compare returns -1, 0, or +1 depending on whether the receiver sorts
+// before, the same as, or after the other identifier, ordering first by
+// name and then by major and minor version.
+func (id ServiceID) Compare(other ServiceID) int {
+	return cmp.Or(
+		cmp.Compare(id.Name, other.Name),
+		cmp.Compare(id.Major, other.Major),
+		cmp.Compare(id.Minor, other.Minor),
+	)
+}
+
+// CompatibleWith returns true if a client that requires the given service
+// version can use the receiver, which is the case if both have the same
+// name and major version and the receiver's minor version is at least that
+// of want.
+func (id ServiceID) CompatibleWith(want ServiceID) bool {
+	return id.Name == want.Name && id.Major == want.Major && id.Minor >= want.Minor
+}
+
+// ServiceURLCompatible returns the URL of the newest revision of the given
+// service that the host declares and that is compatible with want, as
+// decided by [ServiceID.CompatibleWith], along with the identifier of the
+// selected revision.
+//
+// If the host provides the service but no compatible revision then the
+// error is [ErrVersionNotSupported].
+func (h *Host) ServiceURLCompatible(want ServiceID) (*url.URL, ServiceID, error) {
+	// No services supported for an empty Host.
+	if h == nil || h.services == nil {
+		return nil, ServiceID{}, &ErrServiceNotProvided{service: want.Name}
+	}
+
+	var best ServiceID
+	var bestID string
+	provided := false
+	for rawID := range h.services {
+		id, err := ParseServiceID(rawID)
+		if err != nil || id.Name != want.Name {
+			continue
+		}
+		provided = true
+		if !id.CompatibleWith(want) {
+			continue
+		}
+		// If the same revision is declared more than once, such as both
+		// "foo.v1" and "foo.v1.0", we use the lexically-lowest so that the
+		// result is deterministic.
+		if c := id.Compare(best); bestID == "" || c > 0 || (c == 0 && rawID < bestID) {
+			best, bestID = id, rawID
+		}
+	}
+	if bestID == "" {
+		if provided {
+			return nil, ServiceID{}, &ErrVersionNotSupported{
+				hostname: h.hostname,
+				service:  want.Name,
+				version:  want.Major,
+			}
+		}
+		return nil, ServiceID{}, &ErrServiceNotProvided{hostname: h.hostname, service: want.Name}
+	}
+
+	urlStr, ok := h.services[bestID].(string)
+	if !ok {
+		return nil, ServiceID{}, fmt.Errorf("service %s must be declared with a URL string", bestID)
+	}
+	u, err := h.parseURL(urlStr)
+	if err != nil {
+		return nil, ServiceID{}, fmt.Errorf("failed to parse service URL: %v", err)
+	}
+	if err := h.checkServiceURL(bestID, u); err != nil {
+		return nil, ServiceID{}, err
+	}
+	return u, best, nil
+}
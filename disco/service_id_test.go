@@ -0,0 +1,132 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package disco
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+)
+
+func TestParseServiceID(t *testing.T) {
+	tests := []struct {
+		id      string
+		want    ServiceID
+		wantStr string
+		wantErr bool
+	}{
+		{"modules.v1", ServiceID{Name: "modules", Major: 1}, "modules.v1", false},
+		{"modules.v1.2", ServiceID{Name: "modules", Major: 1, Minor: 2}, "modules.v1.2", false},
+		{"modules.v1.0", ServiceID{Name: "modules", Major: 1}, "modules.v1", false},
+		{"tfe.v2.1", ServiceID{Name: "tfe", Major: 2, Minor: 1}, "tfe.v2.1", false},
+		{"modules", ServiceID{}, "", true},
+		{"modules.1", ServiceID{}, "", true},
+		{"modules.v", ServiceID{}, "", true},
+		{"modules.v1.", ServiceID{}, "", true},
+		{"modules.v1.x", ServiceID{}, "", true},
+		{"modules.v1.2.3", ServiceID{}, "", true},
+	}
+	for _, test := range tests {
+		t.Run(test.id, func(t *testing.T) {
+			got, err := ParseServiceID(test.id)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("unexpected success %#v; want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got != test.want {
+				t.Errorf("wrong result %#v; want %#v", got, test.want)
+			}
+			if got := got.String(); got != test.wantStr {
+				t.Errorf("wrong string %q; want %q", got, test.wantStr)
+			}
+		})
+	}
+}
+
+func TestServiceIDCompare(t *testing.T) {
+	tests := []struct {
+		a, b ServiceID
+		want int
+	}{
+		{ServiceID{"a", 1, 0}, ServiceID{"a", 1, 0}, 0},
+		{ServiceID{"a", 1, 0}, ServiceID{"a", 1, 1}, -1},
+		{ServiceID{"a", 2, 0}, ServiceID{"a", 1, 9}, 1},
+		{ServiceID{"a", 9, 9}, ServiceID{"b", 1, 0}, -1},
+	}
+	for _, test := range tests {
+		if got := test.a.Compare(test.b); got != test.want {
+			t.Errorf("%s.Compare(%s) = %d; want %d", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestHostServiceURLCompatible(t *testing.T) {
+	baseURL, _ := url.Parse("https://example.com/disco/")
+	host := Host{
+		discoURL: baseURL,
+		hostname: "test-server",
+		services: map[string]any{
+			"modules.v1":   "/modules/v1/",
+			"modules.v1.1": "/modules/v1.1/",
+			"modules.v1.3": "/modules/v1.3/",
+			"modules.v2":   "/modules/v2/",
+			"other.v1.0":   "/other/v1.0/",
+			"other.v1":     "/other/v1/",
+			"broken.v1.1":  map[string]any{},
+		},
+	}
+
+	tests := []struct {
+		want    ServiceID
+		wantURL string
+		wantID  ServiceID
+		wantErr error
+	}{
+		{ServiceID{"modules", 1, 0}, "https://example.com/modules/v1.3/", ServiceID{"modules", 1, 3}, nil},
+		{ServiceID{"modules", 1, 2}, "https://example.com/modules/v1.3/", ServiceID{"modules", 1, 3}, nil},
+		{ServiceID{"modules", 2, 0}, "https://example.com/modules/v2/", ServiceID{"modules", 2, 0}, nil},
+		{ServiceID{"other", 1, 0}, "https://example.com/other/v1/", ServiceID{"other", 1, 0}, nil},
+		{ServiceID{"modules", 1, 4}, "", ServiceID{}, &ErrVersionNotSupported{}},
+		{ServiceID{"modules", 3, 0}, "", ServiceID{}, &ErrVersionNotSupported{}},
+		{ServiceID{"missing", 1, 0}, "", ServiceID{}, &ErrServiceNotProvided{}},
+	}
+	for _, test := range tests {
+		t.Run(test.want.String(), func(t *testing.T) {
+			gotURL, gotID, err := host.ServiceURLCompatible(test.want)
+			if test.wantErr != nil {
+				switch test.wantErr.(type) {
+				case *ErrVersionNotSupported:
+					var target *ErrVersionNotSupported
+					if !errors.As(err, &target) {
+						t.Errorf("wrong error %v; want ErrVersionNotSupported", err)
+					}
+				case *ErrServiceNotProvided:
+					var target *ErrServiceNotProvided
+					if !errors.As(err, &target) {
+						t.Errorf("wrong error %v; want ErrServiceNotProvided", err)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got := gotURL.String(); got != test.wantURL {
+				t.Errorf("wrong URL %q; want %q", got, test.wantURL)
+			}
+			if gotID != test.wantID {
+				t.Errorf("wrong ID %s; want %s", gotID, test.wantID)
+			}
+		})
+	}
+
+	if _, _, err := host.ServiceURLCompatible(ServiceID{"broken", 1, 0}); err == nil {
+		t.Error("unexpected success for non-URL service; want error")
+	}
+}
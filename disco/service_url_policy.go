@@ -0,0 +1,111 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package disco
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+
+	svchost "github.com/opentofu/svchost"
+)
+
+// ServiceURLPolicy restricts the URLs that a discovery document may declare
+// for its services, for use with [WithServiceURLPolicy]. This protects
+// against a discovery document that points services at a third-party host
+// in order to capture the credentials that clients send to them.
+//
+// The zero value allows any URL, which is the default behavior.
+type ServiceURLPolicy struct {
+	// SameHostOnly allows only service URLs with the same hostname and port
+	// as the discovered host, or one of AllowedHosts.
+	SameHostOnly bool
+
+	// SameSiteOnly allows only service URLs whose hostnames are in the same
+	// registrable domain as the discovered host, such as "api.example.com"
+	// for "registry.example.com", or one of AllowedHosts.
+	SameSiteOnly bool
+
+	// AllowedHosts are hostnames that service URLs may always use, such as
+	// a separate host that serves downloads or an OAuth identity provider.
+	// Each must match exactly, including its port number.
+	AllowedHosts []svchost.Hostname
+
+	// HTTPSOnly allows only HTTPS service URLs, even for hosts that are
+	// discovered using plain HTTP because of [WithInsecureHosts].
+	HTTPSOnly bool
+}
+
+// ErrServiceURLNotAllowed is returned by the methods of [Host] that return
+// service URLs when the URL declared for a service is not allowed by the
+// [ServiceURLPolicy] given in [WithServiceURLPolicy].
+type ErrServiceURLNotAllowed struct {
+	// ServiceID is the identifier of the service, such as "modules.v1".
+	ServiceID string
+
+	// URL is the URL that the discovery document declared for the service.
+	URL *url.URL
+
+	// Reason describes why the URL is not allowed.
+	Reason string
+}
+
+func (e *ErrServiceURLNotAllowed) Error() string {
+	return fmt.Sprintf("URL %s for service %s is not allowed: %s", e.URL.Redacted(), e.ServiceID, e.Reason)
+}
+
+// check returns a description of why the policy doesn't allow the given
+// service URL for a service of the given hostname, or an empty string if
+// the URL is allowed.
+func (p *ServiceURLPolicy) check(hostname svchost.Hostname, u *url.URL) string {
+	if p.HTTPSOnly && u.Scheme != "https" {
+		return fmt.Sprintf("uses %s instead of https", u.Scheme)
+	}
+	if !p.SameHostOnly && !p.SameSiteOnly {
+		return ""
+	}
+	target, err := svchost.ForComparisonAllowIP(u.Host)
+	if err != nil {
+		return fmt.Sprintf("invalid hostname: %s", err)
+	}
+	if target == hostname || slices.Contains(p.AllowedHosts, target) {
+		return ""
+	}
+	if p.SameHostOnly {
+		return fmt.Sprintf("host %s is not %s", target.ForDisplay(), hostname.ForDisplay())
+	}
+	if !sameSite(hostname, target) {
+		return fmt.Sprintf("host %s is not in the same domain as %s", target.ForDisplay(), hostname.ForDisplay())
+	}
+	return ""
+}
+
+// serviceURLPolicyFor returns the function for [Host] objects for the given
+// hostname to call to check their service URLs, or nil if
+// WithServiceURLPolicy wasn't used.
+func (d *Disco) serviceURLPolicyFor(hostname svchost.Hostname) func(serviceID string, u *url.URL) error {
+	if d.serviceURLPolicy == nil {
+		return nil
+	}
+	policy := d.serviceURLPolicy
+	return func(serviceID string, u *url.URL) error {
+		if reason := policy.check(hostname, u); reason != "" {
+			return &ErrServiceURLNotAllowed{
+				ServiceID: serviceID,
+				URL:       u,
+				Reason:    reason,
+			}
+		}
+		return nil
+	}
+}
+
+// checkServiceURL returns an error if the given URL for the service with
+// the given ID is not allowed by the policy for the receiver.
+func (h *Host) checkServiceURL(serviceID string, u *url.URL) error {
+	if h.serviceURLPolicy == nil {
+		return nil
+	}
+	return h.serviceURLPolicy(serviceID, u)
+}
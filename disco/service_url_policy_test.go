@@ -0,0 +1,125 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package disco
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"testing"
+
+	"github.com/opentofu/svchost"
+)
+
+func TestWithServiceURLPolicy(t *testing.T) {
+	portStr, cleanup := testServer(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+		w.Write([]byte(`{
+			"relative.v1": "/v1/relative/",
+			"insecure.v1": "http://` + r.Host + `/v1/insecure/",
+			"downloads.v1": "https://downloads.example.com/v1/",
+			"evil.v1": "https://evil.example.net/v1/",
+			"login.v1": {
+				"client": "test",
+				"authz": "/authz",
+				"token": "https://evil.example.net/token"
+			}
+		}`))
+	})
+	defer cleanup()
+	hostname := svchost.Hostname("localhost" + portStr)
+
+	tests := map[string]struct {
+		policy     ServiceURLPolicy
+		notAllowed []string
+	}{
+		"zero value": {},
+		"same host": {
+			policy:     ServiceURLPolicy{SameHostOnly: true},
+			notAllowed: []string{"downloads.v1", "evil.v1", "login.v1"},
+		},
+		"same host with allowed hosts": {
+			policy: ServiceURLPolicy{
+				SameHostOnly: true,
+				AllowedHosts: []svchost.Hostname{"downloads.example.com"},
+			},
+			notAllowed: []string{"evil.v1", "login.v1"},
+		},
+		"https only": {
+			policy:     ServiceURLPolicy{HTTPSOnly: true},
+			notAllowed: []string{"insecure.v1"},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			d, err := NewWithErrors(WithHTTPClient(testClient), WithServiceURLPolicy(test.policy))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			host, err := d.Discover(t.Context(), hostname)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			for _, id := range host.ServiceIDs() {
+				if id == "login.v1" {
+					_, err = host.ServiceOAuthClient(id)
+				} else {
+					_, err = host.ServiceURL(id)
+				}
+				var policyErr *ErrServiceURLNotAllowed
+				wantErr := slices.Contains(test.notAllowed, id)
+				switch {
+				case wantErr && !errors.As(err, &policyErr):
+					t.Errorf("wrong error for %s %v; want ErrServiceURLNotAllowed", id, err)
+				case wantErr && policyErr.ServiceID != id:
+					t.Errorf("wrong service ID in error %q; want %q", policyErr.ServiceID, id)
+				case !wantErr && err != nil:
+					t.Errorf("unexpected error for %s: %s", id, err)
+				}
+			}
+		})
+	}
+
+	t.Run("same site", func(t *testing.T) {
+		d, err := NewWithErrors(WithServiceURLPolicy(ServiceURLPolicy{SameSiteOnly: true}))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		discoURL, _ := url.Parse("https://registry.example.com/.well-known/terraform.json")
+		tests := map[string]bool{
+			"/v1/":                                 true,
+			"https://registry.example.com:8443/v1": true,
+			"https://downloads.example.com/v1/":    true,
+			"https://evil.example.net/v1/":         false,
+			"https://192.0.2.1/v1/":                false,
+		}
+		for serviceURL, want := range tests {
+			host := &Host{
+				discoURL:         discoURL,
+				services:         map[string]any{"modules.v1": serviceURL},
+				serviceURLPolicy: d.serviceURLPolicyFor("registry.example.com"),
+			}
+			_, err := host.ServiceURL("modules.v1")
+			if got := err == nil; got != want {
+				t.Errorf("wrong result for %s: got error %v; want allowed %t", serviceURL, err, want)
+			}
+		}
+	})
+
+	t.Run("forced services are not checked", func(t *testing.T) {
+		d, err := NewWithErrors(WithServiceURLPolicy(ServiceURLPolicy{SameHostOnly: true}))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		d.ForceHostServices(hostname, map[string]any{"evil.v1": "https://evil.example.net/v1/"})
+		host, err := d.Discover(t.Context(), hostname)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if _, err := host.ServiceURL("evil.v1"); err != nil {
+			t.Errorf("unexpected error: %s", err)
+		}
+	})
+}
@@ -0,0 +1,118 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package disco
+
+import (
+	"encoding/json"
+	"fmt"
+
+	svchost "github.com/opentofu/svchost"
+)
+
+// ServicesDoc is the content of a discovery document, mapping each service
+// ID to its definition.
+//
+// This is intended for server-side implementations of the discovery protocol,
+// such as private registries, that need to generate a discovery document: use
+// [ServicesDoc.AddService] and [ServicesDoc.AddOAuthService] to build the
+// document, [ServicesDoc.Validate] to check it, and [encoding/json] to
+// serialize it. [ParseServicesDoc] parses a document in the same way as
+// [Disco.Discover].
+type ServicesDoc map[string]any
+
+// ParseServicesDoc parses the given JSON discovery document.
+func ParseServicesDoc(src []byte) (ServicesDoc, error) {
+	var ret ServicesDoc
+	if err := json.Unmarshal(src, &ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// AddService adds a service whose definition is a URL, which may be relative
+// to the discovery document's own URL.
+//
+// It returns an error if the service ID is invalid or already present.
+func (d ServicesDoc) AddService(id string, serviceURL string) error {
+	if err := d.checkNewID(id); err != nil {
+		return err
+	}
+	d[id] = serviceURL
+	return nil
+}
+
+// AddOAuthService adds a service whose definition is an OAuth client
+// configuration, such as the "login.v1" service, in the form that
+// [Host.ServiceOAuthClient] expects.
+//
+// Any zero-valued fields of the client are omitted so that clients use
+// their default values. It returns an error if the service ID is invalid or
+// already present.
+func (d ServicesDoc) AddOAuthService(id string, client *OAuthClient) error {
+	if err := d.checkNewID(id); err != nil {
+		return err
+	}
+	if client == nil {
+		return fmt.Errorf("service %s must have an OAuth client configuration", id)
+	}
+
+	raw := map[string]any{
+		"client": client.ID,
+	}
+	if client.AuthorizationURL != nil {
+		raw["authz"] = client.AuthorizationURL.String()
+	}
+	if client.TokenURL != nil {
+		raw["token"] = client.TokenURL.String()
+	}
+	if client.DeviceAuthorizationURL != nil {
+		raw["device_authz"] = client.DeviceAuthorizationURL.String()
+	}
+	if len(client.SupportedGrantTypes) != 0 {
+		grantTypes := client.SupportedGrantTypes.List()
+		rawGrantTypes := make([]any, len(grantTypes))
+		for i, gt := range grantTypes {
+			rawGrantTypes[i] = gt
+		}
+		raw["grant_types"] = rawGrantTypes
+	}
+	if client.MinPort != 0 || client.MaxPort != 0 {
+		raw["ports"] = []any{int(client.MinPort), int(client.MaxPort)}
+	}
+	if len(client.Scopes) != 0 {
+		scopes := make([]any, len(client.Scopes))
+		for i, scope := range client.Scopes {
+			scopes[i] = scope
+		}
+		raw["scopes"] = scopes
+	}
+	if client.Secret != "" {
+		raw["client_secret"] = client.Secret
+	}
+	if len(client.TokenEndpointAuthMethods) != 0 {
+		methods := make([]any, len(client.TokenEndpointAuthMethods))
+		for i, method := range client.TokenEndpointAuthMethods {
+			methods[i] = string(method)
+		}
+		raw["token_endpoint_auth_methods_supported"] = methods
+	}
+	d[id] = raw
+	return nil
+}
+
+// Validate checks the document for problems that would prevent a client from
+// using the services it describes, in the same way as [ValidateServices].
+func (d ServicesDoc) Validate(hostname svchost.Hostname) svchost.Diagnostics {
+	return ValidateServices(hostname, d)
+}
+
+func (d ServicesDoc) checkNewID(id string) error {
+	if _, err := ParseServiceID(id); err != nil {
+		return err
+	}
+	if _, exists := d[id]; exists {
+		return fmt.Errorf("service %s is already defined", id)
+	}
+	return nil
+}
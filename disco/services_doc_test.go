@@ -0,0 +1,94 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package disco
+
+import (
+	"encoding/json"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	svchost "github.com/opentofu/svchost"
+)
+
+func TestServicesDoc(t *testing.T) {
+	doc := ServicesDoc{}
+	if err := doc.AddService("modules.v1", "/api/modules/v1/"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := doc.AddService("providers.v1", "https://example.net/providers/"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	authz, _ := url.Parse("https://example.com/oauth/authorize")
+	token, _ := url.Parse("/oauth/token")
+	err := doc.AddOAuthService("login.v1", &OAuthClient{
+		ID:                  "tofu-cli",
+		AuthorizationURL:    authz,
+		TokenURL:            token,
+		MinPort:             10000,
+		MaxPort:             10010,
+		SupportedGrantTypes: NewOAuthGrantTypeSet("password", "authz_code"),
+		Scopes:              []string{"openid"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if err := doc.AddService("modules.v1", "/elsewhere/"); err == nil {
+		t.Error("unexpected success adding duplicate service")
+	}
+	if err := doc.AddService("modules", "/elsewhere/"); err == nil {
+		t.Error("unexpected success adding service with invalid ID")
+	}
+	if err := doc.AddOAuthService("login.v2", nil); err == nil {
+		t.Error("unexpected success adding OAuth service without client")
+	}
+
+	hostname := svchost.Hostname("example.com")
+	if diags := doc.Validate(hostname); len(diags) != 0 {
+		t.Errorf("unexpected diagnostics: %s", diags.Err())
+	}
+
+	src, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("failed to marshal: %s", err)
+	}
+	const want = `{"login.v1":{"authz":"https://example.com/oauth/authorize","client":"tofu-cli","grant_types":["authz_code","password"],"ports":[10000,10010],"scopes":["openid"],"token":"/oauth/token"},"modules.v1":"/api/modules/v1/","providers.v1":"https://example.net/providers/"}`
+	if got := string(src); got != want {
+		t.Errorf("wrong JSON\ngot:  %s\nwant: %s", got, want)
+	}
+
+	// The parsed document must describe the same services as the original
+	// when interpreted by a client.
+	parsed, err := ParseServicesDoc(src)
+	if err != nil {
+		t.Fatalf("failed to parse: %s", err)
+	}
+	if diags := parsed.Validate(hostname); len(diags) != 0 {
+		t.Errorf("unexpected diagnostics after round-trip: %s", diags.Err())
+	}
+	host := &Host{
+		discoURL: &url.URL{Scheme: "https", Host: "example.com", Path: discoPath},
+		hostname: "example.com",
+		services: parsed,
+	}
+	client, err := host.ServiceOAuthClient("login.v1")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got, want := client.TokenURL.String(), "https://example.com/oauth/token"; got != want {
+		t.Errorf("wrong token URL %q; want %q", got, want)
+	}
+	if client.MinPort != 10000 || client.MaxPort != 10010 {
+		t.Errorf("wrong ports %d-%d; want 10000-10010", client.MinPort, client.MaxPort)
+	}
+	if diff := cmp.Diff(NewOAuthGrantTypeSet("authz_code", "password"), client.SupportedGrantTypes); diff != "" {
+		t.Errorf("wrong grant types\n%s", diff)
+	}
+
+	if _, err := ParseServicesDoc([]byte(`[]`)); err == nil {
+		t.Error("unexpected success parsing non-object document")
+	}
+}
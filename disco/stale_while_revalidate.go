@@ -0,0 +1,63 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package disco
+
+import (
+	"context"
+
+	"github.com/opentofu/svchost"
+)
+
+// revalidation is a background refresh in progress because of
+// [WithStaleWhileRevalidate].
+type revalidation struct {
+	// forgotten is set if the hostname is forgotten while the refresh is in
+	// progress, in which case its result must not be cached. It is guarded
+	// by the mutex of the Disco that started the refresh.
+	forgotten bool
+}
+
+// startRevalidationLocked returns a new revalidation if the given cached
+// result for the given hostname is old enough to be refreshed in the
+// background because of [WithStaleWhileRevalidate], in which case it records
+// that a refresh is in progress and the caller must start it using
+// revalidateInBackground. Otherwise it returns nil.
+//
+// The caller must hold d.mu.
+func (d *Disco) startRevalidationLocked(hostname svchost.Hostname, host *Host) *revalidation {
+	if d.staleAfter <= 0 || host.fetchedAt.IsZero() {
+		return nil
+	}
+	if d.clock.Now().Sub(host.fetchedAt) < d.staleAfter {
+		return nil
+	}
+	if _, running := d.revalidating[hostname]; running {
+		return nil
+	}
+	rv := &revalidation{}
+	d.revalidating[hostname] = rv
+	return rv
+}
+
+// revalidateInBackground refreshes the cached result for the given hostname
+// and reports the outcome to any [DiscoTrace] in the given context.
+//
+// The refresh isn't canceled along with the given context, since the caller
+// that started it has already returned, but it is limited by the default
+// timeout instead.
+func (d *Disco) revalidateInBackground(ctx context.Context, hostname svchost.Hostname, rv *revalidation) {
+	ctx = context.WithoutCancel(ctx)
+	refreshCtx := ctx
+	if d.timeout > 0 {
+		var cancel context.CancelFunc
+		refreshCtx, cancel = context.WithTimeout(ctx, d.timeout)
+		defer cancel()
+	}
+	_, err := d.refresh(refreshCtx, hostname, rv)
+	d.mu.Lock()
+	delete(d.revalidating, hostname)
+	d.mu.Unlock()
+	trace := discoTraceFromContext(ctx)
+	trace.backgroundRefresh(ctx, hostname, err)
+}
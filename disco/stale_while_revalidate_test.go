@@ -0,0 +1,264 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package disco
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/opentofu/svchost"
+	"github.com/opentofu/svchost/internal/clock"
+)
+
+func TestWithStaleWhileRevalidate(t *testing.T) {
+	var requests atomic.Int32
+	var moved atomic.Bool
+	portStr, cleanup := testServer(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Add("Content-Type", "application/json")
+		if moved.Load() {
+			w.Write([]byte(`{"thingy.v1":"http://example.com/bar"}`))
+			return
+		}
+		w.Write([]byte(`{"thingy.v1":"http://example.com/foo"}`))
+	})
+	defer cleanup()
+	hostname := svchost.Hostname("localhost" + portStr)
+
+	clk := clock.NewFake(time.Now())
+	d, err := NewWithErrors(WithHTTPClient(testClient), WithStaleWhileRevalidate(time.Hour), WithClock(clk))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	refreshed := make(chan error, 1)
+	ctx := ContextWithDiscoTrace(t.Context(), &DiscoTrace{
+		BackgroundRefresh: func(_ context.Context, host svchost.Hostname, err error) {
+			if host != hostname {
+				t.Errorf("wrong hostname %q; want %q", host, hostname)
+			}
+			refreshed <- err
+		},
+	})
+
+	serviceURL := func() string {
+		t.Helper()
+		host, err := d.Discover(ctx, hostname)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		u, err := host.ServiceURL("thingy.v1")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		return u.String()
+	}
+
+	if got, want := serviceURL(), "http://example.com/foo"; got != want {
+		t.Errorf("wrong URL %q; want %q", got, want)
+	}
+
+	// A result younger than the maximum age is served from the cache
+	// without a refresh.
+	moved.Store(true)
+	clk.Advance(30 * time.Minute)
+	if got, want := serviceURL(), "http://example.com/foo"; got != want {
+		t.Errorf("wrong URL %q; want %q", got, want)
+	}
+	if got, want := requests.Load(), int32(1); got != want {
+		t.Errorf("wrong number of requests %d; want %d", got, want)
+	}
+
+	// Once the result is too old it is still returned immediately, but is
+	// then replaced in the background.
+	clk.Advance(30 * time.Minute)
+	if got, want := serviceURL(), "http://example.com/foo"; got != want {
+		t.Errorf("wrong URL %q; want the stale result %q", got, want)
+	}
+	if err := <-refreshed; err != nil {
+		t.Fatalf("unexpected background refresh error: %s", err)
+	}
+	if got, want := serviceURL(), "http://example.com/bar"; got != want {
+		t.Errorf("wrong URL %q; want the refreshed result %q", got, want)
+	}
+	if got, want := requests.Load(), int32(2); got != want {
+		t.Errorf("wrong number of requests %d; want %d", got, want)
+	}
+
+	t.Run("forced services are not refreshed", func(t *testing.T) {
+		forced := svchost.Hostname("forced.example.com")
+		d.ForceHostServices(forced, map[string]any{"thingy.v1": "http://example.net/"})
+		clk.Advance(2 * time.Hour)
+		if _, err := d.Discover(ctx, forced); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		select {
+		case err := <-refreshed:
+			t.Fatalf("unexpected background refresh with error %v", err)
+		default:
+		}
+	})
+
+	t.Run("negative age", func(t *testing.T) {
+		if _, err := NewWithErrors(WithStaleWhileRevalidate(-time.Second)); err == nil {
+			t.Fatal("unexpected success; want error")
+		}
+	})
+}
+
+func TestWithStaleWhileRevalidate_failure(t *testing.T) {
+	var healthy atomic.Bool
+	healthy.Store(true)
+	portStr, cleanup := testServer(func(w http.ResponseWriter, r *http.Request) {
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Add("Content-Type", "application/json")
+		w.Write([]byte(`{"thingy.v1":"http://example.com/foo"}`))
+	})
+	defer cleanup()
+	hostname := svchost.Hostname("localhost" + portStr)
+
+	clk := clock.NewFake(time.Now())
+	d, err := NewWithErrors(WithHTTPClient(testClient), WithStaleWhileRevalidate(time.Minute), WithClock(clk))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	refreshed := make(chan error, 1)
+	ctx := ContextWithDiscoTrace(t.Context(), &DiscoTrace{
+		BackgroundRefresh: func(_ context.Context, _ svchost.Hostname, err error) {
+			refreshed <- err
+		},
+	})
+
+	want, err := d.Discover(ctx, hostname)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	healthy.Store(false)
+	clk.Advance(time.Minute)
+	if _, err := d.Discover(ctx, hostname); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := <-refreshed; err == nil {
+		t.Fatal("unexpected background refresh success; want error")
+	}
+
+	// The stale result is kept after the failed refresh.
+	got, err := d.Discover(t.Context(), hostname)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got != want {
+		t.Error("cached result was replaced after a failed background refresh")
+	}
+}
+
+func TestWithStaleWhileRevalidate_forgotten(t *testing.T) {
+	var requests atomic.Int32
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	portStr, cleanup := testServer(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 2 {
+			started <- struct{}{}
+			<-release
+		}
+		w.Header().Add("Content-Type", "application/json")
+		w.Write([]byte(`{"thingy.v1":"http://example.com/foo"}`))
+	})
+	defer cleanup()
+	hostname := svchost.Hostname("localhost" + portStr)
+
+	clk := clock.NewFake(time.Now())
+	d, err := NewWithErrors(WithHTTPClient(testClient), WithStaleWhileRevalidate(time.Minute), WithClock(clk))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	refreshed := make(chan error, 1)
+	ctx := ContextWithDiscoTrace(t.Context(), &DiscoTrace{
+		BackgroundRefresh: func(_ context.Context, _ svchost.Hostname, err error) {
+			refreshed <- err
+		},
+	})
+
+	if _, err := d.Discover(ctx, hostname); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	clk.Advance(time.Minute)
+	if _, err := d.Discover(ctx, hostname); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Forgetting the host while the refresh is in progress must prevent
+	// the refresh from caching its result again.
+	<-started
+	d.ForgetAll()
+	close(release)
+	if err := <-refreshed; err != nil {
+		t.Fatalf("unexpected background refresh error: %s", err)
+	}
+	d.mu.Lock()
+	_, cached := d.hostCache[hostname]
+	d.mu.Unlock()
+	if cached {
+		t.Error("background refresh cached a result for a forgotten host")
+	}
+}
+
+func TestWithStaleWhileRevalidate_timeout(t *testing.T) {
+	var requests atomic.Int32
+	done := make(chan struct{})
+	portStr, cleanup := testServer(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) > 1 {
+			// Later requests don't complete unless they are canceled.
+			select {
+			case <-r.Context().Done():
+			case <-done:
+			}
+			return
+		}
+		w.Header().Add("Content-Type", "application/json")
+		w.Write([]byte(`{"thingy.v1":"http://example.com/foo"}`))
+	})
+	defer cleanup()
+	defer close(done)
+	hostname := svchost.Hostname("localhost" + portStr)
+
+	clk := clock.NewFake(time.Now())
+	d, err := NewWithErrors(WithHTTPClient(testClient), WithStaleWhileRevalidate(time.Minute), WithClock(clk))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	// WithDefaultTimeout can't be combined with WithHTTPClient, so we set
+	// the timeout directly.
+	d.timeout = 50 * time.Millisecond
+	refreshed := make(chan error, 1)
+	ctx := ContextWithDiscoTrace(t.Context(), &DiscoTrace{
+		BackgroundRefresh: func(_ context.Context, _ svchost.Hostname, err error) {
+			refreshed <- err
+		},
+	})
+
+	if _, err := d.Discover(ctx, hostname); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	clk.Advance(time.Minute)
+	if _, err := d.Discover(ctx, hostname); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	select {
+	case err := <-refreshed:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("wrong error %v; want deadline exceeded", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("background refresh did not time out")
+	}
+}
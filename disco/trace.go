@@ -6,6 +6,8 @@ package disco
 
 import (
 	"context"
+	"net/url"
+	"time"
 
 	svchost "github.com/opentofu/svchost"
 )
@@ -53,6 +55,104 @@ type DiscoTrace struct {
 	// completion callbacks if a service discovery request is served from the
 	// cache of previous results rather than by making a discovery request.
 	DiscoveryHostCached func(ctx context.Context, host svchost.Hostname)
+
+	// DiscoveryNegativeCached is called instead of DiscoveryStart and its
+	// completion callbacks if a service discovery request fails immediately
+	// with the error from a recent failed request, because of
+	// [WithNegativeCacheTTL].
+	DiscoveryNegativeCached func(ctx context.Context, host svchost.Hostname, err error)
+
+	// ServicesChanged is called by [Disco.Refresh] when a new discovery
+	// result replaces a cached one whose services differ, with the changes
+	// as returned by [Host.Diff]. It is called after DiscoverySuccess.
+	ServicesChanged func(ctx context.Context, host svchost.Hostname, changes []ServiceChange)
+
+	// BackgroundRefresh is called when a refresh started in the background
+	// because of [WithStaleWhileRevalidate] is complete, after any call to
+	// ServicesChanged. err is nil if the new result replaced the cached one,
+	// or otherwise describes why the cached result was kept.
+	//
+	// The given context has the values of the context passed to the
+	// [Disco.Discover] call that started the refresh, but is never canceled.
+	BackgroundRefresh func(ctx context.Context, host svchost.Hostname, err error)
+
+	// CredentialsLookupStart is called when the credentials to use for a
+	// discovery request are about to be looked up, if a credentials source
+	// is configured.
+	//
+	// This should return a [context.Context] to be used for the lookup, and
+	// it will then be passed as the context to either CredentialsLookupSuccess
+	// or CredentialsLookupFailure once the lookup is complete.
+	CredentialsLookupStart func(ctx context.Context, host svchost.Hostname) context.Context
+
+	// CredentialsLookupSuccess is called after a credentials lookup completes
+	// without an error. found is true if credentials were available for the
+	// host, in which case they are attached to the discovery request. The
+	// credentials themselves are not reported, to avoid exposing secrets.
+	CredentialsLookupSuccess func(ctx context.Context, host svchost.Hostname, found bool)
+
+	// CredentialsLookupFailure is called after a credentials lookup fails
+	// with an error, in which case the discovery request is made without
+	// credentials.
+	CredentialsLookupFailure func(ctx context.Context, host svchost.Hostname, err error)
+
+	// RedirectFollowed is called once for each redirect that was followed
+	// to reach the final discovery response, in the order they were
+	// followed, before DiscoverySuccess or DiscoveryFailure.
+	//
+	// The given context has the same values as the one returned by the earlier
+	// call to DiscoveryStart.
+	RedirectFollowed func(ctx context.Context, host svchost.Hostname, from, to *url.URL)
+
+	// RedirectCredentialsRemoved is called when a discovery request that
+	// included credentials for the given host is redirected to a different
+	// host, in which case those credentials are removed from the redirected
+	// request. Credentials for the new host are then looked up and, if
+	// available, attached instead, which is reported using the
+	// CredentialsLookup callbacks above.
+	//
+	// The given context has the same values as the one returned by the earlier
+	// call to DiscoveryStart.
+	RedirectCredentialsRemoved func(ctx context.Context, host svchost.Hostname, to *url.URL)
+
+	// DocumentDecompressed is called when the discovery document was
+	// received compressed, with the number of bytes received and the size
+	// of the document after decompression, before DiscoverySuccess or
+	// DiscoveryFailure. The discovery document size limit applies to the
+	// decompressed size.
+	//
+	// The given context has the same values as the one returned by the earlier
+	// call to DiscoveryStart.
+	DocumentDecompressed func(ctx context.Context, host svchost.Hostname, compressedSize, uncompressedSize int64)
+
+	// DiscoveryStats is called after DiscoverySuccess or DiscoveryFailure
+	// with quantitative details about the completed discovery request, for
+	// callers that want to record performance metrics.
+	//
+	// The given context has the same values as the one returned by the earlier
+	// call to DiscoveryStart.
+	DiscoveryStats func(ctx context.Context, host svchost.Hostname, stats DiscoveryStats)
+}
+
+// DiscoveryStats describes a completed discovery request, as reported to
+// [DiscoTrace.DiscoveryStats].
+type DiscoveryStats struct {
+	// Duration is the total time taken by the discovery request, including
+	// any retries and redirects.
+	Duration time.Duration
+
+	// StatusCode is the HTTP status code of the final response, or zero if
+	// no response was received.
+	StatusCode int
+
+	// ResponseSize is the number of bytes read from the body of the final
+	// response after any decompression, which is zero if the body was not
+	// read.
+	ResponseSize int
+
+	// Redirects is the number of redirects that were followed to reach the
+	// final response.
+	Redirects int
 }
 
 func ContextWithDiscoTrace(parent context.Context, trace *DiscoTrace) context.Context {
@@ -87,6 +187,76 @@ func (t *DiscoTrace) discoveryHostCached(ctx context.Context, host svchost.Hostn
 	t.DiscoveryHostCached(ctx, host)
 }
 
+func (t *DiscoTrace) discoveryNegativeCached(ctx context.Context, host svchost.Hostname, err error) {
+	if t.DiscoveryNegativeCached == nil {
+		return
+	}
+	t.DiscoveryNegativeCached(ctx, host, err)
+}
+
+func (t *DiscoTrace) credentialsLookupStart(ctx context.Context, host svchost.Hostname) context.Context {
+	if t.CredentialsLookupStart == nil {
+		return ctx
+	}
+	return t.CredentialsLookupStart(ctx, host)
+}
+
+func (t *DiscoTrace) credentialsLookupSuccess(ctx context.Context, host svchost.Hostname, found bool) {
+	if t.CredentialsLookupSuccess == nil {
+		return
+	}
+	t.CredentialsLookupSuccess(ctx, host, found)
+}
+
+func (t *DiscoTrace) credentialsLookupFailure(ctx context.Context, host svchost.Hostname, err error) {
+	if t.CredentialsLookupFailure == nil {
+		return
+	}
+	t.CredentialsLookupFailure(ctx, host, err)
+}
+
+func (t *DiscoTrace) redirectFollowed(ctx context.Context, host svchost.Hostname, from, to *url.URL) {
+	if t.RedirectFollowed == nil {
+		return
+	}
+	t.RedirectFollowed(ctx, host, from, to)
+}
+
+func (t *DiscoTrace) redirectCredentialsRemoved(ctx context.Context, host svchost.Hostname, to *url.URL) {
+	if t.RedirectCredentialsRemoved == nil {
+		return
+	}
+	t.RedirectCredentialsRemoved(ctx, host, to)
+}
+
+func (t *DiscoTrace) documentDecompressed(ctx context.Context, host svchost.Hostname, compressedSize, uncompressedSize int64) {
+	if t.DocumentDecompressed == nil {
+		return
+	}
+	t.DocumentDecompressed(ctx, host, compressedSize, uncompressedSize)
+}
+
+func (t *DiscoTrace) backgroundRefresh(ctx context.Context, host svchost.Hostname, err error) {
+	if t.BackgroundRefresh == nil {
+		return
+	}
+	t.BackgroundRefresh(ctx, host, err)
+}
+
+func (t *DiscoTrace) discoveryStats(ctx context.Context, host svchost.Hostname, stats DiscoveryStats) {
+	if t.DiscoveryStats == nil {
+		return
+	}
+	t.DiscoveryStats(ctx, host, stats)
+}
+
+func (t *DiscoTrace) servicesChanged(ctx context.Context, host svchost.Hostname, changes []ServiceChange) {
+	if t.ServicesChanged == nil {
+		return
+	}
+	t.ServicesChanged(ctx, host, changes)
+}
+
 func discoTraceFromContext(ctx context.Context) *DiscoTrace {
 	trace, ok := ctx.Value(discoTraceKey).(*DiscoTrace)
 	if !ok {
@@ -6,13 +6,16 @@ package disco
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/opentofu/svchost"
+	"github.com/opentofu/svchost/svcauth"
 )
 
 func TestDiscoTrace(t *testing.T) {
@@ -154,3 +157,116 @@ func TestDiscoTrace(t *testing.T) {
 		}
 	}
 }
+
+func TestDiscoTraceStats(t *testing.T) {
+	const doc = `{"thingy.v1": "http://example.com/foo"}`
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/terraform.json":
+			http.Redirect(w, r, "/moved/1", http.StatusFound)
+		case "/moved/1":
+			http.Redirect(w, r, "/moved/2", http.StatusFound)
+		default:
+			w.Header().Set("content-type", "application/json")
+			w.Write([]byte(doc))
+		}
+	}))
+	defer server.Close()
+	hostname := strings.TrimPrefix(server.URL, "https://")
+
+	var got []DiscoveryStats
+	ctx := ContextWithDiscoTrace(t.Context(), &DiscoTrace{
+		DiscoveryStats: func(ctx context.Context, host svchost.Hostname, stats DiscoveryStats) {
+			got = append(got, stats)
+		},
+	})
+
+	disco := New(WithHTTPClient(server.Client()))
+	if _, err := disco.Discover(ctx, svchost.Hostname(hostname)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	// A cached result does not produce stats.
+	if _, err := disco.Discover(ctx, svchost.Hostname(hostname)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("wrong number of stats reports %d; want 1", len(got))
+	}
+	stats := got[0]
+	if stats.Duration <= 0 {
+		t.Errorf("wrong duration %s; want positive", stats.Duration)
+	}
+	stats.Duration = 0
+	want := DiscoveryStats{
+		StatusCode:   http.StatusOK,
+		ResponseSize: len(doc),
+		Redirects:    2,
+	}
+	if diff := cmp.Diff(want, stats); diff != "" {
+		t.Errorf("wrong stats\n%s", diff)
+	}
+}
+
+func TestDiscoTraceCredentialsAndRedirects(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/terraform.json":
+			http.Redirect(w, r, "/moved/", http.StatusFound)
+		default:
+			w.Header().Set("content-type", "application/json")
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer server.Close()
+	hostname := svchost.Hostname(strings.TrimPrefix(server.URL, "https://"))
+
+	var events []string
+	ctx := ContextWithDiscoTrace(t.Context(), &DiscoTrace{
+		CredentialsLookupStart: func(ctx context.Context, host svchost.Hostname) context.Context {
+			events = append(events, "CredentialsLookupStart")
+			return ctx
+		},
+		CredentialsLookupSuccess: func(ctx context.Context, host svchost.Hostname, found bool) {
+			events = append(events, fmt.Sprintf("CredentialsLookupSuccess(%t)", found))
+		},
+		CredentialsLookupFailure: func(ctx context.Context, host svchost.Hostname, err error) {
+			events = append(events, "CredentialsLookupFailure")
+		},
+		RedirectFollowed: func(ctx context.Context, host svchost.Hostname, from, to *url.URL) {
+			events = append(events, fmt.Sprintf("RedirectFollowed(%s, %s)", from.Path, to.Path))
+		},
+		DiscoverySuccess: func(ctx context.Context, host svchost.Hostname) {
+			events = append(events, "DiscoverySuccess")
+		},
+	})
+
+	disco := New(
+		WithHTTPClient(server.Client()),
+		WithCredentials(svcauth.StaticCredentialsSource(map[svchost.Hostname]svcauth.HostCredentials{
+			hostname: svcauth.HostCredentialsToken("secret"),
+		})),
+	)
+	if _, err := disco.Discover(ctx, hostname); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := []string{
+		"CredentialsLookupStart",
+		"CredentialsLookupSuccess(true)",
+		"RedirectFollowed(/.well-known/terraform.json, /moved/)",
+		"DiscoverySuccess",
+	}
+	if diff := cmp.Diff(want, events); diff != "" {
+		t.Errorf("wrong events\n%s", diff)
+	}
+
+	// Without a credentials source there is no lookup to report.
+	events = nil
+	disco = New(WithHTTPClient(server.Client()))
+	if _, err := disco.Discover(ctx, hostname); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(events) != 0 && events[0] == "CredentialsLookupStart" {
+		t.Errorf("unexpected credentials lookup events without a credentials source: %q", events)
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package disco
+
+import (
+	"runtime/debug"
+	"strings"
+	"sync"
+)
+
+const modulePath = "github.com/opentofu/svchost"
+
+// moduleVersion returns the version of this module as recorded in the
+// build information of the current program, or "devel" if it's unknown,
+// such as when running this module's own tests.
+var moduleVersion = sync.OnceValue(func() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "devel"
+	}
+	var mod *debug.Module
+	if info.Main.Path == modulePath {
+		mod = &info.Main
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == modulePath {
+			mod = dep
+			break
+		}
+	}
+	if mod != nil && mod.Replace != nil {
+		mod = mod.Replace
+	}
+	if mod == nil || mod.Version == "" || mod.Version == "(devel)" {
+		return "devel"
+	}
+	return mod.Version
+})
+
+// userAgent returns the value to use for the User-Agent header in discovery
+// requests, which always identifies this library and its version so that
+// servers can distinguish discovery traffic from other requests. The result
+// has the form "<product> svchost/<version> (<comment>)", where the product
+// and comment are omitted if they weren't configured.
+func (d *Disco) userAgent() string {
+	var b strings.Builder
+	if d.userAgentProduct != "" {
+		b.WriteString(d.userAgentProduct)
+		b.WriteByte(' ')
+	}
+	b.WriteString("svchost/")
+	b.WriteString(moduleVersion())
+	if d.userAgentComment != "" {
+		b.WriteString(" (")
+		b.WriteString(d.userAgentComment)
+		b.WriteByte(')')
+	}
+	return b.String()
+}
+
+// validUserAgentPart returns true if the given string can be included in a
+// User-Agent header without corrupting it.
+func validUserAgentPart(s string) bool {
+	for _, r := range s {
+		if r < 0x20 || r == 0x7f || r > 0x7e {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,84 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package disco
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+
+	svchost "github.com/opentofu/svchost"
+)
+
+// ValidateServices checks the given services map, as would be decoded from
+// the discovery document for the given hostname, for problems that would
+// prevent a client from using the services it describes.
+//
+// This is intended for tools that check the conformance of discovery
+// documents, such as those served by a private registry implementation.
+// [Disco.Discover] does not perform this validation itself, because clients
+// should tolerate problems with services they don't intend to use.
+//
+// Relative service URLs are resolved against the default discovery URL for
+// the given hostname.
+func ValidateServices(hostname svchost.Hostname, services map[string]any) svchost.Diagnostics {
+	host := &Host{
+		discoURL: &url.URL{
+			Scheme: "https",
+			Host:   hostname.String(),
+			Path:   discoPath,
+		},
+		hostname: hostname.ForDisplay(),
+		services: services,
+	}
+
+	var diags svchost.Diagnostics
+	ids := make([]string, 0, len(services))
+	for id := range services {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids) // for deterministic diagnostic order
+
+	for _, id := range ids {
+		diag := func(severity svchost.Severity, summary, detail string) {
+			diags = append(diags, svchost.Diagnostic{
+				Severity: severity,
+				Summary:  summary,
+				Detail:   detail,
+				Host:     hostname,
+				Service:  id,
+			})
+		}
+
+		if id == DocumentMetaKey {
+			if _, err := parseDocumentVersion(services); err != nil {
+				diag(svchost.SeverityError, "Invalid document metadata", err.Error())
+			}
+			continue
+		}
+		if _, err := ParseServiceID(id); err != nil {
+			diag(svchost.SeverityError, "Invalid service identifier", err.Error())
+			continue
+		}
+
+		switch v := services[id].(type) {
+		case string:
+			u, err := host.parseURL(v)
+			if err != nil {
+				diag(svchost.SeverityError, "Invalid service URL", err.Error())
+				continue
+			}
+			if u.Scheme == "http" {
+				diag(svchost.SeverityWarning, "Unencrypted service URL", fmt.Sprintf("The URL %s uses unencrypted HTTP, so any credentials sent to it could be intercepted.", u))
+			}
+		case map[string]any:
+			if _, err := host.ServiceOAuthClient(id); err != nil {
+				diag(svchost.SeverityError, "Invalid OAuth client definition", err.Error())
+			}
+		default:
+			diag(svchost.SeverityWarning, "Unsupported service definition", fmt.Sprintf("Service definitions must be either a URL string or an object, but this is %T.", v))
+		}
+	}
+	return diags
+}
@@ -0,0 +1,57 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package disco
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	svchost "github.com/opentofu/svchost"
+)
+
+func TestValidateServices(t *testing.T) {
+	hostname := svchost.Hostname("example.com")
+	diags := ValidateServices(hostname, map[string]any{
+		"modules.v1":   "/modules/",
+		"providers.v1": "http://example.net/providers/",
+		"badurl.v1":    "ftp://example.com/",
+		"noversion":    "/foo",
+		"login.v1": map[string]any{
+			"client": "tofu-cli",
+			"authz":  "/authz",
+			"token":  "/token",
+		},
+		"badlogin.v1": map[string]any{
+			"authz": "/authz",
+			"token": "/token",
+		},
+		"number.v1": 42.0,
+		"meta":      map[string]any{"version": "2.0"},
+	})
+
+	type summary struct {
+		Severity svchost.Severity
+		Service  string
+		Summary  string
+	}
+	var got []summary
+	for _, diag := range diags {
+		if diag.Host != hostname {
+			t.Errorf("diagnostic has wrong host %q", diag.Host)
+		}
+		got = append(got, summary{diag.Severity, diag.Service, diag.Summary})
+	}
+	want := []summary{
+		{svchost.SeverityError, "badlogin.v1", "Invalid OAuth client definition"},
+		{svchost.SeverityError, "badurl.v1", "Invalid service URL"},
+		{svchost.SeverityError, "meta", "Invalid document metadata"},
+		{svchost.SeverityError, "noversion", "Invalid service identifier"},
+		{svchost.SeverityWarning, "number.v1", "Unsupported service definition"},
+		{svchost.SeverityWarning, "providers.v1", "Unencrypted service URL"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Error("wrong diagnostics\n" + diff)
+	}
+}
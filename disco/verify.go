@@ -0,0 +1,60 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package disco
+
+import (
+	"fmt"
+	"net/http"
+
+	svchost "github.com/opentofu/svchost"
+)
+
+// ResponseVerifier is the signature of a function that decides whether to
+// accept a discovery document, registered using [WithResponseVerifier].
+//
+// doc is the raw body of the discovery response exactly as it was received,
+// except that it is decompressed if the server compressed it, and header
+// contains only the response headers that were selected when the
+// verifier was registered. A non-nil error causes discovery to fail with an
+// error wrapping it, and the document is not cached.
+type ResponseVerifier func(hostname svchost.Hostname, doc []byte, header http.Header) error
+
+// DocumentValidator is the signature of a function that decides whether to
+// accept the decoded content of a discovery document, registered using
+// [WithDocumentValidator].
+//
+// doc maps each service ID to its definition as decoded from JSON. A non-nil
+// error causes discovery to fail with an error wrapping it, and the document
+// is not cached.
+type DocumentValidator func(hostname svchost.Hostname, doc map[string]any) error
+
+// RequestMutator is the signature of a function that modifies each discovery
+// request before it is sent, registered using [WithRequestMutator].
+//
+// A non-nil error causes discovery to fail with an error wrapping it, without
+// sending the request.
+type RequestMutator func(req *http.Request) error
+
+// mutateRequest calls each of the receiver's request mutators in turn on the
+// given request, stopping at the first error.
+func (d *Disco) mutateRequest(req *http.Request) error {
+	for _, mutate := range d.requestMutators {
+		if err := mutate(req); err != nil {
+			return fmt.Errorf("failed to prepare discovery request: %w", err)
+		}
+	}
+	return nil
+}
+
+// verifierHeader returns a copy of just the named headers from the given
+// response header.
+func verifierHeader(header http.Header, names []string) http.Header {
+	ret := make(http.Header, len(names))
+	for _, name := range names {
+		if values := header.Values(name); len(values) != 0 {
+			ret[http.CanonicalHeaderKey(name)] = append([]string(nil), values...)
+		}
+	}
+	return ret
+}
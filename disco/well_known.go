@@ -0,0 +1,37 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package disco
+
+import (
+	"net/url"
+)
+
+// The identifiers of the services that OpenTofu CLI itself uses.
+const (
+	// ModulesV1 is the module registry protocol, version 1.
+	ModulesV1 = "modules.v1"
+
+	// ProvidersV1 is the provider registry protocol, version 1.
+	ProvidersV1 = "providers.v1"
+
+	// LoginV1 is the OAuth client configuration used by "tofu login",
+	// version 1.
+	LoginV1 = "login.v1"
+)
+
+// ModulesV1URL returns the base URL of the host's [ModulesV1] service.
+func (h *Host) ModulesV1URL() (*url.URL, error) {
+	return h.ServiceURL(ModulesV1)
+}
+
+// ProvidersV1URL returns the base URL of the host's [ProvidersV1] service.
+func (h *Host) ProvidersV1URL() (*url.URL, error) {
+	return h.ServiceURL(ProvidersV1)
+}
+
+// LoginV1Client returns the OAuth client configuration of the host's
+// [LoginV1] service.
+func (h *Host) LoginV1Client() (*OAuthClient, error) {
+	return h.ServiceOAuthClient(LoginV1)
+}
@@ -0,0 +1,48 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package disco
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+)
+
+func TestHostWellKnownServices(t *testing.T) {
+	baseURL, _ := url.Parse("https://example.com/.well-known/terraform.json")
+	host := &Host{
+		discoURL: baseURL,
+		hostname: "example.com",
+		services: map[string]any{
+			ModulesV1: "/api/modules/",
+			LoginV1: map[string]any{
+				"client": "tofu",
+				"authz":  "/oauth/authz",
+				"token":  "/oauth/token",
+			},
+		},
+	}
+
+	u, err := host.ModulesV1URL()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got, want := u.String(), "https://example.com/api/modules/"; got != want {
+		t.Errorf("wrong modules URL %q; want %q", got, want)
+	}
+
+	_, err = host.ProvidersV1URL()
+	var notProvided *ErrServiceNotProvided
+	if !errors.As(err, &notProvided) {
+		t.Errorf("wrong error %v; want ErrServiceNotProvided", err)
+	}
+
+	client, err := host.LoginV1Client()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got, want := client.ID, "tofu"; got != want {
+		t.Errorf("wrong client ID %q; want %q", got, want)
+	}
+}
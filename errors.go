@@ -0,0 +1,58 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package svchost
+
+import (
+	"errors"
+	"fmt"
+)
+
+// HostError records an error that occurred during an operation concerning
+// a specific service host, such as service discovery or a credentials lookup.
+//
+// The other packages in this module wrap the errors they return in this type
+// so that callers can use [errors.As] to group failures by host without
+// needing to parse error messages.
+type HostError struct {
+	// Host is the hostname that the failed operation was concerned with.
+	Host Hostname
+
+	// Op is a short description of the operation that failed, such as
+	// "discover services for".
+	Op string
+
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *HostError) Error() string {
+	return fmt.Sprintf("%s %s: %s", e.Op, ForDisplay(string(e.Host)), e.Err)
+}
+
+// Unwrap returns the underlying error, for use with the standard library
+// errors package and its "Is", "As", and "Unwrap" functions.
+func (e *HostError) Unwrap() error {
+	return e.Err
+}
+
+// WrapHostError returns a [HostError] wrapping the given error with the given
+// hostname and operation.
+//
+// If err is nil then the result is also nil. If err already wraps a
+// HostError for the same hostname then err is returned verbatim, so that
+// layers of wrapping don't repeat the same information.
+func WrapHostError(host Hostname, op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var existing *HostError
+	if errors.As(err, &existing) && existing.Host == host {
+		return err
+	}
+	return &HostError{
+		Host: host,
+		Op:   op,
+		Err:  err,
+	}
+}
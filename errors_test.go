@@ -0,0 +1,40 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package svchost
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestWrapHostError(t *testing.T) {
+	if got := WrapHostError("example.com", "test", nil); got != nil {
+		t.Errorf("wrong result for nil error %#v; want nil", got)
+	}
+
+	inner := errors.New("oops")
+	err := WrapHostError("xn--caf-dma.example.com", "test", inner)
+	if got, want := err.Error(), "test café.example.com: oops"; got != want {
+		t.Errorf("wrong message\ngot:  %s\nwant: %s", got, want)
+	}
+	var hostErr *HostError
+	if !errors.As(err, &hostErr) {
+		t.Fatalf("result is not a HostError")
+	}
+	if got, want := hostErr.Host, Hostname("xn--caf-dma.example.com"); got != want {
+		t.Errorf("wrong host %q; want %q", got, want)
+	}
+	if !errors.Is(err, inner) {
+		t.Error("result does not wrap the given error")
+	}
+
+	// Wrapping again for the same host returns the existing error, but
+	// a different host adds a new layer.
+	if got := WrapHostError("xn--caf-dma.example.com", "other", err); got != err {
+		t.Errorf("error was wrapped again for the same host: %s", got)
+	}
+	if got, want := WrapHostError("example.net", "other", err).Error(), "other example.net: test café.example.com: oops"; got != want {
+		t.Errorf("wrong message\ngot:  %s\nwant: %s", got, want)
+	}
+}
@@ -7,6 +7,7 @@ require (
 	github.com/zclconf/go-cty v1.16.2
 	golang.org/x/net v0.40.0
 	golang.org/x/oauth2 v0.30.0
+	golang.org/x/sys v0.33.0
 )
 
 require (
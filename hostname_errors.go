@@ -0,0 +1,188 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package svchost
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+)
+
+// InvalidHostnameError is the type of the errors returned by [ForComparison]
+// and its variants when the given hostname is invalid, describing the
+// problem in enough detail for a user interface to point to it.
+//
+// Use [errors.As] to obtain an InvalidHostnameError from an error.
+type InvalidHostnameError struct {
+	// Given is the hostname exactly as it was given.
+	Given string
+
+	// Reason categorizes the problem.
+	Reason InvalidHostnameReason
+
+	// Offset is the byte offset in Given of the start of the problem, such
+	// as the start of an invalid label or the colon before an invalid port
+	// number.
+	Offset int
+
+	// Length is the number of bytes in Given, starting at Offset, that are
+	// involved in the problem, which may be zero when the problem is a
+	// missing part, such as an empty label or an empty hostname.
+	Length int
+
+	// Err is the underlying error, if any, such as from IDNA processing.
+	Err error
+
+	msg string
+}
+
+func (e *InvalidHostnameError) Error() string {
+	if e.msg == "" && e.Err != nil {
+		return e.Err.Error()
+	}
+	return e.msg
+}
+
+// Unwrap returns the underlying error, if any, for use with the standard
+// library errors package and its "Is", "As", and "Unwrap" functions.
+func (e *InvalidHostnameError) Unwrap() error {
+	return e.Err
+}
+
+// Part returns the portion of the given hostname that the problem concerns,
+// which may be empty.
+func (e *InvalidHostnameError) Part() string {
+	return e.Given[e.Offset : e.Offset+e.Length]
+}
+
+// InvalidHostnameReason is a category of problem described by an
+// [InvalidHostnameError].
+type InvalidHostnameReason int
+
+const (
+	// InvalidHostnameEmpty means that the hostname is empty, not counting
+	// any port number.
+	InvalidHostnameEmpty InvalidHostnameReason = iota + 1
+
+	// InvalidHostnameEmptyLabel means that the hostname has two consecutive
+	// periods, or starts with a period.
+	InvalidHostnameEmptyLabel
+
+	// InvalidHostnamePunycode means that a label is given in Punycode form,
+	// which is not allowed for user-specified hostnames.
+	InvalidHostnamePunycode
+
+	// InvalidHostnameCharacter means that a label contains a character
+	// that is not allowed in hostnames. The problem refers to just that
+	// character.
+	InvalidHostnameCharacter
+
+	// InvalidHostnameLabel means that a label is not valid as an
+	// internationalized domain name label for some other reason, such as
+	// starting with a hyphen or being too long.
+	InvalidHostnameLabel
+
+	// InvalidHostnamePort means that the port number is not valid.
+	InvalidHostnamePort
+
+	// InvalidHostnameURL means that a URL was given instead of a hostname.
+	InvalidHostnameURL
+
+	// InvalidHostnameIPAddress means that the hostname is an IP address
+	// literal that is invalid, or is not allowed.
+	InvalidHostnameIPAddress
+)
+
+// String returns a short description of the reason.
+func (r InvalidHostnameReason) String() string {
+	switch r {
+	case InvalidHostnameEmpty:
+		return "empty hostname"
+	case InvalidHostnameEmptyLabel:
+		return "empty label"
+	case InvalidHostnamePunycode:
+		return "punycode label"
+	case InvalidHostnameCharacter:
+		return "invalid character"
+	case InvalidHostnameLabel:
+		return "invalid label"
+	case InvalidHostnamePort:
+		return "invalid port number"
+	case InvalidHostnameURL:
+		return "URL instead of hostname"
+	case InvalidHostnameIPAddress:
+		return "invalid IP address"
+	default:
+		return "unknown problem"
+	}
+}
+
+// invalidIDN returns an [InvalidHostnameError] describing why the first
+// hostLen bytes of the given hostname, which exclude its port portion, were
+// rejected by IDNA processing with the given error.
+//
+// The IDNA package doesn't report where the problem is, so we find it by
+// processing each label, and then each character of the first failing
+// label, separately.
+func invalidIDN(given string, hostLen int, err error) *InvalidHostnameError {
+	ret := &InvalidHostnameError{
+		Given:  given,
+		Reason: InvalidHostnameLabel,
+		Length: hostLen,
+		Err:    err,
+	}
+	labels := labelIter{orig: given[:hostLen]}
+	for ; !labels.done(); labels.next() {
+		label := labels.label()
+		if _, err := idna.Lookup.ToASCII(label); err == nil {
+			continue
+		}
+		ret.Offset, ret.Length = labels.curStart, len(label)
+		for i, r := range label {
+			if _, err := characterProfile.ToASCII(string(r)); err != nil {
+				ret.Reason = InvalidHostnameCharacter
+				ret.Offset, ret.Length = labels.curStart+i, utf8.RuneLen(r)
+				break
+			}
+		}
+		break
+	}
+	return ret
+}
+
+// characterProfile is an IDNA profile that checks only whether individual
+// characters are allowed, and not the rules that apply to whole labels,
+// such as those about hyphens and bidirectional text.
+var characterProfile = idna.New(idna.MapForLookup(), idna.CheckHyphens(false), idna.CheckJoiners(false))
+
+// invalidPort returns an [InvalidHostnameError] describing the invalid port
+// portion of the given hostname, which starts at the given offset, as
+// reported by [normalizePortPortion].
+func invalidPort(given string, offset int, err error) *InvalidHostnameError {
+	return &InvalidHostnameError{
+		Given:  given,
+		Reason: InvalidHostnamePort,
+		Offset: offset,
+		Length: len(given) - offset,
+		msg:    err.Error(),
+	}
+}
+
+// invalidIPAddress returns an [InvalidHostnameError] describing a problem
+// with the IP address literal that the given hostname starts with.
+func invalidIPAddress(given string, msg string) *InvalidHostnameError {
+	length := len(given)
+	if end := strings.LastIndex(given, "]"); end != -1 {
+		length = end + 1
+	} else if colonPos := strings.Index(given, ":"); colonPos != -1 && strings.Count(given, ":") == 1 {
+		length = colonPos
+	}
+	return &InvalidHostnameError{
+		Given:  given,
+		Reason: InvalidHostnameIPAddress,
+		Length: length,
+		msg:    msg,
+	}
+}
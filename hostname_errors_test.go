@@ -0,0 +1,63 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package svchost
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestInvalidHostnameError(t *testing.T) {
+	tests := []struct {
+		given      string
+		fn         func(string) (Hostname, error)
+		wantReason InvalidHostnameReason
+		wantPart   string
+		wantOffset int
+	}{
+		{"", ForComparisonAllowIP, InvalidHostnameEmpty, "", 0},
+		{":8080", ForComparisonAllowIP, InvalidHostnameEmpty, "", 0},
+		{"blah..blah", ForComparisonAllowIP, InvalidHostnameEmptyLabel, "", 5},
+		{".example.com", ForComparisonAllowIP, InvalidHostnameEmptyLabel, "", 0},
+		{"registry.xn--80akhbyknj4f.com", ForComparisonAllowIP, InvalidHostnamePunycode, "xn--80akhbyknj4f", 9},
+		{"exa mple.com", ForComparisonAllowIP, InvalidHostnameCharacter, " ", 3},
+		{"registry.ex_ample.com:8443", ForComparisonAllowIP, InvalidHostnameCharacter, "_", 11},
+		{"registry.-example.com", ForComparisonAllowIP, InvalidHostnameLabel, "-example", 9},
+		{"example.com:boo", ForComparisonAllowIP, InvalidHostnamePort, ":boo", 11},
+		{"example.com:9999999", ForComparisonAllowIP, InvalidHostnamePort, ":9999999", 11},
+		{"https://example.com", ForComparisonAllowIP, InvalidHostnameURL, "https://example.com", 0},
+		{"2001:db8::1", ForComparisonAllowIP, InvalidHostnameIPAddress, "2001:db8::1", 0},
+		{"[2001:db8::1", ForComparisonAllowIP, InvalidHostnameIPAddress, "[2001:db8::1", 0},
+		{"[2001:db8::1]x", ForComparisonAllowIP, InvalidHostnamePort, "x", 13},
+		{"[2001:db8::1]:boo", ForComparisonAllowIP, InvalidHostnamePort, ":boo", 13},
+		{"192.0.2.1:boo", ForComparisonAllowIP, InvalidHostnamePort, ":boo", 9},
+		{"192.0.2.1:8080", ForComparisonDNSOnly, InvalidHostnameIPAddress, "192.0.2.1", 0},
+		{"[2001:db8::1]", ForComparison, InvalidHostnameIPAddress, "[2001:db8::1]", 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.given, func(t *testing.T) {
+			_, err := test.fn(test.given)
+			var hostErr *InvalidHostnameError
+			if !errors.As(err, &hostErr) {
+				t.Fatalf("wrong error %v; want InvalidHostnameError", err)
+			}
+			if hostErr.Given != test.given {
+				t.Errorf("wrong Given %q; want %q", hostErr.Given, test.given)
+			}
+			if hostErr.Reason != test.wantReason {
+				t.Errorf("wrong reason %q; want %q", hostErr.Reason, test.wantReason)
+			}
+			if got := hostErr.Part(); got != test.wantPart {
+				t.Errorf("wrong part %q; want %q", got, test.wantPart)
+			}
+			if hostErr.Offset != test.wantOffset {
+				t.Errorf("wrong offset %d; want %d", hostErr.Offset, test.wantOffset)
+			}
+			if hostErr.Error() == "" {
+				t.Error("error has no message")
+			}
+		})
+	}
+}
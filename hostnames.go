@@ -0,0 +1,115 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package svchost
+
+import (
+	"maps"
+	"slices"
+)
+
+// NormalizeAll is like [ForComparison] but normalizes many hostnames at once,
+// such as all of the hostnames in a configuration file, collecting all of the
+// errors rather than stopping at the first.
+//
+// The result has one element for each of the given inputs, in the same order,
+// which is the empty string for any input that is invalid. The errors are
+// in the same order as the invalid inputs they describe, and each is an
+// [*InvalidHostnameError] whose Given field is that input. The errors are nil
+// if all of the inputs are valid.
+func NormalizeAll(inputs []string) ([]Hostname, []error) {
+	ret := make([]Hostname, len(inputs))
+	var errs []error
+	for i, given := range inputs {
+		host, err := ForComparison(given)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		ret[i] = host
+	}
+	return ret, errs
+}
+
+// MustForComparison is like [ForComparison] but panics if the given hostname
+// is invalid. It's intended for tests and for hostnames that are constant in
+// the program source, and must not be used with hostnames given by users.
+func MustForComparison(given string) Hostname {
+	host, err := ForComparison(given)
+	if err != nil {
+		panic(err)
+	}
+	return host
+}
+
+// SortHostnames sorts the given hostnames in place, in lexical order of their
+// comparison forms.
+//
+// The hostnames must already be normalized, such as by [ForComparison], so
+// that equivalent hostnames sort together.
+func SortHostnames(hosts []Hostname) {
+	slices.Sort(hosts)
+}
+
+// UniqueHostnames returns the given hostnames with any duplicates removed,
+// preserving the order of the first occurrence of each, which matters for
+// ordered lists such as mirror lists.
+//
+// The hostnames must already be normalized, such as by [ForComparison], so
+// that equivalent hostnames are recognized as duplicates. The given slice is
+// not modified.
+func UniqueHostnames(hosts []Hostname) []Hostname {
+	seen := make(map[Hostname]struct{}, len(hosts))
+	ret := make([]Hostname, 0, len(hosts))
+	for _, host := range hosts {
+		if _, exists := seen[host]; exists {
+			continue
+		}
+		seen[host] = struct{}{}
+		ret = append(ret, host)
+	}
+	return ret
+}
+
+// Hostnames is a set of hostnames, which must be normalized, such as by
+// [ForComparison], before they are added so that equivalent hostnames are
+// treated as the same element.
+//
+// A nil Hostnames is an empty set that cannot be added to. Use
+// [NewHostnames] to create a set.
+type Hostnames map[Hostname]struct{}
+
+// NewHostnames returns a set containing the given hostnames.
+func NewHostnames(hosts ...Hostname) Hostnames {
+	ret := make(Hostnames, len(hosts))
+	for _, host := range hosts {
+		ret.Add(host)
+	}
+	return ret
+}
+
+// Add adds the given hostname to the set, if it isn't already present.
+func (s Hostnames) Add(host Hostname) {
+	s[host] = struct{}{}
+}
+
+// Contains returns true if the given hostname is in the set.
+func (s Hostnames) Contains(host Hostname) bool {
+	_, exists := s[host]
+	return exists
+}
+
+// Union returns a new set containing all of the hostnames that are in either
+// the receiver or the given set.
+func (s Hostnames) Union(other Hostnames) Hostnames {
+	ret := make(Hostnames, len(s)+len(other))
+	maps.Copy(ret, s)
+	maps.Copy(ret, other)
+	return ret
+}
+
+// Sorted returns the hostnames in the set in the same order as
+// [SortHostnames].
+func (s Hostnames) Sorted() []Hostname {
+	return slices.Sorted(maps.Keys(s))
+}
@@ -0,0 +1,99 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package svchost
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestNormalizeAll(t *testing.T) {
+	inputs := []string{"Example.COM", "bad host", "example.com:443", "", "café.fr"}
+	got, errs := NormalizeAll(inputs)
+	want := []Hostname{"example.com", "", "example.com", "", "xn--caf-dma.fr"}
+	if !slices.Equal(got, want) {
+		t.Errorf("wrong result %q; want %q", got, want)
+	}
+	if len(errs) != 2 {
+		t.Fatalf("wrong number of errors %d; want 2\n%q", len(errs), errs)
+	}
+	for i, wantGiven := range []string{"bad host", ""} {
+		var hostErr *InvalidHostnameError
+		if !errors.As(errs[i], &hostErr) {
+			t.Errorf("error %d is %T; want *InvalidHostnameError", i, errs[i])
+			continue
+		}
+		if hostErr.Given != wantGiven {
+			t.Errorf("error %d is for %q; want %q", i, hostErr.Given, wantGiven)
+		}
+	}
+
+	if _, errs := NormalizeAll([]string{"example.com"}); errs != nil {
+		t.Errorf("unexpected errors for valid input: %q", errs)
+	}
+}
+
+func TestMustForComparison(t *testing.T) {
+	if got, want := MustForComparison("Example.COM"), Hostname("example.com"); got != want {
+		t.Errorf("wrong result %q; want %q", got, want)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("no panic for invalid hostname")
+		}
+	}()
+	MustForComparison("bad host")
+}
+
+func TestSortHostnames(t *testing.T) {
+	hosts := []Hostname{"registry.example.com", "example.com:8443", "example.com", "a.example.net"}
+	SortHostnames(hosts)
+	want := []Hostname{"a.example.net", "example.com", "example.com:8443", "registry.example.com"}
+	if !slices.Equal(hosts, want) {
+		t.Errorf("wrong result %q; want %q", hosts, want)
+	}
+}
+
+func TestUniqueHostnames(t *testing.T) {
+	hosts := []Hostname{"b.example.com", "a.example.com", "b.example.com", "c.example.com", "a.example.com"}
+	got := UniqueHostnames(hosts)
+	want := []Hostname{"b.example.com", "a.example.com", "c.example.com"}
+	if !slices.Equal(got, want) {
+		t.Errorf("wrong result %q; want %q", got, want)
+	}
+	if len(hosts) != 5 {
+		t.Errorf("given slice was modified")
+	}
+}
+
+func TestHostnames(t *testing.T) {
+	a := NewHostnames("example.com", "registry.example.com")
+	b := NewHostnames("example.net", "example.com")
+
+	if !a.Contains("example.com") {
+		t.Error("set does not contain example.com")
+	}
+	if a.Contains("example.net") {
+		t.Error("set unexpectedly contains example.net")
+	}
+	var empty Hostnames
+	if empty.Contains("example.com") {
+		t.Error("nil set unexpectedly contains example.com")
+	}
+
+	got := a.Union(b).Sorted()
+	want := []Hostname{"example.com", "example.net", "registry.example.com"}
+	if !slices.Equal(got, want) {
+		t.Errorf("wrong union %q; want %q", got, want)
+	}
+	if len(a) != 2 || len(b) != 2 {
+		t.Error("union modified its operands")
+	}
+
+	a.Add("example.org")
+	if !a.Contains("example.org") {
+		t.Error("set does not contain added example.org")
+	}
+}
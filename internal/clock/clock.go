@@ -0,0 +1,103 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+// Package clock contains the abstraction over the passage of time that other
+// packages in this module use for caching, expiry, and retry delays, so that
+// tests can control time instead of waiting for it to pass.
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the current time and waits for time to pass.
+//
+// Implementations must be safe for concurrent use.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// After returns a channel that receives the current time once at least
+	// the given duration has passed, in the same way as [time.After].
+	After(d time.Duration) <-chan time.Time
+}
+
+// Real is the [Clock] that uses the system clock.
+var Real Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// Fake is a [Clock] whose time changes only when [Fake.Advance] is called.
+type Fake struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []fakeWaiter
+}
+
+type fakeWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+var _ Clock = (*Fake)(nil)
+
+// NewFake returns a [Fake] clock whose current time is the given time.
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+// Now implements [Clock].
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// After implements [Clock]. The channel receives only once the clock has
+// been advanced by at least the given duration.
+func (f *Fake) After(d time.Duration) <-chan time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- f.now
+		return ch
+	}
+	f.waiters = append(f.waiters, fakeWaiter{at: f.now.Add(d), ch: ch})
+	return ch
+}
+
+// Advance moves the clock forward by the given duration, notifying any
+// callers of After whose duration has then passed.
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+	remain := f.waiters[:0]
+	for _, w := range f.waiters {
+		if w.at.After(f.now) {
+			remain = append(remain, w)
+			continue
+		}
+		w.ch <- f.now
+	}
+	f.waiters = remain
+}
+
+// Waiters returns the number of calls to After that are still waiting for
+// the clock to advance, so that tests can wait until some other goroutine
+// has started waiting before advancing the clock.
+func (f *Fake) Waiters() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.waiters)
+}
@@ -0,0 +1,47 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFake(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewFake(start)
+
+	short := c.After(time.Second)
+	long := c.After(time.Minute)
+	if got, want := c.Waiters(), 2; got != want {
+		t.Fatalf("wrong number of waiters %d; want %d", got, want)
+	}
+
+	c.Advance(2 * time.Second)
+	if got, want := c.Now(), start.Add(2*time.Second); !got.Equal(want) {
+		t.Errorf("wrong time %s; want %s", got, want)
+	}
+	select {
+	case got := <-short:
+		if want := start.Add(2 * time.Second); !got.Equal(want) {
+			t.Errorf("wrong time from After %s; want %s", got, want)
+		}
+	default:
+		t.Error("short wait did not complete")
+	}
+	select {
+	case <-long:
+		t.Error("long wait completed too early")
+	default:
+	}
+	if got, want := c.Waiters(), 1; got != want {
+		t.Errorf("wrong number of waiters %d; want %d", got, want)
+	}
+
+	select {
+	case <-c.After(0):
+	default:
+		t.Error("zero wait did not complete immediately")
+	}
+}
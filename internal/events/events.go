@@ -0,0 +1,61 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+// Package events contains a minimal publish/subscribe helper used by other
+// packages in this module to notify callers about changes to their state.
+package events
+
+import (
+	"slices"
+	"sync"
+)
+
+// Bus delivers published events of type E to each of its subscribers.
+//
+// The zero value of Bus is ready to use, and a Bus is safe for concurrent
+// use. A Bus must not be copied after first use.
+type Bus[E any] struct {
+	mu     sync.Mutex
+	subs   []subscriber[E]
+	nextID int
+}
+
+type subscriber[E any] struct {
+	id int
+	fn func(E)
+}
+
+// Subscribe registers a function to be called for each event published
+// after it returns, and returns a function that cancels the subscription.
+//
+// Subscribers are called synchronously by [Bus.Publish], in the order they
+// subscribed, so they should return promptly.
+func (b *Bus[E]) Subscribe(fn func(E)) (cancel func()) {
+	b.mu.Lock()
+	id := b.nextID
+	b.nextID++
+	b.subs = append(b.subs, subscriber[E]{id: id, fn: fn})
+	b.mu.Unlock()
+
+	return func() {
+		b.mu.Lock()
+		b.subs = slices.DeleteFunc(b.subs, func(s subscriber[E]) bool {
+			return s.id == id
+		})
+		b.mu.Unlock()
+	}
+}
+
+// Publish calls each current subscriber with the given event.
+//
+// The bus is not locked while subscribers run, so subscribers may
+// themselves subscribe, cancel, or publish further events.
+func (b *Bus[E]) Publish(event E) {
+	b.mu.Lock()
+	subs := slices.Clone(b.subs)
+	b.mu.Unlock()
+
+	for _, s := range subs {
+		s.fn(event)
+	}
+}
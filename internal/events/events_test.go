@@ -0,0 +1,29 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package events
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestBus(t *testing.T) {
+	var bus Bus[string]
+	var got []string
+
+	cancelA := bus.Subscribe(func(e string) {
+		got = append(got, "a:"+e)
+	})
+	bus.Subscribe(func(e string) {
+		got = append(got, "b:"+e)
+	})
+	bus.Publish("one")
+	cancelA()
+	bus.Publish("two")
+
+	want := []string{"a:one", "b:one", "b:two"}
+	if !slices.Equal(got, want) {
+		t.Errorf("wrong events\ngot:  %q\nwant: %q", got, want)
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+// Package filelock implements advisory locking of files, to coordinate
+// updates to files that are shared between processes.
+package filelock
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+)
+
+// pollInterval is how long Lock waits before trying again to acquire a lock
+// that is held by another process.
+const pollInterval = 50 * time.Millisecond
+
+// Lock acquires an exclusive advisory lock on the file at the given path,
+// creating it if necessary, waiting until the lock is available or the
+// given context is cancelled.
+//
+// The returned function releases the lock. The locked file should be
+// dedicated to locking, rather than being the file whose updates are being
+// coordinated, so that the latter can be replaced atomically by renaming.
+//
+// On platforms where locking is not supported, Lock succeeds immediately
+// without actually locking anything.
+func Lock(ctx context.Context, path string) (unlock func() error, err error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		locked, err := tryLock(f)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		if locked {
+			return func() error {
+				return errors.Join(unlockFile(f), f.Close())
+			}, nil
+		}
+		select {
+		case <-ctx.Done():
+			f.Close()
+			return nil, ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+}
@@ -0,0 +1,44 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd || windows
+
+package filelock
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.lock")
+
+	unlock, err := Lock(t.Context(), path)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// A second attempt must wait for the first lock to be released, so it
+	// fails when its context expires.
+	ctx, cancel := context.WithTimeout(t.Context(), 2*pollInterval)
+	defer cancel()
+	if _, err := Lock(ctx, path); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("wrong error %v; want context.DeadlineExceeded", err)
+	}
+
+	if err := unlock(); err != nil {
+		t.Fatalf("unexpected error unlocking: %s", err)
+	}
+	ctx, cancel = context.WithTimeout(t.Context(), time.Second)
+	defer cancel()
+	unlock, err = Lock(ctx, path)
+	if err != nil {
+		t.Fatalf("unexpected error after unlock: %s", err)
+	}
+	if err := unlock(); err != nil {
+		t.Fatalf("unexpected error unlocking: %s", err)
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+// Package fips centralizes the decisions about which cryptographic
+// algorithms and settings are acceptable when this module is operating in
+// FIPS mode.
+//
+// Any code in this module that constructs TLS configurations or uses
+// cryptographic primitives directly must consult this package so that the
+// restrictions are applied consistently.
+package fips
+
+import (
+	"crypto/fips140"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+)
+
+// Enabled returns true if FIPS mode should be used, either because the
+// caller explicitly requested it or because the Go runtime is itself
+// operating in FIPS 140-3 mode, such as when built with GOFIPS140 or run
+// with GODEBUG=fips140=on.
+func Enabled(requested bool) bool {
+	return requested || fips140.Enabled()
+}
+
+// approvedCipherSuites are the TLS 1.2 cipher suites that use only
+// FIPS-approved algorithms for key exchange, encryption, and integrity.
+//
+// The TLS 1.3 cipher suites are not configurable in crypto/tls, but when
+// the Go runtime is in FIPS 140-3 mode it restricts those itself.
+var approvedCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+}
+
+// approvedCurves are the elliptic curves approved for key exchange.
+var approvedCurves = []tls.CurveID{
+	tls.CurveP256,
+	tls.CurveP384,
+	tls.CurveP521,
+}
+
+// RestrictTLSConfig returns a copy of the given TLS configuration, which
+// may be nil, modified to allow only FIPS-approved protocol versions,
+// cipher suites, and key exchange curves.
+func RestrictTLSConfig(cfg *tls.Config) *tls.Config {
+	var ret *tls.Config
+	if cfg != nil {
+		ret = cfg.Clone()
+	} else {
+		ret = &tls.Config{}
+	}
+	if ret.MinVersion < tls.VersionTLS12 {
+		ret.MinVersion = tls.VersionTLS12
+	}
+	ret.CipherSuites = approvedCipherSuites
+	ret.CurvePreferences = approvedCurves
+	return ret
+}
+
+// CheckTLSConfig returns an error if the given TLS configuration, which
+// may be nil, allows settings that are not acceptable in FIPS mode.
+//
+// A nil configuration is acceptable, because the Go runtime's defaults are
+// FIPS-compliant when the runtime is itself in FIPS 140-3 mode.
+func CheckTLSConfig(cfg *tls.Config) error {
+	if cfg == nil {
+		return nil
+	}
+	var errs []error
+	if cfg.InsecureSkipVerify {
+		errs = append(errs, errors.New("TLS certificate verification must not be disabled in FIPS mode"))
+	}
+	if cfg.MinVersion != 0 && cfg.MinVersion < tls.VersionTLS12 {
+		errs = append(errs, errors.New("TLS versions earlier than 1.2 are not allowed in FIPS mode"))
+	}
+	for _, id := range cfg.CipherSuites {
+		if !slices.Contains(approvedCipherSuites, id) {
+			errs = append(errs, fmt.Errorf("TLS cipher suite %s is not allowed in FIPS mode", tls.CipherSuiteName(id)))
+		}
+	}
+	for _, id := range cfg.CurvePreferences {
+		if !slices.Contains(approvedCurves, id) {
+			errs = append(errs, fmt.Errorf("TLS key exchange curve %s is not allowed in FIPS mode", id))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// CheckHTTPClient returns an error if the given HTTP client uses a TLS
+// configuration that is not acceptable in FIPS mode.
+//
+// Only clients whose transport is an [*http.Transport] can be checked. Other
+// transports are assumed to be compliant, since they are outside of this
+// module's control.
+func CheckHTTPClient(client *http.Client) error {
+	tr, ok := client.Transport.(*http.Transport)
+	if !ok {
+		return nil
+	}
+	return CheckTLSConfig(tr.TLSClientConfig)
+}
@@ -0,0 +1,70 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package fips
+
+import (
+	"crypto/tls"
+	"net/http"
+	"testing"
+)
+
+func TestRestrictTLSConfig(t *testing.T) {
+	given := &tls.Config{
+		MinVersion: tls.VersionTLS10,
+		ServerName: "example.com",
+	}
+	got := RestrictTLSConfig(given)
+	if err := CheckTLSConfig(got); err != nil {
+		t.Errorf("restricted configuration is not compliant: %s", err)
+	}
+	if got.ServerName != "example.com" {
+		t.Error("restricted configuration did not preserve other settings")
+	}
+	if given.MinVersion != tls.VersionTLS10 {
+		t.Error("RestrictTLSConfig modified its argument")
+	}
+
+	if err := CheckTLSConfig(RestrictTLSConfig(nil)); err != nil {
+		t.Errorf("restricted default configuration is not compliant: %s", err)
+	}
+}
+
+func TestCheckTLSConfig(t *testing.T) {
+	tests := map[string]struct {
+		cfg     *tls.Config
+		wantErr bool
+	}{
+		"nil":           {nil, false},
+		"defaults":      {&tls.Config{}, false},
+		"TLS 1.2":       {&tls.Config{MinVersion: tls.VersionTLS12}, false},
+		"TLS 1.1":       {&tls.Config{MinVersion: tls.VersionTLS11}, true},
+		"no verify":     {&tls.Config{InsecureSkipVerify: true}, true},
+		"approved":      {&tls.Config{CipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256}}, false},
+		"not approved":  {&tls.Config{CipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256}}, true},
+		"X25519":        {&tls.Config{CurvePreferences: []tls.CurveID{tls.X25519}}, true},
+		"approved only": {&tls.Config{CurvePreferences: []tls.CurveID{tls.CurveP384}}, false},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := CheckTLSConfig(test.cfg)
+			if gotErr := err != nil; gotErr != test.wantErr {
+				t.Errorf("wrong result %v; want error %t", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckHTTPClient(t *testing.T) {
+	insecure := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+	if err := CheckHTTPClient(insecure); err == nil {
+		t.Error("unexpected success for insecure client; want error")
+	}
+	if err := CheckHTTPClient(&http.Client{}); err != nil {
+		t.Errorf("unexpected error for default client: %s", err)
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package transport
+
+import (
+	"net/http"
+
+	"github.com/opentofu/svchost"
+	"github.com/opentofu/svchost/internal/fips"
+)
+
+// NewRoundTripper is like [New], except that it also honors
+// [Config.HostTLSConfigs], which requires routing requests between multiple
+// transports and so can't be represented by a single [http.Transport].
+func NewRoundTripper(cfg *Config) http.RoundTripper {
+	base := New(cfg)
+	if len(cfg.HostTLSConfigs) == 0 {
+		return base
+	}
+	ret := &hostTLSTransport{
+		base:  base,
+		hosts: make(map[svchost.Hostname]*http.Transport, len(cfg.HostTLSConfigs)),
+	}
+	for host, tlsConfig := range cfg.HostTLSConfigs {
+		tr := base.Clone()
+		tr.TLSClientConfig = tlsConfig.Clone()
+		if len(tr.TLSClientConfig.Certificates) == 0 && tr.TLSClientConfig.GetClientCertificate == nil {
+			tr.TLSClientConfig.Certificates = cfg.ClientCertificates
+		}
+		if fips.Enabled(cfg.FIPS) {
+			tr.TLSClientConfig = fips.RestrictTLSConfig(tr.TLSClientConfig)
+		}
+		ret.hosts[host] = tr
+	}
+	return ret
+}
+
+// hostTLSTransport is an [http.RoundTripper] that sends requests to hosts
+// with their own TLS configuration through a separate transport for each,
+// so that connections to each host use the right configuration even when
+// made through a proxy.
+type hostTLSTransport struct {
+	base  *http.Transport
+	hosts map[svchost.Hostname]*http.Transport
+}
+
+// RoundTrip implements [http.RoundTripper].
+func (t *hostTLSTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if host, err := svchost.ForComparisonAllowIP(req.URL.Host); err == nil {
+		if tr, ok := t.hosts[host]; ok {
+			return tr.RoundTrip(req)
+		}
+	}
+	return t.base.RoundTrip(req)
+}
+
+// CloseIdleConnections closes the idle connections of all of the underlying
+// transports, as expected by [http.Client.CloseIdleConnections].
+func (t *hostTLSTransport) CloseIdleConnections() {
+	t.base.CloseIdleConnections()
+	for _, tr := range t.hosts {
+		tr.CloseIdleConnections()
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// ErrPrivateAddress is the error wrapped by connection failures caused by
+// [Config.DenyPrivateAddresses].
+var ErrPrivateAddress = errors.New("connections to private network addresses are not allowed")
+
+// privateAddressDialer returns a function suitable for use as
+// [http.Transport.DialContext] that refuses to connect to private addresses
+// other than those covered by the given prefixes.
+//
+// The check happens after the hostname has been resolved, immediately before
+// connecting, so that it applies to the address actually used even if the
+// DNS response changes between requests.
+func privateAddressDialer(allowed []netip.Prefix) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialer := &net.Dialer{
+		// These match the settings used by http.DefaultTransport.
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control: func(network, address string, c syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return fmt.Errorf("invalid address %q: %w", address, err)
+			}
+			return checkAddress(addrPort.Addr(), allowed)
+		},
+	}
+	return dialer.DialContext
+}
+
+// sharedAddressSpace is the range reserved for carrier-grade NAT by RFC 6598,
+// which is not covered by [netip.Addr.IsPrivate] but is just as unroutable
+// from the public internet.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// checkAddress returns an error wrapping [ErrPrivateAddress] if the given
+// address is loopback, link-local, private, shared (carrier-grade NAT), or
+// unspecified, unless it belongs to one of the given allowed prefixes.
+func checkAddress(addr netip.Addr, allowed []netip.Prefix) error {
+	addr = addr.Unmap()
+	for _, prefix := range allowed {
+		if prefix.Contains(addr) {
+			return nil
+		}
+	}
+	if addr.IsLoopback() || addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsPrivate() || addr.IsUnspecified() ||
+		sharedAddressSpace.Contains(addr) {
+		return fmt.Errorf("%w: %s", ErrPrivateAddress, addr)
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package transport
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/opentofu/svchost"
+)
+
+// hostProxyFunc returns a proxy function, suitable for [http.Transport.Proxy],
+// that selects the proxy for a request from the given per-host proxies when
+// the request's hostname is present, or uses the given fallback otherwise.
+//
+// A nil URL in the proxies map means that requests to that host are made
+// directly, without a proxy.
+func hostProxyFunc(proxies map[svchost.Hostname]*url.URL, fallback func(*http.Request) (*url.URL, error)) func(*http.Request) (*url.URL, error) {
+	return func(req *http.Request) (*url.URL, error) {
+		if host, err := svchost.ForComparisonAllowIP(req.URL.Host); err == nil {
+			if proxyURL, ok := proxies[host]; ok {
+				return proxyURL, nil
+			}
+		}
+		if fallback == nil {
+			return nil, nil
+		}
+		return fallback(req)
+	}
+}
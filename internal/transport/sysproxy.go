@@ -0,0 +1,230 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package transport
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"sync"
+)
+
+// SystemProxy returns a function suitable for use as [Config.Proxy] which
+// selects a proxy based on the operating system's proxy settings, for
+// platforms where those are stored outside of the environment.
+//
+// The conventional proxy environment variables take priority when they are
+// set, so that this can be used as a drop-in replacement for
+// [http.ProxyFromEnvironment]. On platforms without a separate system proxy
+// configuration, this behaves exactly like [http.ProxyFromEnvironment].
+//
+// Only statically-configured proxies are supported: the ProxyServer and
+// ProxyOverride registry values on Windows, and the HTTP and HTTPS proxies
+// and exceptions list on macOS. Proxy auto-configuration, whether by a PAC
+// script or by WPAD auto-discovery, is ignored because it would require
+// executing arbitrary JavaScript code, so requests are sent directly when
+// the system is configured only for auto-configuration.
+func SystemProxy() func(*http.Request) (*url.URL, error) {
+	return func(req *http.Request) (*url.URL, error) {
+		if proxyEnvironmentSet() {
+			return http.ProxyFromEnvironment(req)
+		}
+		settings := loadSystemProxySettings()
+		if settings == nil {
+			return nil, nil
+		}
+		return settings.proxyFor(req.URL), nil
+	}
+}
+
+// loadSystemProxySettings returns the system proxy settings, loading them
+// only on first call since on some platforms that involves running an
+// external program.
+var loadSystemProxySettings = sync.OnceValue(func() *systemProxySettings {
+	// readSystemProxySettings is implemented separately for each platform.
+	settings, err := readSystemProxySettings()
+	if err != nil {
+		// If we can't read the settings then we'll just behave as if there
+		// are none, which is consistent with how we behaved before we
+		// supported system proxy settings at all.
+		return nil
+	}
+	return settings
+})
+
+func proxyEnvironmentSet() bool {
+	for _, name := range []string{"HTTPS_PROXY", "https_proxy", "HTTP_PROXY", "http_proxy"} {
+		if os.Getenv(name) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// systemProxySettings is a platform-agnostic representation of the subset
+// of system proxy settings that we support.
+type systemProxySettings struct {
+	httpProxy  *url.URL
+	httpsProxy *url.URL
+
+	// bypass are glob patterns or CIDR prefixes matching hosts that should
+	// be accessed directly. bypassLocal means that hostnames without any
+	// dots should also be accessed directly.
+	bypass      []string
+	bypassLocal bool
+}
+
+func (s *systemProxySettings) proxyFor(u *url.URL) *url.URL {
+	host := u.Hostname()
+	if s.bypassLocal && !strings.Contains(host, ".") && !strings.Contains(host, ":") {
+		return nil
+	}
+	for _, pattern := range s.bypass {
+		if matchProxyBypass(pattern, host) {
+			return nil
+		}
+	}
+	if u.Scheme == "https" {
+		return s.httpsProxy
+	}
+	return s.httpProxy
+}
+
+func matchProxyBypass(pattern, host string) bool {
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+	host = strings.ToLower(host)
+	if prefix, err := parseBypassPrefix(pattern); err == nil {
+		addr, err := netip.ParseAddr(host)
+		return err == nil && prefix.Contains(addr)
+	}
+	matched, err := path.Match(pattern, host)
+	return err == nil && matched
+}
+
+// parseBypassPrefix parses a CIDR prefix as used in proxy bypass lists,
+// which may use a shorthand that omits trailing zero octets, such as
+// "169.254/16".
+func parseBypassPrefix(s string) (netip.Prefix, error) {
+	addrStr, bits, ok := strings.Cut(s, "/")
+	if ok && !strings.Contains(addrStr, ":") {
+		for strings.Count(addrStr, ".") < 3 {
+			addrStr += ".0"
+		}
+		s = addrStr + "/" + bits
+	}
+	return netip.ParsePrefix(s)
+}
+
+// parseScutilProxy parses the output of the macOS "scutil --proxy" command.
+func parseScutilProxy(output string) *systemProxySettings {
+	values := map[string]string{}
+	var exceptions []string
+	inExceptions := false
+	sc := bufio.NewScanner(strings.NewReader(output))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if inExceptions {
+			if line == "}" {
+				inExceptions = false
+				continue
+			}
+			if _, v, ok := strings.Cut(line, " : "); ok {
+				exceptions = append(exceptions, strings.TrimSpace(v))
+			}
+			continue
+		}
+		k, v, ok := strings.Cut(line, " : ")
+		if !ok {
+			continue
+		}
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+		if k == "ExceptionsList" {
+			inExceptions = true
+			continue
+		}
+		values[k] = v
+	}
+
+	proxyURL := func(prefix string) *url.URL {
+		if values[prefix+"Enable"] != "1" || values[prefix+"Proxy"] == "" {
+			return nil
+		}
+		host := values[prefix+"Proxy"]
+		if port := values[prefix+"Port"]; port != "" {
+			host = net.JoinHostPort(host, port)
+		}
+		return &url.URL{Scheme: "http", Host: host}
+	}
+	return &systemProxySettings{
+		httpProxy:   proxyURL("HTTP"),
+		httpsProxy:  proxyURL("HTTPS"),
+		bypass:      exceptions,
+		bypassLocal: values["ExcludeSimpleHostnames"] == "1",
+	}
+}
+
+// windowsProxyValues are the values in the Internet Settings registry key
+// that contains the Windows proxy settings.
+type windowsProxyValues struct {
+	ProxyEnable   uint64
+	ProxyServer   string
+	ProxyOverride string
+}
+
+// windowsProxySettings interprets the given Windows proxy settings.
+func windowsProxySettings(values windowsProxyValues) *systemProxySettings {
+	ret := &systemProxySettings{}
+	if values.ProxyEnable == 0 || values.ProxyServer == "" {
+		return ret
+	}
+
+	// ProxyServer is either a single host:port used for all protocols, or
+	// a semicolon-separated list of protocol=host:port pairs.
+	server := values.ProxyServer
+	if !strings.Contains(server, "=") {
+		u := windowsProxyURL(server)
+		ret.httpProxy, ret.httpsProxy = u, u
+	} else {
+		for part := range strings.SplitSeq(server, ";") {
+			proto, addr, ok := strings.Cut(part, "=")
+			if !ok {
+				continue
+			}
+			switch strings.ToLower(proto) {
+			case "http":
+				ret.httpProxy = windowsProxyURL(addr)
+			case "https":
+				ret.httpsProxy = windowsProxyURL(addr)
+			}
+		}
+	}
+
+	for pattern := range strings.SplitSeq(values.ProxyOverride, ";") {
+		switch pattern = strings.TrimSpace(pattern); pattern {
+		case "":
+			// ignore
+		case "<local>":
+			ret.bypassLocal = true
+		default:
+			ret.bypass = append(ret.bypass, pattern)
+		}
+	}
+	return ret
+}
+
+func windowsProxyURL(addr string) *url.URL {
+	addr = strings.TrimSpace(addr)
+	if addr == "" {
+		return nil
+	}
+	if u, err := url.Parse(addr); err == nil && u.Scheme != "" && u.Host != "" {
+		return u
+	}
+	return &url.URL{Scheme: "http", Host: addr}
+}
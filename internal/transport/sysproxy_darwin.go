@@ -0,0 +1,21 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build darwin
+
+package transport
+
+import (
+	"os/exec"
+)
+
+// readSystemProxySettings reads the proxy settings from the macOS
+// SystemConfiguration framework, using the scutil command to avoid
+// depending on cgo.
+func readSystemProxySettings() (*systemProxySettings, error) {
+	out, err := exec.Command("/usr/sbin/scutil", "--proxy").Output()
+	if err != nil {
+		return nil, err
+	}
+	return parseScutilProxy(string(out)), nil
+}
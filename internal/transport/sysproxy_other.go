@@ -0,0 +1,12 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build !darwin && !windows
+
+package transport
+
+// readSystemProxySettings returns no settings on platforms where the proxy
+// configuration is conventionally provided only by environment variables.
+func readSystemProxySettings() (*systemProxySettings, error) {
+	return nil, nil
+}
@@ -0,0 +1,89 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package transport
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestParseScutilProxy(t *testing.T) {
+	settings := parseScutilProxy(`<dictionary> {
+  ExceptionsList : <array> {
+    0 : *.local
+    1 : 169.254/16
+  }
+  ExcludeSimpleHostnames : 1
+  FTPPassive : 1
+  HTTPEnable : 0
+  HTTPSEnable : 1
+  HTTPSPort : 3128
+  HTTPSProxy : proxy.example.com
+  ProxyAutoConfigEnable : 0
+}
+`)
+
+	tests := map[string]string{
+		"https://registry.example.com/": "http://proxy.example.com:3128",
+		"http://registry.example.com/":  "<nil>",
+		"https://printer.local/":        "<nil>",
+		"https://169.254.1.2/":          "<nil>",
+		"https://intranet/":             "<nil>",
+	}
+	testProxySettings(t, settings, tests)
+}
+
+func TestWindowsProxySettings(t *testing.T) {
+	t.Run("single server", func(t *testing.T) {
+		settings := windowsProxySettings(windowsProxyValues{
+			ProxyEnable:   1,
+			ProxyServer:   "proxy.example.com:8080",
+			ProxyOverride: "*.corp.example;<local>;10.0.0.0/8",
+		})
+		tests := map[string]string{
+			"https://registry.example.com/": "http://proxy.example.com:8080",
+			"http://registry.example.com/":  "http://proxy.example.com:8080",
+			"https://a.corp.example/":       "<nil>",
+			"https://intranet/":             "<nil>",
+			"https://10.1.2.3/":             "<nil>",
+		}
+		testProxySettings(t, settings, tests)
+	})
+	t.Run("per protocol", func(t *testing.T) {
+		settings := windowsProxySettings(windowsProxyValues{
+			ProxyEnable: 1,
+			ProxyServer: "http=web.example.com:80;https=secure.example.com:443",
+		})
+		tests := map[string]string{
+			"https://registry.example.com/": "http://secure.example.com:443",
+			"http://registry.example.com/":  "http://web.example.com:80",
+		}
+		testProxySettings(t, settings, tests)
+	})
+	t.Run("disabled", func(t *testing.T) {
+		settings := windowsProxySettings(windowsProxyValues{
+			ProxyServer: "proxy.example.com:8080",
+		})
+		testProxySettings(t, settings, map[string]string{
+			"https://registry.example.com/": "<nil>",
+		})
+	})
+}
+
+func testProxySettings(t *testing.T, settings *systemProxySettings, tests map[string]string) {
+	t.Helper()
+	for given, want := range tests {
+		u, err := url.Parse(given)
+		if err != nil {
+			t.Fatalf("invalid test URL %q: %s", given, err)
+		}
+		got := "<nil>"
+		if proxyURL := settings.proxyFor(u); proxyURL != nil {
+			got = proxyURL.String()
+		}
+		if got != want {
+			t.Errorf("wrong proxy for %s\ngot:  %s\nwant: %s", given, got, want)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build windows
+
+package transport
+
+import (
+	"errors"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+// readSystemProxySettings reads the current user's proxy settings from the
+// Windows registry.
+func readSystemProxySettings() (*systemProxySettings, error) {
+	key, err := registry.OpenKey(registry.CURRENT_USER, `Software\Microsoft\Windows\CurrentVersion\Internet Settings`, registry.QUERY_VALUE)
+	if err != nil {
+		return nil, err
+	}
+	defer key.Close()
+
+	var values windowsProxyValues
+	if values.ProxyEnable, _, err = key.GetIntegerValue("ProxyEnable"); err != nil && !errors.Is(err, registry.ErrNotExist) {
+		return nil, err
+	}
+	if values.ProxyServer, _, err = key.GetStringValue("ProxyServer"); err != nil && !errors.Is(err, registry.ErrNotExist) {
+		return nil, err
+	}
+	if values.ProxyOverride, _, err = key.GetStringValue("ProxyOverride"); err != nil && !errors.Is(err, registry.ErrNotExist) {
+		return nil, err
+	}
+	return windowsProxySettings(values), nil
+}
@@ -0,0 +1,102 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+// Package transport contains helpers for constructing the HTTP transports
+// used by the HTTP clients that other packages in this module create on
+// behalf of their callers.
+package transport
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+
+	"github.com/opentofu/svchost"
+	"github.com/opentofu/svchost/internal/fips"
+)
+
+// Config describes the customizations to apply to a transport built by
+// [New]. The zero value of Config produces a transport equivalent to
+// [http.DefaultTransport].
+type Config struct {
+	// Proxy, if non-nil, overrides the default behavior of selecting a
+	// proxy based on the conventional environment variables.
+	Proxy func(*http.Request) (*url.URL, error)
+
+	// HostProxies, if non-empty, overrides the proxy for requests to
+	// specific hosts, taking priority over Proxy. A nil URL means that
+	// requests to that host are made without a proxy.
+	HostProxies map[svchost.Hostname]*url.URL
+
+	// FIPS, if set, restricts the transport's TLS settings to only those
+	// that are acceptable in FIPS mode, as decided by the fips package.
+	FIPS bool
+
+	// ClientCertificates, if non-empty, are offered to servers that request
+	// a TLS client certificate.
+	ClientCertificates []tls.Certificate
+
+	// HostTLSConfigs, if non-empty, overrides the TLS configuration for
+	// connections to specific hosts, such as to trust a private certificate
+	// authority for just one host. The ClientCertificates are added to any
+	// configuration that doesn't specify its own, and the FIPS restrictions
+	// apply to these configurations too.
+	//
+	// Only [NewRoundTripper] honors this field.
+	HostTLSConfigs map[svchost.Hostname]*tls.Config
+
+	// DenyPrivateAddresses, if set, causes the transport to refuse to
+	// connect to loopback, link-local, private, shared, and unspecified
+	// addresses, with an error wrapping [ErrPrivateAddress].
+	//
+	// Addresses within any of AllowedPrefixes are permitted regardless.
+	DenyPrivateAddresses bool
+	AllowedPrefixes      []netip.Prefix
+}
+
+// New returns a new transport with the standard library's default settings
+// customized by the given configuration.
+func New(cfg *Config) *http.Transport {
+	ret := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.Proxy != nil {
+		ret.Proxy = cfg.Proxy
+	}
+	if len(cfg.HostProxies) != 0 {
+		ret.Proxy = hostProxyFunc(cfg.HostProxies, ret.Proxy)
+	}
+	if len(cfg.ClientCertificates) != 0 {
+		ret.TLSClientConfig = &tls.Config{
+			Certificates: cfg.ClientCertificates,
+		}
+	}
+	if fips.Enabled(cfg.FIPS) {
+		ret.TLSClientConfig = fips.RestrictTLSConfig(ret.TLSClientConfig)
+	}
+	if cfg.DenyPrivateAddresses {
+		ret.DialContext = privateAddressDialer(cfg.AllowedPrefixes)
+	}
+	return ret
+}
+
+// SOCKS5ProxyURL returns a proxy URL, suitable for use in [Config.Proxy]
+// via [http.ProxyURL], that describes a SOCKS5 proxy at the given address
+// with optional username/password authentication.
+//
+// The net/http package implements the SOCKS5 protocol itself, so no further
+// configuration is required once the proxy URL is set.
+func SOCKS5ProxyURL(address string, auth *url.Userinfo) (*url.URL, error) {
+	if address == "" {
+		return nil, errors.New("SOCKS5 proxy address must not be empty")
+	}
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		return nil, errors.New("SOCKS5 proxy address must be in host:port form")
+	}
+	return &url.URL{
+		Scheme: "socks5",
+		Host:   address,
+		User:   auth,
+	}, nil
+}
@@ -0,0 +1,165 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package svchost
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// IsIPAddress returns true if the given hostname is an IP address literal,
+// such as "192.0.2.1" or "[2001:db8::1]", rather than a DNS name.
+func IsIPAddress(h Hostname) bool {
+	_, ok := h.IPAddress()
+	return ok
+}
+
+// IPAddress returns the IP address that the receiver represents, ignoring
+// any port number, or false if the receiver is a DNS name rather than an
+// IP address literal.
+//
+// An IPv6 address includes its zone, if any.
+func (h Hostname) IPAddress() (netip.Addr, bool) {
+	host, _ := h.split()
+	if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+		addr, err := netip.ParseAddr(host[1 : len(host)-1])
+		return addr, err == nil && addr.Is6()
+	}
+	addr, err := netip.ParseAddr(host)
+	return addr, err == nil && addr.Is4()
+}
+
+// ForAddrPort returns the normalized hostname for the given IP address and
+// port, such as the address of a network listener, with an IPv6 address
+// enclosed in brackets. The port is omitted if it is zero or [DefaultPort].
+//
+// An IPv4 address mapped into IPv6 is kept in its IPv6 form, as it would be
+// by [ForComparisonAllowIP].
+func ForAddrPort(addrPort netip.AddrPort) Hostname {
+	ret := formatIPLiteral(addrPort.Addr())
+	if port := addrPort.Port(); port != 0 && port != DefaultPort {
+		ret += ":" + strconv.Itoa(int(port))
+	}
+	return Hostname(ret)
+}
+
+// ForComparisonAllowIP is like [ForComparison] except that it also accepts
+// IPv6 address literals, enclosed in brackets as in URLs and optionally
+// including a zone, such as "[fe80::1%eth0]:8443". The zone may be
+// introduced either by "%", or by "%25" as in URLs.
+//
+// Use this to interpret the host portion of a URL, or hostnames given by
+// users in deployments that address hosts by IP address.
+func ForComparisonAllowIP(given string) (Hostname, error) {
+	return forComparison(given, ipLiteralsAllowed)
+}
+
+// ForComparisonDNSOnly is like [ForComparison] except that it rejects all IP
+// address literals, with or without a port number, so that only DNS
+// hostnames are accepted.
+func ForComparisonDNSOnly(given string) (Hostname, error) {
+	return forComparison(given, ipLiteralsDenied)
+}
+
+// ipLiteralPolicy decides which IP address literals forComparison accepts.
+type ipLiteralPolicy int
+
+const (
+	// ipLiteralsLegacy accepts only IPv4 address literals, as ForComparison
+	// always has.
+	ipLiteralsLegacy ipLiteralPolicy = iota
+	ipLiteralsAllowed
+	ipLiteralsDenied
+)
+
+// forComparison is the implementation of [ForComparison] and its variants,
+// which differ only in which IP address literals they accept.
+func forComparison(given string, policy ipLiteralPolicy) (Hostname, error) {
+	addr, portPortion, ok, err := parseIPLiteral(given)
+	if err != nil {
+		return Hostname(""), err
+	}
+	if !ok {
+		return forComparisonDNS(given)
+	}
+	switch {
+	case policy == ipLiteralsDenied:
+		return Hostname(""), invalidIPAddress(given, fmt.Sprintf("IP address %s is not allowed; a DNS hostname is required", addr))
+	case policy == ipLiteralsLegacy && addr.Is6():
+		return Hostname(""), invalidIPAddress(given, fmt.Sprintf("IPv6 address %s is not allowed; use a DNS hostname or an IPv4 address", addr))
+	}
+	return Hostname(formatIPLiteral(addr) + portPortion), nil
+}
+
+// parseIPLiteral attempts to interpret the given user-specified hostname as
+// an IP address literal with an optional port number.
+//
+// If the given string is not an IP address literal at all then the result
+// has ok set to false and a nil error, so that the caller can try to treat
+// it as a DNS name instead. If it is an IP address literal but is invalid in
+// some way then the error is non-nil.
+//
+// IPv6 addresses must be enclosed in brackets, as in URLs. The zone of an
+// IPv6 address can be introduced either by "%", or by "%25" as in URLs.
+func parseIPLiteral(given string) (addr netip.Addr, portPortion string, ok bool, err error) {
+	if strings.HasPrefix(given, "[") {
+		end := strings.Index(given, "]")
+		if end == -1 {
+			return netip.Addr{}, "", false, invalidIPAddress(given, "IPv6 address is missing its closing bracket")
+		}
+		inner, rest := given[1:end], given[end+1:]
+		if rest != "" && !strings.HasPrefix(rest, ":") {
+			return netip.Addr{}, "", false, invalidPort(given, end+1, errors.New("unexpected characters after IPv6 address; only a port number is allowed"))
+		}
+		if before, zone, found := strings.Cut(inner, "%25"); found {
+			inner = before + "%" + zone
+		}
+		addr, err := netip.ParseAddr(inner)
+		if err != nil || !addr.Is6() {
+			return netip.Addr{}, "", false, invalidIPAddress(given, fmt.Sprintf("invalid IPv6 address %q", inner))
+		}
+		if strings.HasSuffix(inner, "%") {
+			return netip.Addr{}, "", false, invalidIPAddress(given, fmt.Sprintf("invalid IPv6 address %q: empty zone", inner))
+		}
+		portPortion, err := normalizePortPortion(rest)
+		if err != nil {
+			return netip.Addr{}, "", false, invalidPort(given, end+1, err)
+		}
+		return addr, portPortion, true, nil
+	}
+
+	// An unbracketed IPv6 address is ambiguous with a port number, so we
+	// reject it with a hint about the correct syntax.
+	if strings.Count(given, ":") > 1 {
+		if addr, err := netip.ParseAddr(given); err == nil && addr.Is6() {
+			return netip.Addr{}, "", false, invalidIPAddress(given, fmt.Sprintf("IPv6 address must be enclosed in brackets, as in [%s]", addr))
+		}
+	}
+
+	host, portPortion := given, ""
+	if colonPos := strings.Index(given, ":"); colonPos != -1 {
+		host, portPortion = given[:colonPos], given[colonPos:]
+	}
+	addr, err = netip.ParseAddr(host)
+	if err != nil || !addr.Is4() {
+		return netip.Addr{}, "", false, nil
+	}
+	portPortion, err = normalizePortPortion(portPortion)
+	if err != nil {
+		return netip.Addr{}, "", false, invalidPort(given, len(host), err)
+	}
+	return addr, portPortion, true, nil
+}
+
+// formatIPLiteral returns the normalized form of the given address for use
+// as the host portion of a [Hostname].
+func formatIPLiteral(addr netip.Addr) string {
+	if addr.Is6() {
+		return "[" + addr.String() + "]"
+	}
+	return addr.String()
+}
@@ -0,0 +1,131 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package svchost
+
+import (
+	"net/netip"
+	"net/url"
+	"testing"
+)
+
+func TestForComparisonIP(t *testing.T) {
+	tests := []struct {
+		Input     string
+		Func      func(string) (Hostname, error)
+		Want      string
+		WantIsIP  bool
+		WantError string
+	}{
+		{"example.com", ForComparisonDNSOnly, "example.com", false, ``},
+		{"192.0.2.1", ForComparisonAllowIP, "192.0.2.1", true, ``},
+		{"192.0.2.1:443", ForComparisonAllowIP, "192.0.2.1", true, ``},
+		{"192.0.2.1:0080", ForComparisonAllowIP, "192.0.2.1:80", true, ``},
+		{"192.0.2.1", ForComparisonDNSOnly, "", false, `IP address 192.0.2.1 is not allowed; a DNS hostname is required`},
+		{"[2001:DB8:0::1]", ForComparisonAllowIP, "[2001:db8::1]", true, ``},
+		{"[2001:db8::1]:8443", ForComparisonAllowIP, "[2001:db8::1]:8443", true, ``},
+		{"[2001:db8::1]:443", ForComparisonAllowIP, "[2001:db8::1]", true, ``},
+		{"[::ffff:192.0.2.1]", ForComparisonAllowIP, "[::ffff:192.0.2.1]", true, ``},
+		{"[fe80::1%eth0]", ForComparisonAllowIP, "[fe80::1%eth0]", true, ``},
+		{"[fe80::1%25eth0]:8080", ForComparisonAllowIP, "[fe80::1%eth0]:8080", true, ``},
+		{"[2001:db8::1]", ForComparisonDNSOnly, "", false, `IP address 2001:db8::1 is not allowed; a DNS hostname is required`},
+		{"192.0.2.1:8443", ForComparison, "192.0.2.1:8443", true, ``},
+		{"[2001:db8::1]", ForComparison, "", false, `IPv6 address 2001:db8::1 is not allowed; use a DNS hostname or an IPv4 address`},
+		{"2001:db8::1", ForComparisonAllowIP, "", false, `IPv6 address must be enclosed in brackets, as in [2001:db8::1]`},
+		{"[2001:db8::1", ForComparisonAllowIP, "", false, `IPv6 address is missing its closing bracket`},
+		{"[2001:db8::1]x", ForComparisonAllowIP, "", false, `unexpected characters after IPv6 address; only a port number is allowed`},
+		{"[192.0.2.1]", ForComparisonAllowIP, "", false, `invalid IPv6 address "192.0.2.1"`},
+		{"[fe80::1%]", ForComparisonAllowIP, "", false, `invalid IPv6 address "fe80::1%"`},
+		{"[2001:db8::1]:99999", ForComparisonAllowIP, "", false, `port number is greater than 65535`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Input, func(t *testing.T) {
+			got, err := test.Func(test.Input)
+			var errStr string
+			if err != nil {
+				errStr = err.Error()
+			}
+			if errStr != test.WantError {
+				t.Errorf("unexpected error\ngot error:  %s\nwant error: %s", err, test.WantError)
+			}
+			if string(got) != test.Want {
+				t.Errorf("wrong result\ninput: %s\ngot:   %s\nwant:  %s", test.Input, got, test.Want)
+			}
+			if got := IsIPAddress(got); got != test.WantIsIP {
+				t.Errorf("wrong IsIPAddress result %t; want %t", got, test.WantIsIP)
+			}
+			if err == nil {
+				// The normalized form must survive a round-trip through
+				// the other functions that interpret hostnames.
+				if again, err := ForComparisonAllowIP(string(got)); err != nil || again != got {
+					t.Errorf("normalized form %q does not round-trip: got %q, %v", got, again, err)
+				}
+				if display := got.ForDisplay(); display != string(got) {
+					t.Errorf("wrong display form %q; want %q", display, got)
+				}
+				// It must also survive being used as the host of a URL,
+				// which escapes an IPv6 zone.
+				u, err := url.Parse((&url.URL{Scheme: "https", Host: got.String(), Path: "/"}).String())
+				if err != nil {
+					t.Fatalf("normalized form %q is not valid in a URL: %s", got, err)
+				}
+				if again, err := ForComparisonAllowIP(u.Host); err != nil || again != got {
+					t.Errorf("normalized form %q does not round-trip through a URL: got %q, %v", got, again, err)
+				}
+			}
+		})
+	}
+}
+
+func TestHostnameIPAddress(t *testing.T) {
+	tests := []struct {
+		Input Hostname
+		Want  string
+	}{
+		{"example.com", ""},
+		{"example.com:8080", ""},
+		{"192.0.2.1:8080", "192.0.2.1"},
+		{"[::1]:8080", "::1"},
+		{"[fe80::1%eth0]", "fe80::1%eth0"},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.Input), func(t *testing.T) {
+			addr, ok := test.Input.IPAddress()
+			if ok != (test.Want != "") {
+				t.Fatalf("wrong ok %t", ok)
+			}
+			if ok && addr.String() != test.Want {
+				t.Errorf("wrong address %s; want %s", addr, test.Want)
+			}
+		})
+	}
+}
+
+func TestForAddrPort(t *testing.T) {
+	tests := []struct {
+		Input string
+		Want  Hostname
+	}{
+		{"192.0.2.1:8080", "192.0.2.1:8080"},
+		{"192.0.2.1:443", "192.0.2.1"},
+		{"192.0.2.1:0", "192.0.2.1"},
+		{"[::1]:8443", "[::1]:8443"},
+		{"[2001:DB8:0::1]:443", "[2001:db8::1]"},
+		{"[fe80::1%eth0]:8080", "[fe80::1%eth0]:8080"},
+		{"[::ffff:192.0.2.1]:80", "[::ffff:192.0.2.1]:80"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Input, func(t *testing.T) {
+			got := ForAddrPort(netip.MustParseAddrPort(test.Input))
+			if got != test.Want {
+				t.Errorf("wrong result %q; want %q", got, test.Want)
+			}
+			if again, err := ForComparisonAllowIP(string(got)); err != nil || again != got {
+				t.Errorf("result %q is not normalized: got %q, %v", got, again, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package svchost
+
+import (
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// Labels returns the DNS labels of the receiver, without any port number,
+// in the same order as they appear in the hostname, such as
+// ["registry", "example", "com"] for "registry.example.com:8443".
+//
+// The labels are in the receiver's normalized form, so internationalized
+// labels are in their "xn--" punycode form. Use [ForDisplay] to convert a
+// label to its display form.
+//
+// The result is nil if the receiver is an IP address literal, since IP
+// addresses don't have labels.
+func (h Hostname) Labels() []string {
+	if h == "" || IsIPAddress(h) {
+		return nil
+	}
+	host, _ := h.split()
+	return strings.Split(host, ".")
+}
+
+// RegistrableDomain returns the part of the receiver that was registered
+// with a domain name registrar, without any port number, as decided by the
+// public suffix list. For example, the registrable domain of
+// "registry.corp.example.com" is "example.com", and the registrable domain
+// of "example.github.io" is itself because "github.io" is a public suffix.
+//
+// Policy code should use this rather than splitting hostnames on dots,
+// because the number of labels in a public suffix varies, as in "co.uk".
+//
+// The public suffix list is embedded in this module, and so may be out of
+// date for recently-added suffixes. The result is false if the receiver is
+// an IP address literal, or has no registrable domain because it is itself
+// a public suffix or has only one label, as with "localhost".
+func (h Hostname) RegistrableDomain() (Hostname, bool) {
+	if h == "" || IsIPAddress(h) {
+		return "", false
+	}
+	host, _ := h.split()
+	domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return "", false
+	}
+	return Hostname(domain), true
+}
@@ -0,0 +1,65 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package svchost
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestHostnameLabels(t *testing.T) {
+	tests := []struct {
+		Input string
+		Want  []string
+	}{
+		{"example.com", []string{"example", "com"}},
+		{"Registry.Example.com:8443", []string{"registry", "example", "com"}},
+		{"ÉXAMPLE.com", []string{"xn--xample-9ua", "com"}},
+		{"localhost", []string{"localhost"}},
+		{"192.0.2.1", nil},
+		{"[2001:db8::1]:8443", nil},
+	}
+	for _, test := range tests {
+		t.Run(test.Input, func(t *testing.T) {
+			host, err := ForComparisonAllowIP(test.Input)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if diff := cmp.Diff(test.Want, host.Labels()); diff != "" {
+				t.Errorf("wrong labels\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestHostnameRegistrableDomain(t *testing.T) {
+	tests := []struct {
+		Input  string
+		Want   Hostname
+		WantOK bool
+	}{
+		{"registry.corp.example.com", "example.com", true},
+		{"example.com:8443", "example.com", true},
+		{"registry.example.co.uk", "example.co.uk", true},
+		{"example.github.io", "example.github.io", true},
+		{"registry.ÉXAMPLE.com", "xn--xample-9ua.com", true},
+		{"co.uk", "", false},
+		{"localhost", "", false},
+		{"192.0.2.1", "", false},
+		{"[2001:db8::1]", "", false},
+	}
+	for _, test := range tests {
+		t.Run(test.Input, func(t *testing.T) {
+			host, err := ForComparisonAllowIP(test.Input)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			got, ok := host.RegistrableDomain()
+			if got != test.Want || ok != test.WantOK {
+				t.Errorf("wrong result %q, %t; want %q, %t", got, ok, test.Want, test.WantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+// Package otelmetrics publishes OpenTelemetry metrics describing the
+// behavior of the disco and svcauth packages, such as how long service
+// discovery requests take and how often credentials are found.
+//
+// This package is in a separate module from the packages it instruments so
+// that callers who don't use OpenTelemetry don't depend on its metrics API.
+//
+// The API of this package is currently experimental and primarily intended for
+// use in OpenTofu CLI itself, rather than external consumption. We may make
+// breaking changes to the API before blessing this module with a stable version
+// number, so third-party callers should be prepared to make adjustments if they
+// choose to use this library before then.
+package otelmetrics
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/opentofu/svchost"
+	"github.com/opentofu/svchost/disco"
+	"github.com/opentofu/svchost/svcauth"
+)
+
+// The names of the metrics published by this package. These names are
+// part of the public API and will not change without a major version bump.
+const (
+	// DiscoveryDurationMetric is a histogram of the time taken by network
+	// service discovery requests, in seconds, with attributes
+	// "server.address" and "outcome" (either "success" or "failure").
+	DiscoveryDurationMetric = "svchost.discovery.duration"
+
+	// DiscoveryCacheHitsMetric counts the discovery requests that were served
+	// from a cache of earlier results, with attribute "server.address".
+	DiscoveryCacheHitsMetric = "svchost.discovery.cache_hits"
+
+	// CredentialsLookupsMetric counts credentials lookups, with attributes
+	// "svchost.credentials.source" (the name given to [Metrics.CredentialsSource])
+	// and "outcome" (one of "found", "not_found", or "error").
+	CredentialsLookupsMetric = "svchost.credentials.lookups"
+)
+
+// instrumentationName is the name of the OpenTelemetry meter that all of
+// the metrics in this package belong to.
+const instrumentationName = "github.com/opentofu/svchost/otelmetrics"
+
+// Metrics is a set of OpenTelemetry instruments that can be attached to
+// the objects from the disco and svcauth packages.
+type Metrics struct {
+	discoveryDuration  metric.Float64Histogram
+	discoveryCacheHits metric.Int64Counter
+	credentialsLookups metric.Int64Counter
+}
+
+// New creates the instruments for all of the metrics described in this
+// package using the given meter provider.
+func New(provider metric.MeterProvider) (*Metrics, error) {
+	meter := provider.Meter(instrumentationName)
+
+	var errs []error
+	discoveryDuration, err := meter.Float64Histogram(
+		DiscoveryDurationMetric,
+		metric.WithDescription("Duration of network service discovery requests."),
+		metric.WithUnit("s"),
+	)
+	errs = append(errs, err)
+	discoveryCacheHits, err := meter.Int64Counter(
+		DiscoveryCacheHitsMetric,
+		metric.WithDescription("Number of service discovery requests served from cache."),
+	)
+	errs = append(errs, err)
+	credentialsLookups, err := meter.Int64Counter(
+		CredentialsLookupsMetric,
+		metric.WithDescription("Number of credentials lookups, by source and outcome."),
+	)
+	errs = append(errs, err)
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	return &Metrics{
+		discoveryDuration:  discoveryDuration,
+		discoveryCacheHits: discoveryCacheHits,
+		credentialsLookups: credentialsLookups,
+	}, nil
+}
+
+// DiscoTrace returns a [disco.DiscoTrace] that records discovery metrics.
+// Use [disco.ContextWithDiscoTrace] to associate it with the context used
+// for discovery requests.
+func (m *Metrics) DiscoTrace() *disco.DiscoTrace {
+	return &disco.DiscoTrace{
+		DiscoveryStart: func(ctx context.Context, host svchost.Hostname) context.Context {
+			return context.WithValue(ctx, discoveryStartKey, time.Now())
+		},
+		DiscoverySuccess: func(ctx context.Context, host svchost.Hostname) {
+			m.recordDiscovery(ctx, host, "success")
+		},
+		DiscoveryFailure: func(ctx context.Context, host svchost.Hostname, err error) {
+			m.recordDiscovery(ctx, host, "failure")
+		},
+		DiscoveryHostCached: func(ctx context.Context, host svchost.Hostname) {
+			m.discoveryCacheHits.Add(ctx, 1, metric.WithAttributes(
+				attribute.String("server.address", host.String()),
+			))
+		},
+	}
+}
+
+func (m *Metrics) recordDiscovery(ctx context.Context, host svchost.Hostname, outcome string) {
+	start, ok := ctx.Value(discoveryStartKey).(time.Time)
+	if !ok {
+		return // should not happen, since DiscoveryStart always sets this
+	}
+	m.discoveryDuration.Record(ctx, time.Since(start).Seconds(), metric.WithAttributes(
+		attribute.String("server.address", host.String()),
+		attribute.String("outcome", outcome),
+	))
+}
+
+// CredentialsSource wraps the given credentials source so that its lookups
+// are counted, using the given name to distinguish it from other sources.
+//
+// The result also implements [svcauth.CredentialsStore] in the same way
+// as [svcauth.NewCredentialsSource].
+func (m *Metrics) CredentialsSource(name string, source svcauth.CredentialsSource) (svcauth.CredentialsSource, error) {
+	record := func(ctx context.Context, outcome string) {
+		m.credentialsLookups.Add(ctx, 1, metric.WithAttributes(
+			attribute.String("svchost.credentials.source", name),
+			attribute.String("outcome", outcome),
+		))
+	}
+	return svcauth.NewCredentialsSource(source, svcauth.WithTrace(&svcauth.CredentialsTrace{
+		LookupSuccess: func(ctx context.Context, host svchost.Hostname, found bool) {
+			if found {
+				record(ctx, "found")
+			} else {
+				record(ctx, "not_found")
+			}
+		},
+		LookupFailure: func(ctx context.Context, host svchost.Hostname, err error) {
+			record(ctx, "error")
+		},
+	}))
+}
+
+type contextKey string
+
+const discoveryStartKey = contextKey("discoveryStart")
@@ -0,0 +1,88 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package otelmetrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/opentofu/svchost"
+	"github.com/opentofu/svchost/disco"
+	"github.com/opentofu/svchost/svcauth"
+)
+
+func TestMetrics(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	metrics, err := New(provider)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+	hostname := svchost.Hostname(strings.TrimPrefix(server.URL, "https://"))
+
+	creds, err := metrics.CredentialsSource("static", svcauth.StaticCredentialsSource(map[svchost.Hostname]svcauth.HostCredentials{
+		hostname: svcauth.HostCredentialsToken("abc123"),
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	d := disco.New(disco.WithHTTPClient(server.Client()), disco.WithCredentials(creds))
+	ctx := disco.ContextWithDiscoTrace(t.Context(), metrics.DiscoTrace())
+
+	// The first call makes a network request, and the second is served
+	// from the cache.
+	for range 2 {
+		if _, err := d.Discover(ctx, hostname); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	if _, err := creds.ForHost(ctx, "other.example.com"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(t.Context(), &rm); err != nil {
+		t.Fatalf("failed to collect metrics: %s", err)
+	}
+	got := map[string]metricdata.Aggregation{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			got[m.Name] = m.Data
+		}
+	}
+
+	if hist, ok := got[DiscoveryDurationMetric].(metricdata.Histogram[float64]); !ok {
+		t.Errorf("missing %s histogram", DiscoveryDurationMetric)
+	} else if len(hist.DataPoints) != 1 || hist.DataPoints[0].Count != 1 {
+		t.Errorf("wrong %s data points: %#v", DiscoveryDurationMetric, hist.DataPoints)
+	}
+	if sum, ok := got[DiscoveryCacheHitsMetric].(metricdata.Sum[int64]); !ok {
+		t.Errorf("missing %s counter", DiscoveryCacheHitsMetric)
+	} else if len(sum.DataPoints) != 1 || sum.DataPoints[0].Value != 1 {
+		t.Errorf("wrong %s data points: %#v", DiscoveryCacheHitsMetric, sum.DataPoints)
+	}
+	if sum, ok := got[CredentialsLookupsMetric].(metricdata.Sum[int64]); !ok {
+		t.Errorf("missing %s counter", CredentialsLookupsMetric)
+	} else {
+		outcomes := map[string]int64{}
+		for _, dp := range sum.DataPoints {
+			outcome, _ := dp.Attributes.Value("outcome")
+			outcomes[outcome.AsString()] += dp.Value
+		}
+		if outcomes["found"] != 1 || outcomes["not_found"] != 1 {
+			t.Errorf("wrong %s outcomes: %#v", CredentialsLookupsMetric, outcomes)
+		}
+	}
+}
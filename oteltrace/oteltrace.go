@@ -0,0 +1,147 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+// Package oteltrace records OpenTelemetry trace spans describing the
+// behavior of the disco package, such as a span for each service discovery
+// request and events for results served from cache.
+//
+// This package is in a separate module from the packages it instruments so
+// that callers who don't use OpenTelemetry don't depend on its tracing API.
+//
+// The API of this package is currently experimental and primarily intended for
+// use in OpenTofu CLI itself, rather than external consumption. We may make
+// breaking changes to the API before blessing this module with a stable version
+// number, so third-party callers should be prepared to make adjustments if they
+// choose to use this library before then.
+package oteltrace
+
+import (
+	"context"
+	"net/url"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/opentofu/svchost"
+	"github.com/opentofu/svchost/disco"
+)
+
+// The names of the spans and span events recorded by this package. These
+// names are part of the public API and will not change without a major
+// version bump.
+const (
+	// DiscoverySpan is the name of the span covering each network service
+	// discovery request, with attribute "server.address" and, once the
+	// request completes, "http.response.status_code",
+	// "svchost.discovery.redirects", and "svchost.discovery.response_size".
+	DiscoverySpan = "svchost.discovery"
+
+	// CredentialsLookupSpan is the name of the span covering each
+	// credentials lookup for a discovery request, with attributes
+	// "server.address" and "svchost.credentials.found".
+	CredentialsLookupSpan = "svchost.credentials.lookup"
+
+	// CacheHitEvent is added to the span in the context passed to
+	// [disco.Disco.Discover] when the result is served from a cache of
+	// earlier results, with attribute "server.address".
+	CacheHitEvent = "svchost.discovery.cache_hit"
+
+	// NegativeCacheHitEvent is like CacheHitEvent, but for a failure served
+	// from the cache configured by [disco.WithNegativeCacheTTL].
+	NegativeCacheHitEvent = "svchost.discovery.negative_cache_hit"
+
+	// RedirectEvent is added to the discovery span for each redirect that
+	// was followed, with attribute "url.full" giving the new location.
+	RedirectEvent = "svchost.discovery.redirect"
+
+	// RedirectCredentialsRemovedEvent is added to the discovery span when
+	// credentials were removed from a request that was redirected to
+	// another host, with attribute "url.full" giving the new location.
+	RedirectCredentialsRemovedEvent = "svchost.discovery.redirect_credentials_removed"
+
+	// ServicesChangedEvent is added to the span in the context passed to
+	// [disco.Disco.Refresh] when the services of a host changed, with
+	// attribute "svchost.discovery.changes" giving the number of changes.
+	ServicesChangedEvent = "svchost.discovery.services_changed"
+)
+
+// instrumentationName is the name of the OpenTelemetry tracer that all of
+// the spans in this package belong to.
+const instrumentationName = "github.com/opentofu/svchost/oteltrace"
+
+// DiscoTrace returns a [disco.DiscoTrace] that records spans and span events
+// using the given tracer provider. Use [disco.ContextWithDiscoTrace] to
+// associate it with the context used for discovery requests.
+//
+// Failures are recorded on the relevant spans using [trace.Span.RecordError],
+// and set the span status to [codes.Error].
+func DiscoTrace(provider trace.TracerProvider) *disco.DiscoTrace {
+	tracer := provider.Tracer(instrumentationName)
+	addEvent := func(ctx context.Context, name string, attrs ...attribute.KeyValue) {
+		trace.SpanFromContext(ctx).AddEvent(name, trace.WithAttributes(attrs...))
+	}
+
+	return &disco.DiscoTrace{
+		DiscoveryStart: func(ctx context.Context, host svchost.Hostname) context.Context {
+			ctx, _ = tracer.Start(ctx, DiscoverySpan,
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(serverAddress(host)),
+			)
+			return ctx
+		},
+		DiscoveryFailure: func(ctx context.Context, host svchost.Hostname, err error) {
+			span := trace.SpanFromContext(ctx)
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		},
+		// DiscoveryStats is always called after either DiscoverySuccess or
+		// DiscoveryFailure, so we end the span here so that it can include
+		// the stats.
+		DiscoveryStats: func(ctx context.Context, host svchost.Hostname, stats disco.DiscoveryStats) {
+			span := trace.SpanFromContext(ctx)
+			if stats.StatusCode != 0 {
+				span.SetAttributes(attribute.Int("http.response.status_code", stats.StatusCode))
+			}
+			span.SetAttributes(
+				attribute.Int("svchost.discovery.redirects", stats.Redirects),
+				attribute.Int("svchost.discovery.response_size", stats.ResponseSize),
+			)
+			span.End()
+		},
+		DiscoveryHostCached: func(ctx context.Context, host svchost.Hostname) {
+			addEvent(ctx, CacheHitEvent, serverAddress(host))
+		},
+		DiscoveryNegativeCached: func(ctx context.Context, host svchost.Hostname, err error) {
+			addEvent(ctx, NegativeCacheHitEvent, serverAddress(host), attribute.String("error.message", err.Error()))
+		},
+		ServicesChanged: func(ctx context.Context, host svchost.Hostname, changes []disco.ServiceChange) {
+			addEvent(ctx, ServicesChangedEvent, serverAddress(host), attribute.Int("svchost.discovery.changes", len(changes)))
+		},
+		CredentialsLookupStart: func(ctx context.Context, host svchost.Hostname) context.Context {
+			ctx, _ = tracer.Start(ctx, CredentialsLookupSpan, trace.WithAttributes(serverAddress(host)))
+			return ctx
+		},
+		CredentialsLookupSuccess: func(ctx context.Context, host svchost.Hostname, found bool) {
+			span := trace.SpanFromContext(ctx)
+			span.SetAttributes(attribute.Bool("svchost.credentials.found", found))
+			span.End()
+		},
+		CredentialsLookupFailure: func(ctx context.Context, host svchost.Hostname, err error) {
+			span := trace.SpanFromContext(ctx)
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			span.End()
+		},
+		RedirectFollowed: func(ctx context.Context, host svchost.Hostname, from, to *url.URL) {
+			addEvent(ctx, RedirectEvent, attribute.String("url.full", to.Redacted()))
+		},
+		RedirectCredentialsRemoved: func(ctx context.Context, host svchost.Hostname, to *url.URL) {
+			addEvent(ctx, RedirectCredentialsRemovedEvent, attribute.String("url.full", to.Redacted()))
+		},
+	}
+}
+
+func serverAddress(host svchost.Hostname) attribute.KeyValue {
+	return attribute.String("server.address", host.String())
+}
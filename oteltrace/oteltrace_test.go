@@ -0,0 +1,102 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package oteltrace
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/opentofu/svchost"
+	"github.com/opentofu/svchost/disco"
+	"github.com/opentofu/svchost/svcauth"
+)
+
+func TestDiscoTrace(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/.well-known/terraform.json" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+	hostname := svchost.Hostname(strings.TrimPrefix(server.URL, "https://"))
+
+	creds := svcauth.StaticCredentialsSource(map[svchost.Hostname]svcauth.HostCredentials{
+		hostname: svcauth.HostCredentialsToken("abc123"),
+	})
+	d := disco.New(disco.WithHTTPClient(server.Client()), disco.WithCredentials(creds))
+
+	// The first call makes a network request, and the second is served
+	// from the cache and so adds an event to the parent span.
+	ctx, parent := provider.Tracer("test").Start(t.Context(), "parent")
+	ctx = disco.ContextWithDiscoTrace(ctx, DiscoTrace(provider))
+	for range 2 {
+		if _, err := d.Discover(ctx, hostname); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	if err := d.SetDiscoveryPath(hostname, "/broken"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := d.Discover(ctx, hostname); err == nil {
+		t.Fatal("unexpected success; want error")
+	}
+	parent.End()
+
+	var discoverySpans, lookupSpans []sdktrace.ReadOnlySpan
+	var parentSpan sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		switch span.Name() {
+		case DiscoverySpan:
+			discoverySpans = append(discoverySpans, span)
+		case CredentialsLookupSpan:
+			lookupSpans = append(lookupSpans, span)
+		case "parent":
+			parentSpan = span
+		}
+	}
+
+	if len(discoverySpans) != 2 {
+		t.Fatalf("wrong number of %s spans %d; want 2", DiscoverySpan, len(discoverySpans))
+	}
+	if got := discoverySpans[0].Status().Code; got != codes.Unset {
+		t.Errorf("wrong status %s for successful discovery", got)
+	}
+	if got := discoverySpans[1].Status().Code; got != codes.Error {
+		t.Errorf("wrong status %s for failed discovery; want Error", got)
+	}
+	for _, span := range discoverySpans {
+		if span.Parent().SpanID() != parentSpan.SpanContext().SpanID() {
+			t.Errorf("%s span is not a child of the parent span", DiscoverySpan)
+		}
+	}
+
+	if len(lookupSpans) != 2 {
+		t.Fatalf("wrong number of %s spans %d; want 2", CredentialsLookupSpan, len(lookupSpans))
+	}
+	if lookupSpans[0].Parent().SpanID() != discoverySpans[0].SpanContext().SpanID() {
+		t.Errorf("%s span is not a child of the %s span", CredentialsLookupSpan, DiscoverySpan)
+	}
+
+	var cacheHits int
+	for _, event := range parentSpan.Events() {
+		if event.Name == CacheHitEvent {
+			cacheHits++
+		}
+	}
+	if cacheHits != 1 {
+		t.Errorf("wrong number of %s events %d; want 1", CacheHitEvent, cacheHits)
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package svchost
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Pattern is a specialized name for string that indicates that the string is
+// a hostname pattern that has been converted to the storage and comparison
+// form, as returned by [ParsePattern].
+//
+// A pattern is either a single hostname, which matches only that hostname,
+// or a hostname whose first label is "*", such as "*.example.com", which
+// matches any hostname with at least one additional label in that position,
+// such as "registry.example.com" or "a.b.example.com", but not
+// "example.com" itself. The pattern "*" alone matches all hostnames.
+//
+// Port numbers are significant: a pattern that doesn't specify a port matches
+// only hostnames that also don't specify a port, in the same way as for
+// comparison of [Hostname] values.
+type Pattern string
+
+// wildcardLabel is the label that represents a wildcard in a [Pattern].
+const wildcardLabel = "*"
+
+// ParsePattern takes a user-specified hostname pattern and returns a
+// normalized form of it suitable for matching, applying the same
+// normalization to the non-wildcard portion as [ForComparison].
+func ParsePattern(given string) (Pattern, error) {
+	if given == wildcardLabel {
+		return Pattern(wildcardLabel), nil
+	}
+	rest, isWildcard := strings.CutPrefix(given, wildcardLabel+".")
+	if strings.Contains(rest, wildcardLabel) {
+		return Pattern(""), fmt.Errorf("wildcard is allowed only as the entire first label of a hostname pattern")
+	}
+	host, err := ForComparison(rest)
+	if err != nil {
+		return Pattern(""), err
+	}
+	if isWildcard {
+		return Pattern(wildcardLabel + "." + string(host)), nil
+	}
+	return Pattern(host), nil
+}
+
+// MatchHostname returns true if the given hostname matches the receiving
+// pattern.
+func (p Pattern) MatchHostname(host Hostname) bool {
+	if p == wildcardLabel {
+		return true
+	}
+	suffix, isWildcard := strings.CutPrefix(string(p), wildcardLabel)
+	if !isWildcard {
+		return host == Hostname(p)
+	}
+
+	// suffix now starts with the period that separates the wildcard from
+	// the rest of the pattern.
+	suffixHost, suffixPort := Hostname(suffix).split()
+	hostName, hostPort := host.split()
+	return hostPort == suffixPort &&
+		len(hostName) > len(suffixHost) &&
+		strings.HasSuffix(hostName, suffixHost)
+}
+
+func (p Pattern) String() string {
+	return string(p)
+}
+
+func (p Pattern) GoString() string {
+	return fmt.Sprintf("svchost.Pattern(%q)", string(p))
+}
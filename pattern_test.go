@@ -0,0 +1,78 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package svchost
+
+import (
+	"testing"
+)
+
+func TestParsePattern(t *testing.T) {
+	tests := []struct {
+		Input   string
+		Want    Pattern
+		WantErr string
+	}{
+		{"*", "*", ""},
+		{"example.com", "example.com", ""},
+		{"*.example.com", "*.example.com", ""},
+		{"*.EXAMPLE.com:443", "*.example.com", ""},
+		{"*.example.com:8443", "*.example.com:8443", ""},
+		{"*.bücher.example.com", "*.xn--bcher-kva.example.com", ""},
+		{"foo.*.example.com", "", "wildcard is allowed only as the entire first label of a hostname pattern"},
+		{"foo*.example.com", "", "wildcard is allowed only as the entire first label of a hostname pattern"},
+		{"*.*.example.com", "", "wildcard is allowed only as the entire first label of a hostname pattern"},
+		{"*.", "", "empty string is not a valid hostname"},
+		{"*.xn--bcher-kva.example.com", "", `hostname label "xn--bcher-kva" specified in punycode format; service hostnames must be given in unicode`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Input, func(t *testing.T) {
+			got, err := ParsePattern(test.Input)
+			if test.WantErr != "" {
+				if err == nil {
+					t.Fatalf("unexpected success; want error %q", test.WantErr)
+				}
+				if err.Error() != test.WantErr {
+					t.Errorf("wrong error\ngot:  %s\nwant: %s", err, test.WantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got != test.Want {
+				t.Errorf("wrong result %#v; want %#v", got, test.Want)
+			}
+		})
+	}
+}
+
+func TestPatternMatchHostname(t *testing.T) {
+	tests := []struct {
+		Pattern Pattern
+		Host    Hostname
+		Want    bool
+	}{
+		{"*", "example.com", true},
+		{"*", "example.com:8443", true},
+		{"example.com", "example.com", true},
+		{"example.com", "www.example.com", false},
+		{"example.com", "example.com:8443", false},
+		{"*.example.com", "registry.example.com", true},
+		{"*.example.com", "a.b.example.com", true},
+		{"*.example.com", "example.com", false},
+		{"*.example.com", "badexample.com", false},
+		{"*.example.com", "registry.example.com:8443", false},
+		{"*.example.com:8443", "registry.example.com:8443", true},
+		{"*.example.com:8443", "registry.example.com", false},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.Pattern)+" "+string(test.Host), func(t *testing.T) {
+			if got := test.Pattern.MatchHostname(test.Host); got != test.Want {
+				t.Errorf("wrong result %t; want %t", got, test.Want)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package svcauth
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/opentofu/svchost"
+)
+
+// AuditHooks allows a caller to be notified about each operation performed
+// through a credentials source, so that they can keep an audit log of when
+// and for which hosts credentials are used.
+//
+// Use [WithAuditHooks] to associate hooks with a credentials source
+// constructed by [NewCredentialsSource] or [NewCredentialsStore].
+//
+// Unlike [CredentialsTrace], which is intended for diagnostics, the hooks
+// are also called for operations that store or forget credentials, and
+// describe what kind of credentials were involved. The credentials
+// themselves are never reported.
+//
+// All of the function-typed fields may either be left as nil or set to
+// a function with the specified signature. If nil then the call for the
+// corresponding operation will be skipped. The functions are called
+// synchronously after each operation completes, and so they should return
+// promptly.
+type AuditHooks struct {
+	// ForHost is called after each credentials lookup, with an outcome of
+	// AuditFound, AuditNotFound, or AuditFailed.
+	ForHost func(ctx context.Context, record AuditRecord)
+
+	// StoreForHost is called after each attempt to store credentials, with
+	// an outcome of either AuditStored or AuditFailed.
+	StoreForHost func(ctx context.Context, record AuditRecord)
+
+	// ForgetForHost is called after each attempt to forget credentials,
+	// with an outcome of either AuditForgotten or AuditFailed.
+	ForgetForHost func(ctx context.Context, record AuditRecord)
+}
+
+// AuditOutcome describes the result of an operation reported to
+// [AuditHooks].
+type AuditOutcome string
+
+const (
+	// AuditFound means that credentials were available for the host.
+	AuditFound = AuditOutcome("found")
+
+	// AuditNotFound means that no credentials were available for the host.
+	AuditNotFound = AuditOutcome("not_found")
+
+	// AuditStored means that new credentials were stored for the host.
+	AuditStored = AuditOutcome("stored")
+
+	// AuditForgotten means that any stored credentials for the host were
+	// discarded.
+	AuditForgotten = AuditOutcome("forgotten")
+
+	// AuditFailed means that the operation failed with an error.
+	AuditFailed = AuditOutcome("failed")
+)
+
+// AuditRecord describes a single operation reported to [AuditHooks].
+type AuditRecord struct {
+	// Host is the hostname that the operation was for.
+	Host svchost.Hostname
+
+	// Outcome is the result of the operation.
+	Outcome AuditOutcome
+
+	// Kind describes the kind of credentials that were found or stored,
+	// such as "token" or "basic", as returned by [CredentialsKind]. It is
+	// empty if no credentials were involved, such as when forgetting
+	// credentials or when a lookup found none.
+	Kind string
+
+	// Err is the error that caused the operation to fail, if Outcome is
+	// AuditFailed.
+	Err error
+}
+
+// CredentialsKind returns a short description of the kind of the given
+// credentials, which may be either [HostCredentials] or [NewHostCredentials],
+// that is safe to include in logs because it doesn't include any part of
+// the credentials themselves.
+//
+// The result is "token", "basic", "oauth", "bound_token",
+// "client_certificate", "aws_sigv4", or "combined" for the credentials types
+// defined in this package, the Go type name for other types, or an empty
+// string if creds is nil.
+func CredentialsKind(creds any) string {
+	switch creds.(type) {
+	case nil:
+		return ""
+	case HostCredentialsToken:
+		return "token"
+	case HostCredentialsBasic:
+		return "basic"
+	case HostCredentialsOAuthToken, HostCredentialsOAuthTokens:
+		return "oauth"
+	case HostCredentialsBoundToken:
+		return "bound_token"
+	case *HostCredentialsClientCert:
+		return "client_certificate"
+	case HostCredentialsAWSSigV4:
+		return "aws_sigv4"
+	case CombinedHostCredentials:
+		return "combined"
+	default:
+		return fmt.Sprintf("%T", creds)
+	}
+}
+
+func (h *AuditHooks) forHost(ctx context.Context, host svchost.Hostname, creds HostCredentials, err error) {
+	if h == nil || h.ForHost == nil {
+		return
+	}
+	record := AuditRecord{Host: host, Err: err}
+	switch {
+	case err != nil:
+		record.Outcome = AuditFailed
+	case creds == nil:
+		record.Outcome = AuditNotFound
+	default:
+		record.Outcome = AuditFound
+		record.Kind = CredentialsKind(creds)
+	}
+	h.ForHost(ctx, record)
+}
+
+func (h *AuditHooks) storeForHost(ctx context.Context, host svchost.Hostname, creds NewHostCredentials, err error) {
+	if h == nil || h.StoreForHost == nil {
+		return
+	}
+	record := AuditRecord{Host: host, Kind: CredentialsKind(creds), Err: err, Outcome: AuditStored}
+	if err != nil {
+		record.Outcome = AuditFailed
+	}
+	h.StoreForHost(ctx, record)
+}
+
+func (h *AuditHooks) forgetForHost(ctx context.Context, host svchost.Hostname, err error) {
+	if h == nil || h.ForgetForHost == nil {
+		return
+	}
+	record := AuditRecord{Host: host, Err: err, Outcome: AuditForgotten}
+	if err != nil {
+		record.Outcome = AuditFailed
+	}
+	h.ForgetForHost(ctx, record)
+}
@@ -0,0 +1,129 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package svcauth
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+
+	"github.com/opentofu/svchost"
+)
+
+func TestWithAuditHooks(t *testing.T) {
+	var got []AuditRecord
+	record := func(op string) func(context.Context, AuditRecord) {
+		return func(ctx context.Context, r AuditRecord) {
+			r.Kind = op + " " + r.Kind
+			got = append(got, r)
+		}
+	}
+	hooks := &AuditHooks{
+		ForHost:       record("get"),
+		StoreForHost:  record("store"),
+		ForgetForHost: record("forget"),
+	}
+	src, err := NewCredentialsSource(&mapCredentialsStore{}, WithAuditHooks(hooks))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	store := src.(CredentialsStore)
+
+	ctx := t.Context()
+	if _, err := store.ForHost(ctx, "example.com"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := store.StoreForHost(ctx, "example.com", HostCredentialsToken("abc123")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := store.ForHost(ctx, "example.com"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := store.ForgetForHost(ctx, "example.com"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := []AuditRecord{
+		{Host: "example.com", Outcome: AuditNotFound, Kind: "get "},
+		{Host: "example.com", Outcome: AuditStored, Kind: "store token"},
+		{Host: "example.com", Outcome: AuditFound, Kind: "get token"},
+		{Host: "example.com", Outcome: AuditForgotten, Kind: "forget "},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("wrong audit records\n%s", diff)
+	}
+}
+
+func TestWithAuditHooksFailure(t *testing.T) {
+	wantErr := errors.New("boom")
+	inner := credentialsSourceFunc(func(ctx context.Context, host svchost.Hostname) (HostCredentials, error) {
+		return nil, wantErr
+	})
+	var got []AuditRecord
+	record := func(ctx context.Context, r AuditRecord) {
+		got = append(got, r)
+	}
+	hooks := &AuditHooks{
+		ForHost:       record,
+		StoreForHost:  record,
+		ForgetForHost: record,
+	}
+	src, err := NewCredentialsSource(inner, WithAuditHooks(hooks))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	store := src.(CredentialsStore)
+
+	ctx := t.Context()
+	if _, err := store.ForHost(ctx, "example.com"); !errors.Is(err, wantErr) {
+		t.Fatalf("wrong error %v; want %v", err, wantErr)
+	}
+	// The inner source is not a store, so these both fail.
+	_ = store.StoreForHost(ctx, "example.com", HostCredentialsBasic{Username: "u", Password: "p"})
+	_ = store.ForgetForHost(ctx, "example.com")
+
+	want := []AuditRecord{
+		{Host: "example.com", Outcome: AuditFailed},
+		{Host: "example.com", Outcome: AuditFailed, Kind: "basic"},
+		{Host: "example.com", Outcome: AuditFailed},
+	}
+	if diff := cmp.Diff(want, got, cmpopts.IgnoreFields(AuditRecord{}, "Err")); diff != "" {
+		t.Errorf("wrong audit records\n%s", diff)
+	}
+	for i, r := range got {
+		if r.Err == nil {
+			t.Errorf("record %d has no error", i)
+		}
+	}
+}
+
+func TestWithAuditHooksNil(t *testing.T) {
+	_, err := NewCredentialsSource(&mapCredentialsStore{}, WithAuditHooks(nil))
+	if err == nil {
+		t.Fatal("unexpected success; want error")
+	}
+}
+
+func TestCredentialsKind(t *testing.T) {
+	tests := []struct {
+		creds any
+		want  string
+	}{
+		{nil, ""},
+		{HostCredentialsToken("abc"), "token"},
+		{HostCredentialsBasic{}, "basic"},
+		{HostCredentialsOAuthToken{}, "oauth"},
+		{HostCredentialsBoundToken{}, "bound_token"},
+		{&HostCredentialsClientCert{}, "client_certificate"},
+		{credentialsSourceFunc(nil), "svcauth.credentialsSourceFunc"},
+	}
+	for _, test := range tests {
+		if got := CredentialsKind(test.creds); got != test.want {
+			t.Errorf("wrong kind for %T: got %q, want %q", test.creds, got, test.want)
+		}
+	}
+}
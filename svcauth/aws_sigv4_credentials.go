@@ -0,0 +1,322 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package svcauth
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/opentofu/svchost/internal/clock"
+)
+
+// AWSCredentials are the access keys used to sign requests with
+// [HostCredentialsAWSSigV4].
+//
+// AWSCredentials also implements [AWSCredentialsProvider] by returning
+// itself, for callers that have long-lived access keys.
+type AWSCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+
+	// SessionToken is the session token that accompanies temporary
+	// credentials, or empty for long-lived credentials.
+	SessionToken string
+}
+
+// AWSCredentialsProvider provides the current access keys for signing
+// requests with [HostCredentialsAWSSigV4], so that temporary credentials
+// can be renewed before they expire.
+type AWSCredentialsProvider interface {
+	// RetrieveAWSCredentials returns the access keys to use for signing
+	// a request. It is called once for each request.
+	RetrieveAWSCredentials(ctx context.Context) (AWSCredentials, error)
+}
+
+// RetrieveAWSCredentials returns the receiver. This implements
+// [AWSCredentialsProvider].
+func (c AWSCredentials) RetrieveAWSCredentials(context.Context) (AWSCredentials, error) {
+	return c, nil
+}
+
+// AWSCredentialsProviderFunc is a function that implements
+// [AWSCredentialsProvider].
+type AWSCredentialsProviderFunc func(ctx context.Context) (AWSCredentials, error)
+
+// RetrieveAWSCredentials calls the receiver. This implements
+// [AWSCredentialsProvider].
+func (f AWSCredentialsProviderFunc) RetrieveAWSCredentials(ctx context.Context) (AWSCredentials, error) {
+	return f(ctx)
+}
+
+// EnvironmentAWSCredentials is an [AWSCredentialsProvider] that reads the
+// access keys from the conventional AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY, and AWS_SESSION_TOKEN environment variables each
+// time it is called, returning an error if either of the first two is unset.
+var EnvironmentAWSCredentials AWSCredentialsProvider = AWSCredentialsProviderFunc(func(context.Context) (AWSCredentials, error) {
+	ret := AWSCredentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if ret.AccessKeyID == "" || ret.SecretAccessKey == "" {
+		return AWSCredentials{}, errors.New("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must both be set")
+	}
+	return ret, nil
+})
+
+// HostCredentialsAWSSigV4 is a HostCredentials implementation that signs
+// each request using AWS Signature Version 4, for services fronted by AWS
+// API Gateway, S3, or other AWS services that use IAM authentication.
+//
+// These credentials can't be saved in a [CredentialsStore], since they
+// depend on a provider of access keys rather than on a fixed secret.
+type HostCredentialsAWSSigV4 struct {
+	// Region is the AWS region of the service, such as "us-east-1".
+	Region string
+
+	// Service is the name of the AWS service that authenticates requests,
+	// such as "execute-api" for API Gateway or "s3" for S3.
+	Service string
+
+	// Credentials provides the access keys to sign with.
+	Credentials AWSCredentialsProvider
+
+	// Clock provides the time used in signatures. If nil, the system clock
+	// is used.
+	Clock Clock
+}
+
+// Interface implementation assertions. Compilation will fail here if
+// HostCredentialsAWSSigV4 does not fully implement these interfaces.
+var _ HostCredentialsWithHeaders = HostCredentialsAWSSigV4{}
+
+// The names of the headers used by AWS Signature Version 4.
+const (
+	awsDateHeader          = "X-Amz-Date"
+	awsContentSHA256Header = "X-Amz-Content-Sha256"
+	awsSecurityTokenHeader = "X-Amz-Security-Token"
+)
+
+// PrepareRequest alters the given HTTP request by adding the headers that
+// carry an AWS Signature Version 4 signature, including the Authorization
+// header. The signature covers the method, URL, body, and Host header of
+// the request, along with any headers whose names start with "X-Amz-", so
+// those must not be changed afterwards.
+//
+// The request body, if any, is read in order to include its hash in the
+// signature. It is then replaced so that it can still be sent, unless the
+// request has a GetBody function that can provide another copy.
+//
+// If the access keys can't be retrieved, or the body can't be read, then
+// the request is left unsigned so that the server will reject it.
+func (c HostCredentialsAWSSigV4) PrepareRequest(req *http.Request) {
+	if c.Credentials == nil {
+		return
+	}
+	keys, err := c.Credentials.RetrieveAWSCredentials(req.Context())
+	if err != nil {
+		return
+	}
+	payloadHash, err := awsPayloadHash(req)
+	if err != nil {
+		return
+	}
+	now := clock.Real.Now()
+	if c.Clock != nil {
+		now = c.Clock.Now()
+	}
+	now = now.UTC()
+	date := now.Format("20060102")
+
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+	req.Header.Set(awsDateHeader, now.Format("20060102T150405Z"))
+	if keys.SessionToken != "" {
+		req.Header.Set(awsSecurityTokenHeader, keys.SessionToken)
+	} else {
+		req.Header.Del(awsSecurityTokenHeader)
+	}
+	if c.Service == "s3" {
+		// S3 requires the payload hash to be sent as well as signed.
+		req.Header.Set(awsContentSHA256Header, payloadHash)
+	}
+
+	signedHeaders, canonicalHeaders := awsCanonicalHeaders(req)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		awsCanonicalURI(req, c.Service != "s3"),
+		awsCanonicalQuery(req),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + c.Region + "/" + c.Service + "/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		req.Header.Get(awsDateHeader),
+		scope,
+		awsHashHex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := awsHMAC([]byte("AWS4"+keys.SecretAccessKey), date)
+	for _, part := range []string{c.Region, c.Service, "aws4_request"} {
+		key = awsHMAC(key, part)
+	}
+	signature := hex.EncodeToString(awsHMAC(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+keys.AccessKeyID+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+// CredentialHeaders returns the names of the headers set by PrepareRequest,
+// which include the session token and so must not be sent to other hosts.
+// This implements [HostCredentialsWithHeaders].
+func (c HostCredentialsAWSSigV4) CredentialHeaders() []string {
+	return []string{"Authorization", awsDateHeader, awsContentSHA256Header, awsSecurityTokenHeader}
+}
+
+// awsPayloadHash returns the hex-encoded SHA-256 hash of the body of the
+// given request, replacing the body if reading it consumed it.
+func awsPayloadHash(req *http.Request) (string, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return awsHashHex(nil), nil
+	}
+	body := req.Body
+	if req.GetBody != nil {
+		var err error
+		body, err = req.GetBody()
+		if err != nil {
+			return "", err
+		}
+	}
+	raw, err := io.ReadAll(body)
+	body.Close()
+	if err != nil {
+		return "", err
+	}
+	if req.GetBody == nil {
+		req.Body = io.NopCloser(bytes.NewReader(raw))
+	}
+	return awsHashHex(raw), nil
+}
+
+// awsCanonicalURI returns the canonical form of the path of the given
+// request. All services other than S3 require each path segment to be
+// escaped a second time, which is requested by doubleEscape.
+func awsCanonicalURI(req *http.Request, doubleEscape bool) string {
+	path := req.URL.EscapedPath()
+	if req.URL.Opaque != "" {
+		path = req.URL.Opaque
+	}
+	if path == "" {
+		return "/"
+	}
+	if doubleEscape {
+		return awsEscape(path, true)
+	}
+	return path
+}
+
+// awsCanonicalQuery returns the canonical form of the query string of the
+// given request, with the parameters sorted by name and then by value.
+func awsCanonicalQuery(req *http.Request) string {
+	var params [][2]string
+	for name, values := range req.URL.Query() {
+		for _, value := range values {
+			params = append(params, [2]string{awsEscape(name, false), awsEscape(value, false)})
+		}
+	}
+	// Sorting the encoded names and values separately matters when one
+	// name is a prefix of another, such as "a" and "a-b".
+	slices.SortFunc(params, func(a, b [2]string) int {
+		if c := strings.Compare(a[0], b[0]); c != 0 {
+			return c
+		}
+		return strings.Compare(a[1], b[1])
+	})
+	parts := make([]string, len(params))
+	for i, param := range params {
+		parts[i] = param[0] + "=" + param[1]
+	}
+	return strings.Join(parts, "&")
+}
+
+// awsCanonicalHeaders returns the semicolon-separated names of the headers
+// that are included in the signature for the given request, and the
+// canonical form of those headers.
+//
+// The signature covers the Host header and all of the "X-Amz-" headers,
+// which are enough to identify the request and are not changed by the Go
+// HTTP client when sending it.
+func awsCanonicalHeaders(req *http.Request) (signedHeaders, canonicalHeaders string) {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	values := map[string]string{"host": host}
+	for name, vals := range req.Header {
+		lower := strings.ToLower(name)
+		if !strings.HasPrefix(lower, "x-amz-") {
+			continue
+		}
+		trimmed := make([]string, len(vals))
+		for i, v := range vals {
+			trimmed[i] = strings.Join(strings.Fields(v), " ")
+		}
+		values[lower] = strings.Join(trimmed, ",")
+	}
+	names := slices.Sorted(maps.Keys(values))
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(name)
+		b.WriteByte(':')
+		b.WriteString(values[name])
+		b.WriteByte('\n')
+	}
+	return strings.Join(names, ";"), b.String()
+}
+
+// awsEscape percent-encodes every byte of the given string except the
+// unreserved characters of RFC 3986, and also slashes if keepSlash is set,
+// as required for the canonical request.
+func awsEscape(s string, keepSlash bool) string {
+	const hexDigits = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := range len(s) {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', keepSlash && c == '/':
+			b.WriteByte(c)
+		default:
+			b.WriteByte('%')
+			b.WriteByte(hexDigits[c>>4])
+			b.WriteByte(hexDigits[c&0xf])
+		}
+	}
+	return b.String()
+}
+
+func awsHashHex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func awsHMAC(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,135 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package svcauth
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/opentofu/svchost/internal/clock"
+)
+
+func TestHostCredentialsAWSSigV4(t *testing.T) {
+	// The expected signatures are from the AWS Signature Version 4 test
+	// suite, which uses these example credentials.
+	creds := HostCredentialsAWSSigV4{
+		Region:  "us-east-1",
+		Service: "service",
+		Credentials: AWSCredentials{
+			AccessKeyID:     "AKIDEXAMPLE",
+			SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+		},
+		Clock: clock.NewFake(time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)),
+	}
+
+	tests := map[string]struct {
+		url  string
+		want string
+	}{
+		"get-vanilla": {
+			"https://example.amazonaws.com/",
+			"AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		},
+		"get-vanilla-query-order-key-case": {
+			"https://example.amazonaws.com/?Param2=value2&Param1=value1",
+			"AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", test.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			creds.PrepareRequest(req)
+			if got, want := req.Header.Get("X-Amz-Date"), "20150830T123600Z"; got != want {
+				t.Errorf("wrong X-Amz-Date %q; want %q", got, want)
+			}
+			if got := req.Header.Get("Authorization"); got != test.want {
+				t.Errorf("wrong Authorization header\ngot:  %s\nwant: %s", got, test.want)
+			}
+		})
+	}
+
+	t.Run("canonical query", func(t *testing.T) {
+		req, err := http.NewRequest("GET", "https://example.com/?a-b=1&a=2&a=1&c=x+y", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := awsCanonicalQuery(req), "a=1&a=2&a-b=1&c=x%20y"; got != want {
+			t.Errorf("wrong canonical query %q; want %q", got, want)
+		}
+	})
+
+	t.Run("body and session token", func(t *testing.T) {
+		creds := creds
+		creds.Service = "s3"
+		creds.Credentials = AWSCredentials{
+			AccessKeyID:     "AKIDEXAMPLE",
+			SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+			SessionToken:    "session",
+		}
+		req, err := http.NewRequest("PUT", "https://bucket.s3.amazonaws.com/a%20b", io.NopCloser(strings.NewReader("hello")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		creds.PrepareRequest(req)
+		if got, want := req.Header.Get("X-Amz-Security-Token"), "session"; got != want {
+			t.Errorf("wrong X-Amz-Security-Token %q; want %q", got, want)
+		}
+		if got, want := req.Header.Get("X-Amz-Content-Sha256"), "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"; got != want {
+			t.Errorf("wrong X-Amz-Content-Sha256 %q; want %q", got, want)
+		}
+		if got, want := req.Header.Get("Authorization"), "SignedHeaders=host;x-amz-content-sha256;x-amz-date;x-amz-security-token,"; !strings.Contains(got, want) {
+			t.Errorf("Authorization header %q does not contain %q", got, want)
+		}
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := string(body), "hello"; got != want {
+			t.Errorf("wrong body after signing %q; want %q", got, want)
+		}
+	})
+
+	t.Run("provider error", func(t *testing.T) {
+		creds := creds
+		creds.Credentials = AWSCredentialsProviderFunc(func(context.Context) (AWSCredentials, error) {
+			return AWSCredentials{}, errors.New("expired")
+		})
+		req, err := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		creds.PrepareRequest(req)
+		if got := req.Header.Get("Authorization"); got != "" {
+			t.Errorf("unexpected Authorization header %q", got)
+		}
+	})
+}
+
+func TestEnvironmentAWSCredentials(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	if _, err := EnvironmentAWSCredentials.RetrieveAWSCredentials(t.Context()); err == nil {
+		t.Error("unexpected success with no environment variables; want error")
+	}
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_SESSION_TOKEN", "session")
+	got, err := EnvironmentAWSCredentials.RetrieveAWSCredentials(t.Context())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := AWSCredentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret", SessionToken: "session"}
+	if got != want {
+		t.Errorf("wrong credentials %#v; want %#v", got, want)
+	}
+}
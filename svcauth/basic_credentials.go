@@ -0,0 +1,46 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package svcauth
+
+import (
+	"net/http"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+// HostCredentialsBasic is a HostCredentials implementation that represents a
+// username and password, to be sent to the server using HTTP Basic
+// authentication as described in RFC 7617.
+//
+// This is intended for services fronted by reverse proxies that support only
+// Basic authentication. Prefer [HostCredentialsToken] for services that
+// support bearer tokens.
+type HostCredentialsBasic struct {
+	Username string
+	Password string
+}
+
+// Interface implementation assertions. Compilation will fail here if
+// HostCredentialsBasic does not fully implement these interfaces.
+var _ HostCredentials = HostCredentialsBasic{}
+var _ NewHostCredentials = HostCredentialsBasic{}
+
+// PrepareRequest alters the given HTTP request by setting its Authorization
+// header to the string "Basic " followed by the base64 encoding of the
+// username and password.
+func (bc HostCredentialsBasic) PrepareRequest(req *http.Request) {
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+	req.SetBasicAuth(bc.Username, bc.Password)
+}
+
+// ToStore returns a credentials object with the attributes "username" and
+// "password". This implements [NewHostCredentials].
+func (bc HostCredentialsBasic) ToStore() cty.Value {
+	return cty.ObjectVal(map[string]cty.Value{
+		"username": cty.StringVal(bc.Username),
+		"password": cty.StringVal(bc.Password),
+	})
+}
@@ -0,0 +1,80 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package svcauth
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+// CertificateBoundCredentials is implemented by [HostCredentials] that may
+// only be used by a client presenting a particular TLS client certificate,
+// such as the certificate-bound access tokens described in RFC 8705.
+//
+// The client returned by [NewAuthenticatedClient] refuses to send such
+// credentials unless it was configured using [WithClientCertificate] with
+// the matching certificate.
+type CertificateBoundCredentials interface {
+	HostCredentials
+
+	// CertificateThumbprint returns the expected client certificate's
+	// thumbprint, in the format returned by [CertificateThumbprint].
+	CertificateThumbprint() string
+}
+
+// CertificateThumbprint returns the base64url-encoded SHA-256 hash of the
+// given DER-encoded certificate, which is the format used for the "x5t#S256"
+// confirmation claim in RFC 8705.
+func CertificateThumbprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// HostCredentialsBoundToken is a HostCredentials implementation that
+// represents a "bearer token" bound to a client certificate, which is sent
+// in the same way as [HostCredentialsToken] but only over a connection
+// where the matching certificate is presented.
+type HostCredentialsBoundToken struct {
+	// AccessToken is the token to send in the Authorization header.
+	AccessToken string
+
+	// Thumbprint identifies the client certificate the token is bound to,
+	// in the format returned by [CertificateThumbprint].
+	Thumbprint string
+}
+
+// Interface implementation assertions. Compilation will fail here if
+// HostCredentialsBoundToken does not fully implement these interfaces.
+var _ CertificateBoundCredentials = HostCredentialsBoundToken{}
+var _ NewHostCredentials = HostCredentialsBoundToken{}
+
+// PrepareRequest alters the given HTTP request by setting its Authorization
+// header to the string "Bearer " followed by the encapsulated authentication
+// token.
+func (tc HostCredentialsBoundToken) PrepareRequest(req *http.Request) {
+	HostCredentialsToken(tc.AccessToken).PrepareRequest(req)
+}
+
+// Token returns the authentication token.
+func (tc HostCredentialsBoundToken) Token() string {
+	return tc.AccessToken
+}
+
+// CertificateThumbprint returns the thumbprint of the client certificate
+// that the token is bound to. This implements [CertificateBoundCredentials].
+func (tc HostCredentialsBoundToken) CertificateThumbprint() string {
+	return tc.Thumbprint
+}
+
+// ToStore returns a credentials object with the attributes "token" and
+// "cert_thumbprint". This implements [NewHostCredentials].
+func (tc HostCredentialsBoundToken) ToStore() cty.Value {
+	return cty.ObjectVal(map[string]cty.Value{
+		"token":           cty.StringVal(tc.AccessToken),
+		"cert_thumbprint": cty.StringVal(tc.Thumbprint),
+	})
+}
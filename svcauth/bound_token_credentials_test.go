@@ -0,0 +1,38 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package svcauth
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestHostCredentialsBoundToken(t *testing.T) {
+	creds := HostCredentialsBoundToken{
+		AccessToken: "foo-bar",
+		Thumbprint:  CertificateThumbprint([]byte("not really a certificate")),
+	}
+
+	{
+		req := &http.Request{}
+		creds.PrepareRequest(req)
+		authStr := req.Header.Get("authorization")
+		if got, want := authStr, "Bearer foo-bar"; got != want {
+			t.Errorf("wrong Authorization header value %q; want %q", got, want)
+		}
+	}
+
+	{
+		got := creds.ToStore()
+		want := cty.ObjectVal(map[string]cty.Value{
+			"token":           cty.StringVal("foo-bar"),
+			"cert_thumbprint": cty.StringVal("1hgiVeVznVX8l4F1nHqCPU3_PSt9F5SecIXufAzCKs8"),
+		})
+		if !want.RawEquals(got) {
+			t.Errorf("wrong storable object value\ngot:  %#v\nwant: %#v", got, want)
+		}
+	}
+}
@@ -5,28 +5,57 @@
 package svcauth
 
 import (
+	"container/list"
 	"context"
-	"fmt"
 	"sync"
+	"time"
 
 	svchost "github.com/opentofu/svchost"
+	"github.com/opentofu/svchost/internal/clock"
 )
 
 // CachingCredentialsSource creates a new credentials source that wraps another
 // and caches its results in memory, on a per-hostname basis.
 //
-// No means is provided for expiration of cached credentials, so a caching
-// credentials source should have a limited lifetime (one OpenTofu operation,
-// for example) to ensure that time-limited credentials don't expire before
-// their cache entries do.
+// Cached credentials that implement [ExpiringHostCredentials] are discarded
+// once they have expired, but other credentials are cached indefinitely, so
+// a caching credentials source should have a limited lifetime (one OpenTofu
+// operation, for example) to ensure that time-limited credentials that don't
+// report their expiry don't expire before their cache entries do. Use
+// [CachingCredentialsSourceWithTTL] instead for a longer-lived cache.
 //
 // The result also implements [CredentialsStore] by forwarding to the inner
 // source, but the store and forget methods will fail with an error if the
 // wrapped source does not also implement that interface.
 func CachingCredentialsSource(source CredentialsSource) CredentialsSource {
+	return newCachingCredentialsSource(source, 0, 0, clock.Real)
+}
+
+// CachingCredentialsSourceWithTTL is like [CachingCredentialsSource] except
+// that each cache entry is discarded once the given duration has passed since
+// it was created, or earlier if the credentials expire sooner, so that the
+// result is suitable for long-running programs.
+//
+// A ttl of zero or less means that entries don't expire, in the same way as
+// for [CachingCredentialsSource]. Use [NewCredentialsSource] with the
+// [WithCacheTTL] and [WithCacheMaxEntries] options to also limit the number
+// of cache entries.
+func CachingCredentialsSourceWithTTL(source CredentialsSource, ttl time.Duration) CredentialsSource {
+	return newCachingCredentialsSource(source, ttl, 0, clock.Real)
+}
+
+// newCachingCredentialsSource returns a caching credentials source whose
+// entries are discarded after the given ttl, if positive, and which keeps
+// at most maxEntries entries, if positive, by discarding the least recently
+// used entry when full. The given clock decides when entries expire.
+func newCachingCredentialsSource(source CredentialsSource, ttl time.Duration, maxEntries int, clk clock.Clock) *cachingCredentialsSource {
 	return &cachingCredentialsSource{
-		source: source,
-		cache:  map[svchost.Hostname]HostCredentials{},
+		source:     source,
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		clock:      clk,
+		cache:      map[svchost.Hostname]*list.Element{},
+		lru:        list.New(),
 	}
 }
 
@@ -49,9 +78,26 @@ func CachingCredentialsStore(store CredentialsStore) CredentialsStore {
 }
 
 type cachingCredentialsSource struct {
-	source CredentialsSource
-	cache  map[svchost.Hostname]HostCredentials
-	mu     sync.Mutex
+	source     CredentialsSource
+	ttl        time.Duration
+	maxEntries int
+	clock      clock.Clock
+
+	// cache maps each hostname to its element in lru, whose value is
+	// a *cacheEntry. The front of lru is the most recently used entry.
+	cache map[svchost.Hostname]*list.Element
+	lru   *list.List
+	mu    sync.Mutex
+}
+
+// cacheEntry is a single entry in a [cachingCredentialsSource].
+type cacheEntry struct {
+	host  svchost.Hostname
+	creds HostCredentials
+
+	// expires is the time after which the entry must not be used, or the
+	// zero time if the cache has no TTL.
+	expires time.Time
 }
 
 // ForHost passes the given hostname on to the wrapped credentials source and
@@ -62,50 +108,110 @@ type cachingCredentialsSource struct {
 // No cache entry is created if the wrapped source returns an error, to allow
 // the caller to retry the failing operation.
 func (s *cachingCredentialsSource) ForHost(ctx context.Context, host svchost.Hostname) (HostCredentials, error) {
-	s.mu.Lock()
-	if cache, cached := s.cache[host]; cached {
-		s.mu.Unlock()
-		return cache, nil
+	if creds, cached := s.get(host, s.clock.Now()); cached {
+		return creds, nil
 	}
-	s.mu.Unlock()
 
 	result, err := s.source.ForHost(ctx, host)
 	if err != nil {
-		return result, err
+		return result, svchost.WrapHostError(host, opForHost, err)
 	}
 
-	s.mu.Lock()
-	s.cache[host] = result
-	s.mu.Unlock()
+	s.put(host, result, s.clock.Now())
 	return result, nil
 }
 
+// get returns the cached credentials for the given host, if there is an
+// entry that is still valid at the given time, and marks it as recently used.
+func (s *cachingCredentialsSource) get(host svchost.Hostname, now time.Time) (HostCredentials, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	elem, cached := s.cache[host]
+	if !cached {
+		return nil, false
+	}
+	entry := elem.Value.(*cacheEntry)
+	if credentialsExpired(entry.creds, now) || (!entry.expires.IsZero() && !now.Before(entry.expires)) {
+		s.removeElement(elem)
+		return nil, false
+	}
+	s.lru.MoveToFront(elem)
+	return entry.creds, true
+}
+
+// put adds or replaces the cache entry for the given host, evicting the
+// least recently used entry if the cache is full.
+func (s *cachingCredentialsSource) put(host svchost.Hostname, creds HostCredentials, now time.Time) {
+	entry := &cacheEntry{host: host, creds: creds}
+	if s.ttl > 0 {
+		entry.expires = now.Add(s.ttl)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if elem, exists := s.cache[host]; exists {
+		elem.Value = entry
+		s.lru.MoveToFront(elem)
+		return
+	}
+	s.cache[host] = s.lru.PushFront(entry)
+	if s.maxEntries > 0 && s.lru.Len() > s.maxEntries {
+		s.removeElement(s.lru.Back())
+	}
+}
+
+// forget removes the cache entry for the given host, if any.
+func (s *cachingCredentialsSource) forget(host svchost.Hostname) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if elem, exists := s.cache[host]; exists {
+		s.removeElement(elem)
+	}
+}
+
+// removeElement removes the given element of s.lru and its corresponding
+// entry in s.cache. The caller must hold s.mu.
+func (s *cachingCredentialsSource) removeElement(elem *list.Element) {
+	entry := s.lru.Remove(elem).(*cacheEntry)
+	delete(s.cache, entry.host)
+}
+
 func (s *cachingCredentialsSource) StoreForHost(ctx context.Context, host svchost.Hostname, credentials NewHostCredentials) error {
 	// We'll delete the cache entry even if the store fails, since that just
 	// means that the next read will go to the real store and get a chance to
 	// see which object (old or new) is actually present.
-	s.mu.Lock()
-	delete(s.cache, host)
-	s.mu.Unlock()
+	s.forget(host)
 
 	store, ok := s.source.(CredentialsStore)
 	if !ok {
-		return fmt.Errorf("no credentials store is available")
+		return svchost.WrapHostError(host, opStoreForHost, errNoStore)
 	}
-	return store.StoreForHost(ctx, host, credentials)
+	return svchost.WrapHostError(host, opStoreForHost, store.StoreForHost(ctx, host, credentials))
 }
 
 func (s *cachingCredentialsSource) ForgetForHost(ctx context.Context, host svchost.Hostname) error {
 	// We'll delete the cache entry even if the store fails, since that just
 	// means that the next read will go to the real store and get a chance to
 	// see if the object is still present.
-	s.mu.Lock()
-	delete(s.cache, host)
-	s.mu.Unlock()
+	s.forget(host)
+
+	store, ok := s.source.(CredentialsStore)
+	if !ok {
+		return svchost.WrapHostError(host, opForgetForHost, errNoStore)
+	}
+	return svchost.WrapHostError(host, opForgetForHost, store.ForgetForHost(ctx, host))
+}
+
+func (s *cachingCredentialsSource) UpdateForHost(ctx context.Context, host svchost.Hostname, update UpdateFunc) error {
+	// We'll delete the cache entry both before and after the update, since
+	// a concurrent lookup could otherwise cache the old credentials while
+	// the update is in progress.
+	s.forget(host)
+	defer s.forget(host)
 
 	store, ok := s.source.(CredentialsStore)
 	if !ok {
-		return fmt.Errorf("no credentials store is available")
+		return svchost.WrapHostError(host, opUpdateForHost, errNoStore)
 	}
-	return store.ForgetForHost(ctx, host)
+	return svchost.WrapHostError(host, opUpdateForHost, UpdateForHost(ctx, store, host, update))
 }
@@ -0,0 +1,108 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package svcauth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/opentofu/svchost"
+	"github.com/opentofu/svchost/internal/clock"
+)
+
+func TestCachingCredentialsSourceTTL(t *testing.T) {
+	host := svchost.Hostname("example.com")
+	s := newCachingCredentialsSource(&mapCredentialsStore{}, time.Minute, 0, clock.Real)
+	now := time.Now()
+	s.put(host, HostCredentialsToken("abc123"), now)
+
+	if creds, cached := s.get(host, now.Add(30*time.Second)); !cached || creds != HostCredentialsToken("abc123") {
+		t.Errorf("wrong result before TTL %#v, %t; want cached credentials", creds, cached)
+	}
+	if _, cached := s.get(host, now.Add(time.Minute)); cached {
+		t.Error("entry was returned after its TTL")
+	}
+	if _, exists := s.cache[host]; exists {
+		t.Error("expired entry was not removed")
+	}
+}
+
+func TestCachingCredentialsSourceWithClock(t *testing.T) {
+	host := svchost.Hostname("example.com")
+	store := &mapCredentialsStore{host: HostCredentialsToken("abc123")}
+	clk := clock.NewFake(time.Now())
+	source, err := NewCredentialsSource(store, WithCacheTTL(time.Minute), WithClock(clk))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	lookup := func() HostCredentials {
+		t.Helper()
+		creds, err := source.ForHost(t.Context(), host)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		return creds
+	}
+
+	lookup()
+	(*store)[host] = HostCredentialsToken("new")
+	clk.Advance(30 * time.Second)
+	if got, want := lookup(), HostCredentialsToken("abc123"); got != want {
+		t.Errorf("wrong result before TTL %#v; want cached %#v", got, want)
+	}
+	clk.Advance(30 * time.Second)
+	if got, want := lookup(), HostCredentialsToken("new"); got != want {
+		t.Errorf("wrong result after TTL %#v; want %#v", got, want)
+	}
+}
+
+func TestCachingCredentialsSourceMaxEntries(t *testing.T) {
+	store := &mapCredentialsStore{
+		"a.example.com": HostCredentialsToken("a"),
+		"b.example.com": HostCredentialsToken("b"),
+		"c.example.com": HostCredentialsToken("c"),
+	}
+	source, err := NewCredentialsSource(store, WithCacheMaxEntries(2))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for _, host := range []svchost.Hostname{"a.example.com", "b.example.com", "a.example.com", "c.example.com"} {
+		if _, err := source.ForHost(t.Context(), host); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	// b.example.com was the least recently used entry when c.example.com
+	// was added, so it should have been evicted.
+	(*store)["a.example.com"] = HostCredentialsToken("new-a")
+	(*store)["b.example.com"] = HostCredentialsToken("new-b")
+	tests := []struct {
+		host svchost.Hostname
+		want HostCredentials
+	}{
+		{"a.example.com", HostCredentialsToken("a")},
+		{"b.example.com", HostCredentialsToken("new-b")},
+	}
+	for _, test := range tests {
+		creds, err := source.ForHost(t.Context(), test.host)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if creds != test.want {
+			t.Errorf("wrong credentials for %s %#v; want %#v", test.host, creds, test.want)
+		}
+	}
+}
+
+func TestCachingCredentialsSourceOptions(t *testing.T) {
+	if _, err := NewCredentialsSource(&mapCredentialsStore{}, WithCacheTTL(0)); err == nil {
+		t.Error("unexpected success with zero TTL")
+	}
+	if _, err := NewCredentialsSource(&mapCredentialsStore{}, WithCacheMaxEntries(-1)); err == nil {
+		t.Error("unexpected success with negative size limit")
+	}
+	if _, err := NewCredentialsSource(&mapCredentialsStore{}, WithClock(nil)); err == nil {
+		t.Error("unexpected success with nil clock")
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package svcauth
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/opentofu/svchost"
+	"github.com/opentofu/svchost/internal/transport"
+)
+
+// NewAuthenticatedClient returns an HTTP client that automatically applies
+// credentials from the given source to each outgoing request, based on the
+// hostname in the request URL.
+//
+// Requests to hosts for which the source has no credentials are sent
+// without any. If the credentials lookup fails then the request fails with
+// the same error, without being sent.
+//
+// Credentials implementing [CertificateBoundCredentials] are only sent over
+// HTTPS, and only if the client certificate given in [WithClientCertificate]
+// matches the one the credentials are bound to. Credentials implementing
+// [HostCredentialsForService] receive the service identifier recorded in
+// each request's context by [ContextWithServiceID], if any.
+//
+// This function supports the [WithCache], [WithCacheTTL],
+// [WithCacheMaxEntries], [WithClock], [WithTimeout], [WithTrace],
+// [WithSOCKS5Proxy], [WithProxyFunc], [WithHostProxy], [WithFIPSMode],
+// [WithHostPolicy], and [WithClientCertificate] options.
+// [WithTimeout] limits the total duration of each request, including the
+// credentials lookup.
+func NewAuthenticatedClient(source CredentialsSource, opts ...Option) (*http.Client, error) {
+	if source == nil {
+		return nil, errors.New("credentials source must not be nil")
+	}
+	o, err := newOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	if o.cache {
+		source = newCachingCredentialsSource(source, o.cacheTTL, o.cacheMaxEntries, o.clock)
+	}
+
+	return &http.Client{
+		Transport: &authenticatedTransport{
+			source: source,
+			opts:   o,
+			base:   transport.New(&o.transport),
+		},
+		Timeout: o.timeout,
+	}, nil
+}
+
+// authenticatedTransport is the [http.RoundTripper] implementation used by
+// clients returned from [NewAuthenticatedClient].
+type authenticatedTransport struct {
+	source CredentialsSource
+	opts   *options
+	base   http.RoundTripper
+}
+
+// RoundTrip implements [http.RoundTripper].
+func (t *authenticatedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host, err := svchost.ForComparisonAllowIP(req.URL.Host)
+	if err != nil {
+		// If the URL host isn't a valid service hostname then it can't
+		// possibly have credentials, so we'll just send it as-is.
+		return t.base.RoundTrip(req)
+	}
+
+	if t.opts.hostPolicy != nil && t.opts.hostPolicy(host) != nil {
+		// Credentials are not permitted for this host, such as for a CDN
+		// that serves downloads, so the request is sent anonymously.
+		return t.base.RoundTrip(req)
+	}
+
+	ctx := t.opts.trace.lookupStart(req.Context(), host)
+	creds, err := t.source.ForHost(ctx, host)
+	if err != nil {
+		err = svchost.WrapHostError(host, opForHost, err)
+		t.opts.trace.lookupFailure(ctx, host, err)
+		if req.Body != nil {
+			req.Body.Close() // RoundTrip must always close the body
+		}
+		return nil, err
+	}
+	t.opts.trace.lookupSuccess(ctx, host, creds != nil)
+	if creds == nil {
+		return t.base.RoundTrip(req)
+	}
+	if bound, ok := certificateBound(creds); ok {
+		if err := t.checkCertificateBinding(req, bound); err != nil {
+			if req.Body != nil {
+				req.Body.Close() // RoundTrip must always close the body
+			}
+			return nil, svchost.WrapHostError(host, opForHost, err)
+		}
+	}
+
+	// A RoundTripper must not modify the request it was given, so we'll
+	// apply the credentials to a copy.
+	req = req.Clone(req.Context())
+	PrepareRequest(req.Context(), creds, req, "")
+	return t.base.RoundTrip(req)
+}
+
+// checkCertificateBinding returns an error if the given certificate-bound
+// credentials cannot be used for the given request, because the request
+// would not present the certificate that the credentials are bound to.
+func (t *authenticatedTransport) checkCertificateBinding(req *http.Request, creds CertificateBoundCredentials) error {
+	switch {
+	case req.URL.Scheme != "https":
+		return errors.New("credentials are bound to a client certificate and so can only be sent over HTTPS")
+	case t.opts.clientCertThumbprint == "":
+		return errors.New("credentials are bound to a client certificate, but no client certificate is configured")
+	case t.opts.clientCertThumbprint != creds.CertificateThumbprint():
+		return errors.New("credentials are bound to a different client certificate than the one configured")
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package svcauth
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+// HostCredentialsClientCert is a HostCredentials implementation that
+// represents a TLS client certificate, which authenticates the client during
+// the TLS handshake rather than by modifying each request.
+//
+// Because the certificate must be presented when the connection is
+// established, these credentials must be applied to the HTTP client's TLS
+// configuration using [ConfigureTLSForHost].
+type HostCredentialsClientCert struct {
+	Certificate tls.Certificate
+}
+
+// Interface implementation assertions. Compilation will fail here if
+// HostCredentialsClientCert does not fully implement these interfaces.
+var _ HostCredentials = (*HostCredentialsClientCert)(nil)
+var _ NewHostCredentials = (*HostCredentialsClientCert)(nil)
+
+// NewHostCredentialsClientCert returns client certificate credentials from
+// the given PEM-encoded certificate chain and private key.
+func NewHostCredentialsClientCert(certPEM, keyPEM []byte) (*HostCredentialsClientCert, error) {
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("invalid client certificate: %w", err)
+	}
+	return &HostCredentialsClientCert{Certificate: cert}, nil
+}
+
+// LoadHostCredentialsClientCert is like [NewHostCredentialsClientCert] but
+// reads the certificate chain and private key from the given files.
+func LoadHostCredentialsClientCert(certFile, keyFile string) (*HostCredentialsClientCert, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("invalid client certificate: %w", err)
+	}
+	return &HostCredentialsClientCert{Certificate: cert}, nil
+}
+
+// PrepareRequest does nothing, because client certificates are presented
+// during the TLS handshake rather than as part of each request.
+func (c *HostCredentialsClientCert) PrepareRequest(req *http.Request) {}
+
+// ToStore returns a credentials object with the PEM-encoded certificate
+// chain and private key in the attributes "client_certificate" and
+// "client_key" respectively. This implements [NewHostCredentials].
+//
+// The private key is included in the result, so it must be stored securely.
+func (c *HostCredentialsClientCert) ToStore() cty.Value {
+	certPEM, keyPEM, err := c.encodePEM()
+	if err != nil {
+		// Should not get here for any certificate that crypto/tls is able
+		// to use, since PKCS #8 supports all of the same key types.
+		panic(fmt.Sprintf("can't encode client certificate: %s", err))
+	}
+	return cty.ObjectVal(map[string]cty.Value{
+		"client_certificate": cty.StringVal(string(certPEM)),
+		"client_key":         cty.StringVal(string(keyPEM)),
+	})
+}
+
+func (c *HostCredentialsClientCert) encodePEM() (certPEM, keyPEM []byte, err error) {
+	if len(c.Certificate.Certificate) == 0 || c.Certificate.PrivateKey == nil {
+		return nil, nil, errors.New("certificate or private key is missing")
+	}
+	var buf bytes.Buffer
+	for _, der := range c.Certificate.Certificate {
+		pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: der}) //nolint:errcheck // writes to a bytes.Buffer cannot fail
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(c.Certificate.PrivateKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return buf.Bytes(), pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), nil
+}
+
+// ConfigureTLSForHost returns a copy of the given TLS configuration, which
+// may be nil, updated to present the client certificate from the given
+// credentials if they are a [*HostCredentialsClientCert], or are
+// [CombinedHostCredentials] that include one.
+//
+// Other kinds of credentials are applied to each request instead, so for
+// those the result is an unmodified copy. Because the certificate is then
+// presented to every server the configuration is used with, the result
+// should be used only for connections to the host the credentials belong to.
+func ConfigureTLSForHost(cfg *tls.Config, creds HostCredentials) *tls.Config {
+	var ret *tls.Config
+	if cfg != nil {
+		ret = cfg.Clone()
+	} else {
+		ret = &tls.Config{}
+	}
+	if cert := clientCertificate(creds); cert != nil {
+		ret.Certificates = []tls.Certificate{cert.Certificate}
+	}
+	return ret
+}
@@ -0,0 +1,93 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package svcauth
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestHostCredentialsClientCert(t *testing.T) {
+	certPEM, keyPEM := testClientCertPEM(t)
+	creds, err := NewHostCredentialsClientCert(certPEM, keyPEM)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	{
+		req := &http.Request{}
+		creds.PrepareRequest(req)
+		if len(req.Header) != 0 {
+			t.Errorf("PrepareRequest modified the request headers: %#v", req.Header)
+		}
+	}
+
+	{
+		stored := creds.ToStore()
+		m := map[string]any{
+			"client_certificate": stored.GetAttr("client_certificate").AsString(),
+			"client_key":         stored.GetAttr("client_key").AsString(),
+		}
+		got, ok := HostCredentialsFromMap(m).(*HostCredentialsClientCert)
+		if !ok {
+			t.Fatalf("stored credentials did not round-trip; got %#v", HostCredentialsFromMap(m))
+		}
+		if !bytes.Equal(got.Certificate.Certificate[0], creds.Certificate.Certificate[0]) {
+			t.Error("round-tripped certificate does not match the original")
+		}
+	}
+
+	{
+		cfg := ConfigureTLSForHost(&tls.Config{ServerName: "example.com"}, creds)
+		if len(cfg.Certificates) != 1 || cfg.ServerName != "example.com" {
+			t.Errorf("wrong TLS configuration %#v", cfg)
+		}
+		cfg = ConfigureTLSForHost(nil, HostCredentialsToken("abc123"))
+		if len(cfg.Certificates) != 0 {
+			t.Errorf("token credentials added certificates to the TLS configuration")
+		}
+	}
+
+	if _, err := NewHostCredentialsClientCert(certPEM, nil); err == nil {
+		t.Error("unexpected success without a private key; want error")
+	}
+}
+
+// testClientCertPEM returns a newly-generated self-signed certificate and
+// its private key, PEM-encoded.
+func testClientCertPEM(t *testing.T) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM
+}
@@ -0,0 +1,128 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package svcauth
+
+import (
+	"net/http"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+// CombinedHostCredentials is a HostCredentials implementation that combines
+// several other credentials for the same host, for hosts that require more
+// than one kind of authentication at once, such as a bearer token sent over
+// a connection that presents a TLS client certificate.
+//
+// Nil elements are ignored.
+type CombinedHostCredentials []HostCredentials
+
+// Interface implementation assertions. Compilation will fail here if
+// CombinedHostCredentials does not fully implement these interfaces.
+var _ HostCredentialsForService = CombinedHostCredentials(nil)
+var _ HostCredentialsWithHeaders = CombinedHostCredentials(nil)
+var _ NewHostCredentials = CombinedHostCredentials(nil)
+
+// PrepareRequest applies each of the combined credentials to the given
+// request in turn, so that later credentials take precedence if more than
+// one of them sets the same header.
+func (c CombinedHostCredentials) PrepareRequest(req *http.Request) {
+	for _, creds := range c {
+		if creds != nil {
+			creds.PrepareRequest(req)
+		}
+	}
+}
+
+// PrepareRequestForService is like PrepareRequest except that it passes the
+// given service identifier to any of the combined credentials that implement
+// [HostCredentialsForService]. This implements [HostCredentialsForService].
+func (c CombinedHostCredentials) PrepareRequestForService(req *http.Request, serviceID string) {
+	for _, creds := range c {
+		if forService, ok := creds.(HostCredentialsForService); ok {
+			forService.PrepareRequestForService(req, serviceID)
+		} else if creds != nil {
+			creds.PrepareRequest(req)
+		}
+	}
+}
+
+// CredentialHeaders returns the names of the headers that any of the
+// combined credentials may set, as described for [CredentialHeaders]. This
+// implements [HostCredentialsWithHeaders].
+func (c CombinedHostCredentials) CredentialHeaders() []string {
+	return CredentialHeaders(c)
+}
+
+// ClientCertificate returns the first of the combined credentials that is a
+// TLS client certificate, or nil if there is none.
+//
+// [ConfigureTLSForHost] uses this to present the certificate when given
+// combined credentials.
+func (c CombinedHostCredentials) ClientCertificate() *HostCredentialsClientCert {
+	for _, creds := range c {
+		if cert := clientCertificate(creds); cert != nil {
+			return cert
+		}
+	}
+	return nil
+}
+
+// ToStore returns a credentials object with all of the attributes of the
+// objects returned by the combined credentials that implement
+// [NewHostCredentials]. If more than one of them has the same attribute then
+// the first one's value is used. This implements [NewHostCredentials].
+//
+// [HostCredentialsFromStore] converts the result back into combined
+// credentials when it contains a client certificate along with other
+// credentials.
+func (c CombinedHostCredentials) ToStore() cty.Value {
+	attrs := make(map[string]cty.Value)
+	for _, creds := range c {
+		toStore, ok := creds.(NewHostCredentials)
+		if !ok {
+			continue
+		}
+		for it := toStore.ToStore().ElementIterator(); it.Next(); {
+			k, v := it.Element()
+			if _, exists := attrs[k.AsString()]; !exists {
+				attrs[k.AsString()] = v
+			}
+		}
+	}
+	if len(attrs) == 0 {
+		return cty.EmptyObjectVal
+	}
+	return cty.ObjectVal(attrs)
+}
+
+// clientCertificate returns the TLS client certificate in the given
+// credentials, which may be either a [*HostCredentialsClientCert] or
+// [CombinedHostCredentials], or nil if there is none.
+func clientCertificate(creds HostCredentials) *HostCredentialsClientCert {
+	switch creds := creds.(type) {
+	case *HostCredentialsClientCert:
+		return creds
+	case CombinedHostCredentials:
+		return creds.ClientCertificate()
+	default:
+		return nil
+	}
+}
+
+// certificateBound returns the credentials in the given credentials that
+// implement [CertificateBoundCredentials], looking inside
+// [CombinedHostCredentials], if any.
+func certificateBound(creds HostCredentials) (CertificateBoundCredentials, bool) {
+	switch creds := creds.(type) {
+	case CertificateBoundCredentials:
+		return creds, true
+	case CombinedHostCredentials:
+		for _, inner := range creds {
+			if bound, ok := certificateBound(inner); ok {
+				return bound, true
+			}
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,79 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package svcauth
+
+import (
+	"bytes"
+	"crypto/tls"
+	"net/http"
+	"testing"
+)
+
+func TestCombinedHostCredentials(t *testing.T) {
+	certPEM, keyPEM := testClientCertPEM(t)
+	cert, err := NewHostCredentialsClientCert(certPEM, keyPEM)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	creds := CombinedHostCredentials{HostCredentialsToken("abc123"), nil, cert}
+
+	{
+		req := &http.Request{}
+		creds.PrepareRequest(req)
+		if got, want := req.Header.Get("Authorization"), "Bearer abc123"; got != want {
+			t.Errorf("wrong Authorization header %q; want %q", got, want)
+		}
+	}
+
+	{
+		req := &http.Request{}
+		combined := CombinedHostCredentials{audienceCredentials{"modules.v1": "modules-token"}, cert}
+		PrepareRequest(t.Context(), combined, req, "modules.v1")
+		if got, want := req.Header.Get("Authorization"), "Bearer modules-token"; got != want {
+			t.Errorf("wrong Authorization header %q; want %q", got, want)
+		}
+	}
+
+	{
+		if got := creds.ClientCertificate(); got != cert {
+			t.Errorf("wrong client certificate %#v; want %#v", got, cert)
+		}
+		if got := (CombinedHostCredentials{HostCredentialsToken("abc123")}).ClientCertificate(); got != nil {
+			t.Errorf("unexpected client certificate %#v", got)
+		}
+		cfg := ConfigureTLSForHost(&tls.Config{}, creds)
+		if len(cfg.Certificates) != 1 {
+			t.Errorf("combined credentials didn't add the certificate to the TLS configuration")
+		}
+	}
+
+	{
+		bound := HostCredentialsBoundToken{AccessToken: "abc123", Thumbprint: "thumb"}
+		got, ok := certificateBound(CombinedHostCredentials{bound, cert})
+		if !ok || got.CertificateThumbprint() != "thumb" {
+			t.Errorf("combined credentials didn't report the certificate-bound token")
+		}
+		if _, ok := certificateBound(creds); ok {
+			t.Errorf("unexpected certificate-bound credentials in %#v", creds)
+		}
+	}
+
+	{
+		restored, err := HostCredentialsFromStore(creds.ToStore())
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		got, ok := restored.(CombinedHostCredentials)
+		if !ok || len(got) != 2 {
+			t.Fatalf("stored credentials did not round-trip; got %#v", restored)
+		}
+		if got, want := got[0], HostCredentialsToken("abc123"); got != want {
+			t.Errorf("wrong round-tripped token %#v; want %#v", got, want)
+		}
+		gotCert, ok := got[1].(*HostCredentialsClientCert)
+		if !ok || !bytes.Equal(gotCert.Certificate.Certificate[0], cert.Certificate.Certificate[0]) {
+			t.Error("round-tripped certificate does not match the original")
+		}
+	}
+}
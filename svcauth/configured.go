@@ -0,0 +1,189 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package svcauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/opentofu/svchost"
+)
+
+// NewCredentialsSource wraps the given source with the behaviors requested
+// by the given options.
+//
+// This function supports the [WithCache], [WithCacheTTL],
+// [WithCacheMaxEntries], [WithRefresh], [WithOAuthConfig], [WithClock],
+// [WithTimeout], [WithTrace], [WithSourceName], [WithAuditHooks], and
+// [WithEvents] options. It returns an error if the given source is nil or if
+// any of the options are invalid.
+//
+// The result also implements [CredentialsStore] by forwarding to the inner
+// source, but the store and forget methods will fail with an error if the
+// wrapped source does not also implement that interface.
+func NewCredentialsSource(source CredentialsSource, opts ...Option) (CredentialsSource, error) {
+	if source == nil {
+		return nil, errors.New("credentials source must not be nil")
+	}
+	o, err := newOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	if o.sourceName == "" {
+		o.sourceName = fmt.Sprintf("%T", source)
+	}
+
+	// The cache is the innermost wrapper, so that the trace still reports
+	// lookups that are served from the cache and the timeout applies to
+	// the overall operation.
+	if o.cache {
+		source = newCachingCredentialsSource(source, o.cacheTTL, o.cacheMaxEntries, o.clock)
+	}
+	// Refreshing happens outside of the cache, since the cache keeps
+	// credentials until they actually expire rather than until they are
+	// within the refresh leeway.
+	if o.refresh {
+		source = newRefreshingCredentialsSource(source, o.refreshLeeway, o.clock, o.oauthConfig)
+	}
+	return &configuredCredentialsSource{
+		source: source,
+		opts:   o,
+	}, nil
+}
+
+// NewCredentialsStore is like [NewCredentialsSource] but provides a
+// statically-checkable guarantee that the wrapped object is a
+// [CredentialsStore], in the same way as [CachingCredentialsStore].
+func NewCredentialsStore(store CredentialsStore, opts ...Option) (CredentialsStore, error) {
+	if store == nil {
+		return nil, errors.New("credentials store must not be nil")
+	}
+	ret, err := NewCredentialsSource(store, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return ret.(CredentialsStore), nil
+}
+
+type configuredCredentialsSource struct {
+	source CredentialsSource
+	opts   *options
+}
+
+// ForHost implements [CredentialsSource].
+func (s *configuredCredentialsSource) ForHost(ctx context.Context, host svchost.Hostname) (HostCredentials, error) {
+	ctx, cancel := s.opts.operationContext(ctx)
+	defer cancel()
+
+	ctx = s.opts.trace.lookupStart(ctx, host)
+	start := time.Now()
+	creds, err := s.source.ForHost(ctx, host)
+	stats := LookupStats{
+		Source:   s.opts.sourceName,
+		Duration: time.Since(start),
+		Found:    err == nil && creds != nil,
+	}
+	if err != nil {
+		err = svchost.WrapHostError(host, opForHost, err)
+		s.opts.trace.lookupFailure(ctx, host, err)
+		s.opts.trace.lookupStats(ctx, host, stats)
+		s.opts.audit.forHost(ctx, host, nil, err)
+		return nil, err
+	}
+	s.opts.trace.lookupSuccess(ctx, host, creds != nil)
+	s.opts.trace.lookupStats(ctx, host, stats)
+	s.opts.audit.forHost(ctx, host, creds, nil)
+	return creds, nil
+}
+
+// StoreForHost implements [CredentialsStore].
+func (s *configuredCredentialsSource) StoreForHost(ctx context.Context, host svchost.Hostname, credentials NewHostCredentials) error {
+	ctx, cancel := s.opts.operationContext(ctx)
+	defer cancel()
+
+	store, ok := s.source.(CredentialsStore)
+	if !ok {
+		err := svchost.WrapHostError(host, opStoreForHost, errNoStore)
+		s.opts.audit.storeForHost(ctx, host, credentials, err)
+		return err
+	}
+	if err := store.StoreForHost(ctx, host, credentials); err != nil {
+		err = svchost.WrapHostError(host, opStoreForHost, err)
+		s.opts.audit.storeForHost(ctx, host, credentials, err)
+		return err
+	}
+	s.opts.events.publish(host, CredentialsStored)
+	s.opts.audit.storeForHost(ctx, host, credentials, nil)
+	return nil
+}
+
+// ForgetForHost implements [CredentialsStore].
+func (s *configuredCredentialsSource) ForgetForHost(ctx context.Context, host svchost.Hostname) error {
+	ctx, cancel := s.opts.operationContext(ctx)
+	defer cancel()
+
+	store, ok := s.source.(CredentialsStore)
+	if !ok {
+		err := svchost.WrapHostError(host, opForgetForHost, errNoStore)
+		s.opts.audit.forgetForHost(ctx, host, err)
+		return err
+	}
+	if err := store.ForgetForHost(ctx, host); err != nil {
+		err = svchost.WrapHostError(host, opForgetForHost, err)
+		s.opts.audit.forgetForHost(ctx, host, err)
+		return err
+	}
+	s.opts.events.publish(host, CredentialsForgotten)
+	s.opts.audit.forgetForHost(ctx, host, nil)
+	return nil
+}
+
+// UpdateForHost implements [CredentialsUpdater], and is atomic only if the
+// wrapped source also implements that interface.
+func (s *configuredCredentialsSource) UpdateForHost(ctx context.Context, host svchost.Hostname, update UpdateFunc) error {
+	ctx, cancel := s.opts.operationContext(ctx)
+	defer cancel()
+
+	store, ok := s.source.(CredentialsStore)
+	if !ok {
+		err := svchost.WrapHostError(host, opUpdateForHost, errNoStore)
+		s.opts.audit.storeForHost(ctx, host, nil, err)
+		return err
+	}
+	// We remember the result of the update function so that we can report
+	// whether the credentials were replaced or discarded.
+	var updated NewHostCredentials
+	err := UpdateForHost(ctx, store, host, func(old HostCredentials) (NewHostCredentials, error) {
+		var err error
+		updated, err = update(old)
+		return updated, err
+	})
+	if err != nil {
+		err = svchost.WrapHostError(host, opUpdateForHost, err)
+		s.opts.audit.storeForHost(ctx, host, updated, err)
+		return err
+	}
+	if updated == nil {
+		s.opts.events.publish(host, CredentialsForgotten)
+		s.opts.audit.forgetForHost(ctx, host, nil)
+		return nil
+	}
+	s.opts.events.publish(host, CredentialsStored)
+	s.opts.audit.storeForHost(ctx, host, updated, nil)
+	return nil
+}
+
+// operationContext returns a context to use for a single operation, which
+// is bounded by the configured timeout if any.
+//
+// The caller must call the returned cancel function once the operation
+// is complete.
+func (o *options) operationContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if o.timeout > 0 {
+		return context.WithTimeout(ctx, o.timeout)
+	}
+	return context.WithCancel(ctx)
+}
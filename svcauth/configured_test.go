@@ -0,0 +1,231 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package svcauth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/opentofu/svchost"
+)
+
+func TestNewCredentialsSource(t *testing.T) {
+	t.Run("cache", func(t *testing.T) {
+		inner := &countingCredentialsSource{
+			creds: HostCredentialsToken("abc123"),
+		}
+		src, err := NewCredentialsSource(inner, WithCache())
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		for range 3 {
+			if _, err := src.ForHost(t.Context(), "example.com"); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+		}
+		if got, want := inner.calls, 1; got != want {
+			t.Errorf("wrong number of calls to inner source %d; want %d", got, want)
+		}
+	})
+	t.Run("timeout", func(t *testing.T) {
+		inner := credentialsSourceFunc(func(ctx context.Context, host svchost.Hostname) (HostCredentials, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})
+		src, err := NewCredentialsSource(inner, WithTimeout(10*time.Millisecond))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		_, err = src.ForHost(t.Context(), "example.com")
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("wrong error %v; want context.DeadlineExceeded", err)
+		}
+	})
+	t.Run("trace", func(t *testing.T) {
+		var gotEvents []string
+		trace := &CredentialsTrace{
+			LookupStart: func(ctx context.Context, host svchost.Hostname) context.Context {
+				gotEvents = append(gotEvents, "start "+host.String())
+				return ctx
+			},
+			LookupSuccess: func(ctx context.Context, host svchost.Hostname, found bool) {
+				if found {
+					gotEvents = append(gotEvents, "found "+host.String())
+				} else {
+					gotEvents = append(gotEvents, "not found "+host.String())
+				}
+			},
+			LookupFailure: func(ctx context.Context, host svchost.Hostname, err error) {
+				gotEvents = append(gotEvents, "failed "+host.String()+": "+err.Error())
+			},
+		}
+		inner := credentialsSourceFunc(func(ctx context.Context, host svchost.Hostname) (HostCredentials, error) {
+			switch host {
+			case "example.com":
+				return HostCredentialsToken("abc123"), nil
+			case "fail.example.com":
+				return nil, errors.New("oops")
+			default:
+				return nil, nil
+			}
+		})
+		src, err := NewCredentialsSource(inner, WithTrace(trace))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		src.ForHost(t.Context(), "example.com")
+		src.ForHost(t.Context(), "example.net")
+		src.ForHost(t.Context(), "fail.example.com")
+
+		want := []string{
+			"start example.com",
+			"found example.com",
+			"start example.net",
+			"not found example.net",
+			"start fail.example.com",
+			"failed fail.example.com: get credentials for fail.example.com: oops",
+		}
+		if diff := cmp.Diff(want, gotEvents); diff != "" {
+			t.Error("wrong trace events\n" + diff)
+		}
+	})
+	t.Run("stats", func(t *testing.T) {
+		var gotStats []LookupStats
+		trace := &CredentialsTrace{
+			LookupStats: func(ctx context.Context, host svchost.Hostname, stats LookupStats) {
+				gotStats = append(gotStats, stats)
+			},
+		}
+		inner := credentialsSourceFunc(func(ctx context.Context, host svchost.Hostname) (HostCredentials, error) {
+			time.Sleep(time.Millisecond)
+			if host == "fail.example.com" {
+				return nil, errors.New("oops")
+			}
+			return HostCredentialsToken("abc123"), nil
+		})
+		named, err := NewCredentialsSource(inner, WithTrace(trace), WithSourceName("helper"))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		unnamed, err := NewCredentialsSource(inner, WithTrace(trace), WithCache())
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		named.ForHost(t.Context(), "example.com")
+		named.ForHost(t.Context(), "fail.example.com")
+		unnamed.ForHost(t.Context(), "example.com")
+
+		want := []LookupStats{
+			{Source: "helper", Found: true},
+			{Source: "helper", Found: false},
+			{Source: "svcauth.credentialsSourceFunc", Found: true},
+		}
+		for i := range gotStats {
+			if gotStats[i].Duration < time.Millisecond {
+				t.Errorf("stats %d has implausible duration %s", i, gotStats[i].Duration)
+			}
+			gotStats[i].Duration = 0
+		}
+		if diff := cmp.Diff(want, gotStats); diff != "" {
+			t.Error("wrong lookup stats\n" + diff)
+		}
+	})
+	t.Run("events", func(t *testing.T) {
+		var gotEvents []CredentialsEvent
+		events := &CredentialsEvents{}
+		unsubscribe := events.Subscribe(func(e CredentialsEvent) {
+			gotEvents = append(gotEvents, e)
+		})
+		defer unsubscribe()
+
+		store, err := NewCredentialsStore(&mapCredentialsStore{}, WithEvents(events))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if err := store.StoreForHost(t.Context(), "example.com", HostCredentialsToken("abc123")); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if err := store.ForgetForHost(t.Context(), "example.com"); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		// A failed store must not produce an event.
+		src, err := NewCredentialsSource(NoCredentials, WithEvents(events))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		src.(CredentialsStore).StoreForHost(t.Context(), "example.net", HostCredentialsToken("abc123"))
+
+		want := []CredentialsEvent{
+			{Host: "example.com", Kind: CredentialsStored},
+			{Host: "example.com", Kind: CredentialsForgotten},
+		}
+		if diff := cmp.Diff(want, gotEvents); diff != "" {
+			t.Error("wrong events\n" + diff)
+		}
+	})
+	t.Run("not a store", func(t *testing.T) {
+		src, err := NewCredentialsSource(NoCredentials)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		err = src.(CredentialsStore).StoreForHost(t.Context(), "example.com", HostCredentialsToken("abc123"))
+		if err == nil {
+			t.Error("unexpected success; want error")
+		}
+	})
+	t.Run("invalid options", func(t *testing.T) {
+		_, err := NewCredentialsSource(NoCredentials, WithTimeout(0), WithTrace(nil))
+		if err == nil {
+			t.Error("unexpected success; want error")
+		}
+		_, err = NewCredentialsSource(NoCredentials, WithSourceName(""))
+		if err == nil {
+			t.Error("unexpected success with empty source name; want error")
+		}
+		_, err = NewCredentialsSource(nil)
+		if err == nil {
+			t.Error("unexpected success with nil source; want error")
+		}
+	})
+}
+
+type credentialsSourceFunc func(ctx context.Context, host svchost.Hostname) (HostCredentials, error)
+
+func (f credentialsSourceFunc) ForHost(ctx context.Context, host svchost.Hostname) (HostCredentials, error) {
+	return f(ctx, host)
+}
+
+type countingCredentialsSource struct {
+	creds HostCredentials
+	calls int
+}
+
+func (s *countingCredentialsSource) ForHost(ctx context.Context, host svchost.Hostname) (HostCredentials, error) {
+	s.calls++
+	return s.creds, nil
+}
+
+// mapCredentialsStore is a minimal in-memory [CredentialsStore] for testing.
+type mapCredentialsStore map[svchost.Hostname]HostCredentials
+
+func (s *mapCredentialsStore) ForHost(ctx context.Context, host svchost.Hostname) (HostCredentials, error) {
+	return (*s)[host], nil
+}
+
+func (s *mapCredentialsStore) StoreForHost(ctx context.Context, host svchost.Hostname, credentials NewHostCredentials) error {
+	if *s == nil {
+		*s = make(mapCredentialsStore)
+	}
+	(*s)[host] = credentials.(HostCredentials)
+	return nil
+}
+
+func (s *mapCredentialsStore) ForgetForHost(ctx context.Context, host svchost.Hostname) error {
+	delete(*s, host)
+	return nil
+}
@@ -8,8 +8,11 @@ package svcauth
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"maps"
 	"net/http"
+	"time"
 
 	"github.com/zclconf/go-cty/cty"
 
@@ -29,6 +32,38 @@ import (
 // the store and forget operations fail with an error.
 type Credentials []CredentialsSource
 
+// The operation descriptions used for [svchost.HostError] values returned
+// by this package.
+const (
+	opForHost       = "get credentials for"
+	opStoreForHost  = "store credentials for"
+	opForgetForHost = "forget credentials for"
+	opUpdateForHost = "update credentials for"
+)
+
+// errNoStore is returned from the store and forget operations of credentials
+// sources that wrap other sources when the wrapped source is not a store.
+var errNoStore = errors.New("no credentials store is available")
+
+// NewCredentials constructs a [Credentials] from the given sources, returning
+// an error if any of them is nil.
+//
+// This is an alternative to constructing a Credentials value directly, for
+// callers that want to detect misconfiguration up front rather than
+// encountering a panic when credentials are first requested.
+func NewCredentials(sources ...CredentialsSource) (Credentials, error) {
+	var errs []error
+	for i, source := range sources {
+		if source == nil {
+			errs = append(errs, fmt.Errorf("credentials source %d is nil", i))
+		}
+	}
+	if len(errs) != 0 {
+		return nil, errors.Join(errs...)
+	}
+	return Credentials(sources), nil
+}
+
 // NoCredentials is an empty CredentialsSource that always returns nil
 // when asked for credentials.
 var NoCredentials CredentialsSource = Credentials{}
@@ -102,22 +137,38 @@ type NewHostCredentials interface {
 func (c Credentials) ForHost(ctx context.Context, host svchost.Hostname) (HostCredentials, error) {
 	for _, source := range c {
 		creds, err := source.ForHost(ctx, host)
-		if creds != nil || err != nil {
-			return creds, err
+		if err != nil {
+			return nil, svchost.WrapHostError(host, opForHost, err)
+		}
+		if creds != nil {
+			return creds, nil
 		}
 	}
 	return nil, nil
 }
 
+// ForHostWithTimeout calls ForHost on the given source with a context that
+// is canceled after the given duration, so that a source that is blocked on
+// a slow network request or external program cannot stall the caller
+// indefinitely.
+//
+// Whether a source actually respects the deadline depends on its
+// implementation, but all of the sources in this package do.
+func ForHostWithTimeout(ctx context.Context, source CredentialsSource, host svchost.Hostname, timeout time.Duration) (HostCredentials, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return source.ForHost(ctx, host)
+}
+
 // StoreForHost passes the given arguments to the same operation on the
 // first CredentialsSource in the receiver, or returns an error if the
 // first source does not implement [CredentialsStore].
 func (c Credentials) StoreForHost(ctx context.Context, host svchost.Hostname, credentials NewHostCredentials) error {
 	store := c.Store()
 	if store == nil {
-		return fmt.Errorf("no credentials store is available")
+		return svchost.WrapHostError(host, opStoreForHost, errNoStore)
 	}
-	return store.StoreForHost(ctx, host, credentials)
+	return svchost.WrapHostError(host, opStoreForHost, store.StoreForHost(ctx, host, credentials))
 }
 
 // ForgetForHost passes the given arguments to the same operation on the
@@ -125,9 +176,20 @@ func (c Credentials) StoreForHost(ctx context.Context, host svchost.Hostname, cr
 func (c Credentials) ForgetForHost(ctx context.Context, host svchost.Hostname) error {
 	store := c.Store()
 	if store == nil {
-		return fmt.Errorf("no credentials store is available")
+		return svchost.WrapHostError(host, opForgetForHost, errNoStore)
 	}
-	return store.ForgetForHost(ctx, host)
+	return svchost.WrapHostError(host, opForgetForHost, store.ForgetForHost(ctx, host))
+}
+
+// UpdateForHost passes the given arguments to [UpdateForHost] with the
+// first CredentialsSource in the receiver, or returns an error if the
+// first source does not implement [CredentialsStore].
+func (c Credentials) UpdateForHost(ctx context.Context, host svchost.Hostname, update UpdateFunc) error {
+	store := c.Store()
+	if store == nil {
+		return svchost.WrapHostError(host, opUpdateForHost, errNoStore)
+	}
+	return svchost.WrapHostError(host, opUpdateForHost, UpdateForHost(ctx, store, host, update))
 }
 
 // Store returns a [CredentialsStore] for this set of credentials if and only
@@ -143,3 +205,113 @@ func (c Credentials) Store() CredentialsStore {
 	}
 	return store
 }
+
+// HostCredentialsFromMap converts a map of key-value pairs, typically
+// decoded from JSON produced by a credentials helper program or a
+// credentials file, into a [HostCredentials] object if possible.
+//
+// The result is nil if the given map is nil or doesn't contain any
+// recognized credentials, including if it uses a credentials type that
+// this version of the package doesn't support.
+//
+// A client certificate together with other credentials, as produced by
+// [CombinedHostCredentials.ToStore], results in [CombinedHostCredentials].
+func HostCredentialsFromMap(m map[string]any) HostCredentials {
+	if certPEM, ok := m["client_certificate"].(string); ok {
+		keyPEM, _ := m["client_key"].(string)
+		creds, err := NewHostCredentialsClientCert([]byte(certPEM), []byte(keyPEM))
+		if err != nil {
+			return nil
+		}
+		rest := maps.Clone(m)
+		delete(rest, "client_certificate")
+		delete(rest, "client_key")
+		if other := HostCredentialsFromMap(rest); other != nil {
+			return CombinedHostCredentials{other, creds}
+		}
+		return creds
+	}
+	if username, ok := m["username"].(string); ok {
+		if password, ok := m["password"].(string); ok {
+			return HostCredentialsBasic{Username: username, Password: password}
+		}
+	}
+	token, ok := m["token"].(string)
+	if !ok {
+		return nil
+	}
+	if thumbprint, ok := m["cert_thumbprint"].(string); ok {
+		return HostCredentialsBoundToken{
+			AccessToken: token,
+			Thumbprint:  thumbprint,
+		}
+	}
+	refreshToken, hasRefresh := m["refresh_token"].(string)
+	expiryStr, hasExpiry := m["expiry"].(string)
+	if hasRefresh || hasExpiry {
+		ret := HostCredentialsOAuthTokens{
+			AccessToken:  token,
+			RefreshToken: refreshToken,
+		}
+		if expiry, err := time.Parse(time.RFC3339, expiryStr); err == nil {
+			ret.Expiry = expiry
+		}
+		return ret
+	}
+	return HostCredentialsToken(token)
+}
+
+// HostCredentialsFromStore converts a credentials object, such as one
+// returned by [NewHostCredentials.ToStore] and saved by a [CredentialsStore]
+// or produced by a credentials helper program, back into a [HostCredentials]
+// object. It recognizes the same kinds of credentials as
+// [HostCredentialsFromMap], including tokens, basic credentials, OAuth
+// token pairs, and client certificates.
+//
+// The result is nil with no error if the given value is null. Unlike
+// HostCredentialsFromMap, this returns an error if the value is not a known
+// object whose attributes are all strings, if it has a malformed client
+// certificate or token expiry time, or if it doesn't contain any recognized
+// credentials.
+func HostCredentialsFromStore(v cty.Value) (HostCredentials, error) {
+	if v.IsNull() {
+		return nil, nil
+	}
+	if !v.IsWhollyKnown() {
+		return nil, errors.New("credentials object must be known")
+	}
+	if !v.Type().IsObjectType() && !v.Type().IsMapType() {
+		return nil, fmt.Errorf("credentials must be an object, not %s", v.Type().FriendlyName())
+	}
+	m := make(map[string]any, v.LengthInt())
+	for it := v.ElementIterator(); it.Next(); {
+		k, av := it.Element()
+		if av.IsNull() {
+			continue
+		}
+		if av.Type() != cty.String {
+			return nil, fmt.Errorf("credentials attribute %q must be a string", k.AsString())
+		}
+		m[k.AsString()] = av.AsString()
+	}
+
+	// HostCredentialsFromMap silently ignores malformed attributes, so we
+	// check for them first in order to report them.
+	if certPEM, ok := m["client_certificate"].(string); ok {
+		keyPEM, _ := m["client_key"].(string)
+		if _, err := NewHostCredentialsClientCert([]byte(certPEM), []byte(keyPEM)); err != nil {
+			return nil, err
+		}
+	}
+	if expiry, ok := m["expiry"].(string); ok {
+		if _, err := time.Parse(time.RFC3339, expiry); err != nil {
+			return nil, fmt.Errorf("invalid token expiry time: %w", err)
+		}
+	}
+
+	creds := HostCredentialsFromMap(m)
+	if creds == nil {
+		return nil, errors.New("credentials object does not contain any recognized credentials")
+	}
+	return creds, nil
+}
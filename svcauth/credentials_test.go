@@ -0,0 +1,118 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package svcauth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/opentofu/svchost"
+)
+
+func TestNewCredentials(t *testing.T) {
+	static := StaticCredentialsSource(map[svchost.Hostname]HostCredentials{
+		"example.com": HostCredentialsToken("abc123"),
+	})
+
+	got, err := NewCredentials(static, NoCredentials)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(got) != 2 {
+		t.Errorf("wrong number of sources %d; want 2", len(got))
+	}
+
+	_, err = NewCredentials(static, nil)
+	if err == nil {
+		t.Fatal("unexpected success; want error")
+	}
+	if got, want := err.Error(), "credentials source 1 is nil"; got != want {
+		t.Errorf("wrong error\ngot:  %s\nwant: %s", got, want)
+	}
+}
+
+func TestForHostWithTimeout(t *testing.T) {
+	src := credentialsSourceFunc(func(ctx context.Context, host svchost.Hostname) (HostCredentials, error) {
+		if host == "slow.example.com" {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return HostCredentialsToken("abc123"), nil
+	})
+
+	creds, err := ForHostWithTimeout(t.Context(), src, "example.com", time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if creds == nil {
+		t.Error("no credentials returned")
+	}
+
+	_, err = ForHostWithTimeout(t.Context(), src, "slow.example.com", 10*time.Millisecond)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("wrong error %v; want context.DeadlineExceeded", err)
+	}
+}
+
+func TestCredentialsHostErrors(t *testing.T) {
+	failing := credentialsSourceFunc(func(ctx context.Context, host svchost.Hostname) (HostCredentials, error) {
+		return nil, errors.New("oops")
+	})
+	creds := Credentials{NoCredentials, failing}
+
+	_, err := creds.ForHost(t.Context(), "example.com")
+	var hostErr *svchost.HostError
+	if !errors.As(err, &hostErr) {
+		t.Fatalf("error is not a HostError: %v", err)
+	}
+	if got, want := hostErr.Host, svchost.Hostname("example.com"); got != want {
+		t.Errorf("wrong host %q; want %q", got, want)
+	}
+
+	// Wrapping the chain in a cache doesn't add another layer for the
+	// same host.
+	_, err = CachingCredentialsSource(creds).ForHost(t.Context(), "example.com")
+	if got, want := err.Error(), "get credentials for example.com: oops"; got != want {
+		t.Errorf("wrong error\ngot:  %s\nwant: %s", got, want)
+	}
+
+	err = creds.StoreForHost(t.Context(), "example.com", HostCredentialsToken("abc123"))
+	if got, want := err.Error(), "store credentials for example.com: no credentials store is available"; got != want {
+		t.Errorf("wrong error\ngot:  %s\nwant: %s", got, want)
+	}
+}
+
+func TestHostCredentialsFromMap(t *testing.T) {
+	tests := map[string]struct {
+		m    map[string]any
+		want HostCredentials
+	}{
+		"nil": {nil, nil},
+		"token": {
+			map[string]any{"token": "abc123"},
+			HostCredentialsToken("abc123"),
+		},
+		"bound token": {
+			map[string]any{"token": "abc123", "cert_thumbprint": "thumb"},
+			HostCredentialsBoundToken{AccessToken: "abc123", Thumbprint: "thumb"},
+		},
+		"basic": {
+			map[string]any{"username": "alfred", "password": "hunter2"},
+			HostCredentialsBasic{Username: "alfred", Password: "hunter2"},
+		},
+		"unsupported": {
+			map[string]any{"username": "alfred"},
+			nil,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := HostCredentialsFromMap(test.m); got != test.want {
+				t.Errorf("wrong result %#v; want %#v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package svcauth
+
+import (
+	"github.com/opentofu/svchost"
+	"github.com/opentofu/svchost/internal/events"
+)
+
+// CredentialsEventKind describes the kind of change reported by a
+// [CredentialsEvent].
+type CredentialsEventKind int
+
+const (
+	// CredentialsStored means that new credentials were stored for the host.
+	CredentialsStored CredentialsEventKind = iota + 1
+
+	// CredentialsForgotten means that any stored credentials for the host
+	// were discarded.
+	CredentialsForgotten
+)
+
+func (k CredentialsEventKind) String() string {
+	switch k {
+	case CredentialsStored:
+		return "stored"
+	case CredentialsForgotten:
+		return "forgotten"
+	default:
+		return "unknown"
+	}
+}
+
+// CredentialsEvent describes a successful change to the credentials stored
+// for a particular host.
+type CredentialsEvent struct {
+	Host svchost.Hostname
+	Kind CredentialsEventKind
+}
+
+// CredentialsEvents delivers [CredentialsEvent] notifications to subscribers.
+//
+// Use [WithEvents] to have a credentials store constructed by this package
+// publish events to a CredentialsEvents object. The same object can be used
+// with multiple stores to receive notifications about all of them.
+//
+// The zero value of CredentialsEvents is ready to use. It is safe for
+// concurrent use, but must not be copied after first use.
+type CredentialsEvents struct {
+	bus events.Bus[CredentialsEvent]
+}
+
+// Subscribe registers a function to be called for each subsequent event,
+// and returns a function that cancels the subscription.
+//
+// The function is called synchronously from whichever goroutine changed the
+// stored credentials, after the change is complete, and so it should
+// return promptly.
+func (e *CredentialsEvents) Subscribe(fn func(CredentialsEvent)) (unsubscribe func()) {
+	return e.bus.Subscribe(fn)
+}
+
+func (e *CredentialsEvents) publish(host svchost.Hostname, kind CredentialsEventKind) {
+	if e == nil {
+		return
+	}
+	e.bus.Publish(CredentialsEvent{
+		Host: host,
+		Kind: kind,
+	})
+}
@@ -0,0 +1,413 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package svcauth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/zclconf/go-cty/cty"
+	"golang.org/x/oauth2"
+
+	"github.com/opentofu/svchost"
+	"github.com/opentofu/svchost/internal/clock"
+)
+
+// ExpiringHostCredentials is implemented by [HostCredentials] that are valid
+// only for a limited time, and that can obtain replacement credentials before
+// or after they expire, such as OAuth access tokens issued with a refresh
+// token.
+//
+// [RefreshingCredentialsSource] refreshes such credentials automatically,
+// and [CachingCredentialsSource] does not return them from its cache once
+// they have expired.
+type ExpiringHostCredentials interface {
+	HostCredentials
+
+	// ExpiresAt returns the time after which the credentials are no longer
+	// valid, or the zero time if they do not expire.
+	ExpiresAt() time.Time
+
+	// Refresh obtains new credentials to replace the receiver.
+	Refresh(ctx context.Context) (HostCredentials, error)
+}
+
+// credentialsExpired returns true if the given credentials will have expired
+// by the given time. This includes credentials that report their expiry time
+// but can't refresh themselves, such as [HostCredentialsOAuthTokens].
+func credentialsExpired(creds HostCredentials, at time.Time) bool {
+	expiring, ok := creds.(interface{ ExpiresAt() time.Time })
+	if !ok {
+		return false
+	}
+	expiresAt := expiring.ExpiresAt()
+	return !expiresAt.IsZero() && !at.Before(expiresAt)
+}
+
+// oauthTokenExpiry returns the expiry time of the given OAuth access token,
+// given the expiry time reported by the server, if any.
+//
+// If the server didn't say when the token expires but the token is a JSON
+// Web Token with an expiry claim, as reported by [InspectJWT], then the
+// result is the time from that claim instead.
+func oauthTokenExpiry(accessToken string, expiry time.Time) time.Time {
+	if expiry.IsZero() {
+		if claims, ok := InspectJWT(accessToken); ok {
+			return claims.ExpiresAt
+		}
+	}
+	return expiry
+}
+
+// HostCredentialsOAuthToken is a HostCredentials implementation that
+// represents an OAuth access token, which is sent in the same way as
+// [HostCredentialsToken] but which can be refreshed using its refresh
+// token, if any.
+type HostCredentialsOAuthToken struct {
+	// Config is the configuration of the OAuth client that the token was
+	// issued to, whose Endpoint is used to refresh the token.
+	Config *oauth2.Config
+
+	// Token is the current token.
+	Token *oauth2.Token
+}
+
+// Interface implementation assertions. Compilation will fail here if
+// HostCredentialsOAuthToken does not fully implement these interfaces.
+var _ ExpiringHostCredentials = HostCredentialsOAuthToken{}
+var _ NewHostCredentials = HostCredentialsOAuthToken{}
+
+// PrepareRequest alters the given HTTP request by setting its Authorization
+// header to the string "Bearer " followed by the access token.
+func (tc HostCredentialsOAuthToken) PrepareRequest(req *http.Request) {
+	HostCredentialsToken(tc.Token.AccessToken).PrepareRequest(req)
+}
+
+// ExpiresAt returns the expiry time of the access token. This implements
+// [ExpiringHostCredentials].
+//
+// If the server didn't say when the token expires but the token is a JSON
+// Web Token with an expiry claim, as reported by [InspectJWT], then the
+// result is the time from that claim instead, so that
+// [RefreshingCredentialsSource] can refresh the token before it expires.
+func (tc HostCredentialsOAuthToken) ExpiresAt() time.Time {
+	return oauthTokenExpiry(tc.Token.AccessToken, tc.Token.Expiry)
+}
+
+// Refresh uses the refresh token to obtain a new access token, returning
+// an error if there is no refresh token. This implements
+// [ExpiringHostCredentials].
+//
+// Use the [oauth2.HTTPClient] context key to choose the HTTP client used
+// to make the request.
+func (tc HostCredentialsOAuthToken) Refresh(ctx context.Context) (HostCredentials, error) {
+	if tc.Token.RefreshToken == "" {
+		return nil, errors.New("access token has expired and cannot be refreshed")
+	}
+	// The token source refreshes only if the token it is given is invalid,
+	// so we give it a token with only the refresh token to force that.
+	src := tc.Config.TokenSource(ctx, &oauth2.Token{RefreshToken: tc.Token.RefreshToken})
+	token, err := src.Token()
+	if err != nil {
+		return nil, err
+	}
+	return HostCredentialsOAuthToken{Config: tc.Config, Token: token}, nil
+}
+
+// ToStore returns a credentials object describing the access token, refresh
+// token, and expiry time in the same way as [HostCredentialsOAuthTokens].
+// This implements [NewHostCredentials].
+//
+// The client configuration is not saved, so credentials loaded from a store
+// are a [HostCredentialsOAuthTokens] instead. Use [WithOAuthConfig] to allow
+// [NewCredentialsSource] to refresh them again.
+func (tc HostCredentialsOAuthToken) ToStore() cty.Value {
+	return HostCredentialsOAuthTokens{
+		AccessToken:  tc.Token.AccessToken,
+		RefreshToken: tc.Token.RefreshToken,
+		Expiry:       tc.Token.Expiry,
+	}.ToStore()
+}
+
+// RefreshingCredentialsSource creates a new credentials source that wraps
+// another and refreshes any [ExpiringHostCredentials] it returns that will
+// expire within the given leeway, so that callers don't send credentials that
+// expire before the server receives them.
+//
+// Refreshed credentials are kept in memory and returned for subsequent
+// requests for the same hostname until they in turn need refreshing. If the
+// wrapped source is a [CredentialsStore] and the refreshed credentials
+// implement [NewHostCredentials] then they are also saved to the store,
+// using an atomic update if the store implements [CredentialsUpdater] so that
+// other programs sharing the store don't refresh the same credentials too.
+//
+// Only one refresh at a time is made for each hostname, and concurrent
+// requests for the same hostname wait for it and then share its result, so
+// that refresh tokens that can be used only once are not used twice.
+//
+// The result also implements [CredentialsStore] by forwarding to the inner
+// source, but the store and forget methods will fail with an error if the
+// wrapped source does not also implement that interface.
+//
+// A [HostCredentialsOAuthTokens] loaded from a store can't refresh itself
+// because the store doesn't record the OAuth client it was issued to, and so
+// it is returned unchanged even once it has expired. Use
+// [NewCredentialsSource] with the [WithRefresh] and [WithOAuthConfig] options
+// to refresh such tokens too.
+//
+// Use [NewCredentialsSource] with the [WithRefresh] and [WithClock] options
+// to decide when credentials expire using a clock other than the system
+// clock.
+func RefreshingCredentialsSource(source CredentialsSource, leeway time.Duration) CredentialsSource {
+	return newRefreshingCredentialsSource(source, leeway, clock.Real, nil)
+}
+
+// newRefreshingCredentialsSource returns a refreshing credentials source
+// that uses the given clock to decide whether credentials will expire within
+// the given leeway, and the given function, if any, to find the OAuth client
+// configuration for a [HostCredentialsOAuthTokens].
+func newRefreshingCredentialsSource(source CredentialsSource, leeway time.Duration, clk clock.Clock, oauthConfig OAuthConfigFunc) *refreshingCredentialsSource {
+	return &refreshingCredentialsSource{
+		source:      source,
+		leeway:      leeway,
+		clock:       clk,
+		oauthConfig: oauthConfig,
+		refreshed:   map[svchost.Hostname]HostCredentials{},
+		refreshing:  map[svchost.Hostname]*sync.Mutex{},
+	}
+}
+
+type refreshingCredentialsSource struct {
+	source      CredentialsSource
+	leeway      time.Duration
+	clock       clock.Clock
+	oauthConfig OAuthConfigFunc
+	refreshed   map[svchost.Hostname]HostCredentials
+	// refreshing holds a lock for each hostname that is held while
+	// refreshing its credentials and saving the result.
+	refreshing map[svchost.Hostname]*sync.Mutex
+	mu         sync.Mutex
+}
+
+// ForHost implements [CredentialsSource].
+func (s *refreshingCredentialsSource) ForHost(ctx context.Context, host svchost.Hostname) (HostCredentials, error) {
+	deadline := s.clock.Now().Add(s.leeway)
+
+	creds, err := s.current(ctx, host)
+	if err != nil || !s.needsRefresh(creds, deadline) {
+		return creds, err
+	}
+
+	lock := s.hostLock(host)
+	lock.Lock()
+	defer lock.Unlock()
+
+	// Another call may have refreshed the credentials while we were
+	// waiting for the lock, in which case we use its result.
+	creds, err = s.current(ctx, host)
+	if err != nil || !s.needsRefresh(creds, deadline) {
+		return creds, err
+	}
+
+	creds, err = s.refreshAndSave(ctx, host, creds, deadline)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := creds.(ExpiringHostCredentials); !ok && credentialsExpired(creds, deadline) {
+		// The credentials couldn't be refreshed, so we'll look them up
+		// again next time in case they have been replaced.
+		return creds, nil
+	}
+	s.mu.Lock()
+	s.refreshed[host] = creds
+	s.mu.Unlock()
+	return creds, nil
+}
+
+// errRefreshNotNeeded is returned by the update function in refreshAndSave
+// to leave the stored credentials unchanged.
+var errRefreshNotNeeded = errors.New("stored credentials do not need refreshing")
+
+// refreshAndSave refreshes the given credentials for the given host and
+// saves the result in the wrapped source, if it is a [CredentialsStore].
+//
+// If the wrapped source implements [CredentialsUpdater] then the refresh
+// happens during an atomic update, and the stored credentials are used
+// instead of the given ones if they have already been refreshed by some
+// other program sharing the same store and so won't expire before the given
+// deadline. This avoids using a refresh token that can only be used once for
+// a second time.
+func (s *refreshingCredentialsSource) refreshAndSave(ctx context.Context, host svchost.Hostname, creds HostCredentials, deadline time.Time) (HostCredentials, error) {
+	updater, ok := s.source.(CredentialsUpdater)
+	if !ok {
+		expiring, err := s.expiring(ctx, host, creds)
+		if err != nil {
+			return nil, svchost.WrapHostError(host, opForHost, err)
+		}
+		if expiring == nil {
+			return creds, nil
+		}
+		refreshed, err := expiring.Refresh(ctx)
+		if err != nil {
+			return nil, svchost.WrapHostError(host, opForHost, err)
+		}
+		if store, ok := s.source.(CredentialsStore); ok {
+			if toStore, ok := refreshed.(NewHostCredentials); ok {
+				if err := store.StoreForHost(ctx, host, toStore); err != nil {
+					return nil, svchost.WrapHostError(host, opStoreForHost, err)
+				}
+			}
+		}
+		return refreshed, nil
+	}
+
+	var refreshed HostCredentials
+	err := updater.UpdateForHost(ctx, host, func(old HostCredentials) (NewHostCredentials, error) {
+		current := creds
+		if old != nil {
+			current = old
+		}
+		if !credentialsExpired(current, deadline) {
+			refreshed = current
+			return nil, errRefreshNotNeeded
+		}
+		expiring, err := s.expiring(ctx, host, current)
+		if err != nil {
+			return nil, err
+		}
+		if expiring == nil {
+			refreshed = current
+			return nil, errRefreshNotNeeded
+		}
+		refreshed, err = expiring.Refresh(ctx)
+		if err != nil {
+			return nil, err
+		}
+		toStore, ok := refreshed.(NewHostCredentials)
+		if !ok {
+			return nil, errRefreshNotNeeded
+		}
+		return toStore, nil
+	})
+	if err != nil && !errors.Is(err, errRefreshNotNeeded) {
+		return nil, svchost.WrapHostError(host, opForHost, err)
+	}
+	return refreshed, nil
+}
+
+// needsRefresh returns true if the given credentials will have expired by
+// the given deadline and might be refreshable.
+func (s *refreshingCredentialsSource) needsRefresh(creds HostCredentials, deadline time.Time) bool {
+	switch creds.(type) {
+	case ExpiringHostCredentials:
+	case HostCredentialsOAuthTokens:
+		if s.oauthConfig == nil {
+			return false
+		}
+	default:
+		return false
+	}
+	return credentialsExpired(creds, deadline)
+}
+
+// expiring returns the given credentials as [ExpiringHostCredentials] so
+// that they can be refreshed, or nil if they can't be.
+//
+// A [HostCredentialsOAuthTokens] can be refreshed only if the function given
+// in [WithOAuthConfig] returns the configuration of the OAuth client that
+// its tokens were issued to.
+func (s *refreshingCredentialsSource) expiring(ctx context.Context, host svchost.Hostname, creds HostCredentials) (ExpiringHostCredentials, error) {
+	switch creds := creds.(type) {
+	case ExpiringHostCredentials:
+		return creds, nil
+	case HostCredentialsOAuthTokens:
+		if s.oauthConfig == nil {
+			return nil, nil
+		}
+		config, err := s.oauthConfig(ctx, host)
+		if err != nil || config == nil {
+			return nil, err
+		}
+		return creds.WithConfig(config), nil
+	default:
+		return nil, nil
+	}
+}
+
+// current returns the most recently refreshed credentials for the given
+// host, or otherwise the credentials from the wrapped source.
+func (s *refreshingCredentialsSource) current(ctx context.Context, host svchost.Hostname) (HostCredentials, error) {
+	s.mu.Lock()
+	creds, ok := s.refreshed[host]
+	s.mu.Unlock()
+	if ok {
+		return creds, nil
+	}
+	creds, err := s.source.ForHost(ctx, host)
+	if err != nil {
+		return nil, svchost.WrapHostError(host, opForHost, err)
+	}
+	return creds, nil
+}
+
+// hostLock returns the lock that must be held while refreshing the
+// credentials for the given host.
+func (s *refreshingCredentialsSource) hostLock(host svchost.Hostname) *sync.Mutex {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lock, ok := s.refreshing[host]
+	if !ok {
+		lock = &sync.Mutex{}
+		s.refreshing[host] = lock
+	}
+	return lock
+}
+
+// StoreForHost implements [CredentialsStore].
+func (s *refreshingCredentialsSource) StoreForHost(ctx context.Context, host svchost.Hostname, credentials NewHostCredentials) error {
+	s.mu.Lock()
+	delete(s.refreshed, host)
+	s.mu.Unlock()
+
+	store, ok := s.source.(CredentialsStore)
+	if !ok {
+		return svchost.WrapHostError(host, opStoreForHost, errNoStore)
+	}
+	return svchost.WrapHostError(host, opStoreForHost, store.StoreForHost(ctx, host, credentials))
+}
+
+// UpdateForHost implements [CredentialsUpdater], and is atomic only if the
+// wrapped source also implements that interface.
+func (s *refreshingCredentialsSource) UpdateForHost(ctx context.Context, host svchost.Hostname, update UpdateFunc) error {
+	// Holding the host's lock prevents a concurrent refresh from saving
+	// credentials based on the ones being replaced.
+	lock := s.hostLock(host)
+	lock.Lock()
+	defer lock.Unlock()
+	s.mu.Lock()
+	delete(s.refreshed, host)
+	s.mu.Unlock()
+
+	store, ok := s.source.(CredentialsStore)
+	if !ok {
+		return svchost.WrapHostError(host, opUpdateForHost, errNoStore)
+	}
+	return svchost.WrapHostError(host, opUpdateForHost, UpdateForHost(ctx, store, host, update))
+}
+
+// ForgetForHost implements [CredentialsStore].
+func (s *refreshingCredentialsSource) ForgetForHost(ctx context.Context, host svchost.Hostname) error {
+	s.mu.Lock()
+	delete(s.refreshed, host)
+	s.mu.Unlock()
+
+	store, ok := s.source.(CredentialsStore)
+	if !ok {
+		return svchost.WrapHostError(host, opForgetForHost, errNoStore)
+	}
+	return svchost.WrapHostError(host, opForgetForHost, store.ForgetForHost(ctx, host))
+}
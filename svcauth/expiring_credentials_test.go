@@ -0,0 +1,420 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package svcauth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/zclconf/go-cty/cty"
+	"golang.org/x/oauth2"
+
+	"github.com/opentofu/svchost"
+	"github.com/opentofu/svchost/internal/clock"
+)
+
+// testExpiringCredentials is an [ExpiringHostCredentials] whose refresh
+// produces a new generation of itself that expires an hour after it.
+type testExpiringCredentials struct {
+	generation int
+	expiresAt  time.Time
+	refreshes  *int
+}
+
+func (c testExpiringCredentials) PrepareRequest(req *http.Request) {}
+
+func (c testExpiringCredentials) ExpiresAt() time.Time {
+	return c.expiresAt
+}
+
+func (c testExpiringCredentials) Refresh(ctx context.Context) (HostCredentials, error) {
+	*c.refreshes++
+	return testExpiringCredentials{
+		generation: c.generation + 1,
+		expiresAt:  c.expiresAt.Add(time.Hour),
+		refreshes:  c.refreshes,
+	}, nil
+}
+
+func (c testExpiringCredentials) ToStore() cty.Value {
+	return cty.ObjectVal(map[string]cty.Value{
+		"generation": cty.NumberIntVal(int64(c.generation)),
+	})
+}
+
+func TestRefreshingCredentialsSource(t *testing.T) {
+	host := svchost.Hostname("example.com")
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	refreshes := 0
+
+	t.Run("expiry", func(t *testing.T) {
+		refreshes = 0
+		clk := clock.NewFake(now)
+		store := &mapCredentialsStore{host: testExpiringCredentials{expiresAt: now.Add(time.Hour), refreshes: &refreshes}}
+		source, err := NewCredentialsSource(store, WithRefresh(time.Minute), WithClock(clk))
+		if err != nil {
+			t.Fatal(err)
+		}
+		generation := func() int {
+			t.Helper()
+			creds, err := source.ForHost(t.Context(), host)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			return creds.(testExpiringCredentials).generation
+		}
+
+		if got := generation(); got != 0 {
+			t.Errorf("wrong generation %d before the leeway; want 0", got)
+		}
+		if refreshes != 0 {
+			t.Errorf("credentials were refreshed %d times; want 0", refreshes)
+		}
+
+		// Within the leeway the credentials are refreshed once, and the
+		// refreshed credentials are used until they in turn need refreshing.
+		clk.Advance(59*time.Minute + 30*time.Second)
+		for range 2 {
+			if got := generation(); got != 1 {
+				t.Errorf("wrong generation %d within the leeway; want 1", got)
+			}
+		}
+		if refreshes != 1 {
+			t.Errorf("credentials were refreshed %d times; want 1", refreshes)
+		}
+		if got := (*store)[host].(testExpiringCredentials).generation; got != 1 {
+			t.Errorf("refreshed credentials were not saved to the store; stored generation is %d", got)
+		}
+
+		clk.Advance(time.Hour)
+		if got := generation(); got != 2 {
+			t.Errorf("wrong generation %d after the refreshed credentials expire; want 2", got)
+		}
+	})
+	t.Run("never expires", func(t *testing.T) {
+		refreshes = 0
+		store := &mapCredentialsStore{host: testExpiringCredentials{refreshes: &refreshes}}
+		source := RefreshingCredentialsSource(store, time.Minute)
+		if _, err := source.ForHost(t.Context(), host); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if refreshes != 0 {
+			t.Errorf("credentials were refreshed %d times; want 0", refreshes)
+		}
+	})
+	t.Run("negative leeway", func(t *testing.T) {
+		if _, err := NewCredentialsSource(NoCredentials, WithRefresh(-time.Second)); err == nil {
+			t.Error("unexpected success; want error")
+		}
+	})
+}
+
+// onceRefreshCredentials is an [ExpiringHostCredentials] that has already
+// expired and whose refresh token can be used only once, like a rotating
+// refresh token.
+type onceRefreshCredentials struct {
+	token     string
+	refreshes *atomic.Int32
+	started   chan<- struct{}
+	release   <-chan struct{}
+}
+
+func (c onceRefreshCredentials) PrepareRequest(req *http.Request) {}
+
+func (c onceRefreshCredentials) ExpiresAt() time.Time {
+	if c.token == "refreshed" {
+		return time.Time{}
+	}
+	return time.Unix(1, 0)
+}
+
+func (c onceRefreshCredentials) Refresh(ctx context.Context) (HostCredentials, error) {
+	if c.refreshes.Add(1) > 1 {
+		return nil, errors.New("refresh token was already used")
+	}
+	c.started <- struct{}{}
+	<-c.release
+	return onceRefreshCredentials{token: "refreshed"}, nil
+}
+
+func (c onceRefreshCredentials) ToStore() cty.Value {
+	return cty.ObjectVal(map[string]cty.Value{"token": cty.StringVal(c.token)})
+}
+
+// lockedCredentialsStore is a [CredentialsStore] for a single host that is
+// safe for concurrent use.
+type lockedCredentialsStore struct {
+	mu     sync.Mutex
+	creds  HostCredentials
+	stores int
+}
+
+func (s *lockedCredentialsStore) ForHost(context.Context, svchost.Hostname) (HostCredentials, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.creds, nil
+}
+
+func (s *lockedCredentialsStore) StoreForHost(_ context.Context, _ svchost.Hostname, creds NewHostCredentials) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.creds = creds.(HostCredentials)
+	s.stores++
+	return nil
+}
+
+func (s *lockedCredentialsStore) ForgetForHost(context.Context, svchost.Hostname) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.creds = nil
+	return nil
+}
+
+func TestRefreshingCredentialsSource_concurrent(t *testing.T) {
+	host := svchost.Hostname("example.com")
+	var refreshes atomic.Int32
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	store := &lockedCredentialsStore{creds: onceRefreshCredentials{
+		token:     "expired",
+		refreshes: &refreshes,
+		started:   started,
+		release:   release,
+	}}
+	source := RefreshingCredentialsSource(store, time.Minute)
+
+	const callers = 10
+	var wg sync.WaitGroup
+	for range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			creds, err := source.ForHost(t.Context(), host)
+			if err != nil {
+				t.Errorf("unexpected error: %s", err)
+				return
+			}
+			if got := creds.(onceRefreshCredentials).token; got != "refreshed" {
+				t.Errorf("wrong token %q; want the refreshed token", got)
+			}
+		}()
+	}
+	// We give the other callers a chance to reach the refresh while the
+	// first refresh is still in progress.
+	<-started
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if got := refreshes.Load(); got != 1 {
+		t.Errorf("credentials were refreshed %d times; want 1", got)
+	}
+	if store.stores != 1 {
+		t.Errorf("refreshed credentials were stored %d times; want 1", store.stores)
+	}
+}
+
+// racedCredentialsStore is a [CredentialsUpdater] that simulates another
+// program refreshing the stored credentials between a lookup and an update.
+type racedCredentialsStore struct {
+	lockedCredentialsStore
+	refreshedElsewhere HostCredentials
+}
+
+func (s *racedCredentialsStore) UpdateForHost(ctx context.Context, host svchost.Hostname, update UpdateFunc) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	creds, err := update(s.refreshedElsewhere)
+	if err != nil {
+		return err
+	}
+	s.creds = creds.(HostCredentials)
+	s.stores++
+	return nil
+}
+
+func TestRefreshingCredentialsSource_update(t *testing.T) {
+	host := svchost.Hostname("example.com")
+	var refreshes atomic.Int32
+	store := &racedCredentialsStore{
+		lockedCredentialsStore: lockedCredentialsStore{creds: onceRefreshCredentials{
+			token:     "expired",
+			refreshes: &refreshes,
+		}},
+		refreshedElsewhere: onceRefreshCredentials{token: "refreshed"},
+	}
+	source := RefreshingCredentialsSource(store, time.Minute)
+
+	// The credentials were already refreshed by the time the update began,
+	// so their refresh token must not be used again.
+	creds, err := source.ForHost(t.Context(), host)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got := creds.(onceRefreshCredentials).token; got != "refreshed" {
+		t.Errorf("wrong token %q; want the token refreshed elsewhere", got)
+	}
+	if got := refreshes.Load(); got != 0 {
+		t.Errorf("credentials were refreshed %d times; want 0", got)
+	}
+	if store.stores != 0 {
+		t.Errorf("credentials were stored %d times; want 0", store.stores)
+	}
+}
+
+func TestCachingCredentialsSourceExpiry(t *testing.T) {
+	host := svchost.Hostname("example.com")
+	refreshes := 0
+	store := &mapCredentialsStore{host: testExpiringCredentials{expiresAt: time.Now().Add(-time.Minute), refreshes: &refreshes}}
+	source := CachingCredentialsSource(store)
+
+	if _, err := source.ForHost(t.Context(), host); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	(*store)[host] = HostCredentialsToken("new")
+	creds, err := source.ForHost(t.Context(), host)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if creds != HostCredentialsToken("new") {
+		t.Errorf("expired credentials were returned from the cache: %#v", creds)
+	}
+}
+
+func TestHostCredentialsOAuthTokenRefresh(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("invalid token request: %s", err)
+		}
+		if got, want := r.Form.Get("grant_type"), "refresh_token"; got != want {
+			t.Errorf("wrong grant_type %q; want %q", got, want)
+		}
+		if got, want := r.Form.Get("refresh_token"), "refresh-me"; got != want {
+			t.Errorf("wrong refresh_token %q; want %q", got, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"new-access","token_type":"Bearer","refresh_token":"refresh-me-again","expires_in":3600}`))
+	}))
+	defer server.Close()
+
+	creds := HostCredentialsOAuthToken{
+		Config: &oauth2.Config{
+			ClientID: "tofu",
+			Endpoint: oauth2.Endpoint{TokenURL: server.URL},
+		},
+		Token: &oauth2.Token{
+			AccessToken:  "old-access",
+			RefreshToken: "refresh-me",
+			Expiry:       time.Now().Add(-time.Minute),
+		},
+	}
+	refreshed, err := creds.Refresh(t.Context())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	got := refreshed.(HostCredentialsOAuthToken)
+	if got.Token.AccessToken != "new-access" {
+		t.Errorf("wrong access token %q; want %q", got.Token.AccessToken, "new-access")
+	}
+	if !got.ExpiresAt().After(time.Now()) {
+		t.Errorf("refreshed token already expired at %s", got.ExpiresAt())
+	}
+
+	creds.Token.RefreshToken = ""
+	if _, err := creds.Refresh(t.Context()); err == nil {
+		t.Error("unexpected success refreshing without a refresh token")
+	}
+}
+
+func TestRefreshingCredentialsSource_storedOAuthTokens(t *testing.T) {
+	host := svchost.Hostname("example.com")
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("invalid token request: %s", err)
+		}
+		if got, want := r.Form.Get("refresh_token"), "refresh-me"; got != want {
+			t.Errorf("wrong refresh_token %q; want %q", got, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"new-access","token_type":"Bearer","refresh_token":"refresh-me-again","expires_in":3600}`))
+	}))
+	defer server.Close()
+	config := &oauth2.Config{
+		ClientID: "tofu",
+		Endpoint: oauth2.Endpoint{TokenURL: server.URL},
+	}
+
+	store := FileCredentialsStore(filepath.Join(t.TempDir(), "credentials.tfrc.json"))
+	err := store.StoreForHost(t.Context(), host, HostCredentialsOAuthToken{
+		Config: config,
+		Token: &oauth2.Token{
+			AccessToken:  "old-access",
+			RefreshToken: "refresh-me",
+			Expiry:       time.Now().Add(-time.Minute),
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	t.Run("without config", func(t *testing.T) {
+		source := RefreshingCredentialsSource(store, time.Minute)
+		creds, err := source.ForHost(t.Context(), host)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if got, ok := creds.(HostCredentialsOAuthTokens); !ok || got.AccessToken != "old-access" {
+			t.Errorf("wrong credentials %#v; want the stored tokens unchanged", creds)
+		}
+		if got := requests.Load(); got != 0 {
+			t.Errorf("made %d token requests; want 0", got)
+		}
+	})
+	t.Run("with config", func(t *testing.T) {
+		source, err := NewCredentialsSource(store,
+			WithRefresh(time.Minute),
+			WithOAuthConfig(func(ctx context.Context, got svchost.Hostname) (*oauth2.Config, error) {
+				if got != host {
+					t.Errorf("wrong hostname %q; want %q", got, host)
+				}
+				return config, nil
+			}),
+		)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		creds, err := source.ForHost(t.Context(), host)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if got, ok := creds.(HostCredentialsOAuthToken); !ok || got.Token.AccessToken != "new-access" {
+			t.Errorf("wrong credentials %#v; want refreshed token", creds)
+		}
+		if got := requests.Load(); got != 1 {
+			t.Errorf("made %d token requests; want 1", got)
+		}
+
+		// The refreshed tokens must have been saved in the store.
+		stored, err := store.ForHost(t.Context(), host)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		got, ok := stored.(HostCredentialsOAuthTokens)
+		if !ok || got.AccessToken != "new-access" || got.RefreshToken != "refresh-me-again" {
+			t.Errorf("wrong stored credentials %#v; want refreshed tokens", stored)
+		}
+		if !got.ExpiresAt().After(time.Now()) {
+			t.Errorf("stored token already expired at %s", got.ExpiresAt())
+		}
+	})
+}
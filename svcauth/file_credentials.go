@@ -0,0 +1,218 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package svcauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+
+	"github.com/opentofu/svchost"
+	"github.com/opentofu/svchost/internal/filelock"
+)
+
+// FileCredentialsStore returns a [CredentialsStore] that reads and writes
+// credentials in the given JSON file, using the same format as the
+// "credentials.tfrc.json" file that OpenTofu CLI uses to save credentials
+// obtained by "tofu login", so that other tools can share credentials
+// with it.
+//
+// The file contains a JSON object whose "credentials" property maps each
+// hostname to an object describing its credentials. Any other properties
+// are preserved when the file is updated.
+//
+// If the file does not exist then the store initially has no credentials,
+// and the file is created when credentials are first stored. The file is
+// replaced atomically on each update, using a lock file alongside it to
+// coordinate concurrent updates, and is readable only by its owner.
+func FileCredentialsStore(filename string) CredentialsStore {
+	return fileCredentialsStore(filename)
+}
+
+type fileCredentialsStore string
+
+// ForHost implements [CredentialsSource].
+func (s fileCredentialsStore) ForHost(_ context.Context, host svchost.Hostname) (HostCredentials, error) {
+	_, creds, err := s.read()
+	if err != nil {
+		return nil, svchost.WrapHostError(host, opForHost, err)
+	}
+	key, ok := credentialsKey(creds, host)
+	if !ok {
+		return nil, nil
+	}
+
+	var m map[string]any
+	if err := json.Unmarshal(creds[key], &m); err != nil {
+		return nil, svchost.WrapHostError(host, opForHost, fmt.Errorf("invalid credentials in %s: %w", string(s), err))
+	}
+	return HostCredentialsFromMap(m), nil
+}
+
+// StoreForHost implements [CredentialsStore].
+func (s fileCredentialsStore) StoreForHost(ctx context.Context, host svchost.Hostname, credentials NewHostCredentials) error {
+	toStore := credentials.ToStore()
+	toStoreRaw, err := ctyjson.Marshal(toStore, toStore.Type())
+	if err != nil {
+		return svchost.WrapHostError(host, opStoreForHost, fmt.Errorf("can't serialize credentials to store: %w", err))
+	}
+	err = s.update(ctx, func(creds map[string]json.RawMessage) error {
+		if key, ok := credentialsKey(creds, host); ok {
+			delete(creds, key)
+		}
+		creds[string(host)] = toStoreRaw
+		return nil
+	})
+	return svchost.WrapHostError(host, opStoreForHost, err)
+}
+
+// ForgetForHost implements [CredentialsStore].
+func (s fileCredentialsStore) ForgetForHost(ctx context.Context, host svchost.Hostname) error {
+	err := s.update(ctx, func(creds map[string]json.RawMessage) error {
+		if key, ok := credentialsKey(creds, host); ok {
+			delete(creds, key)
+		}
+		return nil
+	})
+	return svchost.WrapHostError(host, opForgetForHost, err)
+}
+
+// UpdateForHost implements [CredentialsUpdater], holding the lock on the
+// file for the whole update so that other processes sharing the file
+// cannot change it in the meantime.
+func (s fileCredentialsStore) UpdateForHost(ctx context.Context, host svchost.Hostname, update UpdateFunc) error {
+	err := s.update(ctx, func(creds map[string]json.RawMessage) error {
+		var old HostCredentials
+		key, exists := credentialsKey(creds, host)
+		if exists {
+			var m map[string]any
+			if err := json.Unmarshal(creds[key], &m); err != nil {
+				return fmt.Errorf("invalid credentials in %s: %w", string(s), err)
+			}
+			old = HostCredentialsFromMap(m)
+		}
+		credentials, err := update(old)
+		if err != nil {
+			return err
+		}
+		if exists {
+			delete(creds, key)
+		}
+		if credentials == nil {
+			return nil
+		}
+		toStore := credentials.ToStore()
+		toStoreRaw, err := ctyjson.Marshal(toStore, toStore.Type())
+		if err != nil {
+			return fmt.Errorf("can't serialize credentials to store: %w", err)
+		}
+		creds[string(host)] = toStoreRaw
+		return nil
+	})
+	return svchost.WrapHostError(host, opUpdateForHost, err)
+}
+
+// read returns the top-level properties of the file and the content of its
+// "credentials" property, both of which are empty if the file doesn't exist.
+func (s fileCredentialsStore) read() (doc, creds map[string]json.RawMessage, err error) {
+	doc = make(map[string]json.RawMessage)
+	creds = make(map[string]json.RawMessage)
+
+	raw, err := os.ReadFile(string(s))
+	if errors.Is(err, fs.ErrNotExist) {
+		return doc, creds, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, nil, fmt.Errorf("invalid credentials file %s: %w", string(s), err)
+	}
+	if rawCreds, ok := doc["credentials"]; ok {
+		if err := json.Unmarshal(rawCreds, &creds); err != nil {
+			return nil, nil, fmt.Errorf("invalid \"credentials\" property in %s: %w", string(s), err)
+		}
+		if creds == nil {
+			// "credentials": null
+			creds = make(map[string]json.RawMessage)
+		}
+	}
+	return doc, creds, nil
+}
+
+// update reads the file, calls the given function to modify its credentials,
+// and then writes the result back to the file, all while holding the lock.
+// If the function returns an error then the file is left unchanged.
+func (s fileCredentialsStore) update(ctx context.Context, modify func(creds map[string]json.RawMessage) error) error {
+	dir := filepath.Dir(string(s))
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create directory for credentials file: %w", err)
+	}
+	unlock, err := filelock.Lock(ctx, string(s)+".lock")
+	if err != nil {
+		return fmt.Errorf("failed to lock credentials file: %w", err)
+	}
+	defer unlock() //nolint:errcheck
+
+	doc, creds, err := s.read()
+	if err != nil {
+		return err
+	}
+	if err := modify(creds); err != nil {
+		return err
+	}
+	doc["credentials"], err = json.Marshal(creds)
+	if err != nil {
+		return err
+	}
+	raw, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	raw = append(raw, '\n')
+
+	// We write to a temporary file and then rename it into place so that
+	// concurrent readers never see a partially-written file. The temporary
+	// file is created with permissions that allow only the owner to read it.
+	f, err := os.CreateTemp(dir, ".tmp-credentials-*")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(raw)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), 0o600)
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), string(s))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
+
+// credentialsKey returns the key in the given credentials that refers to the
+// given hostname, which might not be in the normalized form if the file was
+// written by hand.
+func credentialsKey(creds map[string]json.RawMessage, host svchost.Hostname) (string, bool) {
+	if _, ok := creds[string(host)]; ok {
+		return string(host), true
+	}
+	for key := range creds {
+		if normalized, err := svchost.ForComparison(key); err == nil && normalized == host {
+			return key, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,107 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package svcauth
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/opentofu/svchost"
+)
+
+func TestFileCredentialsStore(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "subdir", "credentials.tfrc.json")
+	store := FileCredentialsStore(filename)
+	host := svchost.Hostname("example.com")
+
+	creds, err := store.ForHost(t.Context(), host)
+	if err != nil {
+		t.Fatalf("unexpected error with no file: %s", err)
+	}
+	if creds != nil {
+		t.Fatalf("unexpected credentials with no file: %#v", creds)
+	}
+
+	if err := store.StoreForHost(t.Context(), host, HostCredentialsToken("abc123")); err != nil {
+		t.Fatalf("unexpected error storing: %s", err)
+	}
+	creds, err = store.ForHost(t.Context(), host)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got, want := creds, HostCredentialsToken("abc123"); got != want {
+		t.Errorf("wrong credentials %#v; want %#v", got, want)
+	}
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := info.Mode().Perm(), os.FileMode(0o600); got != want {
+			t.Errorf("wrong file permissions %s; want %s", got, want)
+		}
+	}
+
+	if err := store.ForgetForHost(t.Context(), host); err != nil {
+		t.Fatalf("unexpected error forgetting: %s", err)
+	}
+	creds, err = store.ForHost(t.Context(), host)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if creds != nil {
+		t.Errorf("unexpected credentials after forget: %#v", creds)
+	}
+}
+
+func TestFileCredentialsStoreExistingFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "credentials.tfrc.json")
+	const existing = `{
+  "credentials": {
+    "EXAMPLE.com": {"token": "old"},
+    "other.example.net": {"token": "other"}
+  },
+  "unrelated": {"preserved": true}
+}`
+	if err := os.WriteFile(filename, []byte(existing), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	store := FileCredentialsStore(filename)
+
+	// Keys that aren't in the normalized form are still recognized.
+	creds, err := store.ForHost(t.Context(), "example.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got, want := creds, HostCredentialsToken("old"); got != want {
+		t.Errorf("wrong credentials %#v; want %#v", got, want)
+	}
+
+	if err := store.StoreForHost(t.Context(), "example.com", HostCredentialsToken("new")); err != nil {
+		t.Fatalf("unexpected error storing: %s", err)
+	}
+	raw, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Fatalf("file is not valid JSON after update: %s", err)
+	}
+	want := map[string]any{
+		"credentials": map[string]any{
+			"example.com":       map[string]any{"token": "new"},
+			"other.example.net": map[string]any{"token": "other"},
+		},
+		"unrelated": map[string]any{"preserved": true},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("wrong file content after update\n%s", diff)
+	}
+}
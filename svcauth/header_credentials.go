@@ -0,0 +1,42 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package svcauth
+
+import (
+	"net/http"
+	"slices"
+)
+
+// HostCredentialsWithHeaders is implemented by HostCredentials that set
+// request headers other than Authorization, so that code forwarding a
+// request to another host, such as when following a redirect, can remove
+// all of them.
+type HostCredentialsWithHeaders interface {
+	HostCredentials
+
+	// CredentialHeaders returns the names of all of the request headers
+	// that PrepareRequest may set.
+	CredentialHeaders() []string
+}
+
+// CredentialHeaders returns the canonical names of the request headers that
+// the given credentials may set when preparing a request, which always
+// includes Authorization, along with any other headers declared by
+// credentials implementing [HostCredentialsWithHeaders], including those
+// within [CombinedHostCredentials].
+func CredentialHeaders(creds HostCredentials) []string {
+	ret := []string{"Authorization"}
+	switch creds := creds.(type) {
+	case CombinedHostCredentials:
+		for _, inner := range creds {
+			ret = append(ret, CredentialHeaders(inner)...)
+		}
+	case HostCredentialsWithHeaders:
+		for _, name := range creds.CredentialHeaders() {
+			ret = append(ret, http.CanonicalHeaderKey(name))
+		}
+	}
+	slices.Sort(ret)
+	return slices.Compact(ret)
+}
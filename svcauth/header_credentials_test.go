@@ -0,0 +1,42 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package svcauth
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCredentialHeaders(t *testing.T) {
+	aws := HostCredentialsAWSSigV4{Region: "us-east-1", Service: "s3", Credentials: AWSCredentials{}}
+	tests := map[string]struct {
+		creds HostCredentials
+		want  []string
+	}{
+		"nil": {
+			creds: nil,
+			want:  []string{"Authorization"},
+		},
+		"token": {
+			creds: HostCredentialsToken("abc123"),
+			want:  []string{"Authorization"},
+		},
+		"aws": {
+			creds: aws,
+			want:  []string{"Authorization", "X-Amz-Content-Sha256", "X-Amz-Date", "X-Amz-Security-Token"},
+		},
+		"combined": {
+			creds: CombinedHostCredentials{HostCredentialsToken("abc123"), nil, aws},
+			want:  []string{"Authorization", "X-Amz-Content-Sha256", "X-Amz-Date", "X-Amz-Security-Token"},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(test.want, CredentialHeaders(test.creds)); diff != "" {
+				t.Errorf("wrong headers\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright (c) The OpenTofu Authors
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package svcauth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/opentofu/svchost"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// HelperProgramCredentialsSource returns a [CredentialsStore] that runs the
+// given external program to obtain, store, and forget credentials, using
+// the same "credentials helper" protocol as OpenTofu CLI.
+//
+// The program is run with the given arguments followed by a verb ("get",
+// "store", or "forget") and the hostname. "get" must print a JSON object
+// describing the credentials, or an empty object if there are none. "store"
+// receives a JSON object on its stdin. A non-zero exit status indicates
+// failure, with the reason written to stderr.
+//
+// The executable path must be absolute, so that the program that runs does
+// not depend on the current working directory or PATH. The program is
+// killed if the context passed to an operation is cancelled, so use
+// [NewCredentialsStore] with [WithTimeout] to limit how long it may run.
+func HelperProgramCredentialsSource(executable string, args ...string) (CredentialsStore, error) {
+	if !filepath.IsAbs(executable) {
+		return nil, errors.New("credentials helper program path must be absolute")
+	}
+	return &helperProgramCredentialsSource{
+		executable: executable,
+		args:       args,
+	}, nil
+}
+
+type helperProgramCredentialsSource struct {
+	executable string
+	args       []string
+}
+
+// ForHost implements [CredentialsSource].
+func (s *helperProgramCredentialsSource) ForHost(ctx context.Context, host svchost.Hostname) (HostCredentials, error) {
+	out, err := s.run(ctx, "get", host, nil)
+	if err != nil {
+		return nil, svchost.WrapHostError(host, opForHost, err)
+	}
+
+	var m map[string]any
+	if err := json.Unmarshal(out, &m); err != nil {
+		return nil, svchost.WrapHostError(host, opForHost, fmt.Errorf("malformed output from %s: %w", s.executable, err))
+	}
+	return HostCredentialsFromMap(m), nil
+}
+
+// StoreForHost implements [CredentialsStore].
+func (s *helperProgramCredentialsSource) StoreForHost(ctx context.Context, host svchost.Hostname, credentials NewHostCredentials) error {
+	toStore := credentials.ToStore()
+	toStoreRaw, err := ctyjson.Marshal(toStore, toStore.Type())
+	if err != nil {
+		return svchost.WrapHostError(host, opStoreForHost, fmt.Errorf("can't serialize credentials to store: %w", err))
+	}
+	_, err = s.run(ctx, "store", host, toStoreRaw)
+	return svchost.WrapHostError(host, opStoreForHost, err)
+}
+
+// ForgetForHost implements [CredentialsStore].
+func (s *helperProgramCredentialsSource) ForgetForHost(ctx context.Context, host svchost.Hostname) error {
+	_, err := s.run(ctx, "forget", host, nil)
+	return svchost.WrapHostError(host, opForgetForHost, err)
+}
+
+// run runs the helper program with the given verb and hostname, returning
+// whatever it wrote to stdout if it succeeds.
+func (s *helperProgramCredentialsSource) run(ctx context.Context, verb string, host svchost.Hostname, stdin []byte) ([]byte, error) {
+	args := make([]string, 0, len(s.args)+2)
+	args = append(args, s.args...)
+	args = append(args, verb, string(host))
+
+	var outBuf, errBuf bytes.Buffer
+	cmd := exec.CommandContext(ctx, s.executable, args...)
+	cmd.Stdout = &outBuf
+	cmd.Stderr = &errBuf
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+
+	err := cmd.Run()
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, fmt.Errorf("%s did not complete: %w", s.executable, ctxErr)
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		errText := errBuf.String()
+		if errText == "" {
+			// Shouldn't happen for a well-behaved helper program
+			return nil, fmt.Errorf("error in %s, but it produced no error message", s.executable)
+		}
+		return nil, fmt.Errorf("error in %s: %s", s.executable, errText)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to run %s: %w", s.executable, err)
+	}
+	return outBuf.Bytes(), nil
+}
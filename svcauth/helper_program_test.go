@@ -0,0 +1,93 @@
+// Copyright (c) The OpenTofu Authors
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package svcauth
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHelperProgramCredentialsSource(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	program := filepath.Join(wd, "testdata/test-helper")
+	t.Logf("testing with helper at %s", program)
+
+	src, err := HelperProgramCredentialsSource(program)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	t.Run("happy path", func(t *testing.T) {
+		creds, err := src.ForHost(t.Context(), "example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tokCreds, isTok := creds.(HostCredentialsToken); isTok {
+			if got, want := string(tokCreds), "example-token"; got != want {
+				t.Errorf("wrong token %q; want %q", got, want)
+			}
+		} else {
+			t.Errorf("wrong type of credentials %T", creds)
+		}
+	})
+	t.Run("no credentials", func(t *testing.T) {
+		creds, err := src.ForHost(t.Context(), "nothing.example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if creds != nil {
+			t.Errorf("got credentials; want nil")
+		}
+	})
+	t.Run("unsupported credentials type", func(t *testing.T) {
+		creds, err := src.ForHost(t.Context(), "other-cred-type.example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if creds != nil {
+			t.Errorf("got credentials; want nil")
+		}
+	})
+	t.Run("lookup error", func(t *testing.T) {
+		_, err := src.ForHost(t.Context(), "fail.example.com")
+		if err == nil || !strings.Contains(err.Error(), "failing because you told me to fail") {
+			t.Errorf("wrong error %v; want helper program's error message", err)
+		}
+	})
+	t.Run("store happy path", func(t *testing.T) {
+		err := src.StoreForHost(t.Context(), "example.com", HostCredentialsToken("example-token"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+	t.Run("store error", func(t *testing.T) {
+		err := src.StoreForHost(t.Context(), "fail.example.com", HostCredentialsToken("example-token"))
+		if err == nil || !strings.Contains(err.Error(), "can't store credentials for fail.example.com") {
+			t.Errorf("wrong error %v; want helper program's error message", err)
+		}
+	})
+	t.Run("forget happy path", func(t *testing.T) {
+		err := src.ForgetForHost(t.Context(), "example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+	t.Run("forget error", func(t *testing.T) {
+		err := src.ForgetForHost(t.Context(), "fail.example.com")
+		if err == nil || !strings.Contains(err.Error(), "can't forget credentials for fail.example.com") {
+			t.Errorf("wrong error %v; want helper program's error message", err)
+		}
+	})
+	t.Run("relative path", func(t *testing.T) {
+		if _, err := HelperProgramCredentialsSource("testdata/test-helper"); err == nil {
+			t.Error("unexpected success with relative path; want error")
+		}
+	})
+}
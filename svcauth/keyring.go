@@ -0,0 +1,141 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package svcauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/opentofu/svchost"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// Keyring is the interface to a secret store, such as the one provided by
+// the operating system, that [KeyringCredentialsStore] uses to save
+// credentials.
+//
+// Each secret is identified by a service name, which is the same for all
+// secrets saved by a particular application, and an account name. Use
+// [SystemKeyring] to access the operating system's own secret store, or
+// implement this interface to adapt some other secret store.
+type Keyring interface {
+	// Get returns the secret saved for the given service and account, or
+	// [ErrKeyringItemNotFound] if there is no such secret.
+	Get(ctx context.Context, service, account string) (string, error)
+
+	// Set saves a secret for the given service and account, replacing any
+	// existing secret.
+	Set(ctx context.Context, service, account, secret string) error
+
+	// Delete discards the secret for the given service and account. It
+	// returns [ErrKeyringItemNotFound] if there is no such secret.
+	Delete(ctx context.Context, service, account string) error
+}
+
+// ErrKeyringItemNotFound is returned by [Keyring] implementations when there
+// is no secret for the requested service and account.
+var ErrKeyringItemNotFound = errors.New("secret not found in keyring")
+
+// ErrKeyringUnavailable is returned by [SystemKeyring] when there is no
+// supported secret store available on the current system.
+var ErrKeyringUnavailable = errors.New("no supported system keyring is available")
+
+// KeyringCredentialsStore returns a [CredentialsStore] that saves credentials
+// in the given keyring, using the given service name and the hostname as the
+// account name, so that credentials need not be written to plaintext files.
+//
+// Each host's credentials are saved as a single secret containing the JSON
+// serialization of the value returned by [NewHostCredentials.ToStore].
+//
+// The result implements [CredentialsUpdater], but keyrings offer no way to
+// lock a secret, so updates are atomic only with respect to other uses of
+// the same store object.
+func KeyringCredentialsStore(ring Keyring, service string) CredentialsStore {
+	return &keyringCredentialsStore{
+		ring:    ring,
+		service: service,
+	}
+}
+
+type keyringCredentialsStore struct {
+	ring    Keyring
+	service string
+
+	// mu serializes changes to the keyring, so that UpdateForHost can
+	// prevent other changes between reading and writing a secret.
+	mu sync.Mutex
+}
+
+// ForHost implements [CredentialsSource].
+func (s *keyringCredentialsStore) ForHost(ctx context.Context, host svchost.Hostname) (HostCredentials, error) {
+	secret, err := s.ring.Get(ctx, s.service, string(host))
+	if errors.Is(err, ErrKeyringItemNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, svchost.WrapHostError(host, opForHost, err)
+	}
+
+	var m map[string]any
+	if err := json.Unmarshal([]byte(secret), &m); err != nil {
+		return nil, svchost.WrapHostError(host, opForHost, fmt.Errorf("malformed credentials in keyring: %w", err))
+	}
+	return HostCredentialsFromMap(m), nil
+}
+
+// StoreForHost implements [CredentialsStore].
+func (s *keyringCredentialsStore) StoreForHost(ctx context.Context, host svchost.Hostname, credentials NewHostCredentials) error {
+	toStore := credentials.ToStore()
+	toStoreRaw, err := ctyjson.Marshal(toStore, toStore.Type())
+	if err != nil {
+		return svchost.WrapHostError(host, opStoreForHost, fmt.Errorf("can't serialize credentials to store: %w", err))
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return svchost.WrapHostError(host, opStoreForHost, s.ring.Set(ctx, s.service, string(host), string(toStoreRaw)))
+}
+
+// ForgetForHost implements [CredentialsStore].
+func (s *keyringCredentialsStore) ForgetForHost(ctx context.Context, host svchost.Hostname) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return svchost.WrapHostError(host, opForgetForHost, s.forget(ctx, host))
+}
+
+// UpdateForHost implements [CredentialsUpdater].
+func (s *keyringCredentialsStore) UpdateForHost(ctx context.Context, host svchost.Hostname, update UpdateFunc) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, err := s.ForHost(ctx, host)
+	if err != nil {
+		return svchost.WrapHostError(host, opUpdateForHost, err)
+	}
+	credentials, err := update(old)
+	if err != nil {
+		return svchost.WrapHostError(host, opUpdateForHost, err)
+	}
+	if credentials == nil {
+		return svchost.WrapHostError(host, opUpdateForHost, s.forget(ctx, host))
+	}
+	toStore := credentials.ToStore()
+	toStoreRaw, err := ctyjson.Marshal(toStore, toStore.Type())
+	if err != nil {
+		return svchost.WrapHostError(host, opUpdateForHost, fmt.Errorf("can't serialize credentials to store: %w", err))
+	}
+	return svchost.WrapHostError(host, opUpdateForHost, s.ring.Set(ctx, s.service, string(host), string(toStoreRaw)))
+}
+
+// forget deletes the secret for the given host, if any. The caller must
+// hold s.mu.
+func (s *keyringCredentialsStore) forget(ctx context.Context, host svchost.Hostname) error {
+	err := s.ring.Delete(ctx, s.service, string(host))
+	if errors.Is(err, ErrKeyringItemNotFound) {
+		return nil
+	}
+	return err
+}
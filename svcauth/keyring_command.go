@@ -0,0 +1,49 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build !windows
+
+package svcauth
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// runKeyringCommand runs a command-line tool that accesses the system
+// keyring, returning whatever it wrote to stdout if it succeeds.
+//
+// If notFound is non-nil and returns true for the command's exit status and
+// error message then the error is [ErrKeyringItemNotFound].
+func runKeyringCommand(ctx context.Context, stdin []byte, notFound func(status int, errText string) bool, executable string, args ...string) ([]byte, error) {
+	var outBuf, errBuf bytes.Buffer
+	cmd := exec.CommandContext(ctx, executable, args...)
+	cmd.Stdout = &outBuf
+	cmd.Stderr = &errBuf
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+
+	err := cmd.Run()
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, fmt.Errorf("%s did not complete: %w", executable, ctxErr)
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		errText := strings.TrimSpace(errBuf.String())
+		if notFound != nil && notFound(exitErr.ExitCode(), errText) {
+			return nil, ErrKeyringItemNotFound
+		}
+		if errText == "" {
+			return nil, fmt.Errorf("%s exited with status %d", executable, exitErr.ExitCode())
+		}
+		return nil, fmt.Errorf("error in %s: %s", executable, errText)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to run %s: %w", executable, err)
+	}
+	return outBuf.Bytes(), nil
+}
@@ -0,0 +1,69 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package svcauth
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// macOSSecurityTool is the command-line interface to the macOS Keychain,
+// which is always installed in this location.
+const macOSSecurityTool = "/usr/bin/security"
+
+// macOSItemNotFound recognizes the exit status of the security tool when
+// the requested item does not exist.
+func macOSItemNotFound(status int, _ string) bool {
+	return status == 44 // errSecItemNotFound
+}
+
+// SystemKeyring returns a [Keyring] that accesses the operating system's
+// secret store.
+//
+// On macOS this is the user's default Keychain. On Windows this is the
+// Windows Credential Manager. On other Unix-like systems this is the
+// freedesktop.org Secret Service, accessed using the "secret-tool"
+// command, and so the result is [ErrKeyringUnavailable] if that command is
+// not installed. On all other systems the result is always
+// [ErrKeyringUnavailable].
+func SystemKeyring() (Keyring, error) {
+	return macOSKeychain{}, nil
+}
+
+type macOSKeychain struct{}
+
+// Get implements [Keyring].
+func (macOSKeychain) Get(ctx context.Context, service, account string) (string, error) {
+	out, err := runKeyringCommand(ctx, nil, macOSItemNotFound, macOSSecurityTool,
+		"find-generic-password", "-s", service, "-a", account, "-w",
+	)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
+
+// Set implements [Keyring].
+func (macOSKeychain) Set(ctx context.Context, service, account, secret string) error {
+	if strings.ContainsAny(service+account, " \t\r\n\"'\\") {
+		return fmt.Errorf("keychain service and account names must not contain whitespace, quotes, or backslashes")
+	}
+	// We use the tool's interactive mode, reading the command from stdin,
+	// so that the secret doesn't appear in the process's arguments where
+	// other users on the system could see it. The secret is hex-encoded to
+	// avoid any need for quoting.
+	cmd := fmt.Sprintf("add-generic-password -U -s %s -a %s -X %s\n", service, account, hex.EncodeToString([]byte(secret)))
+	_, err := runKeyringCommand(ctx, []byte(cmd), nil, macOSSecurityTool, "-i")
+	return err
+}
+
+// Delete implements [Keyring].
+func (macOSKeychain) Delete(ctx context.Context, service, account string) error {
+	_, err := runKeyringCommand(ctx, nil, macOSItemNotFound, macOSSecurityTool,
+		"delete-generic-password", "-s", service, "-a", account,
+	)
+	return err
+}
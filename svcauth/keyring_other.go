@@ -0,0 +1,19 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build !darwin && !windows && !linux && !freebsd && !openbsd && !netbsd && !dragonfly
+
+package svcauth
+
+// SystemKeyring returns a [Keyring] that accesses the operating system's
+// secret store.
+//
+// On macOS this is the user's default Keychain. On Windows this is the
+// Windows Credential Manager. On other Unix-like systems this is the
+// freedesktop.org Secret Service, accessed using the "secret-tool"
+// command, and so the result is [ErrKeyringUnavailable] if that command is
+// not installed. On all other systems the result is always
+// [ErrKeyringUnavailable].
+func SystemKeyring() (Keyring, error) {
+	return nil, ErrKeyringUnavailable
+}
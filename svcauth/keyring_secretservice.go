@@ -0,0 +1,69 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build linux || freebsd || openbsd || netbsd || dragonfly
+
+package svcauth
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+)
+
+// SystemKeyring returns a [Keyring] that accesses the operating system's
+// secret store.
+//
+// On macOS this is the user's default Keychain. On Windows this is the
+// Windows Credential Manager. On other Unix-like systems this is the
+// freedesktop.org Secret Service, accessed using the "secret-tool"
+// command, and so the result is [ErrKeyringUnavailable] if that command is
+// not installed. On all other systems the result is always
+// [ErrKeyringUnavailable].
+func SystemKeyring() (Keyring, error) {
+	executable, err := exec.LookPath("secret-tool")
+	if err != nil {
+		return nil, errors.Join(ErrKeyringUnavailable, err)
+	}
+	return secretServiceKeyring{executable: executable}, nil
+}
+
+// secretToolItemNotFound recognizes the result of secret-tool when there is
+// no matching secret: it exits with status 1 without an error message, which
+// is the only way to distinguish that from other failures.
+func secretToolItemNotFound(status int, errText string) bool {
+	return status == 1 && errText == ""
+}
+
+type secretServiceKeyring struct {
+	executable string
+}
+
+// Get implements [Keyring].
+func (k secretServiceKeyring) Get(ctx context.Context, service, account string) (string, error) {
+	out, err := runKeyringCommand(ctx, nil, secretToolItemNotFound, k.executable,
+		"lookup", "service", service, "account", account,
+	)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// Set implements [Keyring].
+func (k secretServiceKeyring) Set(ctx context.Context, service, account, secret string) error {
+	// secret-tool reads the secret from stdin, so that it doesn't appear
+	// in the process's arguments.
+	_, err := runKeyringCommand(ctx, []byte(secret), nil, k.executable,
+		"store", "--label="+service+" credentials for "+account, "service", service, "account", account,
+	)
+	return err
+}
+
+// Delete implements [Keyring].
+func (k secretServiceKeyring) Delete(ctx context.Context, service, account string) error {
+	_, err := runKeyringCommand(ctx, nil, secretToolItemNotFound, k.executable,
+		"clear", "service", service, "account", account,
+	)
+	return err
+}
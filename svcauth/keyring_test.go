@@ -0,0 +1,84 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package svcauth
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/opentofu/svchost"
+)
+
+// memoryKeyring is a [Keyring] that keeps secrets in memory, for testing.
+type memoryKeyring map[[2]string]string
+
+func (k memoryKeyring) Get(_ context.Context, service, account string) (string, error) {
+	secret, ok := k[[2]string{service, account}]
+	if !ok {
+		return "", ErrKeyringItemNotFound
+	}
+	return secret, nil
+}
+
+func (k memoryKeyring) Set(_ context.Context, service, account, secret string) error {
+	k[[2]string{service, account}] = secret
+	return nil
+}
+
+func (k memoryKeyring) Delete(_ context.Context, service, account string) error {
+	key := [2]string{service, account}
+	if _, ok := k[key]; !ok {
+		return ErrKeyringItemNotFound
+	}
+	delete(k, key)
+	return nil
+}
+
+func TestKeyringCredentialsStore(t *testing.T) {
+	ring := memoryKeyring{}
+	store := KeyringCredentialsStore(ring, "opentofu")
+	host := svchost.Hostname("example.com")
+
+	creds, err := store.ForHost(t.Context(), host)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if creds != nil {
+		t.Fatalf("unexpected credentials before storing: %#v", creds)
+	}
+
+	if err := store.StoreForHost(t.Context(), host, HostCredentialsToken("abc123")); err != nil {
+		t.Fatalf("unexpected error storing: %s", err)
+	}
+	if got, want := ring[[2]string{"opentofu", "example.com"}], `{"token":"abc123"}`; got != want {
+		t.Errorf("wrong secret in keyring\ngot:  %s\nwant: %s", got, want)
+	}
+	creds, err = store.ForHost(t.Context(), host)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got, want := creds, HostCredentialsToken("abc123"); got != want {
+		t.Errorf("wrong credentials %#v; want %#v", got, want)
+	}
+
+	if err := store.ForgetForHost(t.Context(), host); err != nil {
+		t.Fatalf("unexpected error forgetting: %s", err)
+	}
+	if len(ring) != 0 {
+		t.Errorf("keyring still contains secrets after forget: %#v", ring)
+	}
+	// Forgetting again is not an error, even though the keyring reports
+	// that the secret is missing.
+	if err := store.ForgetForHost(t.Context(), host); err != nil {
+		t.Fatalf("unexpected error forgetting again: %s", err)
+	}
+
+	ring[[2]string{"opentofu", "example.com"}] = "not json"
+	_, err = store.ForHost(t.Context(), host)
+	var hostErr *svchost.HostError
+	if !errors.As(err, &hostErr) {
+		t.Errorf("wrong error %v; want malformed credentials error", err)
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package svcauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	advapi32       = syscall.NewLazyDLL("advapi32.dll")
+	procCredReadW  = advapi32.NewProc("CredReadW")
+	procCredWriteW = advapi32.NewProc("CredWriteW")
+	procCredDelete = advapi32.NewProc("CredDeleteW")
+	procCredFree   = advapi32.NewProc("CredFree")
+)
+
+const (
+	credTypeGeneric         = 1
+	credPersistLocalMachine = 2
+
+	errorNotFound syscall.Errno = 1168
+)
+
+// winCredential is the CREDENTIALW structure used by the Windows
+// Credential Manager API.
+type winCredential struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        syscall.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+// SystemKeyring returns a [Keyring] that accesses the operating system's
+// secret store.
+//
+// On macOS this is the user's default Keychain. On Windows this is the
+// Windows Credential Manager. On other Unix-like systems this is the
+// freedesktop.org Secret Service, accessed using the "secret-tool"
+// command, and so the result is [ErrKeyringUnavailable] if that command is
+// not installed. On all other systems the result is always
+// [ErrKeyringUnavailable].
+func SystemKeyring() (Keyring, error) {
+	if err := advapi32.Load(); err != nil {
+		return nil, errors.Join(ErrKeyringUnavailable, err)
+	}
+	return windowsCredentialManager{}, nil
+}
+
+type windowsCredentialManager struct{}
+
+// Get implements [Keyring].
+func (windowsCredentialManager) Get(_ context.Context, service, account string) (string, error) {
+	target, err := syscall.UTF16PtrFromString(windowsCredentialTarget(service, account))
+	if err != nil {
+		return "", err
+	}
+	var cred *winCredential
+	r, _, err := procCredReadW.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0, uintptr(unsafe.Pointer(&cred)))
+	if r == 0 {
+		return "", windowsCredentialError(err)
+	}
+	defer procCredFree.Call(uintptr(unsafe.Pointer(cred))) //nolint:errcheck
+
+	if cred.CredentialBlobSize == 0 {
+		return "", nil
+	}
+	return string(unsafe.Slice(cred.CredentialBlob, cred.CredentialBlobSize)), nil
+}
+
+// Set implements [Keyring].
+func (windowsCredentialManager) Set(_ context.Context, service, account, secret string) error {
+	target, err := syscall.UTF16PtrFromString(windowsCredentialTarget(service, account))
+	if err != nil {
+		return err
+	}
+	userName, err := syscall.UTF16PtrFromString(account)
+	if err != nil {
+		return err
+	}
+	blob := []byte(secret)
+	cred := winCredential{
+		Type:               credTypeGeneric,
+		TargetName:         target,
+		UserName:           userName,
+		CredentialBlobSize: uint32(len(blob)),
+		Persist:            credPersistLocalMachine,
+	}
+	if len(blob) != 0 {
+		cred.CredentialBlob = &blob[0]
+	}
+	r, _, err := procCredWriteW.Call(uintptr(unsafe.Pointer(&cred)), 0)
+	if r == 0 {
+		return windowsCredentialError(err)
+	}
+	return nil
+}
+
+// Delete implements [Keyring].
+func (windowsCredentialManager) Delete(_ context.Context, service, account string) error {
+	target, err := syscall.UTF16PtrFromString(windowsCredentialTarget(service, account))
+	if err != nil {
+		return err
+	}
+	r, _, err := procCredDelete.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0)
+	if r == 0 {
+		return windowsCredentialError(err)
+	}
+	return nil
+}
+
+// windowsCredentialTarget returns the target name used to identify the
+// credential for the given service and account.
+func windowsCredentialTarget(service, account string) string {
+	return service + ":" + account
+}
+
+func windowsCredentialError(err error) error {
+	if errors.Is(err, errorNotFound) {
+		return ErrKeyringItemNotFound
+	}
+	return fmt.Errorf("Windows Credential Manager: %w", err)
+}
@@ -0,0 +1,157 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package svcauth
+
+import (
+	"context"
+
+	"github.com/opentofu/svchost"
+)
+
+// LegacyCredentialsSource is the context-free form of [CredentialsSource]
+// used by older credentials implementations that predate this package.
+//
+// Use [FromLegacySource] to adapt such an implementation for use with this
+// package, or [ToLegacySource] to adapt a [CredentialsSource] for callers
+// that have not yet been updated to pass a context.
+//
+// Together these adapters allow callers of the legacy API to use any of the
+// implementations in this package, such as [CachingCredentialsSource] or
+// [Credentials], by adapting a legacy source, wrapping it, and then adapting
+// the result back:
+//
+//	legacy = svcauth.ToLegacySource(svcauth.CachingCredentialsSource(svcauth.FromLegacySource(legacy)))
+type LegacyCredentialsSource interface {
+	ForHost(host svchost.Hostname) (HostCredentials, error)
+}
+
+// FromLegacySource returns a [CredentialsSource] that delegates to the given
+// legacy source.
+//
+// The legacy source cannot respect cancellation, so the returned source
+// checks the context only before calling it. If the legacy source also
+// implements [LegacyCredentialsStore] then the result is a
+// [CredentialsStore], whose store and forget operations are adapted in
+// the same way.
+//
+// If the given source was itself returned by [ToLegacySource] or
+// [ToLegacyStore] then the result is the original source.
+func FromLegacySource(source LegacyCredentialsSource) CredentialsSource {
+	switch source := source.(type) {
+	case contextFreeCredentialsSource:
+		return source.source
+	case contextFreeCredentialsStore:
+		return source.store
+	}
+	if store, ok := source.(LegacyCredentialsStore); ok {
+		return legacyCredentialsStore{store}
+	}
+	return legacyCredentialsSource{source}
+}
+
+// LegacyCredentialsStore is the context-free form of [CredentialsStore].
+type LegacyCredentialsStore interface {
+	LegacyCredentialsSource
+	StoreForHost(host svchost.Hostname, credentials NewHostCredentials) error
+	ForgetForHost(host svchost.Hostname) error
+}
+
+// ToLegacySource returns a [LegacyCredentialsSource] that delegates to the
+// given source, passing [context.Background] as the context.
+//
+// This is intended only as a transitional aid for callers that cannot yet
+// propagate a context of their own. If the given source also implements
+// [CredentialsStore] then the result is a [LegacyCredentialsStore], and if
+// it was itself returned by [FromLegacySource] then the result is the
+// original legacy source.
+func ToLegacySource(source CredentialsSource) LegacyCredentialsSource {
+	switch source := source.(type) {
+	case legacyCredentialsSource:
+		return source.legacy
+	case legacyCredentialsStore:
+		return source.legacy
+	case CredentialsStore:
+		return contextFreeCredentialsStore{source}
+	}
+	return contextFreeCredentialsSource{source}
+}
+
+// ToLegacyStore is like [ToLegacySource] but provides a statically-checkable
+// guarantee that the result is a [LegacyCredentialsStore], in the same way
+// as [CachingCredentialsStore].
+func ToLegacyStore(store CredentialsStore) LegacyCredentialsStore {
+	// The following always succeeds because ToLegacySource returns a
+	// LegacyCredentialsStore for any CredentialsStore, including those
+	// returned by FromLegacySource for a legacy store.
+	return ToLegacySource(store).(LegacyCredentialsStore)
+}
+
+type legacyCredentialsSource struct {
+	legacy LegacyCredentialsSource
+}
+
+var _ CredentialsSource = legacyCredentialsSource{}
+
+// ForHost implements [CredentialsSource].
+func (s legacyCredentialsSource) ForHost(ctx context.Context, host svchost.Hostname) (HostCredentials, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.legacy.ForHost(host)
+}
+
+type legacyCredentialsStore struct {
+	legacy LegacyCredentialsStore
+}
+
+var _ CredentialsStore = legacyCredentialsStore{}
+
+// ForHost implements [CredentialsSource].
+func (s legacyCredentialsStore) ForHost(ctx context.Context, host svchost.Hostname) (HostCredentials, error) {
+	return legacyCredentialsSource{s.legacy}.ForHost(ctx, host)
+}
+
+// StoreForHost implements [CredentialsStore].
+func (s legacyCredentialsStore) StoreForHost(ctx context.Context, host svchost.Hostname, credentials NewHostCredentials) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.legacy.StoreForHost(host, credentials)
+}
+
+// ForgetForHost implements [CredentialsStore].
+func (s legacyCredentialsStore) ForgetForHost(ctx context.Context, host svchost.Hostname) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.legacy.ForgetForHost(host)
+}
+
+type contextFreeCredentialsSource struct {
+	source CredentialsSource
+}
+
+// ForHost implements [LegacyCredentialsSource].
+func (s contextFreeCredentialsSource) ForHost(host svchost.Hostname) (HostCredentials, error) {
+	return s.source.ForHost(context.Background(), host)
+}
+
+type contextFreeCredentialsStore struct {
+	store CredentialsStore
+}
+
+// ForHost implements [LegacyCredentialsSource].
+func (s contextFreeCredentialsStore) ForHost(host svchost.Hostname) (HostCredentials, error) {
+	return s.store.ForHost(context.Background(), host)
+}
+
+// StoreForHost implements [LegacyCredentialsStore].
+func (s contextFreeCredentialsStore) StoreForHost(host svchost.Hostname, credentials NewHostCredentials) error {
+	return s.store.StoreForHost(context.Background(), host, credentials)
+}
+
+// ForgetForHost implements [LegacyCredentialsStore].
+func (s contextFreeCredentialsStore) ForgetForHost(host svchost.Hostname) error {
+	return s.store.ForgetForHost(context.Background(), host)
+}
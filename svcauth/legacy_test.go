@@ -0,0 +1,137 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package svcauth
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/opentofu/svchost"
+)
+
+type legacyMapSource map[svchost.Hostname]HostCredentials
+
+func (s legacyMapSource) ForHost(host svchost.Hostname) (HostCredentials, error) {
+	return s[host], nil
+}
+
+func TestFromLegacySource(t *testing.T) {
+	host := svchost.Hostname("example.com")
+	creds := HostCredentialsToken("abc123")
+	source := FromLegacySource(legacyMapSource{host: creds})
+
+	got, err := source.ForHost(t.Context(), host)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got != creds {
+		t.Errorf("wrong credentials %#v; want %#v", got, creds)
+	}
+	if _, ok := source.(CredentialsStore); ok {
+		t.Errorf("result is a CredentialsStore, but the legacy source is not a store")
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	_, err = source.ForHost(ctx, host)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("wrong error %v; want context.Canceled", err)
+	}
+}
+
+func TestToLegacySource(t *testing.T) {
+	host := svchost.Hostname("example.com")
+	creds := HostCredentialsToken("abc123")
+	legacy := ToLegacySource(StaticCredentialsSource(map[svchost.Hostname]HostCredentials{
+		host: creds,
+	}))
+
+	got, err := legacy.ForHost(host)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got != creds {
+		t.Errorf("wrong credentials %#v; want %#v", got, creds)
+	}
+
+	// Round-tripping through both adapters must preserve behavior.
+	got, err = FromLegacySource(legacy).ForHost(t.Context(), host)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got != creds {
+		t.Errorf("wrong credentials after round-trip %#v; want %#v", got, creds)
+	}
+}
+
+// legacyMapStore is a [LegacyCredentialsStore] for testing.
+type legacyMapStore map[svchost.Hostname]HostCredentials
+
+func (s legacyMapStore) ForHost(host svchost.Hostname) (HostCredentials, error) {
+	return s[host], nil
+}
+
+func (s legacyMapStore) StoreForHost(host svchost.Hostname, credentials NewHostCredentials) error {
+	s[host] = credentials.(HostCredentials)
+	return nil
+}
+
+func (s legacyMapStore) ForgetForHost(host svchost.Hostname) error {
+	delete(s, host)
+	return nil
+}
+
+func TestToLegacyStore(t *testing.T) {
+	host := svchost.Hostname("example.com")
+	store := &mapCredentialsStore{}
+	legacy := ToLegacyStore(store)
+
+	if err := legacy.StoreForHost(host, HostCredentialsToken("abc123")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got, want := (*store)[host], HostCredentials(HostCredentialsToken("abc123")); got != want {
+		t.Errorf("wrong stored credentials %#v; want %#v", got, want)
+	}
+	if err := legacy.ForgetForHost(host); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, exists := (*store)[host]; exists {
+		t.Error("credentials were not forgotten")
+	}
+
+	if got := FromLegacySource(legacy); got != CredentialsSource(store) {
+		t.Errorf("round-trip did not return the original store: %#v", got)
+	}
+}
+
+func TestLegacyAdapterWrapping(t *testing.T) {
+	// A legacy store wrapped in a caching source from this package must
+	// still be usable as a legacy store.
+	host := svchost.Hostname("example.com")
+	legacy := legacyMapStore{host: HostCredentialsToken("abc123")}
+	wrapped := ToLegacyStore(CachingCredentialsStore(FromLegacySource(legacy).(CredentialsStore)))
+
+	got, err := wrapped.ForHost(host)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got != HostCredentialsToken("abc123") {
+		t.Errorf("wrong credentials %#v; want abc123", got)
+	}
+	if err := wrapped.StoreForHost(host, HostCredentialsToken("new")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	got, err = wrapped.ForHost(host)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got != HostCredentialsToken("new") {
+		t.Errorf("wrong credentials after store %#v; want new", got)
+	}
+
+	if _, ok := ToLegacySource(FromLegacySource(legacy)).(legacyMapStore); !ok {
+		t.Error("round-trip did not return the original legacy store")
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package svcauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/opentofu/svchost"
+)
+
+// MultiStorePolicy decides which of the sources in a store returned by
+// [NewMultiStore] receive newly-stored credentials.
+type MultiStorePolicy int
+
+const (
+	// StoreFirstWritable stores credentials only in the first of the
+	// sources that implements [CredentialsStore], which is typically the
+	// most preferred place to keep them. This is similar to [Credentials],
+	// except that read-only sources earlier in the list are skipped.
+	StoreFirstWritable MultiStorePolicy = iota
+
+	// StoreAllWritable stores credentials in all of the sources that
+	// implement [CredentialsStore], such as to keep a file and a keyring in
+	// sync while migrating from one to the other.
+	StoreAllWritable
+)
+
+// MultiStoreError is used in errors returned by a store created by
+// [NewMultiStore] to describe which of its sources failed.
+type MultiStoreError struct {
+	// Index is the position of the source that failed in the list given
+	// to [NewMultiStore].
+	Index int
+
+	// Source is the source that failed.
+	Source CredentialsSource
+
+	// Err is the error returned by the source.
+	Err error
+}
+
+func (e *MultiStoreError) Error() string {
+	return fmt.Sprintf("credentials source %d (%T): %s", e.Index, e.Source, e.Err)
+}
+
+func (e *MultiStoreError) Unwrap() error {
+	return e.Err
+}
+
+// NewMultiStore returns a [CredentialsStore] that combines the given sources,
+// which may be a mixture of read-only sources, such as environment variables,
+// and stores, such as files and keyrings.
+//
+// ForHost tries each of the sources in the given order, in the same way as
+// [Credentials]. StoreForHost stores credentials in the sources selected by
+// the given policy, skipping sources that don't implement [CredentialsStore].
+// ForgetForHost always forgets credentials in all of the sources that
+// implement [CredentialsStore], regardless of the policy, so that a later
+// ForHost doesn't find credentials left behind in another store.
+//
+// An error returned by a source is wrapped in a [*MultiStoreError] that
+// identifies it. If more than one source fails when storing or forgetting
+// credentials then the operation continues with the remaining sources and
+// the result combines all of the errors. The store and forget operations
+// fail if none of the sources implements [CredentialsStore].
+//
+// NewMultiStore returns an error if any of the given sources is nil, or if
+// the policy is invalid.
+func NewMultiStore(policy MultiStorePolicy, sources ...CredentialsSource) (CredentialsStore, error) {
+	var errs []error
+	if policy != StoreFirstWritable && policy != StoreAllWritable {
+		errs = append(errs, fmt.Errorf("invalid multi-store policy %d", policy))
+	}
+	for i, source := range sources {
+		if source == nil {
+			errs = append(errs, fmt.Errorf("credentials source %d is nil", i))
+		}
+	}
+	if len(errs) != 0 {
+		return nil, errors.Join(errs...)
+	}
+	return &multiStore{
+		policy:  policy,
+		sources: sources,
+	}, nil
+}
+
+type multiStore struct {
+	policy  MultiStorePolicy
+	sources []CredentialsSource
+}
+
+// ForHost implements [CredentialsSource].
+func (s *multiStore) ForHost(ctx context.Context, host svchost.Hostname) (HostCredentials, error) {
+	for i, source := range s.sources {
+		creds, err := source.ForHost(ctx, host)
+		if err != nil {
+			return nil, svchost.WrapHostError(host, opForHost, s.sourceError(i, err))
+		}
+		if creds != nil {
+			return creds, nil
+		}
+	}
+	return nil, nil
+}
+
+// StoreForHost implements [CredentialsStore].
+func (s *multiStore) StoreForHost(ctx context.Context, host svchost.Hostname, credentials NewHostCredentials) error {
+	return svchost.WrapHostError(host, opStoreForHost, s.eachStore(s.policy == StoreFirstWritable, func(store CredentialsStore) error {
+		return store.StoreForHost(ctx, host, credentials)
+	}))
+}
+
+// ForgetForHost implements [CredentialsStore].
+func (s *multiStore) ForgetForHost(ctx context.Context, host svchost.Hostname) error {
+	return svchost.WrapHostError(host, opForgetForHost, s.eachStore(false, func(store CredentialsStore) error {
+		return store.ForgetForHost(ctx, host)
+	}))
+}
+
+// UpdateForHost implements [CredentialsUpdater].
+//
+// The update function receives the credentials from the first of the
+// receiver's sources that implements [CredentialsStore], and the update of
+// that store is atomic if it also implements [CredentialsUpdater]. The result
+// is then stored in or forgotten from the other stores in the same way as
+// for StoreForHost and ForgetForHost, which is not atomic.
+func (s *multiStore) UpdateForHost(ctx context.Context, host svchost.Hostname, update UpdateFunc) error {
+	var updated NewHostCredentials
+	var updateErr error
+	first := true
+	err := s.eachStore(false, func(store CredentialsStore) error {
+		if first {
+			first = false
+			updateErr = UpdateForHost(ctx, store, host, func(old HostCredentials) (NewHostCredentials, error) {
+				var err error
+				updated, err = update(old)
+				return updated, err
+			})
+			return updateErr
+		}
+		switch {
+		case updateErr != nil:
+			// The other stores are left unchanged if the update failed.
+			return nil
+		case updated == nil:
+			return store.ForgetForHost(ctx, host)
+		case s.policy == StoreAllWritable:
+			return store.StoreForHost(ctx, host, updated)
+		default:
+			return nil
+		}
+	})
+	return svchost.WrapHostError(host, opUpdateForHost, err)
+}
+
+// eachStore calls the given function for each of the receiver's sources
+// that implements [CredentialsStore], or only the first of them if firstOnly
+// is set, and returns any errors it returns.
+func (s *multiStore) eachStore(firstOnly bool, fn func(CredentialsStore) error) error {
+	var errs []error
+	found := false
+	for i, source := range s.sources {
+		store, ok := source.(CredentialsStore)
+		if !ok {
+			continue
+		}
+		found = true
+		if err := fn(store); err != nil {
+			errs = append(errs, s.sourceError(i, err))
+		}
+		if firstOnly {
+			break
+		}
+	}
+	if !found {
+		return errNoStore
+	}
+	return errors.Join(errs...)
+}
+
+func (s *multiStore) sourceError(i int, err error) error {
+	return &MultiStoreError{
+		Index:  i,
+		Source: s.sources[i],
+		Err:    err,
+	}
+}
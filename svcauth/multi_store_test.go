@@ -0,0 +1,178 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package svcauth
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/opentofu/svchost"
+)
+
+// failingCredentialsStore is a [CredentialsStore] whose operations all fail.
+type failingCredentialsStore struct {
+	err error
+}
+
+func (s failingCredentialsStore) ForHost(ctx context.Context, host svchost.Hostname) (HostCredentials, error) {
+	return nil, s.err
+}
+
+func (s failingCredentialsStore) StoreForHost(ctx context.Context, host svchost.Hostname, credentials NewHostCredentials) error {
+	return s.err
+}
+
+func (s failingCredentialsStore) ForgetForHost(ctx context.Context, host svchost.Hostname) error {
+	return s.err
+}
+
+func TestNewMultiStore(t *testing.T) {
+	ctx := t.Context()
+	env := StaticCredentialsSource(map[svchost.Hostname]HostCredentials{
+		"env.example.com": HostCredentialsToken("from-env"),
+	})
+
+	t.Run("store first writable", func(t *testing.T) {
+		file, keyring := &mapCredentialsStore{}, &mapCredentialsStore{}
+		store, err := NewMultiStore(StoreFirstWritable, env, file, keyring)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if err := store.StoreForHost(ctx, "example.com", HostCredentialsToken("abc123")); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if len(*file) != 1 || len(*keyring) != 0 {
+			t.Errorf("wrong stores updated: file %#v, keyring %#v", *file, *keyring)
+		}
+
+		// Reads follow the given order.
+		(*keyring)["env.example.com"] = HostCredentialsToken("from-keyring")
+		creds, err := store.ForHost(ctx, "env.example.com")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if got, want := creds, HostCredentialsToken("from-env"); got != want {
+			t.Errorf("wrong credentials %#v; want %#v", got, want)
+		}
+
+		// Forgetting applies to all of the stores.
+		(*keyring)["example.com"] = HostCredentialsToken("stale")
+		if err := store.ForgetForHost(ctx, "example.com"); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if creds, _ := store.ForHost(ctx, "example.com"); creds != nil {
+			t.Errorf("credentials remain after forgetting: %#v", creds)
+		}
+	})
+	t.Run("store all writable", func(t *testing.T) {
+		file, keyring := &mapCredentialsStore{}, &mapCredentialsStore{}
+		store, err := NewMultiStore(StoreAllWritable, env, file, keyring)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if err := store.StoreForHost(ctx, "example.com", HostCredentialsToken("abc123")); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if len(*file) != 1 || len(*keyring) != 1 {
+			t.Errorf("wrong stores updated: file %#v, keyring %#v", *file, *keyring)
+		}
+	})
+	t.Run("failures", func(t *testing.T) {
+		wantErr := errors.New("keyring is locked")
+		file := &mapCredentialsStore{}
+		keyring := failingCredentialsStore{err: wantErr}
+		store, err := NewMultiStore(StoreAllWritable, env, file, keyring)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		err = store.StoreForHost(ctx, "example.com", HostCredentialsToken("abc123"))
+		var storeErr *MultiStoreError
+		if !errors.As(err, &storeErr) {
+			t.Fatalf("wrong error %v; want *MultiStoreError", err)
+		}
+		if storeErr.Index != 2 || !errors.Is(err, wantErr) {
+			t.Errorf("wrong error %#v", storeErr)
+		}
+		// The other store is still updated.
+		if len(*file) != 1 {
+			t.Errorf("file store was not updated: %#v", *file)
+		}
+
+		_, err = store.ForHost(ctx, "other.example.com")
+		if !errors.As(err, &storeErr) || storeErr.Index != 2 {
+			t.Errorf("wrong error %v; want *MultiStoreError for source 2", err)
+		}
+	})
+	t.Run("no stores", func(t *testing.T) {
+		store, err := NewMultiStore(StoreFirstWritable, env)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if err := store.StoreForHost(ctx, "example.com", HostCredentialsToken("abc123")); err == nil {
+			t.Error("unexpected success storing without any stores")
+		}
+		if err := store.ForgetForHost(ctx, "example.com"); err == nil {
+			t.Error("unexpected success forgetting without any stores")
+		}
+	})
+	t.Run("invalid", func(t *testing.T) {
+		if _, err := NewMultiStore(StoreFirstWritable, env, nil); err == nil {
+			t.Error("unexpected success with nil source")
+		}
+		if _, err := NewMultiStore(MultiStorePolicy(99), env); err == nil {
+			t.Error("unexpected success with invalid policy")
+		}
+	})
+}
+
+func TestNewMultiStore_update(t *testing.T) {
+	ctx := t.Context()
+	host := svchost.Hostname("example.com")
+
+	t.Run("store all writable", func(t *testing.T) {
+		first, second := &mapCredentialsStore{host: HostCredentialsToken("1")}, &mapCredentialsStore{}
+		store, err := NewMultiStore(StoreAllWritable, first, second)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if err := UpdateForHost(ctx, store, host, incrementToken); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		for i, s := range []*mapCredentialsStore{first, second} {
+			if got, want := (*s)[host], HostCredentialsToken("2"); got != want {
+				t.Errorf("wrong credentials in store %d: %#v; want %#v", i, got, want)
+			}
+		}
+
+		// Discarding the credentials forgets them from all of the stores.
+		err = UpdateForHost(ctx, store, host, func(HostCredentials) (NewHostCredentials, error) {
+			return nil, nil
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if len(*first) != 0 || len(*second) != 0 {
+			t.Errorf("credentials were not forgotten: %#v, %#v", *first, *second)
+		}
+	})
+	t.Run("failed update", func(t *testing.T) {
+		first, second := &mapCredentialsStore{}, &mapCredentialsStore{host: HostCredentialsToken("other")}
+		store, err := NewMultiStore(StoreAllWritable, first, second)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		wantErr := errors.New("rotation failed")
+		err = UpdateForHost(ctx, store, host, func(HostCredentials) (NewHostCredentials, error) {
+			return nil, wantErr
+		})
+		if !errors.Is(err, wantErr) {
+			t.Errorf("wrong error %v; want %v", err, wantErr)
+		}
+		if got, want := (*second)[host], HostCredentialsToken("other"); got != want {
+			t.Errorf("failed update changed the other store to %#v; want %#v", got, want)
+		}
+	})
+}
@@ -0,0 +1,86 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package svcauth
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/zclconf/go-cty/cty"
+	"golang.org/x/oauth2"
+)
+
+// HostCredentialsOAuthTokens is a HostCredentials implementation that
+// represents an OAuth access token along with the refresh token and expiry
+// time that were issued with it, so that all of them can be saved in a
+// [CredentialsStore].
+//
+// The access token is sent in the same way as [HostCredentialsToken]. The
+// refresh token can't be used without the configuration of the OAuth client
+// it was issued to, so use [HostCredentialsOAuthTokens.WithConfig] to obtain
+// an equivalent [HostCredentialsOAuthToken] that can be refreshed, or use
+// [WithOAuthConfig] so that [NewCredentialsSource] does so automatically.
+type HostCredentialsOAuthTokens struct {
+	AccessToken  string
+	RefreshToken string
+
+	// Expiry is when the access token expires, or the zero time if the
+	// server didn't say.
+	Expiry time.Time
+}
+
+// Interface implementation assertions. Compilation will fail here if
+// HostCredentialsOAuthTokens does not fully implement these interfaces.
+var _ HostCredentials = HostCredentialsOAuthTokens{}
+var _ NewHostCredentials = HostCredentialsOAuthTokens{}
+
+// PrepareRequest alters the given HTTP request by setting its Authorization
+// header to the string "Bearer " followed by the access token.
+func (tc HostCredentialsOAuthTokens) PrepareRequest(req *http.Request) {
+	HostCredentialsToken(tc.AccessToken).PrepareRequest(req)
+}
+
+// Token returns the access token.
+func (tc HostCredentialsOAuthTokens) Token() string {
+	return tc.AccessToken
+}
+
+// ExpiresAt returns the expiry time of the access token, or the zero time
+// if it is unknown, in the same way as [HostCredentialsOAuthToken.ExpiresAt].
+func (tc HostCredentialsOAuthTokens) ExpiresAt() time.Time {
+	return oauthTokenExpiry(tc.AccessToken, tc.Expiry)
+}
+
+// WithConfig returns credentials that can be refreshed using the given
+// configuration of the OAuth client that the tokens were issued to.
+func (tc HostCredentialsOAuthTokens) WithConfig(config *oauth2.Config) HostCredentialsOAuthToken {
+	return HostCredentialsOAuthToken{
+		Config: config,
+		Token: &oauth2.Token{
+			AccessToken:  tc.AccessToken,
+			TokenType:    "Bearer",
+			RefreshToken: tc.RefreshToken,
+			Expiry:       tc.Expiry,
+		},
+	}
+}
+
+// ToStore returns a credentials object with the attribute "token" whose
+// value is the access token, along with "refresh_token" and "expiry" if
+// they are set. This implements [NewHostCredentials].
+//
+// The expiry time is saved as a string in RFC 3339 format. Programs that
+// understand only [HostCredentialsToken] can still use the access token.
+func (tc HostCredentialsOAuthTokens) ToStore() cty.Value {
+	attrs := map[string]cty.Value{
+		"token": cty.StringVal(tc.AccessToken),
+	}
+	if tc.RefreshToken != "" {
+		attrs["refresh_token"] = cty.StringVal(tc.RefreshToken)
+	}
+	if !tc.Expiry.IsZero() {
+		attrs["expiry"] = cty.StringVal(tc.Expiry.UTC().Format(time.RFC3339))
+	}
+	return cty.ObjectVal(attrs)
+}
@@ -0,0 +1,125 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package svcauth
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/zclconf/go-cty/cty"
+	"golang.org/x/oauth2"
+)
+
+func TestHostCredentialsOAuthTokens(t *testing.T) {
+	expiry := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	creds := HostCredentialsOAuthTokens{
+		AccessToken:  "access",
+		RefreshToken: "refresh",
+		Expiry:       expiry,
+	}
+
+	req := &http.Request{}
+	creds.PrepareRequest(req)
+	if got, want := req.Header.Get("authorization"), "Bearer access"; got != want {
+		t.Errorf("wrong Authorization header value %q; want %q", got, want)
+	}
+
+	got := creds.ToStore()
+	want := cty.ObjectVal(map[string]cty.Value{
+		"token":         cty.StringVal("access"),
+		"refresh_token": cty.StringVal("refresh"),
+		"expiry":        cty.StringVal("2025-06-01T12:00:00Z"),
+	})
+	if !want.RawEquals(got) {
+		t.Errorf("wrong storable object value\ngot:  %#v\nwant: %#v", got, want)
+	}
+
+	restoredCreds, err := HostCredentialsFromStore(got)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	restored, ok := restoredCreds.(HostCredentialsOAuthTokens)
+	if !ok {
+		t.Fatalf("wrong type of restored credentials %T", restoredCreds)
+	}
+	if restored.AccessToken != creds.AccessToken || restored.RefreshToken != creds.RefreshToken || !restored.Expiry.Equal(creds.Expiry) {
+		t.Errorf("wrong restored credentials %#v; want %#v", restored, creds)
+	}
+
+	// Credentials with a client configuration save the same tokens.
+	config := &oauth2.Config{ClientID: "tofu-cli"}
+	refreshable := restored.WithConfig(config)
+	if got := refreshable.ToStore(); !want.RawEquals(got) {
+		t.Errorf("wrong storable object value with config\ngot:  %#v\nwant: %#v", got, want)
+	}
+	if refreshable.Config != config || refreshable.Token.RefreshToken != "refresh" {
+		t.Errorf("wrong refreshable credentials %#v", refreshable)
+	}
+}
+
+func TestHostCredentialsFromStore(t *testing.T) {
+	tests := map[string]struct {
+		v       cty.Value
+		want    HostCredentials
+		wantErr string
+	}{
+		"null":      {cty.NullVal(cty.EmptyObject), nil, ""},
+		"token":     {HostCredentialsToken("abc123").ToStore(), HostCredentialsToken("abc123"), ""},
+		"basic":     {HostCredentialsBasic{Username: "a", Password: "b"}.ToStore(), HostCredentialsBasic{Username: "a", Password: "b"}, ""},
+		"no expiry": {HostCredentialsOAuthTokens{AccessToken: "a", RefreshToken: "r"}.ToStore(), HostCredentialsOAuthTokens{AccessToken: "a", RefreshToken: "r"}, ""},
+		"map": {
+			cty.MapVal(map[string]cty.Value{"token": cty.StringVal("abc123")}),
+			HostCredentialsToken("abc123"),
+			"",
+		},
+		"not obj": {cty.StringVal("abc123"), nil, "credentials must be an object, not string"},
+		"unknown": {
+			cty.ObjectVal(map[string]cty.Value{"token": cty.UnknownVal(cty.String)}),
+			nil,
+			"credentials object must be known",
+		},
+		"not string": {
+			cty.ObjectVal(map[string]cty.Value{"token": cty.NumberIntVal(1)}),
+			nil,
+			`credentials attribute "token" must be a string`,
+		},
+		"unrecognized": {
+			cty.ObjectVal(map[string]cty.Value{"api_key": cty.StringVal("abc123")}),
+			nil,
+			"credentials object does not contain any recognized credentials",
+		},
+		"bad expiry": {
+			cty.ObjectVal(map[string]cty.Value{
+				"token":         cty.StringVal("a"),
+				"refresh_token": cty.StringVal("r"),
+				"expiry":        cty.StringVal("tomorrow"),
+			}),
+			nil,
+			`invalid token expiry time: parsing time "tomorrow" as "2006-01-02T15:04:05Z07:00": cannot parse "tomorrow" as "2006"`,
+		},
+		"bad certificate": {
+			cty.ObjectVal(map[string]cty.Value{"client_certificate": cty.StringVal("not a certificate")}),
+			nil,
+			"invalid client certificate: tls: failed to find any PEM data in certificate input",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := HostCredentialsFromStore(test.v)
+			if test.wantErr != "" {
+				if err == nil || err.Error() != test.wantErr {
+					t.Errorf("wrong error %v; want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got != test.want {
+				t.Errorf("wrong result %#v; want %#v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,349 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package svcauth
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/opentofu/svchost"
+	"github.com/opentofu/svchost/internal/clock"
+	"github.com/opentofu/svchost/internal/transport"
+)
+
+// Option is an optional setting for the credentials sources and stores
+// constructed by functions in this package.
+//
+// Not all options are meaningful for all constructors. Each constructor's
+// documentation describes which options it makes use of, and any other
+// options are ignored.
+type Option interface {
+	// applyOption applies the option to the given settings object, returning
+	// an error if the option is invalid either in isolation or in
+	// combination with options that were already applied.
+	applyOption(opts *options) error
+}
+
+type option func(opts *options) error
+
+func (o option) applyOption(opts *options) error {
+	return o(opts)
+}
+
+// options is the internal representation of a set of [Option] values, used
+// by constructors to decide how to configure the objects they return.
+type options struct {
+	cache           bool
+	cacheTTL        time.Duration
+	cacheMaxEntries int
+	clock           clock.Clock
+
+	// refresh is set by WithRefresh, and refreshLeeway is how long before
+	// they expire that credentials are refreshed.
+	refresh       bool
+	refreshLeeway time.Duration
+	oauthConfig   OAuthConfigFunc
+
+	timeout   time.Duration
+	trace     *CredentialsTrace
+	transport transport.Config
+
+	// proxyOption is the name of the option that set transport.Proxy, so
+	// that we can report a conflict with another option that sets it.
+	proxyOption string
+
+	// sourceName identifies the wrapped source in [LookupStats], and is
+	// set by WithSourceName or otherwise defaults to the source's type.
+	sourceName string
+
+	hostPolicy func(svchost.Hostname) error
+
+	// clientCertThumbprint is the thumbprint of the certificate given in
+	// WithClientCertificate, if any, for checking against the thumbprint
+	// in [CertificateBoundCredentials].
+	clientCertThumbprint string
+
+	events *CredentialsEvents
+	audit  *AuditHooks
+}
+
+// newOptions applies the given options to a new options object, returning
+// an error if any of them are invalid.
+func newOptions(given []Option) (*options, error) {
+	ret := &options{
+		clock: clock.Real,
+	}
+	var errs []error
+	for _, opt := range given {
+		if err := opt.applyOption(ret); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return ret, errors.Join(errs...)
+}
+
+// WithCache causes the result to cache credentials lookups in memory, on a
+// per-hostname basis, in the same way as [CachingCredentialsSource].
+func WithCache() Option {
+	return option(func(opts *options) error {
+		opts.cache = true
+		return nil
+	})
+}
+
+// WithCacheTTL is like [WithCache] except that each cache entry is discarded
+// once the given duration has passed since it was created, in the same way
+// as for [CachingCredentialsSourceWithTTL].
+func WithCacheTTL(ttl time.Duration) Option {
+	return option(func(opts *options) error {
+		if ttl <= 0 {
+			return errors.New("cache TTL must be positive")
+		}
+		opts.cache = true
+		opts.cacheTTL = ttl
+		return nil
+	})
+}
+
+// WithCacheMaxEntries is like [WithCache] except that the cache holds at most
+// the given number of hostnames, discarding the least recently used entry
+// to make room for a new one.
+func WithCacheMaxEntries(n int) Option {
+	return option(func(opts *options) error {
+		if n <= 0 {
+			return errors.New("cache size limit must be positive")
+		}
+		opts.cache = true
+		opts.cacheMaxEntries = n
+		return nil
+	})
+}
+
+// Clock tells the current time and waits for time to pass, for use with
+// [WithClock]. Its Now method returns the current time, and its After method
+// returns a channel that receives the current time once at least the given
+// duration has passed, in the same way as [time.After].
+type Clock = clock.Clock
+
+// WithRefresh causes the result to refresh [ExpiringHostCredentials] that
+// will expire within the given leeway, in the same way as
+// [RefreshingCredentialsSource].
+func WithRefresh(leeway time.Duration) Option {
+	return option(func(opts *options) error {
+		if leeway < 0 {
+			return errors.New("refresh leeway must not be negative")
+		}
+		opts.refresh = true
+		opts.refreshLeeway = leeway
+		return nil
+	})
+}
+
+// OAuthConfigFunc returns the configuration of the OAuth client that tokens
+// for the given hostname were issued to, for use with [WithOAuthConfig]. It
+// returns nil with no error if the tokens can't be refreshed.
+type OAuthConfigFunc func(ctx context.Context, host svchost.Hostname) (*oauth2.Config, error)
+
+// WithOAuthConfig allows [WithRefresh] to refresh [HostCredentialsOAuthTokens]
+// loaded from a store, which don't record the OAuth client they were issued
+// to, by calling the given function to find its configuration. The function
+// is called only when the tokens need refreshing.
+func WithOAuthConfig(config OAuthConfigFunc) Option {
+	return option(func(opts *options) error {
+		if config == nil {
+			return errors.New("WithOAuthConfig requires a non-nil function")
+		}
+		opts.oauthConfig = config
+		return nil
+	})
+}
+
+// WithClock overrides the clock used to decide when cache entries created
+// because of [WithCache], [WithCacheTTL], or [WithCacheMaxEntries] expire,
+// and when credentials need refreshing because of [WithRefresh], so that
+// tests can simulate the passage of time without waiting for it.
+func WithClock(c Clock) Option {
+	return option(func(opts *options) error {
+		if c == nil {
+			return errors.New("WithClock requires a non-nil clock")
+		}
+		opts.clock = c
+		return nil
+	})
+}
+
+// WithTimeout limits the amount of time that any single operation may take,
+// by deriving a context with the given timeout from the one passed by the
+// caller.
+func WithTimeout(timeout time.Duration) Option {
+	return option(func(opts *options) error {
+		if timeout <= 0 {
+			return errors.New("WithTimeout requires a positive duration")
+		}
+		opts.timeout = timeout
+		return nil
+	})
+}
+
+// WithTrace causes the result to notify the given [CredentialsTrace] about
+// each credentials lookup it performs.
+func WithTrace(trace *CredentialsTrace) Option {
+	return option(func(opts *options) error {
+		if trace == nil {
+			return errors.New("WithTrace requires a non-nil trace")
+		}
+		opts.trace = trace
+		return nil
+	})
+}
+
+// WithSourceName sets the name that identifies the wrapped source in the
+// [LookupStats] reported to a [CredentialsTrace], such as "keyring" or the
+// name of a credentials helper program. By default the source is identified
+// by its Go type.
+//
+// This is most useful when wrapping each member of a [Credentials] list
+// separately, so that a slow source can be told apart from the others.
+func WithSourceName(name string) Option {
+	return option(func(opts *options) error {
+		if name == "" {
+			return errors.New("WithSourceName requires a non-empty name")
+		}
+		opts.sourceName = name
+		return nil
+	})
+}
+
+// WithSOCKS5Proxy causes HTTP requests to be made through the SOCKS5 proxy at
+// the given address, which must be in "host:port" form. auth may be nil
+// if the proxy doesn't require authentication, or otherwise should be
+// constructed using [url.UserPassword]. It cannot be combined with
+// [WithProxyFunc].
+func WithSOCKS5Proxy(address string, auth *url.Userinfo) Option {
+	return option(func(opts *options) error {
+		proxyURL, err := transport.SOCKS5ProxyURL(address, auth)
+		if err != nil {
+			return err
+		}
+		return opts.setProxy("WithSOCKS5Proxy", http.ProxyURL(proxyURL))
+	})
+}
+
+// WithProxyFunc causes HTTP requests to be made through the proxy returned by
+// the given function, in the same way as [http.Transport.Proxy]. This
+// overrides the default behavior of selecting a proxy based on the
+// conventional environment variables. It cannot be combined with
+// [WithSOCKS5Proxy].
+func WithProxyFunc(proxy func(*http.Request) (*url.URL, error)) Option {
+	return option(func(opts *options) error {
+		if proxy == nil {
+			return errors.New("WithProxyFunc requires a non-nil proxy function")
+		}
+		return opts.setProxy("WithProxyFunc", proxy)
+	})
+}
+
+// setProxy sets the proxy function for HTTP requests on behalf of the option
+// with the given name, returning an error if another option already set it.
+// Only one of [WithSOCKS5Proxy] and [WithProxyFunc] may be used, since each
+// replaces the other.
+func (opts *options) setProxy(option string, proxy func(*http.Request) (*url.URL, error)) error {
+	if opts.proxyOption != "" {
+		return fmt.Errorf("conflicting proxies given in %s and %s options", opts.proxyOption, option)
+	}
+	opts.transport.Proxy = proxy
+	opts.proxyOption = option
+	return nil
+}
+
+// WithHostProxy causes HTTP requests for the given hostname to be made
+// through the proxy at the given URL, taking priority over any other proxy
+// configuration. If proxyURL is nil then requests for the hostname are made
+// directly, without a proxy.
+//
+// This option may be used multiple times to configure proxies for different
+// hosts.
+func WithHostProxy(hostname svchost.Hostname, proxyURL *url.URL) Option {
+	return option(func(opts *options) error {
+		if opts.transport.HostProxies == nil {
+			opts.transport.HostProxies = make(map[svchost.Hostname]*url.URL)
+		}
+		opts.transport.HostProxies[hostname] = proxyURL
+		return nil
+	})
+}
+
+// WithFIPSMode restricts the TLS settings used for HTTP requests to only
+// FIPS-approved protocol versions and algorithms.
+//
+// FIPS mode is also enabled automatically when the Go runtime is operating
+// in FIPS 140-3 mode.
+func WithFIPSMode() Option {
+	return option(func(opts *options) error {
+		opts.transport.FIPS = true
+		return nil
+	})
+}
+
+// WithHostPolicy registers a function that is consulted before credentials
+// are attached to a request for a particular hostname. If the function
+// returns an error then the request is sent without credentials, and the
+// credentials source is not consulted.
+func WithHostPolicy(policy func(svchost.Hostname) error) Option {
+	return option(func(opts *options) error {
+		if policy == nil {
+			return errors.New("WithHostPolicy requires a non-nil policy function")
+		}
+		opts.hostPolicy = policy
+		return nil
+	})
+}
+
+// WithClientCertificate causes HTTP requests to present the given TLS client
+// certificate to servers that request one.
+//
+// This is required in order to use [CertificateBoundCredentials], such as
+// [HostCredentialsBoundToken], whose thumbprint must match this certificate.
+func WithClientCertificate(cert tls.Certificate) Option {
+	return option(func(opts *options) error {
+		if len(cert.Certificate) == 0 {
+			return errors.New("WithClientCertificate requires a certificate")
+		}
+		opts.transport.ClientCertificates = []tls.Certificate{cert}
+		opts.clientCertThumbprint = CertificateThumbprint(cert.Certificate[0])
+		return nil
+	})
+}
+
+// WithEvents causes the result to publish a [CredentialsEvent] to the given
+// object each time it successfully stores or forgets credentials for a host.
+func WithEvents(events *CredentialsEvents) Option {
+	return option(func(opts *options) error {
+		if events == nil {
+			return errors.New("WithEvents requires a non-nil events object")
+		}
+		opts.events = events
+		return nil
+	})
+}
+
+// WithAuditHooks causes the result to call the given [AuditHooks] after each
+// operation it performs, so that callers can keep an audit log of when and
+// for which hosts credentials are used.
+func WithAuditHooks(hooks *AuditHooks) Option {
+	return option(func(opts *options) error {
+		if hooks == nil {
+			return errors.New("WithAuditHooks requires a non-nil hooks object")
+		}
+		opts.audit = hooks
+		return nil
+	})
+}
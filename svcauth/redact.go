@@ -0,0 +1,99 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package svcauth
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// redacted is the placeholder used in place of secret values, matching the
+// placeholder that [url.URL.Redacted] uses for passwords.
+const redacted = "xxxxx"
+
+// redactedHeaders are the request headers whose values carry credentials.
+var redactedHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+	"X-Amz-Security-Token",
+}
+
+// redactedQueryParams are the query parameters that are known to carry
+// tokens or other secrets in OAuth and service requests.
+var redactedQueryParams = []string{
+	"access_token",
+	"client_secret",
+	"code",
+	"device_code",
+	"id_token",
+	"password",
+	"refresh_token",
+	"token",
+}
+
+// RedactRequest returns a copy of the given request that is safe to include
+// in logs, with the values of credentials-bearing headers and of query
+// parameters known to carry tokens replaced by a placeholder, and with any
+// password in the URL's userinfo redacted as for [url.URL.Redacted].
+//
+// For the Authorization and Proxy-Authorization headers the authentication
+// scheme is preserved, so "Bearer abc123" becomes "Bearer xxxxx".
+//
+// The copy shares its body with the original request, so it must be used
+// only for inspecting the request and never sent. The original request is
+// not modified. RedactRequest returns nil if req is nil.
+func RedactRequest(req *http.Request) *http.Request {
+	if req == nil {
+		return nil
+	}
+	ret := req.Clone(req.Context())
+
+	for _, name := range redactedHeaders {
+		values := ret.Header.Values(name)
+		if len(values) == 0 {
+			continue
+		}
+		masked := make([]string, len(values))
+		for i, v := range values {
+			masked[i] = redactHeaderValue(name, v)
+		}
+		ret.Header[http.CanonicalHeaderKey(name)] = masked
+	}
+
+	if ret.URL != nil {
+		if _, has := ret.URL.User.Password(); has {
+			ret.URL.User = url.UserPassword(ret.URL.User.Username(), redacted)
+		}
+		if ret.URL.RawQuery != "" {
+			query := ret.URL.Query()
+			changed := false
+			for _, name := range redactedQueryParams {
+				if values, ok := query[name]; ok {
+					for i := range values {
+						values[i] = redacted
+					}
+					changed = true
+				}
+			}
+			if changed {
+				ret.URL.RawQuery = query.Encode()
+			}
+		}
+	}
+	return ret
+}
+
+// redactHeaderValue returns the given value of the header with the given
+// name with its secret portion replaced by a placeholder.
+func redactHeaderValue(name, value string) string {
+	switch http.CanonicalHeaderKey(name) {
+	case "Authorization", "Proxy-Authorization":
+		if scheme, _, ok := strings.Cut(value, " "); ok {
+			return scheme + " " + redacted
+		}
+	}
+	return redacted
+}
@@ -0,0 +1,102 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package svcauth
+
+import (
+	"context"
+	"errors"
+
+	"github.com/opentofu/svchost"
+)
+
+// errNotInScope is returned from the store and forget operations of a scoped
+// credentials source when the hostname doesn't match any of its patterns.
+var errNotInScope = errors.New("host is not in the scope of this credentials store")
+
+// ScopedCredentialsSource creates a new credentials source that wraps another
+// and forwards only lookups for hostnames matching at least one of the given
+// patterns, returning no credentials for all other hostnames.
+//
+// This can be used to guarantee that credentials configured for one host are
+// never sent to any other host, even if the wrapped source would return
+// them. A [svchost.Hostname] converted directly to [svchost.Pattern] matches
+// only that hostname.
+//
+// To build a precedence chain with scope restrictions, include scoped sources
+// in a [Credentials] list: the list consults each source in order, and a
+// scoped source that doesn't match the hostname yields to the next one.
+//
+// The result also implements [CredentialsStore] by forwarding to the inner
+// source, but the store and forget methods will fail with an error if the
+// wrapped source does not also implement that interface, or if the hostname
+// does not match any of the patterns.
+func ScopedCredentialsSource(source CredentialsSource, patterns ...svchost.Pattern) CredentialsSource {
+	return &scopedCredentialsSource{
+		source:   source,
+		patterns: patterns,
+	}
+}
+
+type scopedCredentialsSource struct {
+	source   CredentialsSource
+	patterns []svchost.Pattern
+}
+
+func (s *scopedCredentialsSource) inScope(host svchost.Hostname) bool {
+	for _, pattern := range s.patterns {
+		if pattern.MatchHostname(host) {
+			return true
+		}
+	}
+	return false
+}
+
+// ForHost implements [CredentialsSource].
+func (s *scopedCredentialsSource) ForHost(ctx context.Context, host svchost.Hostname) (HostCredentials, error) {
+	if !s.inScope(host) {
+		return nil, nil
+	}
+	creds, err := s.source.ForHost(ctx, host)
+	if err != nil {
+		return nil, svchost.WrapHostError(host, opForHost, err)
+	}
+	return creds, nil
+}
+
+// StoreForHost implements [CredentialsStore].
+func (s *scopedCredentialsSource) StoreForHost(ctx context.Context, host svchost.Hostname, credentials NewHostCredentials) error {
+	if !s.inScope(host) {
+		return svchost.WrapHostError(host, opStoreForHost, errNotInScope)
+	}
+	store, ok := s.source.(CredentialsStore)
+	if !ok {
+		return svchost.WrapHostError(host, opStoreForHost, errNoStore)
+	}
+	return svchost.WrapHostError(host, opStoreForHost, store.StoreForHost(ctx, host, credentials))
+}
+
+// UpdateForHost implements [CredentialsUpdater], and is atomic only if the
+// wrapped source also implements that interface.
+func (s *scopedCredentialsSource) UpdateForHost(ctx context.Context, host svchost.Hostname, update UpdateFunc) error {
+	if !s.inScope(host) {
+		return svchost.WrapHostError(host, opUpdateForHost, errNotInScope)
+	}
+	store, ok := s.source.(CredentialsStore)
+	if !ok {
+		return svchost.WrapHostError(host, opUpdateForHost, errNoStore)
+	}
+	return svchost.WrapHostError(host, opUpdateForHost, UpdateForHost(ctx, store, host, update))
+}
+
+// ForgetForHost implements [CredentialsStore].
+func (s *scopedCredentialsSource) ForgetForHost(ctx context.Context, host svchost.Hostname) error {
+	if !s.inScope(host) {
+		return svchost.WrapHostError(host, opForgetForHost, errNotInScope)
+	}
+	store, ok := s.source.(CredentialsStore)
+	if !ok {
+		return svchost.WrapHostError(host, opForgetForHost, errNoStore)
+	}
+	return svchost.WrapHostError(host, opForgetForHost, store.ForgetForHost(ctx, host))
+}
@@ -0,0 +1,63 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package svcauth
+
+import (
+	"testing"
+
+	"github.com/opentofu/svchost"
+)
+
+func TestScopedCredentialsSource(t *testing.T) {
+	everywhere := StaticCredentialsSource(map[svchost.Hostname]HostCredentials{
+		"app.example.com":      HostCredentialsToken("app-token"),
+		"registry.example.com": HostCredentialsToken("registry-token"),
+		"other.example.net":    HostCredentialsToken("other-token"),
+	})
+	fallback := StaticCredentialsSource(map[svchost.Hostname]HostCredentials{
+		"registry.example.com": HostCredentialsToken("fallback-token"),
+	})
+	creds := Credentials{
+		ScopedCredentialsSource(everywhere, "app.example.com", "*.example.net"),
+		fallback,
+	}
+
+	tests := map[svchost.Hostname]HostCredentials{
+		"app.example.com":      HostCredentialsToken("app-token"),
+		"other.example.net":    HostCredentialsToken("other-token"),
+		"registry.example.com": HostCredentialsToken("fallback-token"),
+		"unknown.example.org":  nil,
+	}
+	for host, want := range tests {
+		t.Run(string(host), func(t *testing.T) {
+			got, err := creds.ForHost(t.Context(), host)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got != want {
+				t.Errorf("wrong credentials %#v; want %#v", got, want)
+			}
+		})
+	}
+
+	t.Run("store", func(t *testing.T) {
+		store := &mapCredentialsStore{}
+		scoped := ScopedCredentialsSource(store, "app.example.com").(CredentialsStore)
+		if err := scoped.StoreForHost(t.Context(), "app.example.com", HostCredentialsToken("new")); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if err := scoped.StoreForHost(t.Context(), "evil.example.com", HostCredentialsToken("new")); err == nil {
+			t.Error("unexpected success storing credentials for out-of-scope host")
+		}
+		if _, stored := (*store)["evil.example.com"]; stored {
+			t.Error("credentials were stored for out-of-scope host")
+		}
+		if err := scoped.ForgetForHost(t.Context(), "app.example.com"); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if len(*store) != 0 {
+			t.Errorf("credentials still stored after forget: %#v", *store)
+		}
+	})
+}
@@ -0,0 +1,95 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package svcauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/opentofu/svchost"
+)
+
+// SecretResolver is implemented by clients of external secrets managers,
+// such as HashiCorp Vault or a cloud provider's secrets service, so that
+// credentials can be fetched from them each time they are needed rather
+// than saved in a local file.
+type SecretResolver interface {
+	// ResolveSecret returns the secret for the given hostname, or an error
+	// wrapping [ErrSecretNotFound] if the secrets manager has no secret
+	// for it.
+	//
+	// The secret is either a bearer token or a JSON object in the same
+	// format used by credentials helper programs.
+	ResolveSecret(ctx context.Context, host svchost.Hostname) (string, error)
+}
+
+// SecretResolverFunc is an adapter to allow the use of an ordinary function
+// as a [SecretResolver].
+type SecretResolverFunc func(ctx context.Context, host svchost.Hostname) (string, error)
+
+// ResolveSecret implements [SecretResolver] by calling the receiver.
+func (f SecretResolverFunc) ResolveSecret(ctx context.Context, host svchost.Hostname) (string, error) {
+	return f(ctx, host)
+}
+
+// ErrSecretNotFound is wrapped by errors from a [SecretResolver] when the
+// secrets manager has no secret for the requested hostname, which a
+// [SecretResolverCredentialsSource] treats as there being no credentials.
+var ErrSecretNotFound = errors.New("secret not found")
+
+// SecretResolverCredentialsSource returns a [CredentialsSource] that asks
+// the given resolver for the credentials for a host each time they are
+// requested, which allows using short-lived credentials issued by an
+// external secrets manager.
+//
+// If timeout is positive then each request to the resolver is canceled if
+// it doesn't complete within that duration, so that an unreachable secrets
+// manager cannot stall the caller indefinitely.
+//
+// Use [CachingCredentialsSource] to avoid asking the resolver again for a
+// host whose credentials were already obtained.
+func SecretResolverCredentialsSource(resolver SecretResolver, timeout time.Duration) CredentialsSource {
+	return &secretResolverCredentialsSource{
+		resolver: resolver,
+		timeout:  timeout,
+	}
+}
+
+type secretResolverCredentialsSource struct {
+	resolver SecretResolver
+	timeout  time.Duration
+}
+
+// ForHost implements [CredentialsSource].
+func (s *secretResolverCredentialsSource) ForHost(ctx context.Context, host svchost.Hostname) (HostCredentials, error) {
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+	secret, err := s.resolver.ResolveSecret(ctx, host)
+	if errors.Is(err, ErrSecretNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, svchost.WrapHostError(host, opForHost, fmt.Errorf("failed to resolve secret: %w", err))
+	}
+
+	secret = strings.TrimSpace(secret)
+	if secret == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(secret, "{") {
+		return HostCredentialsToken(secret), nil
+	}
+	var m map[string]any
+	if err := json.Unmarshal([]byte(secret), &m); err != nil {
+		return nil, svchost.WrapHostError(host, opForHost, fmt.Errorf("malformed credentials in secret: %w", err))
+	}
+	return HostCredentialsFromMap(m), nil
+}
@@ -0,0 +1,65 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package svcauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/opentofu/svchost"
+)
+
+func TestSecretResolverCredentialsSource(t *testing.T) {
+	resolver := SecretResolverFunc(func(ctx context.Context, host svchost.Hostname) (string, error) {
+		switch host {
+		case "token.example.com":
+			return "abc123\n", nil
+		case "basic.example.com":
+			return `{"username":"alfred","password":"hunter2"}`, nil
+		case "malformed.example.com":
+			return `{"token":`, nil
+		case "slow.example.com":
+			<-ctx.Done()
+			return "", ctx.Err()
+		default:
+			return "", fmt.Errorf("no secret at path %s: %w", host, ErrSecretNotFound)
+		}
+	})
+	source := SecretResolverCredentialsSource(resolver, 10*time.Millisecond)
+
+	tests := map[svchost.Hostname]struct {
+		want    HostCredentials
+		wantErr bool
+	}{
+		"token.example.com":     {want: HostCredentialsToken("abc123")},
+		"basic.example.com":     {want: HostCredentialsBasic{Username: "alfred", Password: "hunter2"}},
+		"missing.example.com":   {want: nil},
+		"malformed.example.com": {wantErr: true},
+		"slow.example.com":      {wantErr: true},
+	}
+	for host, test := range tests {
+		t.Run(string(host), func(t *testing.T) {
+			got, err := source.ForHost(t.Context(), host)
+			if test.wantErr {
+				if err == nil {
+					t.Fatal("unexpected success; want error")
+				}
+				var hostErr *svchost.HostError
+				if !errors.As(err, &hostErr) || hostErr.Host != host {
+					t.Errorf("error does not identify the host: %s", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got != test.want {
+				t.Errorf("wrong credentials %#v; want %#v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package svcauth
+
+import (
+	"context"
+	"net/http"
+)
+
+// HostCredentialsForService is implemented by [HostCredentials] that need to
+// know which service a request is for, such as credentials that use a
+// different audience-scoped token for each service that a host provides.
+//
+// Use [PrepareRequest] to apply credentials to a request so that this
+// interface is used when available.
+type HostCredentialsForService interface {
+	HostCredentials
+
+	// PrepareRequestForService is like PrepareRequest except that it also
+	// receives the identifier of the service that the request is for, such
+	// as "modules.v1".
+	PrepareRequestForService(req *http.Request, serviceID string)
+}
+
+// PrepareRequest applies the given credentials to the given request, which is
+// for the service with the given identifier, such as "modules.v1".
+//
+// If serviceID is empty then the service identifier from the given context,
+// as set by [ContextWithServiceID], is used instead, if any. If the
+// credentials implement [HostCredentialsForService] and a service identifier
+// is known then they are applied using PrepareRequestForService, and
+// otherwise using PrepareRequest. Nil credentials leave the request
+// unchanged.
+func PrepareRequest(ctx context.Context, creds HostCredentials, req *http.Request, serviceID string) {
+	if creds == nil {
+		return
+	}
+	if serviceID == "" {
+		serviceID = ServiceIDFromContext(ctx)
+	}
+	if forService, ok := creds.(HostCredentialsForService); ok && serviceID != "" {
+		forService.PrepareRequestForService(req, serviceID)
+		return
+	}
+	creds.PrepareRequest(req)
+}
+
+// ContextWithServiceID returns a context that records the identifier of the
+// service that requests made with it are for, such as "modules.v1".
+//
+// Clients returned by [NewAuthenticatedClient] use the identifier from each
+// request's context to apply credentials that implement
+// [HostCredentialsForService].
+func ContextWithServiceID(parent context.Context, serviceID string) context.Context {
+	return context.WithValue(parent, serviceIDKey, serviceID)
+}
+
+// ServiceIDFromContext returns the service identifier recorded in the given
+// context by [ContextWithServiceID], or an empty string if there is none.
+func ServiceIDFromContext(ctx context.Context) string {
+	serviceID, _ := ctx.Value(serviceIDKey).(string)
+	return serviceID
+}
+
+type serviceIDKeyType string
+
+const serviceIDKey = serviceIDKeyType("")
@@ -0,0 +1,87 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package svcauth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/opentofu/svchost"
+)
+
+// audienceCredentials is a [HostCredentialsForService] that uses a different
+// token for each service.
+type audienceCredentials map[string]string
+
+func (c audienceCredentials) PrepareRequest(req *http.Request) {
+	HostCredentialsToken(c[""]).PrepareRequest(req)
+}
+
+func (c audienceCredentials) PrepareRequestForService(req *http.Request, serviceID string) {
+	HostCredentialsToken(c[serviceID]).PrepareRequest(req)
+}
+
+func TestPrepareRequest(t *testing.T) {
+	creds := audienceCredentials{
+		"":             "default",
+		"modules.v1":   "modules-token",
+		"providers.v1": "providers-token",
+	}
+
+	tests := []struct {
+		name       string
+		creds      HostCredentials
+		ctxService string
+		serviceID  string
+		want       string
+	}{
+		{"no service", creds, "", "", "Bearer default"},
+		{"explicit service", creds, "", "modules.v1", "Bearer modules-token"},
+		{"service from context", creds, "providers.v1", "", "Bearer providers-token"},
+		{"explicit service overrides context", creds, "providers.v1", "modules.v1", "Bearer modules-token"},
+		{"not service-aware", HostCredentialsToken("abc123"), "", "modules.v1", "Bearer abc123"},
+		{"nil credentials", nil, "", "modules.v1", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := t.Context()
+			if test.ctxService != "" {
+				ctx = ContextWithServiceID(ctx, test.ctxService)
+			}
+			req, _ := http.NewRequestWithContext(ctx, "GET", "https://example.com/", nil)
+			PrepareRequest(ctx, test.creds, req, test.serviceID)
+			if got := req.Header.Get("Authorization"); got != test.want {
+				t.Errorf("wrong Authorization header %q; want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestNewAuthenticatedClientForService(t *testing.T) {
+	var gotAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+	}))
+	defer server.Close()
+	serverHost := svchost.Hostname(strings.TrimPrefix(server.URL, "http://"))
+
+	client, err := NewAuthenticatedClient(StaticCredentialsSource(map[svchost.Hostname]HostCredentials{
+		serverHost: audienceCredentials{"": "default", "modules.v1": "modules-token"},
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	ctx := ContextWithServiceID(t.Context(), "modules.v1")
+	req, _ := http.NewRequestWithContext(ctx, "GET", server.URL, nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	resp.Body.Close()
+	if got, want := gotAuth, "Bearer modules-token"; got != want {
+		t.Errorf("wrong Authorization header %q; want %q", got, want)
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package svcauth
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// ErrInvalidToken is wrapped by the errors returned from [ValidateToken].
+var ErrInvalidToken = errors.New("invalid token")
+
+// maxTokenLength is the length limit enforced by [ValidateToken]. It is
+// generous enough for large JWTs, but small enough to catch something
+// like the contents of the wrong file being used as a token.
+const maxTokenLength = 16 * 1024
+
+// ValidateToken returns an error wrapping [ErrInvalidToken] if the given
+// bearer token is obviously malformed, such as if it is empty, unreasonably
+// long, or contains whitespace or control characters that would corrupt the
+// Authorization header or allow injecting other headers.
+//
+// This doesn't check whether the token is valid for any particular server,
+// only that it can be sent safely. The error message never includes the
+// token itself.
+func ValidateToken(token string) error {
+	if token == "" {
+		return fmt.Errorf("%w: token is empty", ErrInvalidToken)
+	}
+	if len(token) > maxTokenLength {
+		return fmt.Errorf("%w: token is %d bytes long, which exceeds the limit of %d bytes", ErrInvalidToken, len(token), maxTokenLength)
+	}
+	for i := 0; i < len(token); i++ {
+		switch c := token[i]; {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			return fmt.Errorf("%w: token contains whitespace at byte %d", ErrInvalidToken, i)
+		case c < 0x20 || c == 0x7f:
+			return fmt.Errorf("%w: token contains a control character at byte %d", ErrInvalidToken, i)
+		case c > 0x7f:
+			return fmt.Errorf("%w: token contains a non-ASCII character at byte %d", ErrInvalidToken, i)
+		}
+	}
+	return nil
+}
+
+// JWTClaims describes some of the registered claims of a JSON Web Token, as
+// returned by [InspectJWT].
+//
+// Time fields are the zero time if the token doesn't include the
+// corresponding claim.
+type JWTClaims struct {
+	Issuer    string
+	Subject   string
+	Audience  []string
+	ExpiresAt time.Time
+	NotBefore time.Time
+	IssuedAt  time.Time
+}
+
+// InspectJWT returns the registered claims from the given token if it is a
+// JSON Web Token, or false if it isn't.
+//
+// The token's signature is not verified, so the result must not be used to
+// make any security decisions. It is intended only for client-side decisions
+// such as when to refresh a token before the server would reject it.
+func InspectJWT(token string) (JWTClaims, bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return JWTClaims{}, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return JWTClaims{}, false
+	}
+	var raw struct {
+		Issuer    string          `json:"iss"`
+		Subject   string          `json:"sub"`
+		Audience  json.RawMessage `json:"aud"`
+		ExpiresAt json.Number     `json:"exp"`
+		NotBefore json.Number     `json:"nbf"`
+		IssuedAt  json.Number     `json:"iat"`
+	}
+	if err := json.Unmarshal(payload, &raw); err != nil {
+		return JWTClaims{}, false
+	}
+
+	ret := JWTClaims{
+		Issuer:    raw.Issuer,
+		Subject:   raw.Subject,
+		ExpiresAt: jwtTime(raw.ExpiresAt),
+		NotBefore: jwtTime(raw.NotBefore),
+		IssuedAt:  jwtTime(raw.IssuedAt),
+	}
+	// The audience may be either a single string or an array of strings.
+	var aud string
+	if err := json.Unmarshal(raw.Audience, &aud); err == nil {
+		ret.Audience = []string{aud}
+	} else {
+		json.Unmarshal(raw.Audience, &ret.Audience) //nolint:errcheck // an invalid audience is treated as absent
+	}
+	return ret, true
+}
+
+// jwtTime converts a JWT "NumericDate" value, which is a number of seconds
+// since the Unix epoch, into a time, returning the zero time if it is
+// absent or invalid.
+func jwtTime(n json.Number) time.Time {
+	secs, err := n.Float64()
+	if err != nil {
+		return time.Time{}
+	}
+	whole := math.Floor(secs)
+	return time.Unix(int64(whole), int64((secs-whole)*float64(time.Second)))
+}
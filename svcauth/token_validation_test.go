@@ -0,0 +1,116 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package svcauth
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/oauth2"
+)
+
+func TestValidateToken(t *testing.T) {
+	tests := map[string]struct {
+		token   string
+		wantErr string
+	}{
+		"valid":      {"abc123.def-456_~+/=", ""},
+		"empty":      {"", "token is empty"},
+		"too long":   {strings.Repeat("a", maxTokenLength+1), "exceeds the limit"},
+		"space":      {"abc 123", "whitespace at byte 3"},
+		"newline":    {"abc\r\nX-Injected: yes", "whitespace at byte 3"},
+		"control":    {"abc\x00", "control character at byte 3"},
+		"non-ASCII":  {"abcé", "non-ASCII character at byte 3"},
+		"max length": {strings.Repeat("a", maxTokenLength), ""},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := ValidateToken(test.token)
+			if test.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrInvalidToken) {
+				t.Fatalf("wrong error %v; want ErrInvalidToken", err)
+			}
+			if !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("wrong error %q; want it to contain %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+// testJWT returns an unsigned JWT with the given JSON payload.
+func testJWT(payload string) string {
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString([]byte(`{"alg":"none"}`)) + "." + enc.EncodeToString([]byte(payload)) + "."
+}
+
+func TestInspectJWT(t *testing.T) {
+	tests := map[string]struct {
+		token  string
+		want   JWTClaims
+		wantOK bool
+	}{
+		"full": {
+			token: testJWT(`{"iss":"https://example.com","sub":"user","aud":["a","b"],"exp":1700000100,"nbf":1700000000,"iat":1700000000.5}`),
+			want: JWTClaims{
+				Issuer:    "https://example.com",
+				Subject:   "user",
+				Audience:  []string{"a", "b"},
+				ExpiresAt: time.Unix(1700000100, 0),
+				NotBefore: time.Unix(1700000000, 0),
+				IssuedAt:  time.Unix(1700000000, 500_000_000),
+			},
+			wantOK: true,
+		},
+		"single audience": {
+			token:  testJWT(`{"aud":"a"}`),
+			want:   JWTClaims{Audience: []string{"a"}},
+			wantOK: true,
+		},
+		"opaque token":   {token: "abc123"},
+		"invalid base64": {token: "a.!!!.c"},
+		"not JSON":       {token: "a." + base64.RawURLEncoding.EncodeToString([]byte("nope")) + ".c"},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, ok := InspectJWT(test.token)
+			if ok != test.wantOK {
+				t.Fatalf("wrong ok %t; want %t", ok, test.wantOK)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("wrong claims\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestHostCredentialsOAuthTokenJWTExpiry(t *testing.T) {
+	exp := time.Now().Add(30 * time.Second).Truncate(time.Second)
+	token := testJWT(`{"exp":` + strconv.FormatInt(exp.Unix(), 10) + `}`)
+
+	creds := HostCredentialsOAuthToken{Config: &oauth2.Config{}, Token: &oauth2.Token{AccessToken: token}}
+	if got := creds.ExpiresAt(); !got.Equal(exp) {
+		t.Errorf("wrong expiry %s; want %s from the JWT", got, exp)
+	}
+
+	// The expiry reported by the server takes precedence.
+	creds.Token.Expiry = exp.Add(time.Hour)
+	if got := creds.ExpiresAt(); !got.Equal(exp.Add(time.Hour)) {
+		t.Errorf("wrong expiry %s; want %s from the token response", got, exp.Add(time.Hour))
+	}
+	// Tokens loaded from a store behave in the same way.
+	stored := HostCredentialsOAuthTokens{AccessToken: token}
+	if got := stored.ExpiresAt(); !got.Equal(exp) {
+		t.Errorf("wrong expiry %s for stored tokens; want %s from the JWT", got, exp)
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package svcauth
+
+import (
+	"context"
+	"time"
+
+	"github.com/opentofu/svchost"
+)
+
+// CredentialsTrace allows a caller to be notified about credentials lookups
+// made through a credentials source, in case they want to generate log
+// messages, telemetry traces, or similar.
+//
+// Use [WithTrace] to associate a trace with a credentials source constructed
+// by this package.
+//
+// All of the function-typed fields may either be left as nil or set to
+// a function with the specified signature. If nil then the call for the
+// corresponding event will be skipped.
+type CredentialsTrace struct {
+	// LookupStart is called when a credentials lookup is about to begin for
+	// a specific hostname.
+	//
+	// This should return a [context.Context] to be used for the lookup,
+	// and it will then be passed as the context to either LookupSuccess
+	// or LookupFailure once the lookup is complete.
+	LookupStart func(ctx context.Context, host svchost.Hostname) context.Context
+
+	// LookupSuccess is called after a credentials lookup completes without
+	// an error. found is true if credentials were available for the host.
+	LookupSuccess func(ctx context.Context, host svchost.Hostname, found bool)
+
+	// LookupFailure is called after a credentials lookup fails with an error.
+	LookupFailure func(ctx context.Context, host svchost.Hostname, err error)
+
+	// LookupStats is called after LookupSuccess or LookupFailure with
+	// details about the completed lookup, for callers that want to record
+	// performance metrics or notice slow credentials sources.
+	//
+	// The given context has the same values as the one returned by the
+	// earlier call to LookupStart.
+	LookupStats func(ctx context.Context, host svchost.Hostname, stats LookupStats)
+}
+
+// LookupStats describes a completed credentials lookup, as reported to
+// [CredentialsTrace.LookupStats].
+type LookupStats struct {
+	// Source identifies the credentials source that performed the lookup,
+	// as set by [WithSourceName] or otherwise the source's Go type.
+	Source string
+
+	// Duration is the total time taken by the lookup, including any time
+	// spent waiting for an external program or system keyring.
+	Duration time.Duration
+
+	// Found is true if the lookup succeeded and credentials were available
+	// for the host.
+	Found bool
+}
+
+func (t *CredentialsTrace) lookupStart(ctx context.Context, host svchost.Hostname) context.Context {
+	if t == nil || t.LookupStart == nil {
+		return ctx
+	}
+	return t.LookupStart(ctx, host)
+}
+
+func (t *CredentialsTrace) lookupSuccess(ctx context.Context, host svchost.Hostname, found bool) {
+	if t == nil || t.LookupSuccess == nil {
+		return
+	}
+	t.LookupSuccess(ctx, host, found)
+}
+
+func (t *CredentialsTrace) lookupFailure(ctx context.Context, host svchost.Hostname, err error) {
+	if t == nil || t.LookupFailure == nil {
+		return
+	}
+	t.LookupFailure(ctx, host, err)
+}
+
+func (t *CredentialsTrace) lookupStats(ctx context.Context, host svchost.Hostname, stats LookupStats) {
+	if t == nil || t.LookupStats == nil {
+		return
+	}
+	t.LookupStats(ctx, host, stats)
+}
@@ -0,0 +1,65 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package svcauth
+
+import (
+	"context"
+
+	"github.com/opentofu/svchost"
+)
+
+// UpdateFunc decides the new credentials for a host given its current
+// credentials, for use with [UpdateForHost]. old is nil if there are no
+// credentials stored for the host.
+//
+// Returning nil new credentials causes any stored credentials to be
+// discarded, and returning an error leaves the stored credentials unchanged.
+type UpdateFunc func(old HostCredentials) (NewHostCredentials, error)
+
+// CredentialsUpdater is implemented by [CredentialsStore] implementations
+// that can perform a read-modify-write update of a host's credentials
+// atomically, so that two updates made at the same time, such as two
+// processes rotating the same refresh token, cannot overwrite each other.
+//
+// Use [UpdateForHost] to update credentials so that this interface is used
+// when available.
+type CredentialsUpdater interface {
+	CredentialsStore
+
+	// UpdateForHost calls the given function with the credentials currently
+	// stored for the given host and then stores the credentials it returns,
+	// preventing any other update of the same store from happening in
+	// between. The function may be called while locks are held, so it
+	// must not use the same store.
+	UpdateForHost(ctx context.Context, host svchost.Hostname, update UpdateFunc) error
+}
+
+// UpdateForHost performs a read-modify-write update of the credentials for
+// the given host in the given store, as described for [UpdateFunc].
+//
+// If the store implements [CredentialsUpdater] then the update is atomic.
+// Otherwise UpdateForHost falls back on calling ForHost followed by either
+// StoreForHost or ForgetForHost, in which case a concurrent update might
+// be lost. [FileCredentialsStore] and [KeyringCredentialsStore] both
+// support atomic updates, as do [Credentials], [CachingCredentialsSource],
+// [NewCredentialsSource], [ScopedCredentialsSource], and
+// [RefreshingCredentialsSource] when the store they wrap supports it.
+// [NewMultiStore] updates only its first writable store atomically.
+func UpdateForHost(ctx context.Context, store CredentialsStore, host svchost.Hostname, update UpdateFunc) error {
+	if updater, ok := store.(CredentialsUpdater); ok {
+		return updater.UpdateForHost(ctx, host, update)
+	}
+	old, err := store.ForHost(ctx, host)
+	if err != nil {
+		return svchost.WrapHostError(host, opUpdateForHost, err)
+	}
+	creds, err := update(old)
+	if err != nil {
+		return svchost.WrapHostError(host, opUpdateForHost, err)
+	}
+	if creds == nil {
+		return store.ForgetForHost(ctx, host)
+	}
+	return store.StoreForHost(ctx, host, creds)
+}
@@ -0,0 +1,198 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package svcauth
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/opentofu/svchost"
+)
+
+// incrementToken is an [UpdateFunc] that treats a token as a counter, so
+// that tests can detect lost updates.
+func incrementToken(old HostCredentials) (NewHostCredentials, error) {
+	n := 0
+	if old != nil {
+		var err error
+		n, err = strconv.Atoi(string(old.(HostCredentialsToken)))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return HostCredentialsToken(strconv.Itoa(n + 1)), nil
+}
+
+// updatableWrappers returns the wrappers in this package that forward
+// atomic updates, each wrapping a new keyring store.
+func updatableWrappers(host svchost.Hostname) map[string]CredentialsStore {
+	multi, err := NewMultiStore(StoreFirstWritable, StaticCredentialsSource(nil), KeyringCredentialsStore(memoryKeyring{}, "test"), &mapCredentialsStore{})
+	if err != nil {
+		panic(err)
+	}
+	return map[string]CredentialsStore{
+		"scoped":     ScopedCredentialsSource(KeyringCredentialsStore(memoryKeyring{}, "test"), svchost.Pattern(host)).(CredentialsStore),
+		"refreshing": RefreshingCredentialsSource(KeyringCredentialsStore(memoryKeyring{}, "test"), time.Minute).(CredentialsStore),
+		"multi":      multi,
+	}
+}
+
+func TestUpdateForHost(t *testing.T) {
+	host := svchost.Hostname("example.com")
+	tests := map[string]CredentialsStore{
+		"file":     FileCredentialsStore(filepath.Join(t.TempDir(), "credentials.tfrc.json")),
+		"keyring":  KeyringCredentialsStore(memoryKeyring{}, "test"),
+		"fallback": &mapCredentialsStore{},
+		"wrapped":  Credentials{KeyringCredentialsStore(memoryKeyring{}, "test")},
+	}
+	for name, store := range updatableWrappers(host) {
+		tests[name] = store
+	}
+	for name, store := range tests {
+		t.Run(name, func(t *testing.T) {
+			for range 2 {
+				if err := UpdateForHost(t.Context(), store, host, incrementToken); err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+			}
+			creds, err := store.ForHost(t.Context(), host)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got, want := creds, HostCredentialsToken("2"); got != want {
+				t.Errorf("wrong credentials %#v; want %#v", got, want)
+			}
+
+			// An error from the update function leaves the credentials
+			// unchanged.
+			wantErr := errors.New("rotation failed")
+			err = UpdateForHost(t.Context(), store, host, func(HostCredentials) (NewHostCredentials, error) {
+				return HostCredentialsToken("unwanted"), wantErr
+			})
+			if !errors.Is(err, wantErr) {
+				t.Errorf("wrong error %v; want %v", err, wantErr)
+			}
+			var hostErr *svchost.HostError
+			if !errors.As(err, &hostErr) || hostErr.Host != host {
+				t.Errorf("error %v does not identify the host", err)
+			}
+			creds, err = store.ForHost(t.Context(), host)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got, want := creds, HostCredentialsToken("2"); got != want {
+				t.Errorf("wrong credentials after failed update %#v; want %#v", got, want)
+			}
+
+			// Returning nil discards the credentials.
+			err = UpdateForHost(t.Context(), store, host, func(HostCredentials) (NewHostCredentials, error) {
+				return nil, nil
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			creds, err = store.ForHost(t.Context(), host)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if creds != nil {
+				t.Errorf("unexpected credentials after discarding: %#v", creds)
+			}
+		})
+	}
+}
+
+func TestUpdateForHost_concurrent(t *testing.T) {
+	host := svchost.Hostname("example.com")
+	tests := map[string]CredentialsStore{
+		"file":    FileCredentialsStore(filepath.Join(t.TempDir(), "credentials.tfrc.json")),
+		"keyring": KeyringCredentialsStore(memoryKeyring{}, "test"),
+	}
+	for name, store := range updatableWrappers(host) {
+		if _, ok := store.(CredentialsUpdater); !ok {
+			t.Fatalf("%s does not implement CredentialsUpdater", name)
+		}
+		tests[name] = store
+	}
+	for name, store := range tests {
+		t.Run(name, func(t *testing.T) {
+			const updates = 20
+			var wg sync.WaitGroup
+			for range updates {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if err := UpdateForHost(t.Context(), store, host, incrementToken); err != nil {
+						t.Errorf("unexpected error: %s", err)
+					}
+				}()
+			}
+			wg.Wait()
+
+			creds, err := store.ForHost(t.Context(), host)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got, want := creds, HostCredentialsToken(strconv.Itoa(updates)); got != want {
+				t.Errorf("wrong credentials %#v; want %#v", got, want)
+			}
+		})
+	}
+}
+
+func TestNewCredentialsStore_update(t *testing.T) {
+	host := svchost.Hostname("example.com")
+	var records []AuditRecord
+	store, err := NewCredentialsStore(
+		KeyringCredentialsStore(memoryKeyring{}, "test"),
+		WithCache(),
+		WithAuditHooks(&AuditHooks{
+			StoreForHost: func(_ context.Context, record AuditRecord) {
+				records = append(records, record)
+			},
+			ForgetForHost: func(_ context.Context, record AuditRecord) {
+				records = append(records, record)
+			},
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Reading first populates the cache, which the update must invalidate.
+	if _, err := store.ForHost(t.Context(), host); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := UpdateForHost(t.Context(), store, host, incrementToken); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	creds, err := store.ForHost(t.Context(), host)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got, want := creds, HostCredentialsToken("1"); got != want {
+		t.Errorf("wrong credentials %#v; want %#v", got, want)
+	}
+	err = UpdateForHost(t.Context(), store, host, func(HostCredentials) (NewHostCredentials, error) {
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := []AuditRecord{
+		{Host: host, Outcome: AuditStored, Kind: "token"},
+		{Host: host, Outcome: AuditForgotten},
+	}
+	if diff := cmp.Diff(want, records); diff != "" {
+		t.Errorf("wrong audit records\n%s", diff)
+	}
+}
@@ -62,6 +62,10 @@ var displayProfile = idna.New(
 // For validation, use either IsValid (for explicit validation) or
 // ForComparison (which implicitly validates, returning an error if invalid).
 func ForDisplay(given string) string {
+	if addr, portPortion, ok, err := parseIPLiteral(given); ok && err == nil {
+		return formatIPLiteral(addr) + portPortion
+	}
+
 	var portPortion string
 	if colonPos := strings.Index(given, ":"); colonPos != -1 {
 		given, portPortion = given[:colonPos], given[colonPos:]
@@ -102,58 +106,83 @@ func IsValid(given string) bool {
 // user-specified or display-form hostname or a value already normalized for
 // comparison.
 //
-// The returned Hostname is not valid if the returned error is non-nil.
+// IPv4 address literals are accepted and normalized to their canonical
+// textual form, but IPv6 address literals are not. Use [ForComparisonAllowIP]
+// to accept both, or [ForComparisonDNSOnly] to accept neither.
+//
+// The returned Hostname is not valid if the returned error is non-nil, in
+// which case the error is an [*InvalidHostnameError] describing the problem.
 func ForComparison(given string) (Hostname, error) {
-	var portPortion string
+	return forComparison(given, ipLiteralsLegacy)
+}
+
+// forComparisonDNS is the part of [ForComparison] that deals with DNS names,
+// as opposed to IP address literals.
+func forComparisonDNS(given string) (Hostname, error) {
+	host, portPortion := given, ""
 	if colonPos := strings.Index(given, ":"); colonPos != -1 {
-		given, portPortion = given[:colonPos], given[colonPos:]
+		host, portPortion = given[:colonPos], given[colonPos:]
 	}
 
-	var err error
-	portPortion, err = normalizePortPortion(portPortion)
+	portPortion, err := normalizePortPortion(portPortion)
 	if err != nil {
 		// We can get in here if someone has incorrectly specified a URL
 		// instead of a hostname, because normalizePortPortion will try to
 		// treat the colon after the scheme as the port number separator.
 		// We'll return a more specific error message for that situation.
-		given = strings.ToLower(given)
-		if given == "https" || given == "http" {
+		lower := strings.ToLower(host)
+		if lower == "https" || lower == "http" {
 			// Technically it's valid to have a host called "https" or "http"
 			// which would generate a false positive here with input like
 			// "http:foo", but we can only get here if the hostname exactly
 			// matches one of the schemes _and_ the port number is also invalid.
-			return Hostname(""), fmt.Errorf("need just a hostname and optional port number, not a full URL")
+			return Hostname(""), &InvalidHostnameError{
+				Given:  given,
+				Reason: InvalidHostnameURL,
+				Length: len(given),
+				msg:    "need just a hostname and optional port number, not a full URL",
+			}
 		}
-		return Hostname(""), err
+		return Hostname(""), invalidPort(given, len(host), err)
 	}
 
-	if given == "" {
-		return Hostname(""), fmt.Errorf("empty string is not a valid hostname")
+	if host == "" {
+		return Hostname(""), &InvalidHostnameError{
+			Given:  given,
+			Reason: InvalidHostnameEmpty,
+			msg:    "empty string is not a valid hostname",
+		}
 	}
 
 	// First we'll apply our additional constraint that Punycode must not
 	// be given directly by the user. This is not an IDN specification
 	// requirement, but we prohibit it to force users to use human-readable
 	// hostname forms within OpenTofu configuration.
-	labels := labelIter{orig: given}
+	labels := labelIter{orig: host}
 	for ; !labels.done(); labels.next() {
 		label := labels.label()
 		if label == "" {
-			return Hostname(""), fmt.Errorf(
-				"hostname contains empty label (two consecutive periods)",
-			)
+			return Hostname(""), &InvalidHostnameError{
+				Given:  given,
+				Reason: InvalidHostnameEmptyLabel,
+				Offset: labels.curStart,
+				msg:    "hostname contains empty label (two consecutive periods)",
+			}
 		}
 		if strings.HasPrefix(label, acePrefix) {
-			return Hostname(""), fmt.Errorf(
-				"hostname label %q specified in punycode format; service hostnames must be given in unicode",
-				label,
-			)
+			return Hostname(""), &InvalidHostnameError{
+				Given:  given,
+				Reason: InvalidHostnamePunycode,
+				Offset: labels.curStart,
+				Length: len(label),
+				msg:    fmt.Sprintf("hostname label %q specified in punycode format; service hostnames must be given in unicode", label),
+			}
 		}
 	}
 
-	result, err := idna.Lookup.ToASCII(given)
+	result, err := idna.Lookup.ToASCII(host)
 	if err != nil {
-		return Hostname(""), err
+		return Hostname(""), invalidIDN(given, len(host), err)
 	}
 	return Hostname(result + portPortion), nil
 }
@@ -167,11 +196,10 @@ func ForComparison(given string) (Hostname, error) {
 // function, since a round-trip through the Hostname type implies stricter
 // handling than we do when doing basic display-only processing.
 func (h Hostname) ForDisplay() string {
-	given := string(h)
-	var portPortion string
-	if colonPos := strings.Index(given, ":"); colonPos != -1 {
-		given, portPortion = given[:colonPos], given[colonPos:]
+	if IsIPAddress(h) {
+		return string(h)
 	}
+	given, portPortion := h.split()
 	// We don't normalize the port portion here because we assume it's
 	// already been normalized on the way in.
 
@@ -184,6 +212,65 @@ func (h Hostname) ForDisplay() string {
 	return result + portPortion
 }
 
+// DefaultPort is the TCP port number implied by a [Hostname] that does not
+// include an explicit port number.
+const DefaultPort = 443
+
+// Port returns the port number from the receiver, or [DefaultPort] if it does
+// not specify a port.
+func (h Hostname) Port() int {
+	_, portPortion := h.split()
+	if portPortion == "" {
+		return DefaultPort
+	}
+	port, err := strconv.Atoi(portPortion[1:])
+	if err != nil {
+		// Should never happen, since type Hostname indicates that a string
+		// passed through our validation rules.
+		panic(fmt.Errorf("Port called on invalid Hostname: %s", err))
+	}
+	return port
+}
+
+// WithoutPort returns the receiver with any port number removed.
+func (h Hostname) WithoutPort() Hostname {
+	host, _ := h.split()
+	return Hostname(host)
+}
+
+// WithDefaultPort returns the receiver unchanged if it specifies a port
+// number, or otherwise returns it with the given port number added.
+//
+// Because port 443 is always omitted from the normalized form, the result
+// for that port is the same as the receiver.
+func (h Hostname) WithDefaultPort(port int) Hostname {
+	host, portPortion := h.split()
+	if portPortion != "" || port == DefaultPort {
+		return h
+	}
+	return Hostname(host + ":" + strconv.Itoa(port))
+}
+
+// split separates the receiver into its hostname and port portions, with the
+// port portion including its leading colon.
+//
+// The hostname portion may be an IPv6 literal in brackets, in which case
+// the port portion is the part after the closing bracket.
+func (h Hostname) split() (host, portPortion string) {
+	s := string(h)
+	searchFrom := 0
+	if strings.HasPrefix(s, "[") {
+		if end := strings.Index(s, "]"); end != -1 {
+			searchFrom = end
+		}
+	}
+	if colonPos := strings.Index(s[searchFrom:], ":"); colonPos != -1 {
+		colonPos += searchFrom
+		return s[:colonPos], s[colonPos:]
+	}
+	return s, ""
+}
+
 func (h Hostname) String() string {
 	return string(h)
 }
@@ -243,3 +243,61 @@ func TestHostnameForDisplay(t *testing.T) {
 		})
 	}
 }
+
+func TestHostnamePort(t *testing.T) {
+	tests := []struct {
+		Input           string
+		WantPort        int
+		WantWithoutPort Hostname
+		WantWithDefault Hostname
+	}{
+		{
+			"example.com",
+			443,
+			"example.com",
+			"example.com:8443",
+		},
+		{
+			"example.com:81",
+			81,
+			"example.com",
+			"example.com:81",
+		},
+		{
+			"xn--80akhbyknj4f.com:8080",
+			8080,
+			"xn--80akhbyknj4f.com",
+			"xn--80akhbyknj4f.com:8080",
+		},
+		{
+			"[::1]:8080",
+			8080,
+			"[::1]",
+			"[::1]:8080",
+		},
+		{
+			"[::1]",
+			443,
+			"[::1]",
+			"[::1]:8443",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Input, func(t *testing.T) {
+			h := Hostname(test.Input)
+			if got := h.Port(); got != test.WantPort {
+				t.Errorf("wrong port %d; want %d", got, test.WantPort)
+			}
+			if got := h.WithoutPort(); got != test.WantWithoutPort {
+				t.Errorf("wrong WithoutPort result %q; want %q", got, test.WantWithoutPort)
+			}
+			if got := h.WithDefaultPort(8443); got != test.WantWithDefault {
+				t.Errorf("wrong WithDefaultPort result %q; want %q", got, test.WantWithDefault)
+			}
+			if got := h.WithDefaultPort(443); got != h {
+				t.Errorf("WithDefaultPort(443) changed the hostname to %q", got)
+			}
+		})
+	}
+}
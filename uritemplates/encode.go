@@ -4,6 +4,7 @@
 package uritemplates
 
 import (
+	"bytes"
 	"regexp"
 )
 
@@ -43,6 +44,28 @@ func escapeVariableValue(src string) []byte {
 	return variableRequiringEscape.ReplaceAllFunc([]byte(src), percentEncode)
 }
 
+// escapeReservedVariableValue is like [escapeVariableValue] except that it
+// leaves "reserved" characters and existing percent-encoded sequences
+// unescaped, as required for the "+" and "#" expression operators described
+// in [RFC 6570] section 3.2.3.
+func escapeReservedVariableValue(src string) []byte {
+	ret := make([]byte, 0, len(src))
+	for remain := []byte(src); len(remain) > 0; {
+		if startsWithValidPctEncoded(remain) {
+			ret = append(ret, remain[:percentEncodedLength]...)
+			remain = remain[percentEncodedLength:]
+			continue
+		}
+		next := bytes.IndexByte(remain[1:], '%') + 1
+		if next == 0 {
+			next = len(remain)
+		}
+		ret = append(ret, escapeLiteral(remain[:next])...)
+		remain = remain[next:]
+	}
+	return ret
+}
+
 func percentEncode(src []byte) []byte {
 	const hexDigitCount = len(hexChars)
 
@@ -0,0 +1,168 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package uritemplates
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// operator describes the expansion behavior of one of the expression
+// operators defined in [RFC 6570] section 3.2.1, using the same terms as
+// the expansion algorithm in appendix A.
+type operator struct {
+	first         string
+	sep           string
+	named         bool
+	ifEmpty       string
+	allowReserved bool
+}
+
+// operators are the expression operators supported by [Expand], indexed by
+// the operator character. The zero byte represents simple string expansion,
+// which has no operator character.
+var operators = map[byte]operator{
+	0:   {first: "", sep: ","},
+	'+': {first: "", sep: ",", allowReserved: true},
+	'#': {first: "#", sep: ",", allowReserved: true},
+	'.': {first: ".", sep: "."},
+	'/': {first: "/", sep: "/"},
+	';': {first: ";", sep: ";", named: true},
+	'?': {first: "?", sep: "&", named: true, ifEmpty: "="},
+	'&': {first: "&", sep: "&", named: true, ifEmpty: "="},
+}
+
+// Expand performs the "expansion" process, described in [RFC 6570] section 3,
+// on the given template using the given variables. It supports all of the
+// features of levels 1, 2, and 3, but not the value modifiers or list and
+// associative array values added in level 4.
+//
+// Variables that are not present in vars are undefined, and so are omitted
+// from the result entirely along with any separator or name that would've
+// been generated for them. This differs from [ExpandLevel1], where undefined
+// variables expand to an empty string, only for expressions with multiple
+// variables or an operator.
+//
+// If the given template is invalid then this returns a partial expansion along
+// with an error. If the template has multiple problems then it's unspecified
+// which one this function will prefer to describe in its return value.
+func Expand(template string, vars map[string]string) (string, error) {
+	var buf strings.Builder
+	sc := newScanner(template)
+
+	for sc.Scan() {
+		tok := sc.Bytes()
+		switch {
+		case len(tok) > 0 && tok[0] == '{':
+			op, names, err := parseLevel3Expression(tok)
+			if err != nil {
+				return buf.String(), err
+			}
+			expandLevel3Expression(op, names, vars, &buf)
+		default:
+			if err := expandLevel1Literal(tok, &buf); err != nil {
+				return buf.String(), err
+			}
+		}
+	}
+	return buf.String(), sc.Err()
+}
+
+// Validate checks whether the given template is valid for URI Templates up to
+// Level 3, as defined in [RFC 6570], returning an error if not.
+//
+// If this function returns nil then the template uses valid syntax and uses
+// only features that [Expand] supports.
+//
+// If the given template has multiple problems then it's unspecified which one
+// this function will prefer to describe in its return value.
+func Validate(template string) error {
+	sc := newScanner(template)
+
+	for sc.Scan() {
+		tok := sc.Bytes()
+		switch {
+		case len(tok) > 0 && tok[0] == '{':
+			if _, _, err := parseLevel3Expression(tok); err != nil {
+				return err
+			}
+		default:
+			if err := validateLevel1Literal(tok); err != nil {
+				return err
+			}
+		}
+	}
+	return sc.Err()
+}
+
+// parseLevel3Expression parses an expression token, including its braces,
+// returning its operator and the names of the variables it refers to.
+func parseLevel3Expression(tok []byte) (operator, []string, error) {
+	inner := tok[1 : len(tok)-1] // trim the surrounding braces that are always present
+	if len(inner) == 0 {
+		return operator{}, nil, fmt.Errorf("zero-length expression sequence")
+	}
+
+	op, ok := operators[0]
+	switch c := inner[0]; c {
+	case '+', '#', '.', '/', ';', '?', '&':
+		op, ok = operators[c]
+		inner = inner[1:]
+	case '=', ',', '!', '@', '|':
+		return operator{}, nil, fmt.Errorf("reserved template expression operator %q not allowed", c)
+	}
+	if !ok {
+		// Should not get here because the operators table covers all
+		// of the cases above.
+		panic("missing operator definition")
+	}
+
+	sc := bufio.NewScanner(bytes.NewReader(inner))
+	sc.Split(variableListLevel3Split)
+	var names []string
+	for sc.Scan() {
+		if len(sc.Bytes()) == 0 {
+			return operator{}, nil, fmt.Errorf("expression has an empty variable name")
+		}
+		names = append(names, sc.Text())
+	}
+	if err := sc.Err(); err != nil {
+		return operator{}, nil, err
+	}
+	if len(names) == 0 || bytes.HasSuffix(inner, []byte{','}) {
+		return operator{}, nil, fmt.Errorf("expression has an empty variable name")
+	}
+	return op, names, nil
+}
+
+func expandLevel3Expression(op operator, names []string, vars map[string]string, into *strings.Builder) {
+	first := true
+	for _, name := range names {
+		val, defined := vars[name]
+		if !defined {
+			continue // undefined variables are skipped entirely, per the spec
+		}
+		if first {
+			into.WriteString(op.first)
+			first = false
+		} else {
+			into.WriteString(op.sep)
+		}
+		if op.named {
+			into.WriteString(name)
+			if val == "" {
+				into.WriteString(op.ifEmpty)
+				continue
+			}
+			into.WriteByte('=')
+		}
+		if op.allowReserved {
+			into.Write(escapeReservedVariableValue(val))
+		} else {
+			into.Write(escapeVariableValue(val))
+		}
+	}
+}
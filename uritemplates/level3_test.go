@@ -0,0 +1,148 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package uritemplates
+
+import (
+	"testing"
+)
+
+func TestExpand(t *testing.T) {
+	// These are the example variables from RFC 6570 section 3.2.1, which
+	// most of the test cases below are based on.
+	vars := map[string]string{
+		"var":   "value",
+		"hello": "Hello World!",
+		"path":  "/foo/bar",
+		"empty": "",
+		"x":     "1024",
+		"y":     "768",
+
+		// This one is not from the RFC
+		"pct": "a%2Fb%zz",
+	}
+
+	tests := []struct {
+		input   string
+		want    string
+		wantErr string
+	}{
+		// Level 1
+		{`{var}`, `value`, ``},
+		{`{hello}`, `Hello%20World%21`, ``},
+		{`{undef}`, ``, ``},
+		{`{empty}`, ``, ``},
+
+		// Level 2: reserved expansion
+		{`{+var}`, `value`, ``},
+		{`{+hello}`, `Hello%20World!`, ``},
+		{`{+path}/here`, `/foo/bar/here`, ``},
+		{`here?ref={+path}`, `here?ref=/foo/bar`, ``},
+
+		// Level 2: fragment expansion
+		{`X{#var}`, `X#value`, ``},
+		{`X{#hello}`, `X#Hello%20World!`, ``},
+		{`X{#undef}`, `X`, ``},
+
+		// Level 3: multiple variables
+		{`map?{x,y}`, `map?1024,768`, ``},
+		{`{x,hello,y}`, `1024,Hello%20World%21,768`, ``},
+		{`{x,undef,y}`, `1024,768`, ``},
+		{`{+x,hello,y}`, `1024,Hello%20World!,768`, ``},
+		{`{+path,x}/here`, `/foo/bar,1024/here`, ``},
+		{`{#x,hello,y}`, `#1024,Hello%20World!,768`, ``},
+		{`{#path,x}/here`, `#/foo/bar,1024/here`, ``},
+
+		// Level 3: label expansion
+		{`X{.var}`, `X.value`, ``},
+		{`X{.x,y}`, `X.1024.768`, ``},
+
+		// Level 3: path segments
+		{`{/var}`, `/value`, ``},
+		{`{/var,x}/here`, `/value/1024/here`, ``},
+		{`{/path}`, `/%2ffoo%2fbar`, ``},
+
+		// Level 3: path-style parameters
+		{`{;x,y}`, `;x=1024;y=768`, ``},
+		{`{;x,y,empty}`, `;x=1024;y=768;empty`, ``},
+
+		// Level 3: form-style query
+		{`{?x,y}`, `?x=1024&y=768`, ``},
+		{`{?x,y,empty}`, `?x=1024&y=768&empty=`, ``},
+		{`{?undef}`, ``, ``},
+		{`{?undef,x}`, `?x=1024`, ``},
+
+		// Level 3: form-style query continuation
+		{`?fixed=yes{&x}`, `?fixed=yes&x=1024`, ``},
+		{`{&x,y,empty}`, `&x=1024&y=768&empty=`, ``},
+
+		// Reserved expansion preserves existing percent-encoding, but
+		// still escapes a percent sign that doesn't start a valid sequence.
+		{`{+pct}`, `a%2Fb%25zz`, ``},
+
+		// Errors
+		{`{}`, ``, `zero-length expression sequence`},
+		{`{+}`, ``, `expression has an empty variable name`},
+		{`{x,,y}`, ``, `expression has an empty variable name`},
+		{`{x,}`, ``, `expression has an empty variable name`},
+		{`a{=x}`, `a`, `reserved template expression operator '=' not allowed`},
+		{`{var:3}`, ``, `level 4 modifier ':' not allowed`},
+		{`{list*}`, ``, `level 4 modifier '*' not allowed`},
+		{`{x y}`, ``, `invalid symbol ' ' in variable name`},
+		{`{?x`, ``, `unclosed URI template expression`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			got, err := Expand(test.input, vars)
+			if test.wantErr != "" {
+				if err == nil {
+					t.Fatalf("unexpected success\ngot:  %s\nwant error: %s", got, test.wantErr)
+				}
+				if got, want := err.Error(), test.wantErr; got != want {
+					t.Errorf("wrong error\ngot:  %s\nwant: %s", got, want)
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got != test.want {
+				t.Errorf("wrong result\ntemplate: %s\ngot:      %s\nwant:     %s", test.input, got, test.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		input   string
+		wantErr string
+	}{
+		{``, ``},
+		{`foo{bar}baz`, ``},
+		{`{+path}/here`, ``},
+		{`X{#var}`, ``},
+		{`{/var,x}/here{?x,y}{&z}`, ``},
+		{`X{.x,y}{;a,b}`, ``},
+		{`{}`, `zero-length expression sequence`},
+		{`{|x}`, `reserved template expression operator '|' not allowed`},
+		{`{var:3}`, `level 4 modifier ':' not allowed`},
+		{`{oops`, `unclosed URI template expression`},
+		{`{x,}`, `expression has an empty variable name`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			err := Validate(test.input)
+			if test.wantErr != "" {
+				if err == nil {
+					t.Fatalf("unexpected success\nwant error: %s", test.wantErr)
+				}
+				if got, want := err.Error(), test.wantErr; got != want {
+					t.Errorf("wrong error\ngot:  %s\nwant: %s", got, want)
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+		})
+	}
+}
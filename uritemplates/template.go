@@ -0,0 +1,134 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package uritemplates
+
+import (
+	"slices"
+	"strings"
+)
+
+// Template is a parsed URI template, which can be inspected to learn which
+// variables it requires before expanding it.
+//
+// Use [Parse] to obtain a Template.
+type Template struct {
+	raw   string
+	parts []templatePart
+	level int
+}
+
+// templatePart is either a literal, which is already escaped as needed for
+// the result, or an expression.
+type templatePart struct {
+	literal string
+	expr    bool
+	op      operator
+	names   []string
+}
+
+// Parse parses the given template, which may use the features of URI
+// Templates up to Level 3 as defined in [RFC 6570], returning an error if
+// the template is invalid or uses features that this package doesn't
+// support.
+func Parse(template string) (*Template, error) {
+	ret := &Template{
+		raw:   template,
+		level: 1,
+	}
+	sc := newScanner(template)
+	for sc.Scan() {
+		tok := sc.Bytes()
+		if len(tok) > 0 && tok[0] == '{' {
+			op, names, err := parseLevel3Expression(tok)
+			if err != nil {
+				return nil, err
+			}
+			ret.level = max(ret.level, expressionLevel(tok, names))
+			ret.parts = append(ret.parts, templatePart{expr: true, op: op, names: names})
+			continue
+		}
+		var buf strings.Builder
+		if err := expandLevel1Literal(tok, &buf); err != nil {
+			return nil, err
+		}
+		ret.parts = append(ret.parts, templatePart{literal: buf.String()})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// expressionLevel returns the lowest level of [RFC 6570] that supports the
+// given expression token, whose variable names have already been parsed.
+func expressionLevel(tok []byte, names []string) int {
+	level := 1
+	switch tok[1] {
+	case '+', '#':
+		level = 2
+	case '.', '/', ';', '?', '&':
+		level = 3
+	}
+	if len(names) > 1 {
+		level = 3
+	}
+	return level
+}
+
+// Variables returns the names of the variables that the template refers to,
+// in the order of their first appearance and without duplicates.
+func (t *Template) Variables() []string {
+	var ret []string
+	for _, part := range t.parts {
+		for _, name := range part.names {
+			if !slices.Contains(ret, name) {
+				ret = append(ret, name)
+			}
+		}
+	}
+	return ret
+}
+
+// MissingVariables returns the names of any variables the template refers
+// to that are not present in the given variables, in the same order as
+// [Template.Variables], or nil if all of them are present.
+func (t *Template) MissingVariables(vars map[string]string) []string {
+	var ret []string
+	for _, name := range t.Variables() {
+		if _, ok := vars[name]; !ok {
+			ret = append(ret, name)
+		}
+	}
+	return ret
+}
+
+// Level returns the lowest level of [RFC 6570] that supports all of the
+// features the template uses, which is always 1, 2, or 3.
+//
+// A template whose level is 1 can also be expanded using [ExpandLevel1].
+func (t *Template) Level() int {
+	return t.level
+}
+
+// Expand expands the template using the given variables, in the same way
+// as [Expand].
+//
+// Variables that are not present in vars are undefined, and so are omitted
+// from the result. Use [Template.MissingVariables] first to detect that.
+func (t *Template) Expand(vars map[string]string) string {
+	var buf strings.Builder
+	for _, part := range t.parts {
+		if part.expr {
+			expandLevel3Expression(part.op, part.names, vars, &buf)
+		} else {
+			buf.WriteString(part.literal)
+		}
+	}
+	return buf.String()
+}
+
+// String returns the template in its original form.
+func (t *Template) String() string {
+	return t.raw
+}
@@ -0,0 +1,80 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package uritemplates
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input     string
+		wantVars  []string
+		wantLevel int
+		wantErr   string
+	}{
+		{`https://example.com/`, nil, 1, ``},
+		{`/v1/{namespace}/{name}`, []string{"namespace", "name"}, 1, ``},
+		{`/v1/{+path}`, []string{"path"}, 2, ``},
+		{`{#frag}`, []string{"frag"}, 2, ``},
+		{`/v1/{name}{?page,limit}`, []string{"name", "page", "limit"}, 3, ``},
+		{`{x,y}/{x}`, []string{"x", "y"}, 3, ``},
+		{`{x:3}`, nil, 0, `level 4 modifier ':' not allowed`},
+		{`{}`, nil, 0, `zero-length expression sequence`},
+		{`{x`, nil, 0, `unclosed URI template expression`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			tmpl, err := Parse(test.input)
+			if test.wantErr != "" {
+				if err == nil {
+					t.Fatalf("unexpected success; want error: %s", test.wantErr)
+				}
+				if got := err.Error(); got != test.wantErr {
+					t.Errorf("wrong error\ngot:  %s\nwant: %s", got, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got := tmpl.Variables(); !slices.Equal(got, test.wantVars) {
+				t.Errorf("wrong variables %q; want %q", got, test.wantVars)
+			}
+			if got := tmpl.Level(); got != test.wantLevel {
+				t.Errorf("wrong level %d; want %d", got, test.wantLevel)
+			}
+			if got := tmpl.String(); got != test.input {
+				t.Errorf("wrong string %q; want %q", got, test.input)
+			}
+		})
+	}
+}
+
+func TestTemplateExpand(t *testing.T) {
+	tmpl, err := Parse(`/v1/{namespace}/{name}{?page,limit}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	vars := map[string]string{
+		"namespace": "hashi corp",
+		"name":      "aws",
+		"page":      "2",
+	}
+	if got, want := tmpl.MissingVariables(vars), []string{"limit"}; !slices.Equal(got, want) {
+		t.Errorf("wrong missing variables %q; want %q", got, want)
+	}
+	want, err := Expand(tmpl.String(), vars)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got := tmpl.Expand(vars); got != want {
+		t.Errorf("wrong expansion %q; want %q", got, want)
+	}
+	if want := "/v1/hashi%20corp/aws?page=2"; tmpl.Expand(vars) != want {
+		t.Errorf("wrong expansion %q; want %q", tmpl.Expand(vars), want)
+	}
+}
@@ -7,7 +7,14 @@
 // in OpenTofu's network service discovery protocol, which currently supports only
 // Level 1 templates to reduce complexity, because OpenTofu services tend to follow a
 // prescriptive URL scheme that doesn't require advanced URI template features like
-// constructing a query string.
+// constructing a query string. [ExpandLevel1] and [ValidateLevel1] implement that
+// restricted subset.
+//
+// [Expand] and [Validate] additionally support the Level 2 and Level 3 features,
+// for callers that need reserved expansion, fragments, path segments, or query
+// strings. Level 4 features (value modifiers and composite values) are not supported.
+// [Parse] returns a [Template] that can report which variables a template requires,
+// so that callers can check them before expanding it.
 //
 // If those needs increase in future then the scope of this package might increase to
 // follow, or we might adopt an external dependency implementing this specification instead.